@@ -0,0 +1,35 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Linux inode flags, see ioctl_iflags(2).
+const (
+	fsImmutableFl = 0x10
+	fsAppendFl    = 0x20
+)
+
+// setAppendOnly sets the append-only attribute of the file at path being
+// written to, see Options.AppendOnly.
+func (w *Writer) setAppendOnly(path string) error {
+	if !w.opts.AppendOnly {
+		return nil
+	}
+	if err := changeFileFlags(path, fsAppendFl, 0); err != nil {
+		return errors.Wrapf(err, "failed to set append-only attribute of %s", path)
+	}
+	return nil
+}
+
+// setImmutable replaces the append-only attribute of the file at path,
+// which will not be written to again, with the immutable attribute.
+func (w *Writer) setImmutable(path string) error {
+	if !w.opts.AppendOnly {
+		return nil
+	}
+	if err := changeFileFlags(path, fsImmutableFl, fsAppendFl); err != nil {
+		return errors.Wrapf(err, "failed to set immutable attribute of %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+// +build linux
+// +build 386 amd64 arm arm64 loong64 riscv64 s390x
+
+package logrotate
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fileAttributesSupported reports whether Options.AppendOnly is supported on this platform.
+const fileAttributesSupported = true
+
+// FS_IOC_GETFLAGS and FS_IOC_SETFLAGS, encoded for architectures using the
+// generic ioctl numbers.
+const (
+	fsIocGetflags = 2<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 1
+	fsIocSetflags = 1<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 2
+)
+
+// changeFileFlags sets and clears inode flags of the file at path.
+func changeFileFlags(path string, set, clear int32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var flags int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocGetflags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return errno
+	}
+	flags = flags&^clear | set
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocSetflags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return errno
+	}
+	return nil
+}
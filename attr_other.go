@@ -0,0 +1,16 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+// +build !linux !386,!amd64,!arm,!arm64,!loong64,!riscv64,!s390x
+
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+)
+
+// fileAttributesSupported reports whether Options.AppendOnly is supported on this platform.
+const fileAttributesSupported = false
+
+// changeFileFlags is not supported on this platform.
+func changeFileFlags(path string, set, clear int32) error {
+	return errors.New("file attributes are not supported on this platform")
+}
@@ -0,0 +1,86 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_AppendOnly(t *testing.T) {
+	if !fileAttributesSupported {
+		t.Skip("AppendOnly is not supported on this platform")
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			files, _ := ioutil.ReadDir(dir)
+			for _, f := range files {
+				changeFileFlags(filepath.Join(dir, f.Name()), 0, fsAppendFl|fsImmutableFl)
+			}
+			os.RemoveAll(dir)
+		})
+
+		probe := filepath.Join(dir, "probe")
+		require.NoError(t, ioutil.WriteFile(probe, nil, 0644))
+		if err := changeFileFlags(probe, fsAppendFl, 0); err != nil {
+			t.Skipf("File attributes cannot be set: %v", err)
+		}
+		require.NoError(t, changeFileFlags(probe, 0, fsAppendFl))
+		require.NoError(t, os.Remove(probe))
+		return dir
+	}
+
+	t.Run("protects active and closed files", func(t *testing.T) {
+		dir := newDir(t)
+		names := []string{"first.log", "second.log"}
+		w, err := New(logger, Options{
+			Directory:  dir,
+			AppendOnly: true,
+			FileNameFunc: func() string {
+				name := names[0]
+				names = names[1:]
+				return name
+			},
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+		_, err = w.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Sync())
+
+		// The active file can only be appended to.
+		second := filepath.Join(dir, "second.log")
+		_, err = os.OpenFile(second, os.O_WRONLY|os.O_TRUNC, 0)
+		require.Error(t, err)
+		require.Error(t, os.Remove(second))
+		f, err := os.OpenFile(second, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		// Closed files cannot be written to at all.
+		require.NoError(t, w.Close())
+		for _, name := range []string{"first.log", "second.log"} {
+			_, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND, 0)
+			require.Error(t, err, name)
+		}
+		b, err := ioutil.ReadFile(second)
+		require.NoError(t, err)
+		require.Equal(t, "second\n", string(b))
+	})
+
+	t.Run("rejects deleting files", func(t *testing.T) {
+		opts := Options{Directory: "logs", AppendOnly: true, DeleteAfterUpload: true}
+		require.Error(t, opts.Validate())
+
+		opts = Options{Directory: "logs", AppendOnly: true, FS: newMemFS()}
+		require.Error(t, opts.Validate())
+	})
+}
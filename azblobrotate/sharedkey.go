@@ -0,0 +1,74 @@
+package azblobrotate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// signSharedKey authorizes req with the Shared Key scheme of the storage account,
+// key is the base64 decoded account key.
+func signSharedKey(req *http.Request, account string, key []byte) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign(req, account)))
+	req.Header.Set("Authorization", "SharedKey "+account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// stringToSign returns the string signed by the Shared Key scheme for the Blob service,
+// see https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key.
+func stringToSign(req *http.Request, account string) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	for _, value := range []string{
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Date"),
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		b.WriteString(value + "\n")
+	}
+
+	// Canonicalized headers, the x-ms- headers sorted by name.
+	var names []string
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	// Canonicalized resource, the path followed by the query parameters sorted by name.
+	b.WriteString("/" + account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	names = names[:0]
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	return b.String()
+}
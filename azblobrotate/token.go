@@ -0,0 +1,87 @@
+package azblobrotate
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// storageResource is the Azure AD resource of the storage service.
+	storageResource = "https://storage.azure.com/"
+
+	// tokenExpiryMargin renews tokens before they expire, so that they
+	// do not expire during an upload.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// imdsTokenURL is the managed identity endpoint of the Azure Instance Metadata Service.
+var imdsTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// managedIdentityToken fetches access tokens of the managed identity of the
+// virtual machine or AKS node, caching them until they expire.
+type managedIdentityToken struct {
+	client *http.Client
+	// clientID selects a user-assigned identity, the system-assigned identity is used when empty
+	clientID string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns a cached access token, or fetches a new one.
+func (m *managedIdentityToken) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token != "" && time.Now().Before(m.expiry) {
+		return m.token, nil
+	}
+
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {storageResource}}
+	if m.clientID != "" {
+		query.Set("client_id", m.clientID)
+	}
+	req, err := http.NewRequest(http.MethodGet, imdsTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create token request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata", "true")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch managed identity token")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return "", errors.Errorf("failed to fetch managed identity token: %s", resp.Status)
+	}
+
+	// expires_in is a string in responses of the metadata service.
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrap(err, "failed to decode managed identity token")
+	}
+	expiresIn, err := strconv.ParseInt(token.ExpiresIn, 10, 64)
+	if token.AccessToken == "" || err != nil {
+		return "", errors.New("metadata service returned an invalid token")
+	}
+
+	m.token = token.AccessToken
+	m.expiry = time.Now().Add(time.Duration(expiresIn)*time.Second - tokenExpiryMargin)
+	return m.token, nil
+}
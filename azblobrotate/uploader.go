@@ -0,0 +1,340 @@
+// Package azblobrotate uploads rotated files to Azure Blob Storage.
+//
+// Uploader implements logrotate.Uploader, uploading each file as a block blob.
+// Requests are authorized with the account key, a SAS token, or by default with
+// the managed identity of the virtual machine or AKS node:
+//
+//	uploader, err := azblobrotate.NewUploader(azblobrotate.Options{
+//		Account:    "mystorageaccount",
+//		Container:  "logs",
+//		Prefix:     `app/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`,
+//		AccessTier: azblobrotate.AccessTierCool,
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	w, err := logrotate.New(nil, logrotate.Options{
+//		Directory:         "/var/log/app",
+//		Uploader:          uploader,
+//		DeleteAfterUpload: true,
+//	})
+package azblobrotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Access tiers for Options.AccessTier.
+const (
+	AccessTierHot     = "Hot"
+	AccessTierCool    = "Cool"
+	AccessTierCold    = "Cold"
+	AccessTierArchive = "Archive"
+)
+
+const (
+	// DefaultBlockSize is the size of the blocks large files are uploaded in.
+	DefaultBlockSize = 8 << 20
+
+	// maxBlockSize is the largest block accepted by the service.
+	maxBlockSize = 4000 << 20
+
+	// apiVersion is the version of the Blob service REST API used.
+	apiVersion = "2021-12-02"
+)
+
+// Options configure an Uploader.
+type Options struct {
+	// Account is the name of the storage account. Required unless Endpoint is set
+	// and the requests are not authorized with AccountKey.
+	Account string
+
+	// Container is the container files are uploaded to. Required.
+	Container string
+
+	// Prefix is prepended to file names to form blob names. Prefix is a text/template
+	// executed with the file's logrotate.FileMeta and Hostname, for example
+	// `logs/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`.
+	Prefix string
+
+	// AccessTier of uploaded blobs, AccessTierHot, AccessTierCool, AccessTierCold or
+	// AccessTierArchive. Defaults to the default tier of the account.
+	AccessTier string
+
+	// Endpoint is the URL of the Blob service, for example of Azurite or a sovereign cloud.
+	// Defaults to https://<Account>.blob.core.windows.net.
+	Endpoint string
+
+	// AccountKey is the base64 encoded key of the storage account, authorizing
+	// requests with Shared Key.
+	AccountKey string
+	// SASToken is a shared access signature, with or without the leading "?",
+	// appended to each request.
+	SASToken string
+	// Token returns the Azure AD access token authorizing requests.
+	// When neither AccountKey, SASToken nor Token are set, tokens of the managed
+	// identity are fetched from the Instance Metadata Service.
+	Token func(ctx context.Context) (string, error)
+	// ManagedIdentityClientID selects a user-assigned managed identity.
+	// Defaults to the system-assigned identity.
+	ManagedIdentityClientID string
+
+	// BlockSize is the size of the blocks files larger than BlockSize are uploaded in,
+	// smaller files are uploaded with a single request. Defaults to DefaultBlockSize.
+	BlockSize int64
+
+	// ContentType of uploaded blobs. Defaults to text/plain; charset=utf-8.
+	ContentType string
+
+	// Client sends requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Uploader uploads files to Azure Blob Storage, it implements logrotate.Uploader.
+type Uploader struct {
+	opts       Options
+	endpoint   string
+	accountKey []byte
+	sas        url.Values
+	prefix     *template.Template
+	hostname   string
+	now        func() time.Time
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader.
+func NewUploader(opts Options) (*Uploader, error) {
+	if opts.Container == "" {
+		return nil, errors.New("Container must not be empty")
+	}
+	if opts.Endpoint == "" {
+		if opts.Account == "" {
+			return nil, errors.New("Account must be set when Endpoint is not")
+		}
+		opts.Endpoint = "https://" + opts.Account + ".blob.core.windows.net"
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid Endpoint %s", opts.Endpoint)
+	}
+	switch opts.AccessTier {
+	case "", AccessTierHot, AccessTierCool, AccessTierCold, AccessTierArchive:
+	default:
+		return nil, errors.Errorf("unsupported AccessTier %s", opts.AccessTier)
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.BlockSize < 0 || opts.BlockSize > maxBlockSize {
+		return nil, errors.Errorf("BlockSize must be between 1 and %d, got %d", maxBlockSize, opts.BlockSize)
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "text/plain; charset=utf-8"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	u := &Uploader{
+		opts:     opts,
+		endpoint: strings.TrimSuffix(opts.Endpoint, "/"),
+		now:      time.Now,
+	}
+
+	credentials := 0
+	if opts.AccountKey != "" {
+		credentials++
+		if opts.Account == "" {
+			return nil, errors.New("Account must be set when AccountKey is set")
+		}
+		if u.accountKey, err = base64.StdEncoding.DecodeString(opts.AccountKey); err != nil {
+			return nil, errors.Wrap(err, "invalid AccountKey")
+		}
+	}
+	if opts.SASToken != "" {
+		credentials++
+		if u.sas, err = url.ParseQuery(strings.TrimPrefix(opts.SASToken, "?")); err != nil {
+			return nil, errors.Wrap(err, "invalid SASToken")
+		}
+	}
+	if opts.Token != nil {
+		credentials++
+	}
+	if credentials > 1 {
+		return nil, errors.New("only one of AccountKey, SASToken and Token can be set")
+	}
+	if credentials == 0 {
+		u.opts.Token = (&managedIdentityToken{client: opts.Client, clientID: opts.ManagedIdentityClientID}).Token
+	}
+
+	if u.prefix, err = template.New("prefix").Parse(opts.Prefix); err != nil {
+		return nil, errors.Wrap(err, "invalid Prefix")
+	}
+	u.hostname, _ = os.Hostname()
+
+	return u, nil
+}
+
+// prefixData is the data Options.Prefix is executed with.
+type prefixData struct {
+	logrotate.FileMeta
+	Hostname string
+}
+
+// BlobName returns the blob name of a file.
+func (u *Uploader) BlobName(meta logrotate.FileMeta) (string, error) {
+	var b bytes.Buffer
+	if err := u.prefix.Execute(&b, prefixData{FileMeta: meta, Hostname: u.hostname}); err != nil {
+		return "", errors.Wrap(err, "failed to execute Prefix")
+	}
+	return b.String() + meta.Name, nil
+}
+
+// Upload uploads the file at localPath as a block blob. Files larger than BlockSize
+// are uploaded block by block, then committed with a block list.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	name, err := u.BlobName(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat file")
+	}
+
+	if info.Size() <= u.opts.BlockSize {
+		err = u.putBlob(ctx, name, f, info.Size())
+	} else {
+		err = u.putBlocks(ctx, name, f, info.Size())
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload to %s/%s", u.opts.Container, name)
+	}
+	return nil
+}
+
+// putBlob uploads a file with a single Put Blob request.
+func (u *Uploader) putBlob(ctx context.Context, name string, f *os.File, size int64) error {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("Content-Type", u.opts.ContentType)
+	if u.opts.AccessTier != "" {
+		header.Set("x-ms-access-tier", u.opts.AccessTier)
+	}
+	return u.do(ctx, name, nil, header, logrotate.UploadReader(ctx, io.NewSectionReader(f, 0, size)), size, http.StatusCreated)
+}
+
+// putBlocks uploads a file with a Put Block request per block, and commits the blocks with Put Block List.
+func (u *Uploader) putBlocks(ctx context.Context, name string, f *os.File, size int64) error {
+	var blocks blockList
+	for offset := int64(0); offset < size; offset += u.opts.BlockSize {
+		n := u.opts.BlockSize
+		if offset+n > size {
+			n = size - offset
+		}
+
+		// Block IDs must have the same length within a blob.
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blocks.Latest))))
+		query := url.Values{"comp": {"block"}, "blockid": {id}}
+		if err := u.do(ctx, name, query, http.Header{}, logrotate.UploadReader(ctx, io.NewSectionReader(f, offset, n)), n, http.StatusCreated); err != nil {
+			return errors.Wrapf(err, "failed to put block at offset %d", offset)
+		}
+		blocks.Latest = append(blocks.Latest, id)
+	}
+
+	body, err := xml.Marshal(blocks)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode block list")
+	}
+	body = append([]byte(xml.Header), body...)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml")
+	header.Set("x-ms-blob-content-type", u.opts.ContentType)
+	if u.opts.AccessTier != "" {
+		header.Set("x-ms-access-tier", u.opts.AccessTier)
+	}
+	query := url.Values{"comp": {"blocklist"}}
+	if err := u.do(ctx, name, query, header, bytes.NewReader(body), int64(len(body)), http.StatusCreated); err != nil {
+		return errors.Wrap(err, "failed to put block list")
+	}
+	return nil
+}
+
+// blockList is the body of a Put Block List request.
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// do sends an authorized PUT request for the blob, and checks that the response has the status want.
+func (u *Uploader) do(ctx context.Context, name string, query url.Values, header http.Header, body io.Reader, size int64, want int) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	for key, values := range u.sas {
+		query[key] = values
+	}
+
+	target := u.endpoint + (&url.URL{Path: "/" + u.opts.Container + "/" + name}).EscapedPath()
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodPut, target, ioutil.NopCloser(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", u.now().UTC().Format(http.TimeFormat))
+
+	switch {
+	case u.accountKey != nil:
+		signSharedKey(req, u.opts.Account, u.accountKey)
+	case u.opts.Token != nil:
+		token, err := u.opts.Token(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get access token")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := u.opts.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("unexpected response status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
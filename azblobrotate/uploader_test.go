@@ -0,0 +1,195 @@
+package azblobrotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStringToSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://account.blob.core.windows.net/logs/app/a%20b.log?comp=block&blockid=YQ%3D%3D", strings.NewReader("message"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", "Thu, 02 Jan 2020 03:04:05 GMT")
+
+	require.Equal(t, "PUT\n\n\n7\n\ntext/plain\n\n\n\n\n\n\n"+
+		"x-ms-date:Thu, 02 Jan 2020 03:04:05 GMT\n"+
+		"x-ms-version:"+apiVersion+"\n"+
+		"/account/logs/app/a%20b.log\n"+
+		"blockid:YQ==\n"+
+		"comp:block", stringToSign(req, "account"))
+}
+
+// fakeBlobService stores the blobs and blocks put to a container.
+type fakeBlobService struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []*http.Request
+	blocks   map[string][]byte
+	blobs    map[string][]byte
+}
+
+func (s *fakeBlobService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r)
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(s.t, err)
+	require.Equal(s.t, http.MethodPut, r.Method)
+	require.Equal(s.t, apiVersion, r.Header.Get("x-ms-version"))
+
+	name := strings.TrimPrefix(r.URL.Path, "/logs/")
+	switch r.URL.Query().Get("comp") {
+	case "":
+		require.Equal(s.t, "BlockBlob", r.Header.Get("x-ms-blob-type"))
+		s.blobs[name] = body
+	case "block":
+		s.blocks[r.URL.Query().Get("blockid")] = body
+	case "blocklist":
+		var list blockList
+		require.NoError(s.t, xml.Unmarshal(body, &list))
+		var blob []byte
+		for _, id := range list.Latest {
+			blob = append(blob, s.blocks[id]...)
+		}
+		s.blobs[name] = blob
+	}
+	rw.WriteHeader(http.StatusCreated)
+}
+
+func TestUploader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 100)
+	localPath := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, content, 0644))
+
+	meta := logrotate.FileMeta{
+		Name:   "app.log",
+		Size:   int64(len(content)),
+		Closed: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	newService := func(t *testing.T) (*fakeBlobService, *httptest.Server) {
+		s := &fakeBlobService{t: t, blocks: map[string][]byte{}, blobs: map[string][]byte{}}
+		return s, httptest.NewServer(s)
+	}
+
+	t.Run("puts small files with a single request", func(t *testing.T) {
+		s, server := newService(t)
+		defer server.Close()
+
+		key := base64.StdEncoding.EncodeToString([]byte("key"))
+		uploader, err := NewUploader(Options{
+			Account:    "account",
+			Container:  "logs",
+			Prefix:     `app/{{.Closed.Format "2006/01/02"}}/`,
+			AccessTier: AccessTierCool,
+			Endpoint:   server.URL,
+			AccountKey: key,
+		})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.Equal(t, map[string][]byte{"app/2020/01/02/app.log": content}, s.blobs)
+		require.Len(t, s.requests, 1)
+		req := s.requests[0]
+		require.Equal(t, "Cool", req.Header.Get("x-ms-access-tier"))
+		require.Equal(t, "text/plain; charset=utf-8", req.Header.Get("Content-Type"))
+		require.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "SharedKey account:"))
+	})
+
+	t.Run("puts large files in blocks", func(t *testing.T) {
+		s, server := newService(t)
+		defer server.Close()
+
+		uploader, err := NewUploader(Options{
+			Container:  "logs",
+			AccessTier: AccessTierArchive,
+			Endpoint:   server.URL,
+			SASToken:   "?sv=2021-12-02&sig=signature",
+			BlockSize:  500,
+		})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.Equal(t, map[string][]byte{"app.log": content}, s.blobs)
+		require.Len(t, s.blocks, 4)
+		require.Len(t, s.requests, 5)
+		for _, req := range s.requests {
+			require.Equal(t, "signature", req.URL.Query().Get("sig"))
+			require.Empty(t, req.Header.Get("Authorization"))
+		}
+		list := s.requests[4]
+		require.Equal(t, "Archive", list.Header.Get("x-ms-access-tier"))
+		require.Equal(t, "text/plain; charset=utf-8", list.Header.Get("x-ms-blob-content-type"))
+	})
+
+	t.Run("fetches managed identity tokens", func(t *testing.T) {
+		s, server := newService(t)
+		defer server.Close()
+
+		imds := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "true", r.Header.Get("Metadata"))
+			require.Equal(t, storageResource, r.URL.Query().Get("resource"))
+			require.Equal(t, "client", r.URL.Query().Get("client_id"))
+			rw.Write([]byte(`{"access_token":"token","expires_in":"86399","token_type":"Bearer"}`))
+		}))
+		defer imds.Close()
+		defer func(u string) { imdsTokenURL = u }(imdsTokenURL)
+		imdsTokenURL = imds.URL
+
+		uploader, err := NewUploader(Options{Container: "logs", Endpoint: server.URL, ManagedIdentityClientID: "client"})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.Equal(t, "Bearer token", s.requests[0].Header.Get("Authorization"))
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusForbidden)
+			rw.Write([]byte("AuthenticationFailed"))
+		}))
+		defer server.Close()
+
+		uploader, err := NewUploader(Options{Container: "logs", Endpoint: server.URL, SASToken: "sig=signature"})
+		require.NoError(t, err)
+		err = uploader.Upload(context.Background(), localPath, meta)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "AuthenticationFailed")
+	})
+}
+
+func TestNewUploader(t *testing.T) {
+	for _, opts := range []Options{
+		{Account: "account"},
+		{Container: "logs"},
+		{Account: "account", Container: "logs", AccessTier: "Frozen"},
+		{Account: "account", Container: "logs", AccountKey: "not base64"},
+		{Endpoint: "http://localhost", Container: "logs", AccountKey: "a2V5"},
+		{Account: "account", Container: "logs", AccountKey: "a2V5", SASToken: "sig=signature"},
+		{Account: "account", Container: "logs", BlockSize: -1},
+		{Account: "account", Container: "logs", Prefix: "{{"},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+}
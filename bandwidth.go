@@ -0,0 +1,72 @@
+package logrotate
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter paces reads so that, together, they do not exceed a rate in bytes per second.
+type bandwidthLimiter struct {
+	rate int64
+
+	mu sync.Mutex
+	// next is the time by which the bytes read so far have been read at rate.
+	next time.Time
+}
+
+// wait waits for n bytes, which have just been read, to be within the rate.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type bandwidthLimiterKey struct{}
+
+// UploadReader returns a reader of r which respects Options.UploadBandwidth,
+// when ctx is the context passed to Uploader.Upload. Uploaders should read
+// files through UploadReader, without a limit r is returned as is.
+func UploadReader(ctx context.Context, r io.Reader) io.Reader {
+	l, ok := ctx.Value(bandwidthLimiterKey{}).(*bandwidthLimiter)
+	if !ok {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Reads are limited to a second worth of bytes, so that pauses are short
+	// and reads of concurrent uploads interleave.
+	if int64(len(p)) > r.limiter.rate {
+		p = p[:r.limiter.rate]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,37 @@
+package logrotate
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestUploadReader(t *testing.T) {
+	t.Run("returns the reader without a limit", func(t *testing.T) {
+		r := bytes.NewReader(nil)
+		require.Equal(t, io.Reader(r), UploadReader(context.Background(), r))
+	})
+
+	t.Run("limits the rate of reads", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), bandwidthLimiterKey{}, &bandwidthLimiter{rate: 1000})
+
+		start := time.Now()
+		n, err := io.Copy(ioutil.Discard, UploadReader(ctx, bytes.NewReader(make([]byte, 500))))
+		require.NoError(t, err)
+		require.Equal(t, int64(500), n)
+		require.True(t, time.Since(start) >= 400*time.Millisecond, "500 bytes must take about 500ms at 1000 bytes/s")
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = context.WithValue(ctx, bandwidthLimiterKey{}, &bandwidthLimiter{rate: 1})
+		cancel()
+
+		_, err := io.Copy(ioutil.Discard, UploadReader(ctx, bytes.NewReader(make([]byte, 10))))
+		require.Equal(t, context.Canceled, err)
+	})
+}
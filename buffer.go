@@ -0,0 +1,37 @@
+package logrotate
+
+import "sync"
+
+// maximumPooledBufferSize is the capacity above which buffers are not reused,
+// so that an occasional large entry does not pin memory.
+const maximumPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// getBuffer returns a buffer of length n, reusing a previously released one if possible.
+func getBuffer(n int) []byte {
+	if n > maximumPooledBufferSize {
+		return make([]byte, n)
+	}
+
+	b := *bufferPool.Get().(*[]byte)
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+// putBuffer releases b for reuse, b must not be used afterwards.
+func putBuffer(b []byte) {
+	if cap(b) > maximumPooledBufferSize {
+		return
+	}
+
+	b = b[:0]
+	bufferPool.Put(&b)
+}
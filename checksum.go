@@ -0,0 +1,73 @@
+package logrotate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"path/filepath"
+)
+
+// ChecksumSuffix is appended to the name of a file to name its checksum sidecar,
+// see Options.Checksum.
+const ChecksumSuffix = ".sha256"
+
+// startChecksum starts computing the checksum of the file which has just been opened.
+// The checksum is computed from the entries written, files which were not empty
+// when opened, for example when reopened, are not checksummed.
+func (w *Writer) startChecksum() {
+	w.hash = nil
+	if !w.opts.Checksum {
+		return
+	}
+	if w.bytesWritten > 0 {
+		w.logger.Printf("Not computing checksum of %s, it was not empty when opened", w.path)
+		return
+	}
+	w.hash = sha256.New()
+}
+
+// updateChecksum adds b, which has been written to the current file, to its checksum.
+func (w *Writer) updateChecksum(b []byte) {
+	if w.hash != nil {
+		w.hash.Write(b)
+	}
+}
+
+// invalidateChecksum stops computing the checksum of the current file,
+// after a failure left it unknown which entries the file contains.
+func (w *Writer) invalidateChecksum() {
+	if w.hash != nil {
+		w.logger.Printf("Not computing checksum of %s, entries may have been lost", w.path)
+		w.hash = nil
+	}
+}
+
+// finishChecksum writes the checksum sidecar of the file at path, which has just
+// been closed, and returns the hex encoded checksum. The sidecar uses the format
+// of sha256sum, so that it can be verified with sha256sum -c.
+// When no checksum was computed, finishChecksum returns an empty checksum.
+func (w *Writer) finishChecksum(path string) (string, error) {
+	h := w.hash
+	w.hash = nil
+	if h == nil {
+		return "", nil
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	sidecar := path + ChecksumSuffix
+	f, err := w.openLimited(sidecar, newFileFlag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create checksum file %s", sidecar)
+	}
+	if _, err := f.Write([]byte(sum + "  " + filepath.Base(path) + "\n")); err != nil {
+		f.Close()
+		return "", errors.Wrapf(err, "failed to write checksum file %s", sidecar)
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Wrapf(err, "failed to close checksum file %s", sidecar)
+	}
+	if err := setAttributes(w.opts, sidecar, w.opts.FileMode); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
@@ -0,0 +1,125 @@
+package logrotate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriter_Checksum(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	sha256Hex := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+
+	t.Run("writes sidecars", func(t *testing.T) {
+		dir := newDir(t)
+		var events []FileCloseEvent
+		i := 0
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 10,
+			Checksum:        true,
+			FileNameFunc: func() string {
+				i++
+				return fmt.Sprintf("%d.log", i)
+			},
+			OnFileClose: func(e FileCloseEvent) {
+				events = append(events, e)
+			},
+		})
+		require.NoError(t, err)
+
+		for _, msg := range []string{"first\n", "second\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"1.log", "1.log.sha256", "2.log", "2.log.sha256"}, logFiles(t, dir))
+		require.Len(t, events, 2)
+		for i, msg := range []string{"first\n", "second\n"} {
+			name := fmt.Sprintf("%d.log", i+1)
+			sum := sha256Hex([]byte(msg))
+			require.Equal(t, sum, events[i].SHA256)
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, name+ChecksumSuffix))
+			require.NoError(t, err)
+			require.Equal(t, sum+"  "+name+"\n", string(b))
+		}
+	})
+
+	t.Run("does not checksum files which were not empty", func(t *testing.T) {
+		dir := newDir(t)
+		var events []FileCloseEvent
+		w, err := New(logger, Options{
+			Directory:    dir,
+			Checksum:     true,
+			FileNameFunc: func() string { return "app.log" },
+			OnFileClose: func(e FileCloseEvent) {
+				events = append(events, e)
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Reopen())
+		_, err = w.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"app.log"}, logFiles(t, dir))
+		require.Len(t, events, 1)
+		require.Empty(t, events[0].SHA256)
+	})
+
+	t.Run("uploads sidecars after files", func(t *testing.T) {
+		dir := newDir(t)
+		var (
+			mu       sync.Mutex
+			uploaded []FileMeta
+		)
+		w, err := New(logger, Options{
+			Directory: dir,
+			Checksum:  true,
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded = append(uploaded, meta)
+				return nil
+			}),
+			DeleteAfterUpload: true,
+			FileNameFunc:      func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Len(t, uploaded, 2)
+		require.Equal(t, "app.log", uploaded[0].Name)
+		require.Equal(t, sha256Hex([]byte("message\n")), uploaded[0].SHA256)
+		require.Equal(t, "app.log.sha256", uploaded[1].Name)
+		require.Equal(t, int64(len(uploaded[0].SHA256)+len("  app.log\n")), uploaded[1].Size)
+		require.Empty(t, logFiles(t, dir))
+	})
+}
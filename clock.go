@@ -0,0 +1,52 @@
+package logrotate
+
+import "time"
+
+// lifetimeCheckInterval is how often files are checked for exceeding MaximumLifetime,
+// and repeats of the last entry for exceeding repeatsFlushInterval.
+const lifetimeCheckInterval = time.Second
+
+// Ticker delivers ticks at intervals, see Options.NewTicker.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the Ticker.
+	Stop()
+}
+
+type timeTicker struct {
+	*time.Ticker
+}
+
+func newTimeTicker(d time.Duration) Ticker {
+	return timeTicker{time.NewTicker(d)}
+}
+
+func (t timeTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// now returns the current time according to Options.Now.
+func (w *Writer) now() time.Time {
+	return w.clock()
+}
+
+// updateTicker starts the lifetime ticker when MaximumLifetime or
+// SuppressDuplicates is enabled and stops it when both are disabled.
+func (w *Writer) updateTicker() {
+	enabled := w.opts.MaximumLifetime != 0 || w.opts.SuppressDuplicates
+	if enabled && w.ticker == nil {
+		w.ticker = w.opts.NewTicker(lifetimeCheckInterval)
+	}
+	if !enabled && w.ticker != nil {
+		w.ticker.Stop()
+		w.ticker = nil
+	}
+}
+
+// tick runs the periodic work of the Writer, on each tick of the lifetime ticker.
+func (w *Writer) tick() error {
+	w.applyNextOptions()
+	w.flushExpiredRepeats()
+	return w.expire()
+}
@@ -0,0 +1,97 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic tests of time based behavior.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2020, 3, 28, 15, 0, 0, 0, time.UTC),
+		ticks: make(chan time.Time),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Tick delivers a tick to the ticker, blocking until it is received.
+func (c *fakeClock) Tick() {
+	c.ticks <- c.Now()
+}
+
+func (c *fakeClock) NewTicker(time.Duration) Ticker {
+	return fakeTicker{c.ticks}
+}
+
+type fakeTicker struct {
+	c chan time.Time
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t fakeTicker) Stop() {}
+
+func TestWriter_ExpiresIdleFiles(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clock := newFakeClock()
+	closed := make(chan FileCloseEvent, 1)
+	w, err := New(logger, Options{
+		Directory:       dir,
+		MaximumLifetime: time.Hour,
+		Now:             clock.Now,
+		NewTicker:       clock.NewTicker,
+		OnFileClose: func(e FileCloseEvent) {
+			closed <- e
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("message"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	clock.Tick()
+	require.NotEmpty(t, w.CurrentFilename(), "must not close the file before it expires")
+
+	clock.Advance(time.Hour + time.Second)
+	clock.Tick()
+
+	e := <-closed
+	require.Equal(t, time.Hour+time.Second, e.Closed.Sub(e.Opened))
+	require.NoError(t, w.Flush())
+	require.Empty(t, w.CurrentFilename(), "must close expired file without further writes")
+	require.Equal(t, int64(1), w.Stats().Rotations, "an expired file counts as a rotation")
+	require.Equal(t, int64(1), w.RotationCount())
+	path, _ := w.LastRotation()
+	require.Equal(t, e.Path, path)
+
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,41 @@
+package logrotate
+
+import (
+	"context"
+)
+
+// NewWithContext creates a new Writer, like New, which is closed when ctx is done.
+// Cancelling ctx rejects new writes and flushes all accepted ones, the same as Close.
+// Close may still be called, for example to wait for the writer to finish
+// and obtain the result of closing it:
+//
+//	g, ctx := errgroup.WithContext(ctx)
+//	w, err := logrotate.NewWithContext(ctx, logger, opts)
+//	g.Go(func() error {
+//		<-w.Done()
+//		return w.Close()
+//	})
+func NewWithContext(ctx context.Context, logger Logger, opts Options) (*Writer, error) {
+	w, err := New(logger, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := w.Close(); err != nil {
+				w.logger.Printf("Failed to close writer after context was done: %v", err)
+			}
+		case <-w.done:
+		}
+	}()
+
+	return w, nil
+}
+
+// Done returns a channel which is closed once the Writer has been closed
+// and all accepted entries have been written.
+func (w *Writer) Done() <-chan struct{} {
+	return w.done
+}
@@ -0,0 +1,47 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewWithContext(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := NewWithContext(ctx, logger, Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return "file.log" },
+	})
+	require.NoError(t, err)
+
+	message := []byte("message")
+	_, err = w.Write(message)
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatal("must close writer when context is cancelled")
+	}
+
+	require.NoError(t, w.Close())
+	_, err = w.Write(message)
+	require.True(t, errors.Is(err, ErrClosed))
+
+	written, err := ioutil.ReadFile(filepath.Join(dir, "file.log"))
+	require.NoError(t, err)
+	require.Equal(t, message, written, "must flush accepted writes")
+}
@@ -0,0 +1,375 @@
+// Package cwlogsrotate ships rotated files to Amazon CloudWatch Logs.
+//
+// Uploader implements logrotate.Uploader, each line of a rotated file is sent as
+// a log event with PutLogEvents. Requests are signed with AWS Signature Version 4
+// without depending on the AWS SDK:
+//
+//	uploader, err := cwlogsrotate.NewUploader(cwlogsrotate.Options{
+//		LogGroup:  "/app/production",
+//		LogStream: "{{.Hostname}}",
+//		Region:    "eu-west-1",
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	w, err := logrotate.New(nil, logrotate.Options{
+//		Directory: "/var/log/app",
+//		Uploader:  uploader,
+//	})
+//
+// Files are shipped at least once: when an upload fails part way through and
+// is retried, the events which were already accepted are sent again.
+package cwlogsrotate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/easyCZ/logrotate/internal/awsv4"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Limits of PutLogEvents.
+const (
+	// MaxBatchSize is the maximum number of events sent in one request.
+	MaxBatchSize = 10000
+
+	// maxBatchBytes is the maximum size of the events of one request,
+	// counting eventOverhead bytes for each event.
+	maxBatchBytes = 1048576
+	eventOverhead = 26
+	// maxEventBytes is the maximum size of a message, longer lines are truncated.
+	maxEventBytes = 262144 - eventOverhead
+	// maxBatchSpan is the maximum time between the first and last events of one request.
+	maxBatchSpan = 24 * time.Hour
+
+	// tokenAttempts is the number of times a batch is sent while its sequence
+	// token is being corrected.
+	tokenAttempts = 3
+)
+
+// Credentials are AWS access keys used to sign requests.
+type Credentials = awsv4.Credentials
+
+// Options configure an Uploader.
+type Options struct {
+	// LogGroup is the log group events are sent to. Required, the group is not created.
+	LogGroup string
+
+	// LogStream is the name of the log stream events are sent to. LogStream is a
+	// text/template executed with the file's logrotate.FileMeta and Hostname.
+	// Streams which do not exist are created. Defaults to {{.Hostname}}.
+	LogStream string
+
+	// Region is the region of the log group. Defaults to us-east-1.
+	Region string
+
+	// Endpoint is the URL of the CloudWatch Logs service.
+	// Defaults to the AWS endpoint of Region.
+	Endpoint string
+
+	// Credentials sign requests. When nil, credentials are read from the
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+	Credentials *Credentials
+
+	// Timestamp returns the time of the entry on a line, or the zero time when
+	// it is unknown. Events without a time take the time of the previous event,
+	// or the time the file was opened for the first lines. Events must be in
+	// chronological order, events older than the previous event take its time.
+	// When nil, all events of a file take the time the file was closed.
+	Timestamp func(line []byte) time.Time
+
+	// BatchSize is the maximum number of events sent in one request.
+	// Defaults to MaxBatchSize, which is also its maximum.
+	BatchSize int
+
+	// Client sends requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Uploader ships files to CloudWatch Logs, it implements logrotate.Uploader.
+type Uploader struct {
+	opts     Options
+	stream   *template.Template
+	hostname string
+	now      func() time.Time
+
+	// mu serializes uploads, the events of a stream must be sent in sequence.
+	mu sync.Mutex
+	// tokens are the sequence tokens of the next requests to each stream.
+	tokens map[string]string
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader.
+func NewUploader(opts Options) (*Uploader, error) {
+	if opts.LogGroup == "" {
+		return nil, errors.New("LogGroup must not be empty")
+	}
+	if opts.LogStream == "" {
+		opts.LogStream = "{{.Hostname}}"
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://logs." + opts.Region + ".amazonaws.com"
+	}
+	if endpoint, err := url.Parse(opts.Endpoint); err != nil || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid Endpoint %s", opts.Endpoint)
+	}
+	if opts.Credentials == nil {
+		opts.Credentials = awsv4.CredentialsFromEnv()
+	}
+	if opts.Credentials.AccessKeyID == "" || opts.Credentials.SecretAccessKey == "" {
+		return nil, errors.New("missing credentials, set Credentials or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = MaxBatchSize
+	}
+	if opts.BatchSize < 0 || opts.BatchSize > MaxBatchSize {
+		return nil, errors.Errorf("BatchSize must be between 1 and %d", MaxBatchSize)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	stream, err := template.New("stream").Parse(opts.LogStream)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid LogStream")
+	}
+	hostname, _ := os.Hostname()
+
+	return &Uploader{
+		opts:     opts,
+		stream:   stream,
+		hostname: hostname,
+		now:      time.Now,
+		tokens:   map[string]string{},
+	}, nil
+}
+
+// streamData is the data Options.LogStream is executed with.
+type streamData struct {
+	logrotate.FileMeta
+	Hostname string
+}
+
+// LogStream returns the name of the log stream the events of a file are sent to.
+func (u *Uploader) LogStream(meta logrotate.FileMeta) (string, error) {
+	var b bytes.Buffer
+	if err := u.stream.Execute(&b, streamData{FileMeta: meta, Hostname: u.hostname}); err != nil {
+		return "", errors.Wrap(err, "failed to execute LogStream")
+	}
+	if b.Len() == 0 {
+		return "", errors.New("LogStream must not be empty")
+	}
+	return b.String(), nil
+}
+
+// event is an input log event of PutLogEvents.
+type event struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// Upload sends the lines of the file at localPath as log events, in batches.
+// Empty lines are skipped.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	stream, err := u.LogStream(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var (
+		batch     []event
+		size      int
+		first, ts time.Time
+	)
+	ts = meta.Closed
+	if u.opts.Timestamp != nil {
+		ts = meta.Opened
+	}
+	if ts.IsZero() {
+		ts = u.now()
+	}
+	r := bufio.NewReader(logrotate.UploadReader(ctx, f))
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return errors.Wrap(readErr, "failed to read file")
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			if u.opts.Timestamp != nil {
+				if t := u.opts.Timestamp(line); !t.IsZero() && !t.Before(ts) {
+					ts = t
+				}
+			}
+			msg := strings.ToValidUTF8(string(line), "\uFFFD")
+			if len(msg) > maxEventBytes {
+				msg = strings.ToValidUTF8(msg[:maxEventBytes], "")
+			}
+
+			if len(batch) == u.opts.BatchSize || size+len(msg)+eventOverhead > maxBatchBytes ||
+				len(batch) > 0 && ts.Sub(first) > maxBatchSpan {
+				if err := u.putLogEvents(ctx, stream, batch); err != nil {
+					return err
+				}
+				batch, size = batch[:0], 0
+			}
+			if len(batch) == 0 {
+				first = ts
+			}
+			batch = append(batch, event{Timestamp: ts.UnixNano() / int64(time.Millisecond), Message: msg})
+			size += len(msg) + eventOverhead
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(batch) > 0 {
+		return u.putLogEvents(ctx, stream, batch)
+	}
+	return nil
+}
+
+// putLogEvents sends a batch of events to stream. Streams which do not exist
+// are created, and the sequence token is corrected when another writer sent
+// events to the stream since the last request.
+func (u *Uploader) putLogEvents(ctx context.Context, stream string, events []event) error {
+	for attempt := 1; ; attempt++ {
+		in := struct {
+			LogGroupName  string  `json:"logGroupName"`
+			LogStreamName string  `json:"logStreamName"`
+			LogEvents     []event `json:"logEvents"`
+			SequenceToken string  `json:"sequenceToken,omitempty"`
+		}{u.opts.LogGroup, stream, events, u.tokens[stream]}
+		var out struct {
+			NextSequenceToken string `json:"nextSequenceToken"`
+		}
+		err := u.call(ctx, "PutLogEvents", in, &out)
+		if err == nil {
+			u.tokens[stream] = out.NextSequenceToken
+			return nil
+		}
+
+		e, ok := err.(*apiError)
+		if !ok || attempt == tokenAttempts {
+			return errors.Wrapf(err, "failed to put log events to %s/%s", u.opts.LogGroup, stream)
+		}
+		switch e.Type {
+		case "DataAlreadyAcceptedException":
+			// A previous attempt succeeded, but its response was lost.
+			u.tokens[stream] = e.ExpectedSequenceToken
+			return nil
+		case "InvalidSequenceTokenException":
+			u.tokens[stream] = e.ExpectedSequenceToken
+		case "ResourceNotFoundException":
+			if err := u.createLogStream(ctx, stream); err != nil {
+				return err
+			}
+			delete(u.tokens, stream)
+		default:
+			return errors.Wrapf(err, "failed to put log events to %s/%s", u.opts.LogGroup, stream)
+		}
+	}
+}
+
+// createLogStream creates stream, which may have been created concurrently.
+func (u *Uploader) createLogStream(ctx context.Context, stream string) error {
+	in := struct {
+		LogGroupName  string `json:"logGroupName"`
+		LogStreamName string `json:"logStreamName"`
+	}{u.opts.LogGroup, stream}
+	err := u.call(ctx, "CreateLogStream", in, nil)
+	if e, ok := err.(*apiError); ok && e.Type == "ResourceAlreadyExistsException" {
+		return nil
+	}
+	return errors.Wrapf(err, "failed to create log stream %s/%s", u.opts.LogGroup, stream)
+}
+
+// call calls an action of the CloudWatch Logs JSON API, decoding its response into out.
+// Errors returned by the service are *apiError.
+func (u *Uploader) call(ctx context.Context, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	awsv4.Sign(req, *u.opts.Credentials, u.opts.Region, "logs", awsv4.HashHex(body), u.now())
+
+	resp, err := u.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &apiError{Status: resp.Status}
+		if json.Unmarshal(b, e) != nil || e.Type == "" {
+			if len(b) > 1024 {
+				b = b[:1024]
+			}
+			e.Message = strings.TrimSpace(string(b))
+		}
+		// Types are qualified with a namespace, for example
+		// com.amazonaws.logs#InvalidSequenceTokenException.
+		e.Type = e.Type[strings.LastIndex(e.Type, "#")+1:]
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(b, out), "failed to decode response")
+}
+
+// apiError is an error returned by CloudWatch Logs.
+type apiError struct {
+	Status  string `json:"-"`
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+	// ExpectedSequenceToken is set for InvalidSequenceTokenException and DataAlreadyAcceptedException.
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *apiError) Error() string {
+	if e.Type == "" {
+		return e.Status + ": " + e.Message
+	}
+	return e.Status + ": " + e.Type + ": " + e.Message
+}
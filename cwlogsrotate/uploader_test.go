@@ -0,0 +1,197 @@
+package cwlogsrotate
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLogs is a CloudWatch Logs server requiring sequence tokens, as the
+// service did before they were made optional.
+type fakeLogs struct {
+	mu      sync.Mutex
+	streams map[string]*fakeStream
+	// fail is the response status of all requests when set.
+	fail     int
+	requests []http.Header
+}
+
+type fakeStream struct {
+	token  int
+	events []event
+}
+
+func (s *fakeLogs) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Header)
+
+	writeError := func(typ string, token int) {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{
+			"__type":                "com.amazonaws.logs#" + typ,
+			"message":               typ,
+			"expectedSequenceToken": strconv.Itoa(token),
+		})
+	}
+	if s.fail != 0 {
+		rw.WriteHeader(s.fail)
+		rw.Write([]byte(`{"__type":"AccessDeniedException","message":"denied"}`))
+		return
+	}
+
+	var in struct {
+		LogGroupName  string  `json:"logGroupName"`
+		LogStreamName string  `json:"logStreamName"`
+		LogEvents     []event `json:"logEvents"`
+		SequenceToken string  `json:"sequenceToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.LogGroupName != "app" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	stream := s.streams[in.LogStreamName]
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "Logs_20140328.CreateLogStream":
+		if stream != nil {
+			writeError("ResourceAlreadyExistsException", 0)
+			return
+		}
+		s.streams[in.LogStreamName] = &fakeStream{}
+	case "Logs_20140328.PutLogEvents":
+		if stream == nil {
+			writeError("ResourceNotFoundException", 0)
+			return
+		}
+		token := ""
+		if stream.token > 0 {
+			token = strconv.Itoa(stream.token)
+		}
+		if in.SequenceToken != token {
+			writeError("InvalidSequenceTokenException", stream.token)
+			return
+		}
+		stream.token++
+		stream.events = append(stream.events, in.LogEvents...)
+		json.NewEncoder(rw).Encode(map[string]string{"nextSequenceToken": strconv.Itoa(stream.token)})
+	default:
+		rw.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestUploader(t *testing.T) {
+	fake := &fakeLogs{streams: map[string]*fakeStream{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, []byte(
+		"2020-01-02T03:00:00Z first\n"+
+			"\n"+
+			"continued\r\n"+
+			"2020-01-02T02:00:00Z out of order\n"+
+			"2020-01-02T03:00:01Z last"), 0644))
+	opened := time.Date(2020, time.January, 2, 2, 59, 0, 0, time.UTC)
+	meta := logrotate.FileMeta{
+		Name:   "app.log",
+		Opened: opened,
+		Closed: opened.Add(time.Hour),
+	}
+
+	newUploader := func(t *testing.T) *Uploader {
+		uploader, err := NewUploader(Options{
+			LogGroup:    "app",
+			LogStream:   `{{.Closed.Format "2006-01-02"}}`,
+			Region:      "eu-west-1",
+			Endpoint:    server.URL,
+			Credentials: &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+			Timestamp: func(line []byte) time.Time {
+				ts, _ := time.Parse(time.RFC3339, strings.SplitN(string(line), " ", 2)[0])
+				return ts
+			},
+			BatchSize: 2,
+		})
+		require.NoError(t, err)
+		return uploader
+	}
+	millis := func(s string) int64 {
+		ts, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return ts.UnixNano() / int64(time.Millisecond)
+	}
+
+	t.Run("creates the stream and sends events in batches", func(t *testing.T) {
+		uploader := newUploader(t)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		stream := fake.streams["2020-01-02"]
+		require.NotNil(t, stream)
+		require.Equal(t, []event{
+			{Timestamp: millis("2020-01-02T03:00:00Z"), Message: "2020-01-02T03:00:00Z first"},
+			{Timestamp: millis("2020-01-02T03:00:00Z"), Message: "continued"},
+			{Timestamp: millis("2020-01-02T03:00:00Z"), Message: "2020-01-02T02:00:00Z out of order"},
+			{Timestamp: millis("2020-01-02T03:00:01Z"), Message: "2020-01-02T03:00:01Z last"},
+		}, stream.events)
+		require.Equal(t, 2, stream.token, "events must be sent in two batches")
+
+		header := fake.requests[len(fake.requests)-1]
+		require.Equal(t, "application/x-amz-json-1.1", header.Get("Content-Type"))
+		require.Contains(t, header.Get("Authorization"), "/eu-west-1/logs/aws4_request")
+	})
+
+	t.Run("corrects invalid sequence tokens", func(t *testing.T) {
+		// Another writer sent events to the stream since the last upload.
+		uploader := newUploader(t)
+		uploader.tokens["2020-01-02"] = "1"
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		stream := fake.streams["2020-01-02"]
+		require.Len(t, stream.events, 8)
+		require.Equal(t, "4", uploader.tokens["2020-01-02"])
+	})
+
+	t.Run("returns service errors", func(t *testing.T) {
+		fake.fail = http.StatusForbidden
+		defer func() { fake.fail = 0 }()
+
+		err := newUploader(t).Upload(context.Background(), localPath, meta)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "AccessDeniedException")
+	})
+}
+
+func TestNewUploader(t *testing.T) {
+	creds := &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	for _, opts := range []Options{
+		{Credentials: creds},
+		{LogGroup: "app", Credentials: creds, Endpoint: "://"},
+		{LogGroup: "app", Credentials: creds, BatchSize: MaxBatchSize + 1},
+		{LogGroup: "app", Credentials: creds, LogStream: "{{"},
+		{LogGroup: "app", Credentials: &Credentials{}},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+
+	uploader, err := NewUploader(Options{LogGroup: "app", Region: "eu-west-1", Credentials: creds})
+	require.NoError(t, err)
+	require.Equal(t, "https://logs.eu-west-1.amazonaws.com", uploader.opts.Endpoint)
+	stream, err := uploader.LogStream(logrotate.FileMeta{})
+	require.NoError(t, err)
+	require.Equal(t, uploader.hostname, stream)
+}
@@ -0,0 +1,81 @@
+package logrotate
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// debugf logs a decision of the Writer when Options.Debug is set.
+func (w *Writer) debugf(format string, args ...interface{}) {
+	if w.debug {
+		w.logger.Printf("[debug] "+format, args...)
+	}
+}
+
+// debugRotation logs why the current file is rotated, size is the size of the
+// entry which does not fit into the file when rotating by size.
+func (w *Writer) debugRotation(reason RotationReason, size int64) {
+	if !w.debug {
+		return
+	}
+	switch reason {
+	case RotationSize:
+		w.debugf("Rotating %s by size: %d bytes written, the entry of %d bytes exceeds MaximumFileSize of %d bytes",
+			w.path, w.bytesWritten, size, w.opts.MaximumFileSize)
+	case RotationTime:
+		w.debugf("Rotating %s by time: opened at %s, MaximumLifetime of %v elapsed",
+			w.path, w.ts.Format(time.RFC3339), w.opts.MaximumLifetime)
+	default:
+		w.debugf("Rotating %s manually", w.path)
+	}
+}
+
+// queuePressure is how full the queue is, see debugQueuePressure.
+type queuePressure int32
+
+const (
+	queuePressureNormal queuePressure = iota
+	// queuePressureHigh is a queue at least half full
+	queuePressureHigh
+	// queuePressureFull is a full queue, which blocks Write or drops entries
+	queuePressureFull
+)
+
+func (p queuePressure) String() string {
+	switch p {
+	case queuePressureHigh:
+		return "high"
+	case queuePressureFull:
+		return "full"
+	default:
+		return "normal"
+	}
+}
+
+// debugQueuePressure logs the transitions of the queue between normal, high
+// and full pressure.
+func (w *Writer) debugQueuePressure() {
+	if !w.debug {
+		return
+	}
+	queued, size := len(w.queue), cap(w.queue)
+	pressure := queuePressureNormal
+	switch {
+	case queued >= size:
+		pressure = queuePressureFull
+	case queued >= size/2:
+		pressure = queuePressureHigh
+	}
+
+	previous := queuePressure(atomic.SwapInt32(&w.queuePressure, int32(pressure)))
+	if previous != pressure {
+		w.debugf("Queue pressure changed from %s to %s: %d of %d entries queued", previous, pressure, queued, size)
+	}
+}
+
+// debugf logs a decision of the Manager when ManagerOptions.Debug is set.
+func (m *Manager) debugf(format string, args ...interface{}) {
+	if m.opts.Debug {
+		m.logger.Printf("[debug] "+format, args...)
+	}
+}
@@ -0,0 +1,122 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_Debug(t *testing.T) {
+	t.Run("logs why files are rotated", func(t *testing.T) {
+		var logs bytes.Buffer
+		clock := newFakeClock()
+		w, err := New(log.New(&logs, "", 0), Options{
+			Directory:       "logs",
+			FileNameFunc:    func() string { return "app.log" },
+			FS:              newMemFS(),
+			MaximumFileSize: 10,
+			MaximumLifetime: time.Hour,
+			Now:             clock.Now,
+			NewTicker:       clock.NewTicker,
+			Debug:           true,
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := w.Write([]byte("12345\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+		clock.Advance(time.Hour + time.Second)
+		_, err = w.Write([]byte("1\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+		require.NoError(t, w.Close())
+
+		require.Contains(t, logs.String(), "[debug] Rotating "+filepath.Join("logs", "app.log")+" by size: 6 bytes written, the entry of 6 bytes exceeds MaximumFileSize of 10 bytes")
+		require.Contains(t, logs.String(), "by time: opened at 2020-03-28T15:00:00Z, MaximumLifetime of 1h0m0s elapsed")
+		require.Contains(t, logs.String(), "manually")
+	})
+
+	t.Run("logs queue pressure transitions", func(t *testing.T) {
+		var logs bytes.Buffer
+		filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+		w, err := New(log.New(&logs, "", 0), Options{
+			Directory: "logs",
+			FS:        newMemFS(),
+			QueueSize: 2,
+			Filter: func(b []byte) bool {
+				select {
+				case filtering <- struct{}{}:
+					<-unblock
+				default:
+				}
+				return true
+			},
+			Debug: true,
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		<-filtering
+		for i := 0; i < 2; i++ {
+			_, err := w.Write([]byte("entry\n"))
+			require.NoError(t, err)
+		}
+		close(unblock)
+		require.NoError(t, w.Close())
+
+		require.Contains(t, logs.String(), "[debug] Queue pressure changed from normal to high: 1 of 2 entries queued")
+		require.Contains(t, logs.String(), "[debug] Queue pressure changed from high to full: 2 of 2 entries queued")
+		require.Contains(t, logs.String(), "[debug] Queue pressure changed from full to high: 1 of 2 entries queued")
+		require.Contains(t, logs.String(), "[debug] Queue pressure changed from high to normal: 0 of 2 entries queued")
+	})
+
+	t.Run("does not log unless enabled", func(t *testing.T) {
+		var logs bytes.Buffer
+		w, err := New(log.New(&logs, "", 0), Options{Directory: "logs", FS: newMemFS(), MaximumFileSize: 10})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err := w.Write([]byte("12345\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		require.NotContains(t, logs.String(), "[debug]")
+	})
+}
+
+func TestManager_Debug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"1.log", "2.log"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0644))
+	}
+
+	var logs bytes.Buffer
+	m, err := NewManager(log.New(&logs, "", 0), ManagerOptions{
+		Writers: map[string]Options{
+			"app": {Directory: dir, FileNameFunc: func() string { return "current.log" }},
+		},
+		MaximumTotalSize: 15,
+		CleanupInterval:  time.Hour,
+		Debug:            true,
+	})
+	require.NoError(t, err)
+	_, err = m.Writer("app").Write([]byte("entry\n"))
+	require.NoError(t, err)
+	require.NoError(t, m.Writer("app").Flush())
+	require.NoError(t, m.Cleanup())
+	require.NoError(t, m.Close())
+
+	require.Contains(t, logs.String(), "exceeding MaximumTotalSize of 15 bytes")
+	require.Contains(t, logs.String(), "[debug] Keeping "+filepath.Join(dir, "current.log")+", it is the current file")
+	require.Contains(t, logs.String(), "[debug] Removing "+filepath.Join(dir, "1.log")+", the oldest removable file")
+}
@@ -0,0 +1,54 @@
+package logrotate
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// repeatsFlushInterval is how long repeats of the last entry are counted before
+// their summary is written, see Options.SuppressDuplicates.
+const repeatsFlushInterval = 30 * time.Second
+
+// suppressDuplicate returns true if b repeats the last entry and is to be
+// discarded, see Options.SuppressDuplicates. Otherwise, the summary of the
+// repeats of the last entry is written first, if any.
+func (w *Writer) suppressDuplicate(b []byte) bool {
+	if w.opts.SuppressDuplicates && w.lastEntry != nil && bytes.Equal(b, w.lastEntry) {
+		if w.repeats == 0 {
+			w.repeatsSince = w.now()
+		}
+		w.repeats++
+		return true
+	}
+
+	w.flushRepeats()
+	if w.opts.SuppressDuplicates {
+		w.lastEntry = append(w.lastEntry[:0], b...)
+	} else {
+		w.lastEntry = nil
+	}
+	return false
+}
+
+// flushRepeats writes the summary of the repeats of the last entry, if any.
+func (w *Writer) flushRepeats() {
+	if w.repeats == 0 {
+		return
+	}
+	summary := fmt.Sprintf("last message repeated %d times\n", w.repeats)
+	if w.repeats == 1 {
+		summary = "last message repeated 1 time\n"
+	}
+	w.repeats = 0
+	w.processEntry([]byte(summary), time.Time{})
+}
+
+// flushExpiredRepeats writes the summary of the repeats of the last entry once
+// they have been counted for repeatsFlushInterval. Further repeats are counted
+// from zero, and summarized again.
+func (w *Writer) flushExpiredRepeats() {
+	if w.repeats != 0 && w.now().Sub(w.repeatsSince) >= repeatsFlushInterval {
+		w.flushRepeats()
+	}
+}
@@ -0,0 +1,96 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_SuppressDuplicates(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory:          dir,
+		FileNameFunc:       func() string { return "app.log" },
+		SuppressDuplicates: true,
+	})
+	require.NoError(t, err)
+	for _, msg := range []string{
+		"connection refused\n", "connection refused\n", "connection refused\n",
+		"retrying\n", "retrying\n",
+		"connected\n",
+		"closing\n", "closing\n", "closing\n", "closing\n",
+	} {
+		_, err := w.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	require.Equal(t, "connection refused\n"+
+		"last message repeated 2 times\n"+
+		"retrying\n"+
+		"last message repeated 1 time\n"+
+		"connected\n"+
+		"closing\n"+
+		"last message repeated 3 times\n", string(b))
+	require.Equal(t, int64(7), w.Stats().EntriesWritten)
+}
+
+func TestWriter_SuppressDuplicates_Periodic(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clock := newFakeClock()
+	w, err := New(logger, Options{
+		Directory:          dir,
+		FileNameFunc:       func() string { return "app.log" },
+		SuppressDuplicates: true,
+		Now:                clock.Now,
+		NewTicker:          clock.NewTicker,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	write := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := w.Write([]byte("connection refused\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+	}
+	contents := func() string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	write(3)
+	clock.Advance(29 * time.Second)
+	clock.Tick()
+	require.NoError(t, w.Flush())
+	require.Equal(t, "connection refused\n", contents(), "must not summarize repeats before the interval")
+
+	clock.Advance(time.Second)
+	clock.Tick()
+	require.NoError(t, w.Flush())
+	require.Equal(t, "connection refused\n"+
+		"last message repeated 2 times\n", contents(), "must summarize repeats after the interval")
+
+	write(4)
+	clock.Advance(30 * time.Second)
+	clock.Tick()
+	require.NoError(t, w.Flush())
+	require.Equal(t, "connection refused\n"+
+		"last message repeated 2 times\n"+
+		"last message repeated 4 times\n", contents(), "must count repeats again after a summary")
+}
@@ -0,0 +1,81 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+)
+
+var (
+	defaultMu     sync.Mutex
+	defaultWriter *Writer
+)
+
+// Init creates the process wide default Writer with opts, written to by the
+// package level functions, so that small programs and libraries can write into
+// rotated files without passing a Writer around:
+//
+//	if err := logrotate.Init(logrotate.Options{Directory: "/var/log/app"}); err != nil {
+//		// handle err
+//	}
+//	defer logrotate.Close()
+//
+//	log.SetOutput(logrotate.Default())
+//
+// Init fails if the default Writer is already initialized, until Close is called.
+// The Writer's own log lines, such as failures to write files, are discarded,
+// see Writer.Errors.
+func Init(opts Options) error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultWriter != nil {
+		return errors.New("default writer is already initialized")
+	}
+	w, err := New(nil, opts)
+	if err != nil {
+		return err
+	}
+	defaultWriter = w
+	return nil
+}
+
+// Default returns the default Writer created by Init, or nil before Init is called.
+func Default() *Writer {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultWriter
+}
+
+// Write writes p into the default Writer, see Writer.Write.
+// Write returns ErrNotInitialized before Init is called.
+func Write(p []byte) (int, error) {
+	w := Default()
+	if w == nil {
+		return 0, ErrNotInitialized
+	}
+	return w.Write(p)
+}
+
+// Rotate rotates the default Writer, see Writer.Rotate.
+// Rotate returns ErrNotInitialized before Init is called.
+func Rotate() error {
+	w := Default()
+	if w == nil {
+		return ErrNotInitialized
+	}
+	return w.Rotate()
+}
+
+// Close closes the default Writer, see Writer.Close, after which Init may be
+// called again. Close returns ErrNotInitialized before Init is called.
+func Close() error {
+	defaultMu.Lock()
+	w := defaultWriter
+	defaultWriter = nil
+	defaultMu.Unlock()
+
+	if w == nil {
+		return ErrNotInitialized
+	}
+	return w.Close()
+}
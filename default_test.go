@@ -0,0 +1,47 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Write([]byte("lost\n"))
+	require.True(t, errors.Is(err, ErrNotInitialized))
+	require.Nil(t, Default())
+
+	files := 0
+	require.NoError(t, Init(Options{
+		Directory: dir,
+		FileNameFunc: func() string {
+			files++
+			return string(rune('0'+files)) + ".log"
+		},
+	}))
+	require.Error(t, Init(Options{Directory: dir}))
+	require.NotNil(t, Default())
+
+	_, err = Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, Rotate())
+	_, err = Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, Close())
+
+	require.True(t, errors.Is(Close(), ErrNotInitialized))
+	require.True(t, errors.Is(Rotate(), ErrNotInitialized))
+
+	for name, expected := range map[string]string{"1.log": "first\n", "2.log": "second\n"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(b))
+	}
+}
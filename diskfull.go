@@ -0,0 +1,74 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"syscall"
+	"time"
+)
+
+// DiskFullPolicy defines how the Writer behaves when the disk is full.
+type DiskFullPolicy int
+
+const (
+	// DiskFullDrop drops entries while the disk is full.
+	// Dropped entries are counted and reported once space becomes available.
+	DiskFullDrop DiskFullPolicy = iota
+
+	// DiskFullBlock retries writing each entry until it succeeds.
+	// While the disk is full, the queue fills up and Write blocks.
+	DiskFullBlock
+
+	// DiskFullFail marks the Writer as failed on the first ENOSPC.
+	// Any queued entries are dropped and subsequent writes return an error.
+	DiskFullFail
+)
+
+// diskFullInterval is the default time to wait between attempts
+// to write an entry under DiskFullBlock.
+const diskFullInterval = time.Second
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// waitForDiskSpace retries writing b until it succeeds, fails for
+// a reason other than a full disk, or the writer is aborted.
+func (w *Writer) waitForDiskSpace(b []byte, err error) error {
+	for retry := 1; isDiskFull(err); retry++ {
+		interval := w.opts.Retry.backoff(retry)
+		if interval == 0 {
+			interval = diskFullInterval
+		}
+		if !w.sleep(interval) {
+			break
+		}
+		err = w.write(b)
+	}
+	return err
+}
+
+// dropDiskFull drops an entry b which could not be written because the disk is full.
+// Only the first dropped entry is logged, subsequent ones are counted.
+func (w *Writer) dropDiskFull(b []byte, err error) {
+	if w.opts.DiskFullPolicy == DiskFullFail {
+		w.fail(err)
+	}
+
+	if !w.diskFull {
+		w.logger.Printf("Disk is full, dropping entries until space becomes available: %v", err)
+		w.diskFull = true
+	}
+	w.diskFullDropped++
+	w.writeFallback(b)
+}
+
+// diskRecovered reports entries dropped while the disk was full, if any.
+func (w *Writer) diskRecovered() {
+	if !w.diskFull {
+		return
+	}
+
+	w.logger.Printf("Disk space available again, dropped %d entries while the disk was full.", w.diskFullDropped)
+	w.diskFull = false
+	w.diskFullDropped = 0
+}
@@ -0,0 +1,68 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskFullPolicy(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is not available")
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	// Entries larger than the buffer are written to the file immediately.
+	entry := bytes.Repeat([]byte("a"), 8192)
+
+	newDiskFullWriter := func(t *testing.T, policy DiskFullPolicy, fallback io.Writer) *Writer {
+		w, err := New(logger, Options{
+			Directory:      "/dev",
+			FileNameFunc:   func() string { return "full" },
+			DiskFullPolicy: policy,
+			Fallback:       fallback,
+		})
+		require.NoError(t, err)
+		return w
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		var fallback bytes.Buffer
+		w := newDiskFullWriter(t, DiskFullDrop, &fallback)
+
+		for i := 0; i < 3; i++ {
+			_, err := w.Write(entry)
+			require.NoError(t, err, "writes must be accepted")
+		}
+		w.Close()
+
+		require.True(t, errors.Is(w.LastError(), ErrDiskFull), "must report disk full")
+		require.Equal(t, int64(3), w.diskFullDropped, "must count dropped entries")
+		require.Equal(t, 3*len(entry), fallback.Len(), "must write dropped entries to fallback")
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		w := newDiskFullWriter(t, DiskFullFail, nil)
+
+		_, err := w.Write(entry)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			_, err := w.Write(entry)
+			return err != nil
+		}, time.Second, time.Millisecond, "must reject writes once failed")
+
+		_, err = w.Write(entry)
+		require.True(t, errors.Is(err, ErrFailed))
+		require.True(t, isDiskFull(err), "must preserve the underlying error")
+		w.Close()
+
+		require.True(t, isDiskFull(w.LastError()), "must report disk full")
+	})
+}
@@ -0,0 +1,65 @@
+package logrotate
+
+// DropReason is why an entry was not written, see Stats.DroppedByReason.
+type DropReason int
+
+const (
+	// DropQueueFull is an entry rejected with ErrQueueFull in NonBlocking mode.
+	DropQueueFull DropReason = iota
+	// DropFiltered is an entry discarded by Options.Filter.
+	DropFiltered
+	// DropRateLimited is an entry discarded by Options.RateLimit.
+	DropRateLimited
+	// DropTooLarge is an entry exceeding MaxEntrySize with EntrySizeReject,
+	// MaximumFileSize or the maximum record size of Framed.
+	DropTooLarge
+	// DropWriteFailed is an entry which could not be written to the file, after retries.
+	DropWriteFailed
+	// DropDiskFull is an entry which could not be written because the disk is full.
+	DropDiskFull
+	// DropFailed is an entry rejected because the Writer has failed, see ErrFailed.
+	DropFailed
+	// DropClosed is an entry abandoned by CloseContext.
+	DropClosed
+	// DropStalled is an entry which was not written into a target of a Mirror,
+	// because the target was stalled, see MirrorOptions.WriteTimeout.
+	DropStalled
+)
+
+func (r DropReason) String() string {
+	switch r {
+	case DropQueueFull:
+		return "queue_full"
+	case DropFiltered:
+		return "filtered"
+	case DropRateLimited:
+		return "rate_limited"
+	case DropTooLarge:
+		return "too_large"
+	case DropWriteFailed:
+		return "write_failed"
+	case DropDiskFull:
+		return "disk_full"
+	case DropFailed:
+		return "failed"
+	case DropClosed:
+		return "closed"
+	case DropStalled:
+		return "stalled"
+	default:
+		return "unknown"
+	}
+}
+
+// droppedMetric is the name of the counter of the entries dropped for reason.
+func droppedMetric(reason DropReason) string {
+	return MetricEntriesDropped + "_" + reason.String()
+}
+
+// countDrop counts an entry dropped for reason in DroppedByReason.
+func (s *Stats) countDrop(reason DropReason) {
+	if s.DroppedByReason == nil {
+		s.DroppedByReason = make(map[DropReason]int64)
+	}
+	s.DroppedByReason[reason]++
+}
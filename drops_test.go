@@ -0,0 +1,98 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestWriter_DroppedByReason(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("counts drops by policy", func(t *testing.T) {
+		metrics := newRecordingMetrics()
+		w, err := New(logger, Options{
+			Directory:       "logs",
+			FS:              newMemFS(),
+			Now:             newFakeClock().Now,
+			MaximumFileSize: 20,
+			Filter:          func(b []byte) bool { return !bytes.HasPrefix(b, []byte("debug")) },
+			RateLimit:       &RateLimitOptions{EntriesPerSecond: 1},
+			Metrics:         metrics,
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("debug entry\n"))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("entry exceeding MaximumFileSize\n"))
+		require.True(t, errors.Is(err, ErrEntryTooLarge))
+		for i := 0; i < 4; i++ {
+			_, err := w.Write([]byte("entry\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+
+		stats := w.Stats()
+		require.Equal(t, int64(1), stats.DroppedByReason[DropFiltered])
+		require.Equal(t, int64(1), stats.DroppedByReason[DropTooLarge])
+		require.True(t, stats.EntriesRateLimited > 0)
+		require.Equal(t, stats.EntriesRateLimited, stats.DroppedByReason[DropRateLimited])
+		require.Len(t, stats.DroppedByReason, 3)
+
+		require.Equal(t, int64(1), metrics.counter("entries_dropped_filtered"))
+		require.Equal(t, int64(1), metrics.counter("entries_dropped_too_large"))
+		require.Equal(t, stats.EntriesRateLimited, metrics.counter("entries_dropped_rate_limited"))
+
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("counts entries rejected by a full queue", func(t *testing.T) {
+		filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+		w, err := New(logger, Options{
+			Directory:   "logs",
+			FS:          newMemFS(),
+			QueueSize:   1,
+			NonBlocking: true,
+			Filter: func(b []byte) bool {
+				select {
+				case filtering <- struct{}{}:
+					<-unblock
+				default:
+				}
+				return true
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		<-filtering
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("entry\n"))
+		require.Equal(t, ErrQueueFull, err)
+		close(unblock)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, map[DropReason]int64{DropQueueFull: 1}, w.Stats().DroppedByReason)
+		e := <-w.Events()
+		for e.Type != EventDropped {
+			e = <-w.Events()
+		}
+		require.Equal(t, DropQueueFull, e.DropReason)
+	})
+
+	t.Run("returns a copy", func(t *testing.T) {
+		w, err := New(logger, Options{Directory: "logs", FS: newMemFS(), MaximumFileSize: 1})
+		require.NoError(t, err)
+		w.Write([]byte("entry\n"))
+
+		stats := w.Stats()
+		stats.DroppedByReason[DropTooLarge] = 10
+		require.Equal(t, int64(1), w.Stats().DroppedByReason[DropTooLarge])
+		require.NoError(t, w.Close())
+	})
+}
@@ -0,0 +1,278 @@
+package logrotate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// Encrypted files are a sequence of segments, a segment is written each time
+// the file is opened. A segment starts with encryptionMagic and a random salt,
+// from which the segment key is derived, followed by chunks of up to
+// maxChunkSize bytes of entries. Each chunk is a big endian uint32 length
+// followed by the chunk sealed with AES-256-GCM, its nonce is the index of the
+// chunk within the segment and a flag set for the last chunk, so that chunks
+// cannot be reordered, and truncated segments are detected.
+const (
+	encryptionMagic = "logrotate/aes-256-gcm/v1\n"
+	saltSize        = 16
+	maxChunkSize    = 64 << 10
+	// EncryptionKeySize is the size of Options.EncryptionKey.
+	EncryptionKeySize = 32
+)
+
+// segmentCipher returns the AEAD of a segment, with a key derived from key and salt.
+func segmentCipher(key, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk at index within its segment.
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedFile encrypts entries written to a File, see Options.EncryptionKey.
+type encryptedFile struct {
+	File
+	key []byte
+	// written receives the bytes written to the file, for its checksum.
+	written func(b []byte)
+
+	aead  cipher.AEAD
+	index uint64
+}
+
+func newEncryptedFile(f File, key []byte, written func(b []byte)) *encryptedFile {
+	return &encryptedFile{File: f, key: key, written: written}
+}
+
+func (e *encryptedFile) write(b []byte) error {
+	if _, err := e.File.Write(b); err != nil {
+		return err
+	}
+	e.written(b)
+	return nil
+}
+
+// startSegment writes the header of a new segment.
+func (e *encryptedFile) startSegment() error {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return errors.Wrap(err, "failed to generate salt")
+	}
+	aead, err := segmentCipher(e.key, salt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create cipher")
+	}
+	if err := e.write(append([]byte(encryptionMagic), salt...)); err != nil {
+		return err
+	}
+	e.aead, e.index = aead, 0
+	return nil
+}
+
+// writeChunk seals and writes a chunk of the current segment.
+func (e *encryptedFile) writeChunk(p []byte, last bool) error {
+	b := make([]byte, 4, 4+len(p)+e.aead.Overhead())
+	b = e.aead.Seal(b, chunkNonce(e.index, last), p, nil)
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	if err := e.write(b); err != nil {
+		return err
+	}
+	e.index++
+	return nil
+}
+
+// Write encrypts p into chunks. The segment is started on the first write,
+// and a new segment is started after a failure, which may have left a partial
+// chunk behind.
+func (e *encryptedFile) Write(p []byte) (int, error) {
+	if e.aead == nil {
+		if err := e.startSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if len(chunk) > maxChunkSize {
+			chunk = chunk[:maxChunkSize]
+		}
+		if err := e.writeChunk(chunk, false); err != nil {
+			e.aead = nil
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// Close writes the last chunk of the segment, if any, and closes the file.
+func (e *encryptedFile) Close() error {
+	var err error
+	if e.aead != nil {
+		err = e.writeChunk(nil, true)
+		e.aead = nil
+	}
+	if closeErr := e.File.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// DecryptReader returns a reader of the entries of a file written with
+// Options.EncryptionKey set to key. Entries are returned as they are
+// authenticated, when the file is incomplete, for example because the process
+// stopped without closing the Writer, or has been modified, the entries which
+// could be authenticated are returned, followed by ErrDamaged.
+func DecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, errors.Errorf("key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	return &decryptReader{
+		r:   bufio.NewReaderSize(r, 4+maxChunkSize+16),
+		key: key,
+	}, nil
+}
+
+type decryptReader struct {
+	r   *bufio.Reader
+	key []byte
+
+	aead    cipher.AEAD
+	index   uint64
+	damaged bool
+	// plain is the remainder of the last chunk read
+	plain []byte
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next reads the next chunk into plain, or a segment header.
+func (d *decryptReader) next() error {
+	if d.aead == nil {
+		return d.readHeader()
+	}
+	if b, _ := d.r.Peek(len(encryptionMagic)); bytes.Equal(b, []byte(encryptionMagic)) {
+		// The file was opened again before the segment got its last chunk.
+		d.damaged = true
+		return d.readHeader()
+	}
+
+	header, err := d.r.Peek(4)
+	if err == io.EOF && len(header) == 0 {
+		// The segment has no last chunk.
+		d.damaged = true
+		return d.end()
+	}
+	if err != nil {
+		return d.resync(err)
+	}
+	size := int(binary.BigEndian.Uint32(header))
+	if size < d.aead.Overhead() || size > maxChunkSize+d.aead.Overhead() {
+		return d.resync(nil)
+	}
+	b, err := d.r.Peek(4 + size)
+	if err != nil {
+		return d.resync(err)
+	}
+
+	last := false
+	plain, err := d.aead.Open(nil, chunkNonce(d.index, false), b[4:], nil)
+	if err != nil {
+		plain, err = d.aead.Open(nil, chunkNonce(d.index, true), b[4:], nil)
+		last = true
+	}
+	if err != nil {
+		return d.resync(nil)
+	}
+	d.r.Discard(4 + size)
+	d.plain = plain
+	d.index++
+	if last {
+		d.aead = nil
+	}
+	return nil
+}
+
+// readHeader reads the header of the next segment.
+func (d *decryptReader) readHeader() error {
+	header, err := d.r.Peek(len(encryptionMagic) + saltSize)
+	if err == io.EOF && len(header) == 0 {
+		return d.end()
+	}
+	if err != nil || !bytes.HasPrefix(header, []byte(encryptionMagic)) {
+		return d.resync(err)
+	}
+	aead, err := segmentCipher(d.key, header[len(encryptionMagic):])
+	if err != nil {
+		return err
+	}
+	d.r.Discard(len(header))
+	d.aead, d.index = aead, 0
+	return nil
+}
+
+// resync skips damaged data up to the header of the next segment, written
+// when the file was opened again, or after a failed write.
+func (d *decryptReader) resync(err error) error {
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	d.damaged = true
+	d.aead = nil
+
+	// The damaged data does not start with a valid header, skip at least its first byte.
+	d.r.Discard(1)
+	magic := []byte(encryptionMagic)
+	for {
+		b, err := d.r.Peek(d.r.Size())
+		if i := bytes.Index(b, magic); i >= 0 {
+			d.r.Discard(i)
+			return nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			if err == io.EOF {
+				return d.end()
+			}
+			return err
+		}
+		d.r.Discard(len(b) - len(magic) + 1)
+	}
+}
+
+// end returns the error at the end of the file.
+func (d *decryptReader) end() error {
+	if d.damaged {
+		return ErrDamaged
+	}
+	return io.EOF
+}
@@ -0,0 +1,151 @@
+package logrotate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter_EncryptionKey(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	key := bytes.Repeat([]byte{7}, EncryptionKeySize)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	decrypt := func(t *testing.T, b []byte) (string, error) {
+		r, err := DecryptReader(bytes.NewReader(b), key)
+		require.NoError(t, err)
+		plain, err := ioutil.ReadAll(r)
+		return string(plain), err
+	}
+
+	t.Run("never writes entries in plaintext", func(t *testing.T) {
+		dir := newDir(t)
+		var sum string
+		w, err := New(logger, Options{
+			Directory:     dir,
+			EncryptionKey: key,
+			Checksum:      true,
+			FileNameFunc:  func() string { return "app.log.enc" },
+			OnFileClose:   func(e FileCloseEvent) { sum = e.SHA256 },
+		})
+		require.NoError(t, err)
+
+		// Entries spanning several chunks.
+		large := strings.Repeat("x", 3*maxChunkSize) + "\n"
+		for _, msg := range []string{"secret\n", large, "another secret\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log.enc"))
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "secret")
+
+		plain, err := decrypt(t, b)
+		require.NoError(t, err)
+		require.Equal(t, "secret\n"+large+"another secret\n", plain)
+
+		h := sha256.Sum256(b)
+		require.Equal(t, hex.EncodeToString(h[:]), sum, "checksum must be of the encrypted file")
+	})
+
+	t.Run("appends a segment when the file is opened again", func(t *testing.T) {
+		dir := newDir(t)
+		opts := Options{
+			Directory:     dir,
+			EncryptionKey: key,
+			FileNameFunc:  func() string { return "app.log.enc" },
+		}
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Reopen())
+		_, err = w.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log.enc"))
+		require.NoError(t, err)
+		require.Equal(t, 2, bytes.Count(b, []byte(encryptionMagic)))
+		plain, err := decrypt(t, b)
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\n", plain)
+	})
+
+	t.Run("reads damaged files", func(t *testing.T) {
+		var buf bytes.Buffer
+		f := newEncryptedFile(nopFile{&buf}, key, func([]byte) {})
+		_, err := f.Write([]byte("first\n"))
+		require.NoError(t, err)
+		_, err = f.Write([]byte("second\n"))
+		require.NoError(t, err)
+		// The process stops without closing the file.
+		complete := buf.Len()
+		truncated := append([]byte(nil), buf.Bytes()[:complete-3]...)
+
+		plain, err := decrypt(t, truncated)
+		require.True(t, errors.Is(err, ErrDamaged))
+		require.Equal(t, "first\n", plain, "complete chunks must be returned")
+
+		// The file is opened again after the crash.
+		f = newEncryptedFile(nopFile{&buf}, key, func([]byte) {})
+		_, err = f.Write([]byte("third\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		plain, err = decrypt(t, buf.Bytes())
+		require.True(t, errors.Is(err, ErrDamaged))
+		require.Equal(t, "first\nsecond\nthird\n", plain)
+
+		damaged := append(append([]byte(nil), truncated...), buf.Bytes()[complete:]...)
+		plain, err = decrypt(t, damaged)
+		require.True(t, errors.Is(err, ErrDamaged))
+		require.Equal(t, "first\nthird\n", plain, "segments after damaged data must be returned")
+	})
+
+	t.Run("rejects other keys", func(t *testing.T) {
+		var buf bytes.Buffer
+		f := newEncryptedFile(nopFile{&buf}, key, func([]byte) {})
+		_, err := f.Write([]byte("secret\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		r, err := DecryptReader(&buf, bytes.Repeat([]byte{8}, EncryptionKeySize))
+		require.NoError(t, err)
+		plain, err := ioutil.ReadAll(r)
+		require.True(t, errors.Is(err, ErrDamaged))
+		require.Empty(t, plain)
+
+		_, err = DecryptReader(&buf, key[1:])
+		require.Error(t, err)
+	})
+
+	t.Run("rejects keys of the wrong size", func(t *testing.T) {
+		opts := Options{Directory: "logs", EncryptionKey: key[1:]}
+		require.Error(t, opts.Validate())
+	})
+}
+
+// nopFile is a File writing into a buffer.
+type nopFile struct {
+	*bytes.Buffer
+}
+
+func (nopFile) Close() error               { return nil }
+func (nopFile) Sync() error                { return nil }
+func (nopFile) Stat() (os.FileInfo, error) { return nil, nil }
@@ -0,0 +1,69 @@
+package logrotate
+
+import (
+	"fmt"
+	"time"
+)
+
+// EntrySizePolicy defines how the Writer handles entries exceeding Options.MaxEntrySize.
+type EntrySizePolicy int
+
+const (
+	// EntrySizeReject rejects entries exceeding MaxEntrySize, Write returns ErrEntryTooLarge.
+	EntrySizeReject EntrySizePolicy = iota
+
+	// EntrySizeTruncate writes the first bytes of entries exceeding MaxEntrySize,
+	// followed by a marker such as "... [truncated 1048576 bytes]", so that
+	// entries, including the marker, do not exceed MaxEntrySize.
+	EntrySizeTruncate
+
+	// EntrySizeChunk splits entries exceeding MaxEntrySize into entries of
+	// MaxEntrySize bytes, the last one holding the remainder. Chunks are written
+	// in order, but entries written concurrently may be written in between.
+	EntrySizeChunk
+)
+
+const (
+	// truncatedMarker ends entries truncated by EntrySizeTruncate.
+	truncatedMarker = "... [truncated %d bytes]"
+	// minimumTruncatedEntrySize is the smallest MaxEntrySize supported by
+	// EntrySizeTruncate, which must fit the marker.
+	minimumTruncatedEntrySize = 64
+)
+
+// truncatedEntry returns a pooled buffer holding p truncated to size bytes,
+// including a marker counting the bytes removed. The trailing newline of p, if
+// any, is kept.
+func truncatedEntry(p []byte, size int64) []byte {
+	newline := ""
+	if p[len(p)-1] == '\n' {
+		newline = "\n"
+	}
+	// The number of bytes removed has at most as many digits as the size of p.
+	keep := size - int64(len(fmt.Sprintf(truncatedMarker, len(p)))+len(newline))
+	marker := fmt.Sprintf(truncatedMarker, int64(len(p)-len(newline))-keep) + newline
+
+	b := getBuffer(int(keep) + len(marker))
+	copy(b, p[:keep])
+	copy(b[keep:], marker)
+	return b
+}
+
+// writeChunks queues p as entries of up to MaxEntrySize bytes, see EntrySizeChunk.
+// The number of bytes queued is returned.
+func (w *Writer) writeChunks(p []byte, ts time.Time, a admission) (int, error) {
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if int64(len(chunk)) > a.maxEntrySize {
+			chunk = chunk[:a.maxEntrySize]
+		}
+		b := getBuffer(len(chunk))
+		copy(b, chunk)
+		if err := w.enqueue(entry{b: b, ts: ts}, a); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
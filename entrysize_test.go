@@ -0,0 +1,97 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter_MaxEntrySize(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newWriter := func(t *testing.T, fs *memFS, policy EntrySizePolicy) *Writer {
+		w, err := New(logger, Options{
+			Directory:       "logs",
+			FS:              fs,
+			MaxEntrySize:    100,
+			EntrySizePolicy: policy,
+			FileNameFunc:    func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+		return w
+	}
+
+	large := strings.Repeat("x", 249) + "\n"
+
+	t.Run("rejects large entries", func(t *testing.T) {
+		fs := newMemFS()
+		w := newWriter(t, fs, EntrySizeReject)
+		_, err := w.Write([]byte(large))
+		require.True(t, errors.Is(err, ErrEntryTooLarge))
+		_, err = w.Write([]byte("small\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, "small\n", fs.contents(filepath.Join("logs", "app.log")))
+		require.Equal(t, int64(1), w.Stats().EntriesDropped)
+	})
+
+	t.Run("truncates large entries", func(t *testing.T) {
+		fs := newMemFS()
+		w := newWriter(t, fs, EntrySizeTruncate)
+		n, err := w.Write([]byte(large))
+		require.NoError(t, err)
+		require.Equal(t, len(large), n)
+		_, err = w.Write([]byte(strings.Repeat("y", 200)))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		contents := fs.contents(filepath.Join("logs", "app.log"))
+		lines := strings.SplitAfter(contents, "\n")
+		require.Len(t, lines, 2)
+		require.Len(t, lines[0], 100)
+		require.Equal(t, strings.Repeat("x", 74)+"... [truncated 175 bytes]\n", lines[0])
+		require.Len(t, lines[1], 100)
+		require.True(t, strings.HasSuffix(lines[1], "y... [truncated 125 bytes]"), lines[1])
+	})
+
+	t.Run("chunks large entries", func(t *testing.T) {
+		fs := newMemFS()
+		files := 0
+		w, err := New(logger, Options{
+			Directory:       "logs",
+			FS:              fs,
+			MaxEntrySize:    100,
+			EntrySizePolicy: EntrySizeChunk,
+			// Chunks must not be rejected by MaximumFileSize, and are rotated between.
+			MaximumFileSize: 150,
+			FileNameFunc: func() string {
+				files++
+				return fmt.Sprintf("%d.log", files)
+			},
+		})
+		require.NoError(t, err)
+		n, err := w.Write([]byte(large))
+		require.NoError(t, err)
+		require.Equal(t, len(large), n)
+		require.NoError(t, w.Close())
+
+		var chunks []string
+		for i := 1; i <= files; i++ {
+			chunks = append(chunks, fs.contents(filepath.Join("logs", fmt.Sprintf("%d.log", i))))
+		}
+		require.Equal(t, []string{large[:100], large[100:]}, chunks)
+	})
+
+	t.Run("rejects maximum sizes too small for the marker", func(t *testing.T) {
+		opts := Options{Directory: "logs", MaxEntrySize: 10, EntrySizePolicy: EntrySizeTruncate}
+		require.Error(t, opts.Validate())
+		opts.EntrySizePolicy = EntrySizeChunk
+		require.NoError(t, opts.Validate())
+	})
+}
@@ -0,0 +1,85 @@
+package logrotate
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrClosed is returned when writing to a Writer which has been closed.
+	ErrClosed = errors.New("logrotate: writer is closed")
+
+	// ErrQueueFull is returned by Write in NonBlocking mode when the queue is full.
+	ErrQueueFull = errors.New("logrotate: queue is full")
+
+	// ErrEntryTooLarge is returned when an entry exceeds MaximumFileSize,
+	// or MaxEntrySize with EntrySizeReject.
+	ErrEntryTooLarge = errors.New("logrotate: entry is too large")
+
+	// ErrDiskFull is reported when an entry could not be written because the disk is full.
+	ErrDiskFull = errors.New("logrotate: disk is full")
+
+	// ErrFailed is returned when writing to a Writer which stopped accepting writes,
+	// see DiskFullFail.
+	ErrFailed = errors.New("logrotate: writer has failed")
+
+	// ErrDamaged is returned by DecryptReader when an encrypted file is incomplete,
+	// for example because the process stopped without closing the Writer, or has been modified.
+	ErrDamaged = errors.New("logrotate: encrypted file is damaged")
+
+	// ErrCorrupted is returned by RecordReader when it skipped corrupted records.
+	ErrCorrupted = errors.New("logrotate: record is corrupted")
+
+	// ErrLocked is returned by New when another process holds the lock of Options.Lock.
+	ErrLocked = errors.New("logrotate: directory is locked by another process")
+
+	// ErrStalled is returned by Mirror.Write for a target which did not accept
+	// an entry within MirrorOptions.WriteTimeout.
+	ErrStalled = errors.New("logrotate: mirror target is stalled")
+
+	// ErrNotInitialized is returned by the package level functions, such as Write,
+	// before Init is called.
+	ErrNotInitialized = errors.New("logrotate: default writer is not initialized")
+)
+
+// sentinelError annotates an underlying error with one of the sentinel errors,
+// so that both can be matched with errors.Is.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func withSentinel(sentinel, err error) error {
+	return &sentinelError{sentinel: sentinel, err: err}
+}
+
+func (e *sentinelError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.err
+}
+
+// errorsBufferSize is the number of errors buffered by Writer.Errors.
+const errorsBufferSize = 64
+
+// Errors returns a channel of errors encountered by the background writer,
+// such as failures to create, write or sync files and dropped entries.
+// The channel is buffered, when it is full, further errors are discarded
+// rather than blocking the writer.
+// The channel is closed once the Writer has been closed.
+func (w *Writer) Errors() <-chan error {
+	return w.errs
+}
+
+// report sends err to the Errors channel without blocking.
+func (w *Writer) report(err error) {
+	w.metrics.IncCounter(MetricErrors, 1)
+	w.emit(Event{Type: EventError, Err: err})
+	select {
+	case w.errs <- err:
+	default:
+	}
+}
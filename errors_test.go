@@ -0,0 +1,88 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_Errors(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+	})
+	require.NoError(t, err)
+
+	writes := errorsBufferSize * 2
+	for i := 0; i < writes; i++ {
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err)
+	}
+	require.Error(t, w.Close(), "must not block on a full errors channel")
+
+	var errs []error
+	for err := range w.Errors() {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, errorsBufferSize, "must buffer errors up to the channel capacity")
+}
+
+func TestWriter_SentinelErrors(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("entry too large", func(t *testing.T) {
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 4,
+		})
+		require.NoError(t, err)
+		defer w.Close()
+
+		_, err = w.Write([]byte("message"))
+		require.True(t, errors.Is(err, ErrEntryTooLarge))
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		unblock := make(chan struct{})
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+			Fallback:     blockingWriter(unblock),
+			NonBlocking:  true,
+		})
+		require.NoError(t, err)
+
+		for err == nil {
+			_, err = w.Write([]byte("message"))
+		}
+		require.True(t, errors.Is(err, ErrQueueFull))
+
+		close(unblock)
+		w.Close()
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		w, err := New(logger, Options{
+			Directory: dir,
+		})
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		_, err = w.Write([]byte("message"))
+		require.True(t, errors.Is(err, ErrClosed))
+	})
+}
@@ -0,0 +1,98 @@
+package logrotate
+
+import "time"
+
+// eventsBufferSize is the number of events buffered by Writer.Events.
+const eventsBufferSize = 64
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EventFileOpened is emitted when a file is opened, Path is the file.
+	EventFileOpened EventType = iota
+	// EventRotated is emitted when a file is rotated, Path is the closed file
+	// and Rotation describes the rotation.
+	EventRotated
+	// EventUploaded is emitted when a file and its sidecars have been uploaded,
+	// Path is the file.
+	EventUploaded
+	// EventPruned is emitted when a Manager removed a file to keep within
+	// MaximumTotalSize, Path is the file.
+	EventPruned
+	// EventError is emitted for each error reported on Errors, Err is the error.
+	EventError
+	// EventDropped is emitted when an entry is not written, DropReason and Err
+	// are the reason. Entries discarded by Filter and RateLimit are only counted,
+	// see Stats.DroppedByReason.
+	EventDropped
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventFileOpened:
+		return "file_opened"
+	case EventRotated:
+		return "rotated"
+	case EventUploaded:
+		return "uploaded"
+	case EventPruned:
+		return "pruned"
+	case EventError:
+		return "error"
+	case EventDropped:
+		return "dropped"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something which happened to a Writer, see Events.
+type Event struct {
+	Type EventType
+	// Time is the time the event happened.
+	Time time.Time
+	// Path is the file the event is about, empty for EventError and EventDropped.
+	Path string
+	// Rotation describes the rotation of an EventRotated.
+	Rotation *RotationEvent
+	// Err is the error of an EventError, or describes why the entry of an
+	// EventDropped was dropped.
+	Err error
+	// DropReason is why the entry of an EventDropped was dropped.
+	DropReason DropReason
+}
+
+// Events returns a channel of the events of the Writer, such as files being
+// opened, rotated and uploaded, errors and dropped entries, so that a single
+// consumer can follow the Writer without registering callbacks.
+// Like Errors, the channel is buffered, when it is full further events are
+// discarded rather than blocking the writer.
+// The channel is closed once the Writer has been closed.
+func (w *Writer) Events() <-chan Event {
+	return w.events
+}
+
+// emit sends e to the Events channel without blocking.
+func (w *Writer) emit(e Event) {
+	e.Time = w.now()
+
+	w.eventsMu.RLock()
+	defer w.eventsMu.RUnlock()
+	if w.eventsClosed {
+		return
+	}
+	select {
+	case w.events <- e:
+	default:
+	}
+}
+
+// closeEvents closes the Events channel, events emitted afterwards, for
+// example by a Manager removing files, are discarded.
+func (w *Writer) closeEvents() {
+	w.eventsMu.Lock()
+	defer w.eventsMu.Unlock()
+	w.eventsClosed = true
+	close(w.events)
+}
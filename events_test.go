@@ -0,0 +1,113 @@
+package logrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_Events(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("emits lifecycle events", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		i := 0
+		w, err := New(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				i++
+				return fmt.Sprintf("%d.log", i)
+			},
+			MaximumFileSize: 10,
+			Uploader:        uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error { return nil }),
+		})
+		require.NoError(t, err)
+
+		for _, message := range []string{"entry 1\n", "entry 2\n", "too large entry\n"} {
+			w.Write([]byte(message))
+		}
+		require.NoError(t, w.Close())
+
+		var (
+			types []EventType
+			paths = make(map[EventType][]string)
+		)
+		for e := range w.Events() {
+			require.False(t, e.Time.IsZero())
+			types = append(types, e.Type)
+			paths[e.Type] = append(paths[e.Type], filepath.Base(e.Path))
+			switch e.Type {
+			case EventRotated:
+				require.Equal(t, filepath.Join(dir, "1.log"), e.Rotation.Path)
+				require.Equal(t, filepath.Join(dir, "2.log"), e.Rotation.NextPath)
+				require.Equal(t, RotationSize, e.Rotation.Reason)
+			case EventDropped:
+				require.True(t, errors.Is(e.Err, ErrEntryTooLarge))
+			}
+		}
+		require.Contains(t, types, EventDropped)
+		require.Equal(t, []string{"1.log", "2.log"}, paths[EventFileOpened])
+		require.Equal(t, []string{"1.log"}, paths[EventRotated])
+		require.ElementsMatch(t, []string{"1.log", "2.log"}, paths[EventUploaded])
+	})
+
+	t.Run("emits errors", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		w.Close()
+
+		var types []EventType
+		for e := range w.Events() {
+			require.Error(t, e.Err)
+			types = append(types, e.Type)
+		}
+		require.Contains(t, types, EventError)
+		require.Contains(t, types, EventDropped)
+	})
+
+	t.Run("emits files pruned by a manager", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		for _, name := range []string{"1.log", "2.log"} {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0644))
+		}
+
+		m, err := NewManager(logger, ManagerOptions{
+			Writers:          map[string]Options{"app": {Directory: dir}},
+			MaximumTotalSize: 15,
+			CleanupInterval:  time.Hour,
+		})
+		require.NoError(t, err)
+		require.NoError(t, m.Cleanup())
+
+		e := <-m.Writer("app").Events()
+		require.Equal(t, EventPruned, e.Type)
+		require.Equal(t, filepath.Join(dir, "1.log"), e.Path)
+
+		require.NoError(t, m.Close())
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "3.log"), []byte("0123456789"), 0644))
+		require.NoError(t, m.Cleanup(), "must not emit events once closed")
+	})
+}
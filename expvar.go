@@ -0,0 +1,112 @@
+package logrotate
+
+import (
+	"expvar"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// expvarStats lists the variables published for ExpvarPrefix.
+var expvarStats = []struct {
+	name  string
+	value func(Stats) interface{}
+}{
+	{"bytes_written", func(s Stats) interface{} { return s.BytesWritten }},
+	{"entries_written", func(s Stats) interface{} { return s.EntriesWritten }},
+	{"entries_dropped", func(s Stats) interface{} { return s.EntriesDropped }},
+	{"entries_dropped_by_reason", func(s Stats) interface{} {
+		dropped := make(map[string]int64, len(s.DroppedByReason))
+		for reason, n := range s.DroppedByReason {
+			dropped[reason.String()] = n
+		}
+		return dropped
+	}},
+	{"entries_filtered", func(s Stats) interface{} { return s.EntriesFiltered }},
+	{"entries_rate_limited", func(s Stats) interface{} { return s.EntriesRateLimited }},
+	{"rotations", func(s Stats) interface{} { return s.Rotations }},
+	{"current_file_size", func(s Stats) interface{} { return s.CurrentFileSize }},
+	{"current_file_age_seconds", func(s Stats) interface{} { return s.CurrentFileAge.Seconds() }},
+	{"queue_depth", func(s Stats) interface{} { return s.QueueDepth }},
+	{"queue_high_watermark", func(s Stats) interface{} { return s.QueueHighWatermark }},
+}
+
+// expvarWriters holds the Writer reporting on each prefix published, since
+// expvar does not support removing variables, a prefix is reused by the next
+// Writer once the previous one has been closed.
+var expvarWriters = struct {
+	sync.Mutex
+	prefixes map[string]*expvarWriter
+}{prefixes: map[string]*expvarWriter{}}
+
+// expvarWriter is the Writer reporting on the variables of a prefix.
+type expvarWriter struct {
+	mu sync.Mutex
+	// w is the Writer reporting on the variables, nil once it is closed
+	w *Writer
+	// final is the Stats of the last Writer, reported once it is closed
+	final Stats
+}
+
+func (e *expvarWriter) stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.w == nil {
+		return e.final
+	}
+	return e.w.Stats()
+}
+
+// publishExpvars publishes the Writer's Stats via expvar, as variables
+// evaluated whenever expvar is read, for example from /debug/vars.
+// expvar does not support removing variables, they remain published and
+// report the final Stats once the Writer is closed, until another Writer
+// with the same prefix is created, see unpublishExpvars.
+// Since each variable is evaluated separately, they do not reset
+// queue_high_watermark, which is the largest queue depth since the previous
+// call to Stats, or since the Writer was created.
+func (w *Writer) publishExpvars(prefix string) error {
+	expvarWriters.Lock()
+	defer expvarWriters.Unlock()
+
+	if e, ok := expvarWriters.prefixes[prefix]; ok {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.w != nil {
+			return errors.Errorf("expvar prefix %s is already in use", prefix)
+		}
+		e.w = w
+		w.expvar = e
+		return nil
+	}
+
+	for _, stat := range expvarStats {
+		if expvar.Get(prefix+"."+stat.name) != nil {
+			return errors.Errorf("expvar prefix %s is already in use", prefix)
+		}
+	}
+
+	e := &expvarWriter{w: w}
+	for _, stat := range expvarStats {
+		value := stat.value
+		expvar.Publish(prefix+"."+stat.name, expvar.Func(func() interface{} {
+			return value(e.stats())
+		}))
+	}
+	expvarWriters.prefixes[prefix] = e
+	w.expvar = e
+	return nil
+}
+
+// unpublishExpvars releases the prefix of the Writer, the variables keep
+// reporting its final Stats so that the closed Writer is not kept reachable.
+func (w *Writer) unpublishExpvars() {
+	if w.expvar == nil {
+		return
+	}
+	final := w.Stats()
+
+	w.expvar.mu.Lock()
+	defer w.expvar.mu.Unlock()
+	w.expvar.final = final
+	w.expvar.w = nil
+}
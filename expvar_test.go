@@ -0,0 +1,88 @@
+package logrotate
+
+import (
+	"expvar"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_Expvar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	prefix := "logrotate.test." + filepath.Base(dir)
+
+	w, err := New(logger, Options{
+		Directory:    dir,
+		ExpvarPrefix: prefix,
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("message\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, "8", expvar.Get(prefix+".bytes_written").String())
+	require.Equal(t, "1", expvar.Get(prefix+".entries_written").String())
+	require.Equal(t, "0", expvar.Get(prefix+".entries_dropped").String())
+	require.Equal(t, "8", expvar.Get(prefix+".current_file_size").String())
+	require.Equal(t, "0", expvar.Get(prefix+".queue_depth").String())
+
+	_, err = New(logger, Options{
+		Directory:    dir,
+		ExpvarPrefix: prefix,
+	})
+	require.Error(t, err, "prefix must not be reused")
+
+	require.NoError(t, w.Close())
+	require.Equal(t, "8", expvar.Get(prefix+".bytes_written").String(), "final stats are reported once closed")
+	require.Nil(t, expvarWriters.prefixes[prefix].w, "closed writer must not be reachable")
+
+	w, err = New(logger, Options{
+		Directory:    dir,
+		ExpvarPrefix: prefix,
+	})
+	require.NoError(t, err, "prefix is released on close")
+	defer w.Close()
+	require.Equal(t, "0", expvar.Get(prefix+".bytes_written").String())
+}
+
+func TestWriter_ExpvarQueueHighWatermark(t *testing.T) {
+	filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+	w, err := New(log.New(os.Stderr, "", log.LstdFlags), Options{
+		Directory:    "logs",
+		FS:           newMemFS(),
+		QueueSize:    2,
+		ExpvarPrefix: "logrotate.test.watermark",
+		Filter: func(b []byte) bool {
+			select {
+			case filtering <- struct{}{}:
+				<-unblock
+			default:
+			}
+			return true
+		},
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("entry\n"))
+	require.NoError(t, err)
+	<-filtering
+	for i := 0; i < 2; i++ {
+		_, err := w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+	}
+	close(unblock)
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, "0", expvar.Get("logrotate.test.watermark.queue_depth").String())
+	require.Equal(t, "2", expvar.Get("logrotate.test.watermark.queue_high_watermark").String())
+	require.Equal(t, 2, w.Stats().QueueHighWatermark)
+}
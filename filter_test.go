@@ -0,0 +1,43 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_Filter(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var tee bytes.Buffer
+	w, err := NewWithOptions(logger,
+		WithDirectory(dir),
+		WithFileNameFunc(func() string { return "app.log" }),
+		WithTeeTo(&tee),
+		WithFilter(func(b []byte) bool { return !bytes.Contains(b, []byte("GET /healthz")) }),
+		WithTransform(bytes.ToUpper),
+	)
+	require.NoError(t, err)
+	for _, msg := range []string{"GET /orders\n", "GET /healthz\n", "POST /orders\n", "GET /healthz\n"} {
+		_, err := w.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	require.Equal(t, "GET /ORDERS\nPOST /ORDERS\n", string(b))
+	require.Equal(t, "GET /ORDERS\nPOST /ORDERS\n", tee.String())
+
+	stats := w.Stats()
+	require.Equal(t, int64(2), stats.EntriesWritten)
+	require.Equal(t, int64(2), stats.EntriesFiltered)
+	require.Equal(t, int64(0), stats.EntriesDropped)
+}
@@ -0,0 +1,73 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// Flush blocks until all entries accepted so far have been written to the current file,
+// and to Options.TeeTo if set.
+// Flush does not synchronize the file to disk, see Sync.
+func (w *Writer) Flush() error {
+	return w.control(func() error {
+		defer w.flushTees()
+		return w.flush(false)
+	})
+}
+
+// Sync blocks until all entries accepted so far have been written to the current file
+// and the file has been synchronized to disk.
+//
+// Together with Write, Sync makes Writer a zapcore.WriteSyncer, so it can be
+// passed to zapcore.NewCore directly and logger.Sync() provides real durability:
+//
+//	core := zapcore.NewCore(zapcore.NewJSONEncoder(cfg), w, zap.InfoLevel)
+//
+// Writer is safe for concurrent use, wrapping it in zapcore.Lock is not needed.
+func (w *Writer) Sync() error {
+	return w.control(func() error {
+		defer w.flushTees()
+		return w.flush(true)
+	})
+}
+
+// Drain blocks until all entries accepted so far have been written and
+// synchronized to disk, like Sync, or until ctx is done.
+// Drain does not close the Writer, it is intended for checkpoints such as
+// finishing a batch of writes before processing the log directory.
+// If ctx is done first, Drain returns ctx.Err() and the entries
+// continue to be written in the background.
+func (w *Writer) Drain(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Sync()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush writes buffered data into the current file, optionally synchronizing it to disk.
+func (w *Writer) flush(sync bool) error {
+	if w.f == nil {
+		return nil
+	}
+
+	if err := w.bw.Flush(); err != nil {
+		w.bw.Reset(w.f)
+		w.invalidateChecksum()
+		return errors.Wrap(err, "failed to flush buffered writer")
+	}
+
+	if sync {
+		if err := w.f.Sync(); err != nil {
+			return errors.Wrap(err, "failed to sync current log file")
+		}
+	}
+
+	return nil
+}
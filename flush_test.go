@@ -0,0 +1,96 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_Flush(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	for name, flush := range map[string]func(w *Writer) error{
+		"flush": (*Writer).Flush,
+		"sync":  (*Writer).Sync,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			w, err := New(logger, Options{
+				Directory:    dir,
+				FileNameFunc: func() string { return "file.log" },
+			})
+			require.NoError(t, err)
+
+			message := []byte("message")
+			_, err = w.Write(message)
+			require.NoError(t, err)
+			require.NoError(t, flush(w))
+
+			written, err := ioutil.ReadFile(filepath.Join(dir, "file.log"))
+			require.NoError(t, err)
+			require.Equal(t, message, written, "must write entries before returning")
+
+			require.NoError(t, w.Close())
+			require.True(t, errors.Is(flush(w), ErrClosed), "must fail once closed")
+		})
+	}
+}
+
+func TestWriter_Drain(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("writes all entries", func(t *testing.T) {
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "file.log" },
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			_, err = w.Write([]byte("a"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Drain(context.Background()))
+
+		info, err := os.Stat(filepath.Join(dir, "file.log"))
+		require.NoError(t, err)
+		require.Equal(t, int64(100), info.Size())
+
+		_, err = w.Write([]byte("a"))
+		require.NoError(t, err, "must keep accepting writes")
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("gives up when context is done", func(t *testing.T) {
+		unblock := make(chan struct{})
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+			Fallback:     blockingWriter(unblock),
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.Equal(t, context.DeadlineExceeded, w.Drain(ctx))
+
+		close(unblock)
+		w.Close()
+	})
+}
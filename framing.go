@@ -0,0 +1,184 @@
+package logrotate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// Framed files are a sequence of records, each a big endian uint32 length,
+// followed by the entry and the CRC-32C of the length and the entry, as a big
+// endian uint32, see Options.Framed.
+const (
+	recordHeaderSize  = 4
+	recordTrailerSize = 4
+	recordOverhead    = recordHeaderSize + recordTrailerSize
+	// maximumRecordSize is the size of the largest entry a record can hold.
+	maximumRecordSize int64 = 1<<32 - 1
+	// recordReaderSize is the buffer size of RecordReader. Records up to this
+	// size are verified without being copied, and only such records are found
+	// when skipping corrupted data.
+	recordReaderSize = 64 << 10
+)
+
+// DefaultMaximumRecordSize is the size of the largest entry read by the
+// RecordReader of NewRecordReader, see NewRecordReaderSize.
+const DefaultMaximumRecordSize = 64 << 20
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// frame returns b as a record when the Writer is framed, b otherwise.
+func (w *Writer) frame(b []byte) []byte {
+	if !w.framed {
+		return b
+	}
+	record := make([]byte, recordHeaderSize, len(b)+recordOverhead)
+	binary.BigEndian.PutUint32(record, uint32(len(b)))
+	record = append(record, b...)
+	var trailer [recordTrailerSize]byte
+	binary.BigEndian.PutUint32(trailer[:], crc32.Checksum(record, castagnoli))
+	return append(record, trailer[:]...)
+}
+
+// validRecord returns whether the checksum of record matches its contents.
+func validRecord(record []byte) bool {
+	n := len(record) - recordTrailerSize
+	return crc32.Checksum(record[:n], castagnoli) == binary.BigEndian.Uint32(record[n:])
+}
+
+// RecordReader reads the records of a file written with Options.Framed.
+// Records are verified against their checksum, corrupted data, for example
+// left by a write torn by a power loss, is skipped up to the next valid record.
+type RecordReader struct {
+	r       *bufio.Reader
+	buf     []byte
+	maximum int64
+}
+
+// NewRecordReader returns a RecordReader reading records from r, of entries up
+// to DefaultMaximumRecordSize bytes.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return NewRecordReaderSize(r, DefaultMaximumRecordSize)
+}
+
+// NewRecordReaderSize returns a RecordReader reading records from r, of entries
+// up to maximum bytes. Records claiming a larger length are corrupted, so that a
+// damaged length does not make the reader allocate up to 4 GiB.
+func NewRecordReaderSize(r io.Reader, maximum int) *RecordReader {
+	return &RecordReader{r: bufio.NewReaderSize(r, recordReaderSize), maximum: int64(maximum)}
+}
+
+// header peeks the size of the next record, including its length and checksum.
+func (r *RecordReader) header() (int64, error) {
+	header, err := r.r.Peek(recordHeaderSize)
+	if err == io.EOF && len(header) == 0 {
+		return 0, io.EOF
+	}
+	if err == io.EOF {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "truncated record header")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint32(header)) + recordOverhead, nil
+}
+
+// Next returns the next record. The record is only valid until the next call
+// to Next or Skip. Next returns io.EOF once all records have been read, and
+// io.ErrUnexpectedEOF when the file ends within a record, for example because
+// it is still being written to. When Next skipped corrupted data, it returns
+// an error wrapping ErrCorrupted, the following call returns the next valid record.
+func (r *RecordReader) Next() ([]byte, error) {
+	size, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	if size-recordOverhead > r.maximum {
+		return nil, r.resync(false)
+	}
+
+	if size <= int64(r.r.Size()) {
+		b, err := r.r.Peek(int(size))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if int64(len(b)) == size && validRecord(b) {
+			r.r.Discard(len(b))
+			return b[recordHeaderSize : size-recordTrailerSize], nil
+		}
+		return nil, r.resync(int64(len(b)) < size)
+	}
+
+	if int64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	b := r.buf[:size]
+	n, err := io.ReadFull(r.r, b)
+	if err == nil && validRecord(b) {
+		return b[recordHeaderSize : size-recordTrailerSize], nil
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	// The data read may hold valid records, for example appended after a torn
+	// write when the file was opened again, it is read again.
+	r.buf = nil
+	r.r = bufio.NewReaderSize(io.MultiReader(bytes.NewReader(b[:n]), r.r), recordReaderSize)
+	return nil, r.resync(err != nil)
+}
+
+// resync skips corrupted data, starting with the record at the current
+// position, up to the next valid record, or the end of the file. truncated
+// is whether the corrupted record was cut short by the end of the file.
+func (r *RecordReader) resync(truncated bool) error {
+	r.r.Discard(1)
+	skipped := 1
+	for {
+		if size, err := r.header(); err == nil && size <= int64(r.r.Size()) {
+			if b, _ := r.r.Peek(int(size)); int64(len(b)) == size && validRecord(b) {
+				return errors.Wrapf(ErrCorrupted, "skipped %d bytes", skipped)
+			}
+		} else if err != nil && errors.Cause(err) != io.ErrUnexpectedEOF {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		r.r.Discard(1)
+		skipped++
+	}
+
+	if truncated {
+		return errors.Wrap(io.ErrUnexpectedEOF, "truncated record")
+	}
+	return errors.Wrapf(ErrCorrupted, "skipped %d bytes", skipped)
+}
+
+// Skip skips the next record without returning it, see Next. Skip does not
+// verify the checksum of the record, corrupted data is detected by Next, except
+// for records exceeding the maximum size of the RecordReader.
+func (r *RecordReader) Skip() error {
+	size, err := r.header()
+	if err != nil {
+		return err
+	}
+	if size-recordOverhead > r.maximum {
+		return r.resync(false)
+	}
+	if _, err := io.CopyN(ioutil.Discard, r.r, size); err != nil {
+		return errors.Wrap(noEOF(err), "truncated record")
+	}
+	return nil
+}
+
+// noEOF converts io.EOF, returned when a record is cut short, to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package logrotate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter_Framed(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	readAll := func(t *testing.T, contents string) (records []string, corrupted int) {
+		r := NewRecordReader(strings.NewReader(contents))
+		for {
+			b, err := r.Next()
+			if err == io.EOF {
+				return records, corrupted
+			}
+			if errors.Is(err, ErrCorrupted) {
+				corrupted++
+				continue
+			}
+			require.NoError(t, err)
+			records = append(records, string(b))
+		}
+	}
+
+	readRecords := func(t *testing.T, contents string) []string {
+		r := NewRecordReader(strings.NewReader(contents))
+		var records []string
+		for {
+			b, err := r.Next()
+			if err == io.EOF {
+				return records
+			}
+			require.NoError(t, err)
+			records = append(records, string(b))
+		}
+	}
+
+	t.Run("writes entries as records", func(t *testing.T) {
+		fs := newMemFS()
+		w, err := New(logger, Options{
+			Directory:    "logs",
+			FS:           fs,
+			Framed:       true,
+			FileNameFunc: func() string { return "app.bin" },
+			Header: func(w io.Writer) error {
+				_, err := io.WriteString(w, "header")
+				return err
+			},
+		})
+		require.NoError(t, err)
+
+		entries := []string{"first\nline", "\x00\x01\x02", "", strings.Repeat("x", 10000)}
+		for _, entry := range entries {
+			_, err := w.Write([]byte(entry))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		contents := fs.contents(filepath.Join("logs", "app.bin"))
+		require.Equal(t, "\x00\x00\x00\x06header", contents[:10])
+		require.Equal(t, "\x00\x00\x00\x0afirst\nline", contents[14:28])
+		require.Equal(t, append([]string{"header"}, entries...), readRecords(t, contents))
+		require.Equal(t, int64(len(contents)), w.Stats().BytesWritten+recordOverhead+int64(len("header")), "header is not counted")
+	})
+
+	t.Run("accounts for the length and checksum in MaximumFileSize", func(t *testing.T) {
+		fs := newMemFS()
+		w, err := New(logger, Options{
+			Directory:       "logs",
+			FS:              fs,
+			Framed:          true,
+			MaximumFileSize: 14,
+		})
+		require.NoError(t, err)
+
+		for _, entry := range []string{"first!", "second", "too large"} {
+			_, err := w.Write([]byte(entry))
+			require.NoError(t, err)
+		}
+		require.True(t, errors.Is(w.Close(), ErrEntryTooLarge))
+
+		infos, err := fs.ReadDir("logs")
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+		for _, info := range infos {
+			require.Equal(t, int64(14), info.Size())
+		}
+	})
+
+	t.Run("skips records", func(t *testing.T) {
+		r := NewRecordReader(strings.NewReader(frame("first") + frame("second") + frame("third")))
+		require.NoError(t, r.Skip())
+		require.NoError(t, r.Skip())
+		b, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "third", string(b))
+		require.Equal(t, io.EOF, r.Skip())
+	})
+
+	t.Run("reports truncated records", func(t *testing.T) {
+		record := frame("entry")
+		for _, contents := range []string{record[:2], record[:len(record)-1]} {
+			_, err := NewRecordReader(strings.NewReader(contents)).Next()
+			require.True(t, errors.Is(err, io.ErrUnexpectedEOF), "%q", contents)
+			err = NewRecordReader(strings.NewReader(contents)).Skip()
+			require.True(t, errors.Is(err, io.ErrUnexpectedEOF), "%q", contents)
+		}
+	})
+
+	t.Run("does not frame tees", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var tee bytes.Buffer
+		w, err := New(logger, Options{Directory: dir, Framed: true, TeeTo: &tee})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Equal(t, "entry\n", tee.String())
+	})
+	t.Run("skips corrupted records", func(t *testing.T) {
+		second := []byte(frame("second"))
+		second[recordHeaderSize+1] ^= 1
+		records, corrupted := readAll(t, frame("first")+string(second)+frame("third"))
+		require.Equal(t, []string{"first", "third"}, records)
+		require.Equal(t, 1, corrupted)
+
+		// Zeroes written by the filesystem after a power loss.
+		records, corrupted = readAll(t, frame("first")+strings.Repeat("\x00", 1000)+frame("second"))
+		require.Equal(t, []string{"first", "second"}, records)
+		require.Equal(t, 1, corrupted)
+	})
+
+	t.Run("skips torn writes", func(t *testing.T) {
+		// The file was appended to after a torn write, when it was opened again.
+		for _, size := range []int{100, 2 * recordReaderSize} {
+			torn := frame(strings.Repeat("x", size))
+			records, corrupted := readAll(t, frame("first")+torn[:size/2]+frame("second")+frame("third"))
+			require.Equal(t, []string{"first", "second", "third"}, records, "%d bytes", size)
+			require.Equal(t, 1, corrupted)
+		}
+	})
+
+	t.Run("skips records claiming a huge length", func(t *testing.T) {
+		huge := []byte(frame("second"))
+		binary.BigEndian.PutUint32(huge, 1<<32-1)
+		records, corrupted := readAll(t, frame("first")+string(huge)+frame("third"))
+		require.Equal(t, []string{"first", "third"}, records)
+		require.Equal(t, 1, corrupted)
+
+		r := NewRecordReaderSize(strings.NewReader(frame(strings.Repeat("x", 100))+frame("second")), 10)
+		_, err := r.Next()
+		require.True(t, errors.Is(err, ErrCorrupted))
+		b, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "second", string(b))
+		r = NewRecordReaderSize(strings.NewReader(string(huge)+frame("third")), 10)
+		require.True(t, errors.Is(r.Skip(), ErrCorrupted))
+		b, err = r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "third", string(b))
+	})
+
+	t.Run("reports torn writes at the end", func(t *testing.T) {
+		torn := frame(strings.Repeat("x", 100))
+		r := NewRecordReader(strings.NewReader(frame("first") + torn[:50]))
+		b, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "first", string(b))
+		_, err = r.Next()
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		_, err = r.Next()
+		require.Equal(t, io.EOF, err)
+	})
+}
+
+// frame returns entry as a record, see Options.Framed.
+func frame(entry string) string {
+	return string((&Writer{framed: true}).frame([]byte(entry)))
+}
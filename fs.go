@@ -0,0 +1,93 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// newFileFlag opens a new, empty file
+	newFileFlag = os.O_WRONLY | os.O_TRUNC | os.O_CREATE
+	// appendFileFlag opens a file for appending, creating it if necessary
+	appendFileFlag = os.O_WRONLY | os.O_APPEND | os.O_CREATE
+)
+
+// File is a file opened for writing by a FS.
+type File interface {
+	io.Writer
+	io.Closer
+
+	// Sync commits the contents of the file to stable storage.
+	Sync() error
+	// Stat returns the FileInfo describing the file.
+	Stat() (os.FileInfo, error)
+}
+
+// FS is the filesystem used by a Writer for all file operations.
+// Implementations allow plugging in in-memory filesystems for tests,
+// injecting faults, or alternative storage backends.
+// The semantics of each method follow the function of the same name in package os.
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	ReadDir(dirname string) ([]os.FileInfo, error)
+}
+
+// OSFS is the FS backed by the operating system's filesystem.
+type OSFS struct{}
+
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// avoid returning a non-nil File wrapping a nil *os.File
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OSFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dirname)
+}
+
+func (OSFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (OSFS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+func directoryExists(fs FS, dir string) bool {
+	_, err := fs.Stat(dir)
+	return !os.IsNotExist(err)
+}
+
+// createDirectory creates the directory of opts, with DirMode and Owner.
+func createDirectory(opts Options) error {
+	dir := opts.Directory
+	if err := opts.FS.MkdirAll(dir, opts.DirMode); err != nil {
+		return errors.Wrapf(err, "directory %v does not exist and could not be created", dir)
+	}
+	return setAttributes(opts, dir, opts.DirMode)
+}
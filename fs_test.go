@@ -0,0 +1,206 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memFS is an in-memory FS for tests.
+type memFS struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string]*memFile
+}
+
+func newMemFS() *memFS {
+	return &memFS{
+		dirs:  map[string]bool{},
+		files: map[string]*memFile{},
+	}
+}
+
+func (fs *memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if !fs.dirs[filepath.Dir(name)] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	f, ok := fs.files[name]
+	if !ok || flag&os.O_TRUNC != 0 {
+		f = &memFile{name: name}
+		fs.files[name] = f
+	}
+	return f, nil
+}
+
+func (fs *memFS) MkdirAll(path string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.dirs[path] = true
+	return nil
+}
+
+func (fs *memFS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.dirs[name] {
+		return memFileInfo{name: filepath.Base(name), dir: true}, nil
+	}
+	if f, ok := fs.files[name]; ok {
+		return f.Stat()
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (fs *memFS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files[newpath] = fs.files[oldpath]
+	delete(fs.files, oldpath)
+	return nil
+}
+
+func (fs *memFS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	delete(fs.files, name)
+	return nil
+}
+
+func (fs *memFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var infos []os.FileInfo
+	for name, f := range fs.files {
+		if filepath.Dir(name) == dirname {
+			info, _ := f.Stat()
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// contents returns the contents of the file at name.
+func (fs *memFS) contents(name string) string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f := fs.files[name]
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buf.String()
+}
+
+type memFile struct {
+	mu   sync.Mutex
+	name string
+	buf  bytes.Buffer
+	// offset is added to the size reported by Stat,
+	// to simulate large files without allocating them
+	offset int64
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buf.Write(p)
+}
+
+func (f *memFile) Close() error { return nil }
+
+func (f *memFile) Sync() error { return nil }
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return memFileInfo{name: filepath.Base(f.name), size: f.offset + int64(f.buf.Len())}, nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return 0666 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+func TestWriter_FS(t *testing.T) {
+	fs := newMemFS()
+	files := 0
+	w, err := New(nil, Options{
+		Directory:       "/logs",
+		MaximumFileSize: 5,
+		FS:              fs,
+		FileNameFunc: func() string {
+			files++
+			return string(rune('0'+files)) + ".log"
+		},
+	})
+	require.NoError(t, err)
+
+	for _, message := range []string{"abc", "de", "fgh"} {
+		_, err = w.Write([]byte(message))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	infos, err := fs.ReadDir("/logs")
+	require.NoError(t, err)
+	require.Len(t, infos, 2, "must create files through the FS")
+	require.Equal(t, "abcde", fs.contents("/logs/1.log"))
+	require.Equal(t, "fgh", fs.contents("/logs/2.log"))
+
+	_, err = os.Stat("/logs")
+	require.True(t, os.IsNotExist(err), "must not touch the OS filesystem")
+}
+
+func TestWriter_LargeFiles(t *testing.T) {
+	fs := newMemFS()
+	w, err := New(nil, Options{
+		Directory: "/logs",
+		// beyond the range of a 32-bit int
+		MaximumFileSize: 5 << 30,
+		FS:              fs,
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	// pretend the current file already holds 5GiB minus 10 bytes
+	first := w.CurrentFilename()
+	fs.files[first].offset = 5<<30 - 11
+	require.NoError(t, w.Reopen())
+	require.Equal(t, int64(5<<30-10), w.Stats().CurrentFileSize)
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.Equal(t, first, w.CurrentFilename(), "must fill the file up to MaximumFileSize")
+	require.Equal(t, int64(5<<30), w.Stats().CurrentFileSize)
+
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.NotEqual(t, first, w.CurrentFilename(), "must rotate once MaximumFileSize is exceeded")
+	require.Equal(t, int64(1), w.Stats().Rotations)
+	require.Equal(t, int64(5<<30+1), w.Stats().BytesWritten+fs.files[first].offset)
+
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,82 @@
+package gcsrotate
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMetadataHost = "metadata.google.internal"
+	metadataTokenPath   = "/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// tokenExpiryMargin renews tokens before they expire, so that they
+	// do not expire during an upload.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// metadataToken fetches access tokens of the default service account from the
+// metadata server of Compute Engine and GKE, caching them until they expire.
+type metadataToken struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns a cached access token, or fetches a new one.
+func (m *metadataToken) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token != "" && time.Now().Before(m.expiry) {
+		return m.token, nil
+	}
+
+	// GCE_METADATA_HOST is the variable used by the Google Cloud client libraries,
+	// for example to point to an emulator.
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+metadataTokenPath, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create token request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch token from metadata server")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return "", errors.Errorf("failed to fetch token from metadata server: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrap(err, "failed to decode token from metadata server")
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("metadata server returned an empty token")
+	}
+
+	m.token = token.AccessToken
+	m.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin)
+	return m.token, nil
+}
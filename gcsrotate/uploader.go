@@ -0,0 +1,346 @@
+// Package gcsrotate uploads rotated files to Google Cloud Storage.
+//
+// Uploader implements logrotate.Uploader with resumable uploads, so that a
+// failure while uploading a large file resumes where it stopped instead of
+// starting over. On GKE and Compute Engine, requests are authorized with the
+// default service account of the metadata server:
+//
+//	uploader, err := gcsrotate.NewUploader(gcsrotate.Options{
+//		Bucket: "logs",
+//		Prefix: `app/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`,
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	w, err := logrotate.New(nil, logrotate.Options{
+//		Directory:         "/var/log/app",
+//		Uploader:          uploader,
+//		DeleteAfterUpload: true,
+//	})
+package gcsrotate
+
+import (
+	"bytes"
+	"context"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultEndpoint is the endpoint of Google Cloud Storage.
+	DefaultEndpoint = "https://storage.googleapis.com"
+
+	// DefaultChunkSize is the size of the chunks files are uploaded in.
+	DefaultChunkSize = 8 << 20
+
+	// chunkAlignment is the multiple of which chunk sizes must be,
+	// except for the last chunk of a file.
+	chunkAlignment = 256 << 10
+
+	// resumeAttempts is the number of times an upload is resumed after failing
+	// without making progress.
+	resumeAttempts = 3
+)
+
+// Options configure an Uploader.
+type Options struct {
+	// Bucket is the bucket files are uploaded to. Required.
+	Bucket string
+
+	// Prefix is prepended to file names to form object names. Prefix is a text/template
+	// executed with the file's logrotate.FileMeta and Hostname, for example
+	// `logs/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`.
+	Prefix string
+
+	// Endpoint is the URL of the storage service, for example of an emulator.
+	// Defaults to DefaultEndpoint.
+	Endpoint string
+
+	// Token returns the OAuth2 access token authorizing requests. When Token is nil,
+	// tokens of the default service account are fetched from the metadata server.
+	// When Token returns an empty token, requests are not authorized.
+	Token func(ctx context.Context) (string, error)
+
+	// ChunkSize is the size of the chunks files are uploaded in, at most one chunk is
+	// sent again when an upload is resumed. It must be a multiple of 256KiB.
+	// Defaults to DefaultChunkSize.
+	ChunkSize int64
+
+	// ContentType of uploaded objects. Defaults to text/plain; charset=utf-8.
+	ContentType string
+
+	// Client sends requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Uploader uploads files to Google Cloud Storage, it implements logrotate.Uploader.
+type Uploader struct {
+	opts     Options
+	endpoint string
+	prefix   *template.Template
+	hostname string
+	// resumeDelay is the time waited before resuming a failed upload, multiplied by the attempt
+	resumeDelay time.Duration
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader.
+func NewUploader(opts Options) (*Uploader, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("Bucket must not be empty")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid Endpoint %s", opts.Endpoint)
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.ChunkSize < 0 || opts.ChunkSize%chunkAlignment != 0 {
+		return nil, errors.Errorf("ChunkSize must be a multiple of %d, got %d", chunkAlignment, opts.ChunkSize)
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "text/plain; charset=utf-8"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Token == nil {
+		opts.Token = (&metadataToken{client: opts.Client}).Token
+	}
+
+	prefix, err := template.New("prefix").Parse(opts.Prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Prefix")
+	}
+	hostname, _ := os.Hostname()
+
+	return &Uploader{
+		opts:        opts,
+		endpoint:    strings.TrimSuffix(opts.Endpoint, "/"),
+		prefix:      prefix,
+		hostname:    hostname,
+		resumeDelay: time.Second,
+	}, nil
+}
+
+// prefixData is the data Options.Prefix is executed with.
+type prefixData struct {
+	logrotate.FileMeta
+	Hostname string
+}
+
+// ObjectName returns the object name of a file.
+func (u *Uploader) ObjectName(meta logrotate.FileMeta) (string, error) {
+	var b bytes.Buffer
+	if err := u.prefix.Execute(&b, prefixData{FileMeta: meta, Hostname: u.hostname}); err != nil {
+		return "", errors.Wrap(err, "failed to execute Prefix")
+	}
+	return b.String() + meta.Name, nil
+}
+
+// Upload uploads the file at localPath with a resumable upload, in chunks of ChunkSize.
+// When a chunk fails, the upload is resumed from the last byte stored.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	name, err := u.ObjectName(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat file")
+	}
+	size := info.Size()
+
+	session, err := u.startSession(ctx, name, size)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload to gs://%s/%s", u.opts.Bucket, name)
+	}
+
+	offset, failures := int64(0), 0
+	for {
+		persisted, done, err := u.putChunk(ctx, session, f, offset, size)
+		for err != nil {
+			failures++
+			if !retryable(err) || failures > resumeAttempts || ctx.Err() != nil {
+				return errors.Wrapf(err, "failed to upload to gs://%s/%s", u.opts.Bucket, name)
+			}
+			if err := u.wait(ctx, failures); err != nil {
+				return errors.Wrapf(err, "failed to upload to gs://%s/%s", u.opts.Bucket, name)
+			}
+			// Part of the chunk may have been stored, ask where to resume from.
+			persisted, done, err = u.query(ctx, session, size)
+		}
+		if done {
+			return nil
+		}
+
+		if persisted > offset {
+			failures = 0
+		}
+		offset = persisted
+	}
+}
+
+// wait blocks before the given resume attempt, or until ctx is done.
+func (u *Uploader) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(time.Duration(attempt) * u.resumeDelay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startSession initiates a resumable upload and returns its session URI.
+func (u *Uploader) startSession(ctx context.Context, name string, size int64) (string, error) {
+	query := url.Values{"uploadType": {"resumable"}, "name": {name}}
+	req, err := http.NewRequest(http.MethodPost,
+		u.endpoint+"/upload/storage/v1/b/"+url.PathEscape(u.opts.Bucket)+"/o?"+query.Encode(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("X-Upload-Content-Type", u.opts.ContentType)
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	resp, err := u.do(ctx, req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to start resumable upload")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError(resp)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("failed to start resumable upload: missing session URI")
+	}
+	return session, nil
+}
+
+// putChunk sends the chunk of f starting at offset, and returns the number of bytes
+// stored so far and whether the upload is complete.
+func (u *Uploader) putChunk(ctx context.Context, session string, f *os.File, offset, size int64) (int64, bool, error) {
+	end := offset + u.opts.ChunkSize
+	if end > size {
+		end = size
+	}
+
+	req, err := http.NewRequest(http.MethodPut, session, ioutil.NopCloser(logrotate.UploadReader(ctx, io.NewSectionReader(f, offset, end-offset))))
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to create request")
+	}
+	req.ContentLength = end - offset
+	if size == 0 {
+		req.Header.Set("Content-Range", "bytes */0")
+	} else {
+		req.Header.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(end-1, 10)+"/"+strconv.FormatInt(size, 10))
+	}
+
+	return u.progress(ctx, req)
+}
+
+// query asks how many bytes of an interrupted upload have been stored.
+func (u *Uploader) query(ctx context.Context, session string, size int64) (int64, bool, error) {
+	req, err := http.NewRequest(http.MethodPut, session, nil)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+
+	return u.progress(ctx, req)
+}
+
+// progress sends a request to an upload session, and returns the number of bytes
+// stored so far and whether the upload is complete.
+func (u *Uploader) progress(ctx context.Context, req *http.Request) (int64, bool, error) {
+	resp, err := u.do(ctx, req)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+	default:
+		return 0, false, newStatusError(resp)
+	}
+
+	// 308 Resume Incomplete, Range is the range of bytes stored, absent when none are.
+	r := resp.Header.Get("Range")
+	if r == "" {
+		return 0, false, nil
+	}
+	i := strings.LastIndexByte(r, '-')
+	last, err := strconv.ParseInt(r[i+1:], 10, 64)
+	if i < 0 || !strings.HasPrefix(r, "bytes=0-") || err != nil {
+		return 0, false, errors.Errorf("invalid Range %q", r)
+	}
+	return last + 1, false, nil
+}
+
+// do authorizes and sends req.
+func (u *Uploader) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	token, err := u.opts.Token(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get access token")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return u.opts.Client.Do(req.WithContext(ctx))
+}
+
+// statusError is an unexpected response status.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &statusError{code: resp.StatusCode, msg: resp.Status + ": " + strings.TrimSpace(string(body))}
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// retryable reports whether resuming after err may succeed, which is the case
+// for network failures and server errors.
+func retryable(err error) bool {
+	if e, ok := errors.Cause(err).(*statusError); ok {
+		return e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests || e.code >= 500
+	}
+	return true
+}
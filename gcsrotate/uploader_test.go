@@ -0,0 +1,210 @@
+package gcsrotate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGCS implements the resumable upload protocol of a single object.
+type fakeGCS struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu            sync.Mutex
+	name          string
+	authorization string
+	contentType   string
+	size          int64
+	stored        []byte
+	done          bool
+	// failChunks is the number of chunks which fail after storing half of their bytes
+	failChunks int
+	// unavailable fails all requests to the session
+	unavailable bool
+}
+
+func newFakeGCS(t *testing.T) *fakeGCS {
+	g := &fakeGCS{t: t}
+	g.server = httptest.NewServer(http.HandlerFunc(g.serveHTTP))
+	return g
+}
+
+func (g *fakeGCS) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.authorization = r.Header.Get("Authorization")
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(g.t, err)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/logs/o":
+		require.Equal(g.t, "resumable", r.URL.Query().Get("uploadType"))
+		g.name = r.URL.Query().Get("name")
+		g.contentType = r.Header.Get("X-Upload-Content-Type")
+		g.size, err = strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+		require.NoError(g.t, err)
+		rw.Header().Set("Location", g.server.URL+"/session")
+
+	case r.Method == http.MethodPut && r.URL.Path == "/session" && g.unavailable:
+		rw.WriteHeader(http.StatusServiceUnavailable)
+
+	case r.Method == http.MethodPut && r.URL.Path == "/session":
+		contentRange := r.Header.Get("Content-Range")
+		if !strings.HasPrefix(contentRange, "bytes */") {
+			var start, end, size int64
+			_, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size)
+			require.NoError(g.t, err)
+			require.Equal(g.t, int64(len(g.stored)), start)
+			require.Equal(g.t, end-start+1, int64(len(body)))
+
+			if g.failChunks > 0 {
+				g.failChunks--
+				g.stored = append(g.stored, body[:len(body)/2]...)
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			g.stored = append(g.stored, body...)
+		}
+
+		if int64(len(g.stored)) == g.size {
+			g.done = true
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+		if len(g.stored) > 0 {
+			rw.Header().Set("Range", "bytes=0-"+strconv.Itoa(len(g.stored)-1))
+		}
+		rw.WriteHeader(http.StatusPermanentRedirect)
+
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUploader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 40000)
+	localPath := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, content, 0644))
+
+	meta := logrotate.FileMeta{
+		Name:   "app.log",
+		Size:   int64(len(content)),
+		Closed: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+	token := func(ctx context.Context) (string, error) { return "token", nil }
+
+	t.Run("uploads in chunks", func(t *testing.T) {
+		g := newFakeGCS(t)
+		defer g.server.Close()
+
+		uploader, err := NewUploader(Options{
+			Bucket:    "logs",
+			Prefix:    `app/{{.Closed.Format "2006/01/02"}}/`,
+			Endpoint:  g.server.URL,
+			Token:     token,
+			ChunkSize: chunkAlignment,
+		})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.True(t, g.done)
+		require.Equal(t, "app/2020/01/02/app.log", g.name)
+		require.Equal(t, "text/plain; charset=utf-8", g.contentType)
+		require.Equal(t, "Bearer token", g.authorization)
+		require.Equal(t, content, g.stored)
+	})
+
+	t.Run("resumes failed chunks", func(t *testing.T) {
+		g := newFakeGCS(t)
+		defer g.server.Close()
+		g.failChunks = 2
+
+		uploader, err := NewUploader(Options{Bucket: "logs", Endpoint: g.server.URL, Token: token, ChunkSize: chunkAlignment})
+		require.NoError(t, err)
+		uploader.resumeDelay = 0
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.True(t, g.done)
+		require.Equal(t, content, g.stored)
+	})
+
+	t.Run("gives up without progress", func(t *testing.T) {
+		g := newFakeGCS(t)
+		defer g.server.Close()
+		g.unavailable = true
+
+		uploader, err := NewUploader(Options{Bucket: "logs", Endpoint: g.server.URL, Token: token})
+		require.NoError(t, err)
+		uploader.resumeDelay = 0
+
+		err = uploader.Upload(context.Background(), localPath, meta)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "503 Service Unavailable")
+	})
+
+	t.Run("uploads empty files", func(t *testing.T) {
+		g := newFakeGCS(t)
+		defer g.server.Close()
+
+		emptyPath := filepath.Join(dir, "empty.log")
+		require.NoError(t, ioutil.WriteFile(emptyPath, nil, 0644))
+
+		uploader, err := NewUploader(Options{Bucket: "logs", Endpoint: g.server.URL, Token: token})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), emptyPath, logrotate.FileMeta{Name: "empty.log"}))
+		require.True(t, g.done)
+	})
+
+	t.Run("fetches tokens from the metadata server", func(t *testing.T) {
+		requests := 0
+		metadata := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			requests++
+			require.Equal(t, metadataTokenPath, r.URL.Path)
+			require.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			rw.Write([]byte(`{"access_token":"metadata-token","expires_in":3599,"token_type":"Bearer"}`))
+		}))
+		defer metadata.Close()
+		defer os.Unsetenv("GCE_METADATA_HOST")
+		require.NoError(t, os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadata.URL, "http://")))
+
+		g := newFakeGCS(t)
+		defer g.server.Close()
+
+		uploader, err := NewUploader(Options{Bucket: "logs", Endpoint: g.server.URL})
+		require.NoError(t, err)
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+		require.Equal(t, "Bearer metadata-token", g.authorization)
+		require.Equal(t, 1, requests)
+	})
+}
+
+func TestNewUploader(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Bucket: "logs", Endpoint: "://"},
+		{Bucket: "logs", ChunkSize: 1000},
+		{Bucket: "logs", Prefix: "{{"},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+}
@@ -0,0 +1,155 @@
+// Package grpclogrotate adapts a logrotate Writer to grpclog.LoggerV2,
+// so that gRPC internals log into the same rotated files as the application:
+//
+//	grpclog.SetLoggerV2(grpclogrotate.NewLogger(w, &grpclogrotate.Options{
+//		Severity: grpclogrotate.SeverityWarning,
+//	}))
+package grpclogrotate
+
+import (
+	"fmt"
+	"github.com/easyCZ/logrotate"
+	"google.golang.org/grpc/grpclog"
+	"log"
+	"os"
+)
+
+// Severity is the minimum severity of logged messages,
+// like the GRPC_GO_LOG_SEVERITY_LEVEL environment variable.
+type Severity int
+
+// Severities of gRPC log messages, from least to most severe.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// Options configure a Logger.
+type Options struct {
+	// Severity is the minimum severity of logged messages. Defaults to SeverityInfo.
+	// Fatal messages are always logged.
+	Severity Severity
+
+	// Verbosity is the verbosity level reported by V, like the
+	// GRPC_GO_LOG_VERBOSITY_LEVEL environment variable. Defaults to 0.
+	Verbosity int
+}
+
+// osExit is called by the Fatal methods, replaced in tests.
+var osExit = os.Exit
+
+// Logger implements grpclog.LoggerV2, writing messages into a Writer
+// in the format of gRPC's default logger:
+//
+//	WARNING: 2024/01/02 15:04:05 [core] message
+type Logger struct {
+	w         *logrotate.Writer
+	severity  Severity
+	verbosity int
+
+	info, warning, error, fatal *log.Logger
+}
+
+// NewLogger returns a Logger writing messages into w.
+// When opts is nil, default options are used.
+func NewLogger(w *logrotate.Writer, opts *Options) *Logger {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	return &Logger{
+		w:         w,
+		severity:  opts.Severity,
+		verbosity: opts.Verbosity,
+		info:      log.New(w, "INFO: ", log.LstdFlags),
+		warning:   log.New(w, "WARNING: ", log.LstdFlags),
+		error:     log.New(w, "ERROR: ", log.LstdFlags),
+		fatal:     log.New(w, "FATAL: ", log.LstdFlags),
+	}
+}
+
+func (l *Logger) output(severity Severity, logger *log.Logger, msg string) {
+	if severity < l.severity {
+		return
+	}
+	_ = logger.Output(3, msg)
+}
+
+// Info logs to INFO log. Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Info(args ...interface{}) {
+	l.output(SeverityInfo, l.info, fmt.Sprint(args...))
+}
+
+// Infoln logs to INFO log. Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Infoln(args ...interface{}) {
+	l.output(SeverityInfo, l.info, fmt.Sprintln(args...))
+}
+
+// Infof logs to INFO log. Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.output(SeverityInfo, l.info, fmt.Sprintf(format, args...))
+}
+
+// Warning logs to WARNING log. Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Warning(args ...interface{}) {
+	l.output(SeverityWarning, l.warning, fmt.Sprint(args...))
+}
+
+// Warningln logs to WARNING log. Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Warningln(args ...interface{}) {
+	l.output(SeverityWarning, l.warning, fmt.Sprintln(args...))
+}
+
+// Warningf logs to WARNING log. Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.output(SeverityWarning, l.warning, fmt.Sprintf(format, args...))
+}
+
+// Error logs to ERROR log. Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Error(args ...interface{}) {
+	l.output(SeverityError, l.error, fmt.Sprint(args...))
+}
+
+// Errorln logs to ERROR log. Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Errorln(args ...interface{}) {
+	l.output(SeverityError, l.error, fmt.Sprintln(args...))
+}
+
+// Errorf logs to ERROR log. Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.output(SeverityError, l.error, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs to FATAL log, closes the Writer and exits with status 1.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Fatal(args ...interface{}) {
+	l.exit(fmt.Sprint(args...))
+}
+
+// Fatalln logs to FATAL log, closes the Writer and exits with status 1.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Fatalln(args ...interface{}) {
+	l.exit(fmt.Sprintln(args...))
+}
+
+// Fatalf logs to FATAL log, closes the Writer and exits with status 1.
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.exit(fmt.Sprintf(format, args...))
+}
+
+// exit logs a fatal message and exits, closing the Writer first
+// so that queued messages are written to the file.
+func (l *Logger) exit(msg string) {
+	_ = l.fatal.Output(3, msg)
+	_ = l.w.Close()
+	osExit(1)
+}
+
+// V reports whether verbosity level v is at least the requested verbosity level.
+func (l *Logger) V(v int) bool {
+	return v <= l.verbosity
+}
+
+var _ grpclog.LoggerV2 = (*Logger)(nil)
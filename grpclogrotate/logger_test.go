@@ -0,0 +1,50 @@
+package grpclogrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/grpclog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := logrotate.New(nil, logrotate.Options{Directory: dir})
+	require.NoError(t, err)
+
+	exitCode := -1
+	defer func(exit func(int)) { osExit = exit }(osExit)
+	osExit = func(code int) { exitCode = code }
+
+	var l grpclog.LoggerV2 = NewLogger(w, &Options{Severity: SeverityWarning, Verbosity: 2})
+	l.Info("info")
+	l.Infof("info %d", 1)
+	l.Warning("warning")
+	l.Warningf("warning %d", 1)
+	l.Errorln("error", 1)
+	l.Fatalf("fatal %d", 1)
+
+	require.Equal(t, 1, exitCode)
+	require.True(t, l.V(2))
+	require.False(t, l.V(3))
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Len(t, lines, 4)
+	for i, expected := range []string{"WARNING: .* warning", "WARNING: .* warning 1", "ERROR: .* error 1", "FATAL: .* fatal 1"} {
+		require.Regexp(t, regexp.MustCompile("^"+expected+"$"), lines[i])
+	}
+}
@@ -0,0 +1,76 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"io"
+)
+
+// fileWriter writes into the current file outside of entries, such as the
+// header and footer, accounting for the bytes written.
+type fileWriter struct {
+	w *Writer
+}
+
+// With Options.Framed, each write is a record.
+func (fw fileWriter) Write(p []byte) (int, error) {
+	w := fw.w
+	record := w.frame(p)
+	n, err := w.bw.Write(record)
+	if _, encrypted := w.f.(*encryptedFile); !encrypted {
+		w.updateChecksum(record[:n])
+	}
+	w.bytesWritten += int64(n)
+	if err != nil {
+		w.bw.Reset(w.f)
+		w.invalidateChecksum()
+		if w.framed {
+			// Only whole records count as written.
+			n = 0
+		}
+		return n, err
+	}
+	return len(p), nil
+}
+
+// writeHeader writes Options.Header into the file which has just been opened,
+// unless it already has contents.
+func (w *Writer) writeHeader() {
+	w.headerSize = 0
+	header := w.opts.Header
+	if w.jsonArray {
+		header = writeString(arrayOpen)
+	}
+	if header == nil || w.bytesWritten > 0 {
+		return
+	}
+	if err := header(fileWriter{w}); err != nil {
+		err = errors.Wrapf(err, "failed to write header of %s", w.path)
+		w.logger.Printf("%v", err)
+		w.report(err)
+	}
+	w.headerSize = w.bytesWritten
+}
+
+// writeFooter writes Options.Footer into the current file before it is closed.
+func (w *Writer) writeFooter() {
+	footer := w.opts.Footer
+	if w.jsonArray {
+		footer = writeString(arrayClose)
+	}
+	if footer == nil {
+		return
+	}
+	if err := footer(fileWriter{w}); err != nil {
+		err = errors.Wrapf(err, "failed to write footer of %s", w.path)
+		w.logger.Printf("%v", err)
+		w.report(err)
+	}
+}
+
+// writeString returns a Header or Footer writing s.
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
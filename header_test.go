@@ -0,0 +1,109 @@
+package logrotate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_HeaderFooter(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	t.Run("writes header and footer into each file", func(t *testing.T) {
+		dir := newDir(t)
+		names := []string{"first.log", "second.log"}
+		var sums []string
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 30,
+			Checksum:        true,
+			FileNameFunc: func() string {
+				name := names[0]
+				names = names[1:]
+				return name
+			},
+			Header: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "# version 1.2.3")
+				return err
+			},
+			Footer: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "# end")
+				return err
+			},
+			OnFileClose: func(e FileCloseEvent) { sums = append(sums, e.SHA256) },
+		})
+		require.NoError(t, err)
+		for _, msg := range []string{"first\n", "second\n", "third\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		for name, expected := range map[string]string{
+			"first.log":  "# version 1.2.3\nfirst\nsecond\n# end\n",
+			"second.log": "# version 1.2.3\nthird\n# end\n",
+		} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(b), name)
+			sum := sha256.Sum256(b)
+			require.Contains(t, sums, hex.EncodeToString(sum[:]), "checksum must include header and footer")
+		}
+	})
+
+	t.Run("does not rotate files holding only their header", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 20,
+			FileNameFunc:    func() string { return "app.log" },
+			Header: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "# version 1.2.3")
+				return err
+			},
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("a long entry\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Equal(t, int64(0), w.Stats().Rotations)
+	})
+
+	t.Run("does not write headers into existing files", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, "app.log")
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "app.log" },
+			Header: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "# header")
+				return err
+			},
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Reopen())
+		_, err = w.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "# header\nfirst\nsecond\n", string(b))
+	})
+}
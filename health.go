@@ -0,0 +1,129 @@
+package logrotate
+
+import "time"
+
+// Health describes the state of a Writer's file backend.
+type Health struct {
+	// Path is the path of the file currently being written to.
+	// Path is empty when no file is open.
+	Path string
+
+	// LastWrite is the time of the last entry successfully written.
+	LastWrite time.Time
+
+	// LastError is the most recent error encountered, see Writer.LastError.
+	LastError error
+
+	// QueueDepth is the number of entries awaiting to be written.
+	QueueDepth int
+
+	// ConsecutiveFailures is the number of entries which could not be
+	// written since the last successful write.
+	ConsecutiveFailures int
+
+	// Failed is true once the Writer stopped accepting writes.
+	Failed bool
+}
+
+// Healthy returns true when the most recent write succeeded
+// and the Writer accepts writes.
+func (h Health) Healthy() bool {
+	return !h.Failed && h.ConsecutiveFailures == 0
+}
+
+// Health returns the current state of the Writer's file backend.
+// Health is safe to call concurrently, for example from a readiness probe.
+func (w *Writer) Health() Health {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return Health{
+		Path:                w.path,
+		LastWrite:           w.lastWrite,
+		LastError:           w.err,
+		QueueDepth:          len(w.queue) + len(w.priority),
+		ConsecutiveFailures: w.consecutiveFailures,
+		Failed:              w.failed != nil,
+	}
+}
+
+// recordWrite updates health information and statistics after an attempt
+// to write an entry of size bytes.
+func (w *Writer) recordWrite(size int64, err error) {
+	if err != nil {
+		w.mu.Lock()
+		w.err = err
+		w.consecutiveFailures++
+		w.mu.Unlock()
+
+		reason := DropWriteFailed
+		if isDiskFull(err) {
+			reason = DropDiskFull
+		}
+		w.recordDrop(reason, err)
+		w.report(err)
+		return
+	}
+
+	w.mu.Lock()
+	w.lastWrite = w.now()
+	w.consecutiveFailures = 0
+	w.stats.BytesWritten += size
+	w.stats.EntriesWritten++
+	w.fileSize += size
+	fileSize := w.fileSize
+	w.mu.Unlock()
+
+	w.metrics.IncCounter(MetricEntriesWritten, 1)
+	w.metrics.IncCounter(MetricBytesWritten, size)
+	w.metrics.SetGauge(MetricCurrentFileSize, float64(fileSize))
+}
+
+// CurrentFilename returns the path of the file currently being written to.
+// CurrentFilename is empty until the first entry is written and once the Writer is closed.
+func (w *Writer) CurrentFilename() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.path
+}
+
+// PreviousFilename returns the path of the most recently closed file,
+// or an empty string if no file has been closed yet.
+func (w *Writer) PreviousFilename() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.previousPath
+}
+
+// RotationCount returns the number of files closed by a rotation, including
+// files closed for exceeding MaximumLifetime before a new one is opened, like
+// Stats.Rotations.
+func (w *Writer) RotationCount() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats.Rotations
+}
+
+// LastRotation returns the path of the file closed by the most recent
+// rotation, and the time it was closed. LastRotation returns an empty path
+// and a zero time if no file has been rotated yet. Closing the Writer does
+// not count as a rotation.
+func (w *Writer) LastRotation() (path string, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastRotationPath, w.lastRotation
+}
+
+func (w *Writer) setPath(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.path = path
+}
+
+// setPreviousPath records path as the most recently closed file. Reopen, which
+// closes and opens the same file again, does not record it.
+func (w *Writer) setPreviousPath(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.previousPath = path
+}
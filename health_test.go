@@ -0,0 +1,103 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_Health(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("healthy", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "file.log" },
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return !w.Health().LastWrite.IsZero()
+		}, time.Second, time.Millisecond, "must record last write")
+
+		h := w.Health()
+		require.True(t, h.Healthy())
+		require.Equal(t, filepath.Join(dir, "file.log"), h.Path)
+		require.NoError(t, h.LastError)
+
+		require.NoError(t, w.Close())
+		require.Empty(t, w.Health().Path, "must not report a path once closed")
+	})
+
+	t.Run("consecutive failures", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err = w.Write([]byte("message"))
+			require.NoError(t, err)
+		}
+		require.Error(t, w.Close())
+
+		h := w.Health()
+		require.False(t, h.Healthy())
+		require.Equal(t, 3, h.ConsecutiveFailures)
+		require.Error(t, h.LastError)
+	})
+}
+
+func TestWriter_LastRotation(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	clock := newFakeClock()
+	files := 0
+	w, err := New(logger, Options{
+		Directory: "logs",
+		FS:        newMemFS(),
+		FileNameFunc: func() string {
+			files++
+			return fmt.Sprintf("%d.log", files)
+		},
+		Now: clock.Now,
+	})
+	require.NoError(t, err)
+
+	path, at := w.LastRotation()
+	require.Empty(t, path)
+	require.True(t, at.IsZero())
+	require.Equal(t, int64(0), w.RotationCount())
+
+	_, err = w.Write([]byte("entry\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	require.NoError(t, w.Rotate())
+
+	path, at = w.LastRotation()
+	require.Equal(t, filepath.Join("logs", "1.log"), path)
+	require.Equal(t, clock.Now().UTC(), at)
+	require.Equal(t, int64(1), w.RotationCount())
+
+	require.NoError(t, w.Close())
+	path, _ = w.LastRotation()
+	require.Equal(t, filepath.Join("logs", "1.log"), path, "closing must not count as a rotation")
+	require.Equal(t, int64(1), w.RotationCount())
+}
@@ -0,0 +1,151 @@
+// Package httplog writes HTTP access logs into a logrotate.Writer.
+//
+// Requests are logged in the Combined Log Format used by Apache and nginx,
+// so existing tooling can parse the rotated files:
+//
+//	w, err := logrotate.New(nil, logrotate.Options{Directory: "/var/log/app/access"})
+//	if err != nil {
+//		// handle err
+//	}
+//	http.ListenAndServe(":8080", httplog.Handler(w, mux))
+package httplog
+
+import (
+	"bufio"
+	"github.com/easyCZ/logrotate"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is the format of request times in the Combined Log Format.
+const TimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Handler returns an http.Handler serving requests with next and writing a line
+// in the Combined Log Format into w once each request has been served:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "http://example.com/" "Mozilla/5.0"
+//
+// Each line is written as a single entry, so lines are never split across files.
+func Handler(w *logrotate.Writer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: rw}
+
+		next.ServeHTTP(recorder, r)
+
+		_, _ = w.Write(appendCombined(nil, r, start, recorder.status, recorder.size))
+	})
+}
+
+// appendCombined appends the Combined Log Format line of a request to b.
+func appendCombined(b []byte, r *http.Request, start time.Time, status int, size int64) []byte {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, ok := r.BasicAuth()
+	if !ok || user == "" {
+		user = "-"
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	b = appendField(b, host)
+	b = append(b, " - "...)
+	b = appendField(b, user)
+	b = append(b, " ["...)
+	b = start.AppendFormat(b, TimeFormat)
+	b = append(b, "] \""...)
+	b = appendEscaped(b, r.Method+" "+r.RequestURI+" "+r.Proto)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(status), 10)
+	b = append(b, ' ')
+	if size > 0 {
+		b = strconv.AppendInt(b, size, 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, " \""...)
+	b = appendEscaped(b, r.Referer())
+	b = append(b, "\" \""...)
+	b = appendEscaped(b, r.UserAgent())
+	b = append(b, "\"\n"...)
+	return b
+}
+
+// appendField appends an unquoted field, using "-" for empty values.
+func appendField(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, '-')
+	}
+	return appendEscaped(b, s)
+}
+
+// appendEscaped appends s escaping quotes and backslashes with a backslash
+// and non-printable bytes as \xhh, like Apache does.
+func appendEscaped(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c >= 0x7f:
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// responseRecorder records the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying ResponseWriter does.
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying ResponseWriter does,
+// hijacked connections are logged with status 101 unless a status was written.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,71 @@
+package httplog
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := logrotate.New(nil, logrotate.Options{Directory: dir})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/missing", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(Handler(w, mux))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/hello?name=a%20b", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("frank", "secret")
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", `agent "quoted"`)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = http.Post(server.URL+"/missing", "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.NoError(t, w.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Regexp(t, regexp.MustCompile(`^127\.0\.0\.1 - frank \[[^\]]+\] "GET /hello\?name=a%20b HTTP/1\.1" 200 5 "http://example\.com/" "agent \\"quoted\\""$`), lines[0])
+	require.Regexp(t, regexp.MustCompile(`^127\.0\.0\.1 - - \[[^\]]+\] "POST /missing HTTP/1\.1" 404 - "" "Go-http-client/1\.1"$`), lines[1])
+}
+
+func TestAppendCombined(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RequestURI = "/a\nb"
+	r.RemoteAddr = "unix"
+	start := time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+	line := string(appendCombined(nil, r, start, 0, 0))
+	require.Equal(t, `unix - - [10/Oct/2000:13:55:36 -0700] "GET /a\x0ab HTTP/1.1" 200 - "" ""`+"\n", line)
+}
@@ -0,0 +1,90 @@
+package logrotate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// journalSocket is the socket of the systemd journal for native protocol messages.
+var journalSocket = "/run/systemd/journal/socket"
+
+// JournalWriter sends each entry as a message to the systemd journal on Linux.
+// It is intended as Options.Fallback, so that entries which cannot be written
+// to files during disk incidents remain visible via journalctl:
+//
+//	journal, err := logrotate.NewJournalWriter("app")
+//	if err == nil {
+//		opts.Fallback = journal
+//	}
+//
+// Entries are sent as single datagrams, entries larger than the maximum
+// datagram size of the journal socket are rejected.
+type JournalWriter struct {
+	identifier string
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+// NewJournalWriter returns a JournalWriter sending messages with the given
+// SYSLOG_IDENTIFIER, the program name when identifier is empty.
+// NewJournalWriter fails when the systemd journal is not available.
+func NewJournalWriter(identifier string) (*JournalWriter, error) {
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+
+	if _, err := os.Stat(journalSocket); err != nil {
+		return nil, errors.Wrap(err, "systemd journal is not available")
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create socket for systemd journal")
+	}
+
+	return &JournalWriter{
+		identifier: identifier,
+		conn:       conn,
+		addr:       &net.UnixAddr{Name: journalSocket, Net: "unixgram"},
+	}, nil
+}
+
+// Write sends p to the journal as a message with priority error,
+// a trailing newline is removed.
+func (j *JournalWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+
+	var b bytes.Buffer
+	b.WriteString("PRIORITY=3\nSYSLOG_IDENTIFIER=")
+	b.WriteString(j.identifier)
+	b.WriteString("\nSYSLOG_PID=")
+	b.WriteString(strconv.Itoa(os.Getpid()))
+	b.WriteString("\nMESSAGE\n")
+	// The binary field format allows newlines in the message.
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(msg)))
+	b.Write(size[:])
+	b.Write(msg)
+	b.WriteByte('\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.conn.WriteToUnix(b.Bytes(), j.addr); err != nil {
+		return 0, errors.Wrap(err, "failed to write to systemd journal")
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the journal.
+func (j *JournalWriter) Close() error {
+	return j.conn.Close()
+}
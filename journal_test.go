@@ -0,0 +1,66 @@
+package logrotate
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalWriter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(socket string) { journalSocket = socket }(journalSocket)
+	journalSocket = filepath.Join(dir, "socket")
+
+	_, err = NewJournalWriter("app")
+	require.Error(t, err, "journal must not be available")
+
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer journal.Close()
+
+	fallback, err := NewJournalWriter("app")
+	require.NoError(t, err)
+	defer fallback.Close()
+
+	// A file in place of the directory makes all writes fail.
+	logs := filepath.Join(dir, "logs")
+	w, err := New(log.New(os.Stderr, "", log.LstdFlags), Options{
+		Directory: logs,
+		Fallback:  fallback,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(logs))
+	require.NoError(t, ioutil.WriteFile(logs, nil, 0666))
+
+	_, err = w.Write([]byte("first line\nsecond line\n"))
+	require.NoError(t, err)
+	require.Error(t, w.Close(), "entry must not be written to a file")
+
+	require.NoError(t, journal.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 1024)
+	n, err := journal.Read(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	require.True(t, strings.HasPrefix(msg, "PRIORITY=3\nSYSLOG_IDENTIFIER=app\nSYSLOG_PID="), msg)
+
+	i := strings.Index(msg, "MESSAGE\n") + len("MESSAGE\n")
+	size := binary.LittleEndian.Uint64(buf[i : i+8])
+	require.Equal(t, "first line\nsecond line", string(buf[i+8:i+8+int(size)]))
+	require.Equal(t, n, i+8+int(size)+1)
+}
@@ -0,0 +1,40 @@
+package logrotate
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// With Options.JSONArray, files start with arrayOpen, entries are separated
+// by arraySeparator, and files end with arrayClose.
+const (
+	arrayOpen      = "[\n"
+	arraySeparator = ",\n"
+	arrayClose     = "\n]\n"
+)
+
+// arrayElement returns b as an element of the array, with Options.JSONArray.
+// The trailing newline is removed, entries which are not valid JSON are
+// written as JSON strings, so that the file remains well-formed.
+func (w *Writer) arrayElement(b []byte) []byte {
+	if !w.jsonArray {
+		return b
+	}
+	b = bytes.TrimRight(b, "\r\n")
+	if len(b) > 0 && json.Valid(b) {
+		return b
+	}
+	// Marshalling a string cannot fail.
+	s, _ := json.Marshal(string(b))
+	return s
+}
+
+// separator returns the bytes written before the next element of the array,
+// with Options.JSONArray. Files which were appended to, for example by Reopen,
+// already hold elements when they are larger than the opening bracket.
+func (w *Writer) separator() []byte {
+	if !w.jsonArray || w.bytesWritten <= int64(len(arrayOpen)) {
+		return nil
+	}
+	return []byte(arraySeparator)
+}
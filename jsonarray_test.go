@@ -0,0 +1,104 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_JSONArray(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	readArray := func(t *testing.T, path string) []interface{} {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		var elements []interface{}
+		require.NoError(t, json.Unmarshal(b, &elements), string(b))
+		return elements
+	}
+
+	t.Run("writes files as arrays", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{
+			Directory:    dir,
+			JSONArray:    true,
+			FileNameFunc: func() string { return "app.json" },
+		})
+		require.NoError(t, err)
+
+		for _, msg := range []string{`{"level":"info"}` + "\n", "panic: not JSON\n", "[1,2]", ""} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.json"))
+		require.NoError(t, err)
+		require.Equal(t, "[\n{\"level\":\"info\"},\n\"panic: not JSON\",\n[1,2],\n\"\"\n]\n", string(b))
+	})
+
+	t.Run("closes arrays when rotating", func(t *testing.T) {
+		dir := newDir(t)
+		files := 0
+		w, err := New(logger, Options{
+			Directory:       dir,
+			JSONArray:       true,
+			MaximumFileSize: 32,
+			FileNameFunc: func() string {
+				files++
+				return fmt.Sprintf("%d.json", files)
+			},
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			_, err := w.Write([]byte(fmt.Sprintf(`{"i":%d}`+"\n", i)))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		var elements []interface{}
+		for _, name := range logFiles(t, dir) {
+			array := readArray(t, filepath.Join(dir, name))
+			require.NotEmpty(t, array)
+			elements = append(elements, array...)
+		}
+		require.Len(t, elements, 5)
+		require.True(t, files > 1, "must rotate")
+	})
+
+	t.Run("appends to reopened files", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{
+			Directory:    dir,
+			JSONArray:    true,
+			FileNameFunc: func() string { return "app.json" },
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("1\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Reopen())
+		_, err = w.Write([]byte("2\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []interface{}{1.0, 2.0}, readArray(t, filepath.Join(dir, "app.json")))
+	})
+
+	t.Run("rejects headers", func(t *testing.T) {
+		opts := Options{Directory: "logs", JSONArray: true, Header: writeString("#\n")}
+		require.Error(t, opts.Validate())
+	})
+}
@@ -0,0 +1,189 @@
+// Package kafkarotate publishes the entries of rotated files to a Kafka topic.
+//
+// Uploader implements logrotate.Uploader, each line of a rotated file is
+// published as a message once the file is closed. The local file remains the
+// durable source of truth: it is only deleted, with DeleteAfterUpload, once all
+// of its messages have been acknowledged by all in-sync replicas, and it is
+// published again when publishing fails:
+//
+//	uploader, err := kafkarotate.NewUploader(kafkarotate.Options{
+//		Brokers: []string{"kafka-1:9092", "kafka-2:9092"},
+//		Topic:   "app-logs",
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	defer uploader.Close()
+//	w, err := logrotate.New(nil, logrotate.Options{
+//		Directory: "/var/log/app",
+//		Uploader:  uploader,
+//	})
+//
+// Messages are published at least once, when publishing fails part way through
+// a file, the messages which were already published are published again.
+package kafkarotate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultBatchBytes   = 1 << 20
+	defaultBatchTimeout = 10 * time.Millisecond
+
+	// FileHeader is the header of messages holding the name of the file they were read from.
+	FileHeader = "logrotate-file"
+)
+
+// Options configure an Uploader.
+type Options struct {
+	// Brokers are the addresses of the brokers used to discover the cluster. Required.
+	Brokers []string
+
+	// Topic is the topic messages are published to. Required.
+	Topic string
+
+	// Key returns the key of the message of a line, which selects its partition.
+	// Messages with the same key are published to the same partition, in order.
+	// Defaults to the name of the file, so that the entries of a file are consumed
+	// in the order they were written.
+	Key func(meta logrotate.FileMeta, line []byte) []byte
+
+	// BatchSize is the maximum number of messages published in one request. Defaults to 100.
+	BatchSize int
+
+	// BatchBytes is the maximum size of one request, longer lines cannot be published.
+	// Defaults to 1MiB.
+	BatchBytes int64
+
+	// BatchTimeout is the time to wait for more messages before publishing an
+	// incomplete batch. Defaults to 10ms.
+	BatchTimeout time.Duration
+
+	// Compression compresses batches, for example kafka.Snappy. Defaults to no compression.
+	Compression kafka.Compression
+
+	// Transport connects to the brokers, for example a *kafka.Transport
+	// configured with TLS and SASL. Defaults to kafka.DefaultTransport.
+	Transport kafka.RoundTripper
+}
+
+// messageWriter publishes messages, it is implemented by *kafka.Writer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Uploader publishes the lines of files to Kafka, it implements logrotate.Uploader.
+type Uploader struct {
+	opts Options
+	w    messageWriter
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader, without connecting to the brokers.
+func NewUploader(opts Options) (*Uploader, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, errors.New("Brokers must not be empty")
+	}
+	if opts.Topic == "" {
+		return nil, errors.New("Topic must not be empty")
+	}
+	if opts.Key == nil {
+		opts.Key = func(meta logrotate.FileMeta, line []byte) []byte {
+			return []byte(meta.Name)
+		}
+	}
+	if opts.BatchSize < 0 || opts.BatchBytes < 0 || opts.BatchTimeout < 0 {
+		return nil, errors.New("BatchSize, BatchBytes and BatchTimeout must not be negative")
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.BatchBytes == 0 {
+		opts.BatchBytes = defaultBatchBytes
+	}
+	if opts.BatchTimeout == 0 {
+		opts.BatchTimeout = defaultBatchTimeout
+	}
+
+	return &Uploader{
+		opts: opts,
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(opts.Brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    opts.BatchSize,
+			BatchBytes:   opts.BatchBytes,
+			BatchTimeout: opts.BatchTimeout,
+			RequiredAcks: kafka.RequireAll,
+			Compression:  opts.Compression,
+			Transport:    opts.Transport,
+		},
+	}, nil
+}
+
+// Upload publishes the lines of the file at localPath, BatchSize messages at a time,
+// and returns once all of them have been acknowledged. Empty lines are skipped.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	headers := []kafka.Header{{Key: FileHeader, Value: []byte(meta.Name)}}
+	batch := make([]kafka.Message, 0, u.opts.BatchSize)
+	publish := func() error {
+		if err := u.w.WriteMessages(ctx, batch...); err != nil {
+			return errors.Wrapf(err, "failed to publish %s to %s", meta.Name, u.opts.Topic)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	r := bufio.NewReader(logrotate.UploadReader(ctx, f))
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return errors.Wrap(readErr, "failed to read file")
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			batch = append(batch, kafka.Message{
+				Key:     u.opts.Key(meta, line),
+				Value:   line,
+				Headers: headers,
+			})
+			if len(batch) == u.opts.BatchSize {
+				if err := publish(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(batch) > 0 {
+		return publish()
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the connections to the brokers.
+func (u *Uploader) Close() error {
+	return u.w.Close()
+}
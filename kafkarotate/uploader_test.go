@@ -0,0 +1,119 @@
+package kafkarotate
+
+import (
+	"bytes"
+	"context"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeWriter records the batches of messages published, failing while err is set.
+type fakeWriter struct {
+	batches [][]kafka.Message
+	err     error
+	closed  bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestUploader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, []byte("user=1 first\n\nuser=2 second\r\nuser=1 third"), 0644))
+	meta := logrotate.FileMeta{Name: "app.log", Size: 41}
+
+	t.Run("publishes lines in batches", func(t *testing.T) {
+		uploader, err := NewUploader(Options{Brokers: []string{"kafka:9092"}, Topic: "logs", BatchSize: 2})
+		require.NoError(t, err)
+		fake := &fakeWriter{}
+		uploader.w = fake
+
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		require.Len(t, fake.batches, 2)
+		require.Len(t, fake.batches[0], 2)
+		require.Len(t, fake.batches[1], 1)
+
+		var values []string
+		for _, batch := range fake.batches {
+			for _, msg := range batch {
+				values = append(values, string(msg.Value))
+				require.Equal(t, "app.log", string(msg.Key))
+				require.Equal(t, []kafka.Header{{Key: FileHeader, Value: []byte("app.log")}}, msg.Headers)
+			}
+		}
+		require.Equal(t, []string{"user=1 first", "user=2 second", "user=1 third"}, values)
+
+		require.NoError(t, uploader.Close())
+		require.True(t, fake.closed)
+	})
+
+	t.Run("uses the key of each line", func(t *testing.T) {
+		uploader, err := NewUploader(Options{
+			Brokers: []string{"kafka:9092"},
+			Topic:   "logs",
+			Key: func(meta logrotate.FileMeta, line []byte) []byte {
+				return line[:bytes.IndexByte(line, ' ')]
+			},
+		})
+		require.NoError(t, err)
+		fake := &fakeWriter{}
+		uploader.w = fake
+
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		require.Len(t, fake.batches, 1)
+		var keys []string
+		for _, msg := range fake.batches[0] {
+			keys = append(keys, string(msg.Key))
+		}
+		require.Equal(t, []string{"user=1", "user=2", "user=1"}, keys)
+	})
+
+	t.Run("returns failures to publish", func(t *testing.T) {
+		uploader, err := NewUploader(Options{Brokers: []string{"kafka:9092"}, Topic: "logs"})
+		require.NoError(t, err)
+		uploader.w = &fakeWriter{err: errors.New("leader not available")}
+
+		err = uploader.Upload(context.Background(), localPath, meta)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "leader not available")
+	})
+}
+
+func TestNewUploader(t *testing.T) {
+	brokers := []string{"kafka:9092"}
+	for _, opts := range []Options{
+		{Topic: "logs"},
+		{Brokers: brokers},
+		{Brokers: brokers, Topic: "logs", BatchSize: -1},
+		{Brokers: brokers, Topic: "logs", BatchBytes: -1},
+		{Brokers: brokers, Topic: "logs", BatchTimeout: -1},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+
+	uploader, err := NewUploader(Options{Brokers: brokers, Topic: "logs"})
+	require.NoError(t, err)
+	w := uploader.w.(*kafka.Writer)
+	require.Equal(t, kafka.RequireAll, w.RequiredAcks)
+	require.Equal(t, defaultBatchSize, w.BatchSize)
+}
@@ -0,0 +1,17 @@
+module github.com/easyCZ/logrotate/klogrotate
+
+go 1.21
+
+require (
+	github.com/easyCZ/logrotate v1.0.0
+	github.com/stretchr/testify v1.4.0
+	k8s.io/klog/v2 v2.140.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
@@ -0,0 +1,128 @@
+// Package klogrotate redirects klog output into a logrotate Writer.
+//
+// klog is the logging library used by Kubernetes components and controllers:
+//
+//	w, _ := logrotate.New(nil, logrotate.Options{Directory: "/var/log/controller"})
+//	restore := klogrotate.Redirect(w, nil)
+//	defer restore()
+//
+// glog, which klog was forked from, does not support custom outputs and can only
+// write into its own files in -log_dir. Programs using glog can switch to klog,
+// which keeps the glog API, and use Redirect.
+package klogrotate
+
+import (
+	"context"
+	"flag"
+	"github.com/easyCZ/logrotate"
+	"io"
+	"k8s.io/klog/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultFlushInterval matches the interval of the klog flush daemon.
+const DefaultFlushInterval = 5 * time.Second
+
+// Options configure the redirection.
+type Options struct {
+	// FlushInterval controls how often entries buffered by the Writer are written
+	// into the current file, mirroring the klog flush daemon which only flushes
+	// the files created by klog itself. Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+}
+
+// Redirect makes klog write all entries into w, regardless of -logtostderr,
+// and periodically flushes w.
+//
+// Each entry is written into w exactly once. klog writes an entry into the
+// outputs of its own and every lower severity, only the INFO output is
+// redirected into w and the others are discarded. Entries logged with
+// klog.Fatal and klog.Exit are flushed into w before klog exits the process.
+// klog still copies entries at or above -stderrthreshold to stderr.
+//
+// The returned function stops the periodic flush, flushes w and restores
+// the klog flags and outputs in effect before Redirect was called. It does not close w.
+func Redirect(w *logrotate.Writer, opts *Options) (restore func()) {
+	interval := DefaultFlushInterval
+	if opts != nil && opts.FlushInterval > 0 {
+		interval = opts.FlushInterval
+	}
+
+	state := klog.CaptureState()
+	flags := flag.NewFlagSet("klogrotate", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	_ = flags.Set("logtostderr", "false")
+	_ = flags.Set("one_output", "false")
+
+	out := &output{w: w}
+	klog.SetOutputBySeverity("INFO", out)
+	klog.SetOutputBySeverity("WARNING", io.Discard)
+	klog.SetOutputBySeverity("ERROR", io.Discard)
+	klog.SetOutputBySeverity("FATAL", fatalOutput{out})
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go flushPeriodically(w, interval, stop, stopped)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+
+			state.Restore()
+			_ = w.Flush()
+		})
+	}
+}
+
+// flushPeriodically flushes w every interval until stop is closed.
+func flushPeriodically(w *logrotate.Writer, interval time.Duration, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = w.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// output is the klog INFO output, which receives entries of all severities.
+type output struct {
+	w *logrotate.Writer
+
+	// fatal is set when klog is about to exit, the next entry written into
+	// the INFO output is synchronized to disk before klog exits.
+	fatal int32
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+
+	if atomic.CompareAndSwapInt32(&o.fatal, 1, 0) {
+		ctx, cancel := context.WithTimeout(context.Background(), klog.ExitFlushTimeout)
+		defer cancel()
+		_ = o.w.Drain(ctx)
+	}
+
+	return n, err
+}
+
+// fatalOutput is the klog FATAL output. klog writes fatal entries and the stack
+// traces dumped before exiting into the FATAL output first and the INFO output last.
+type fatalOutput struct {
+	*output
+}
+
+func (o fatalOutput) Write(p []byte) (int, error) {
+	atomic.StoreInt32(&o.fatal, 1)
+	return len(p), nil
+}
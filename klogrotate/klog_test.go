@@ -0,0 +1,77 @@
+package klogrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"k8s.io/klog/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirect(t *testing.T) {
+	newWriter := func(t *testing.T) (*logrotate.Writer, string) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		w, err := logrotate.New(nil, logrotate.Options{Directory: dir})
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		return w, dir
+	}
+
+	readAll := func(t *testing.T, dir string) string {
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("writes each entry once", func(t *testing.T) {
+		w, dir := newWriter(t)
+		restore := Redirect(w, nil)
+
+		klog.Info("info message")
+		klog.Warning("warning message")
+		klog.Error("error message")
+		restore()
+
+		contents := readAll(t, dir)
+		for _, message := range []string{"info message", "warning message", "error message"} {
+			require.Equal(t, 1, strings.Count(contents, message), message)
+		}
+		require.Equal(t, 3, strings.Count(contents, "\n"))
+	})
+
+	t.Run("flushes periodically", func(t *testing.T) {
+		w, dir := newWriter(t)
+		restore := Redirect(w, &Options{FlushInterval: 10 * time.Millisecond})
+		defer restore()
+
+		klog.Info("flushed message")
+		require.Eventually(t, func() bool {
+			return strings.Contains(readAll(t, dir), "flushed message")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("syncs before exiting", func(t *testing.T) {
+		w, dir := newWriter(t)
+		restore := Redirect(w, &Options{FlushInterval: time.Hour})
+		defer restore()
+
+		defer func(exit func(int)) { klog.OsExit = exit }(klog.OsExit)
+		klog.OsExit = func(code int) {
+			panic(code)
+		}
+
+		require.PanicsWithValue(t, 1, func() { klog.Exit("exit message") })
+		require.Contains(t, readAll(t, dir), "exit message")
+	})
+}
@@ -0,0 +1,33 @@
+package logrotate
+
+import "time"
+
+// timedFile reports the duration of the writes and syncs of a File to Metrics.
+type timedFile struct {
+	File
+	metrics Metrics
+	now     func() time.Time
+}
+
+func (f *timedFile) Write(p []byte) (int, error) {
+	start := f.now()
+	n, err := f.File.Write(p)
+	f.metrics.ObserveDuration(MetricFileWriteDuration, f.now().Sub(start))
+	return n, err
+}
+
+func (f *timedFile) Sync() error {
+	start := f.now()
+	err := f.File.Sync()
+	f.metrics.ObserveDuration(MetricFileSyncDuration, f.now().Sub(start))
+	return err
+}
+
+// recordDequeue reports the depth of the queue once e has been taken from it,
+// and the time e spent queued.
+func (w *Writer) recordDequeue(e entry) {
+	w.recordQueueDepth()
+	if !e.queued.IsZero() {
+		w.metrics.ObserveDuration(MetricQueueLatency, w.now().Sub(e.queued))
+	}
+}
@@ -0,0 +1,161 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLockFile is the name of the lock file within Directory, see LockOptions.
+const DefaultLockFile = ".lock"
+
+// lockPollInterval is how often a lock held by another process is tried again
+// while waiting for it, see LockOptions.Wait.
+const lockPollInterval = 50 * time.Millisecond
+
+// LockOptions configure the lock preventing several processes from writing
+// into the same Directory, see Options.Lock.
+type LockOptions struct {
+	// Path is the lock file, created if it does not exist. Relative paths are
+	// relative to Directory. Defaults to DefaultLockFile within Directory.
+	Path string
+
+	// Wait is how long New waits for another process to release the lock.
+	// Defaults to 0, New fails immediately with ErrLocked.
+	Wait time.Duration
+}
+
+func (o LockOptions) validate() error {
+	if o.Wait < 0 {
+		return errors.Errorf("Wait must not be negative, got %v", o.Wait)
+	}
+	return nil
+}
+
+// path returns the path of the lock file of dir.
+func (o LockOptions) path(dir string) string {
+	if o.Path == "" {
+		return filepath.Join(dir, DefaultLockFile)
+	}
+	if filepath.IsAbs(o.Path) {
+		return o.Path
+	}
+	return filepath.Join(dir, o.Path)
+}
+
+// dirLock is an advisory lock held on a lock file until it is released.
+type dirLock struct {
+	f *os.File
+}
+
+// acquireLock locks the lock file of opts, waiting up to opts.Lock.Wait
+// for another process to release it. The lock is shared in Shared mode,
+// otherwise the lock file records the process ID of the holder.
+// A lock which is held is never taken over, even though the recorded process
+// is not running, since it may run in another PID namespace or have passed the
+// lock on to a child, the process ID is only used to describe the holder.
+// A process ID left behind by a holder which stopped without releasing the
+// lock is overwritten, as the operating system released its lock.
+func acquireLock(opts Options) (*dirLock, error) {
+	path := opts.Lock.path(opts.Directory)
+	deadline := time.Now().Add(opts.Lock.Wait)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, opts.FileMode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open lock file %s", path)
+		}
+
+		err = tryLock(f, opts.Shared)
+		if err == nil {
+			l := &dirLock{f: f}
+			if opts.Shared {
+				// Shared holders are not recorded, remove the process ID of a previous holder.
+				err = f.Truncate(0)
+			} else {
+				err = l.writePID()
+			}
+			if err != nil {
+				l.release()
+				return nil, errors.Wrapf(err, "failed to update lock file %s", path)
+			}
+			return l, nil
+		}
+		if err != errLockHeld {
+			f.Close()
+			return nil, errors.Wrapf(err, "failed to lock %s", path)
+		}
+
+		pid := 0
+		if !opts.Shared {
+			pid = lockHolder(f)
+		}
+		f.Close()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, errors.Wrapf(ErrLocked, "lock file %s%s", path, describeHolder(pid))
+		}
+		if remaining > lockPollInterval {
+			remaining = lockPollInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// writePID records the process ID of the holder in the lock file.
+func (l *dirLock) writePID() error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := l.f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// lockHolder returns the process ID recorded in the lock file f, or 0.
+func lockHolder(f *os.File) int {
+	b := make([]byte, 32)
+	n, _ := f.ReadAt(b, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b[:n])))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// describeHolder describes the holder pid of a lock for ErrLocked.
+func describeHolder(pid int) string {
+	switch {
+	case pid == 0:
+		return ""
+	case pid != os.Getpid() && !processRunning(pid):
+		return fmt.Sprintf(" held by process %d, which is not running in this PID namespace, the lock may have been inherited by a child", pid)
+	default:
+		return fmt.Sprintf(" held by process %d", pid)
+	}
+}
+
+// release releases the lock, the lock file is kept for the next process,
+// without the process ID of the holder.
+func (l *dirLock) release() error {
+	l.f.Truncate(0)
+	err := unlock(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// releaseLock releases the lock of Options.Lock, if held.
+func (w *Writer) releaseLock() {
+	if w.lock == nil {
+		return
+	}
+	if err := w.lock.release(); err != nil {
+		w.report(errors.Wrap(err, "failed to release lock"))
+	}
+	w.lock = nil
+}
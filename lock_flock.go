@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"syscall"
+)
+
+// lockSupported reports whether Options.Lock is supported on this platform.
+const lockSupported = true
+
+// errLockHeld is returned by tryLock when another process holds the lock.
+var errLockHeld = errors.New("lock is held by another process")
+
+// tryLock takes an exclusive, or shared, flock on f without blocking.
+func tryLock(f *os.File, shared bool) error {
+	how := syscall.LOCK_EX
+	if shared {
+		how = syscall.LOCK_SH
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLockHeld
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// processRunning returns true if a process with the given ID is running.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,29 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"os"
+)
+
+// lockSupported reports whether Options.Lock is supported on this platform.
+const lockSupported = false
+
+// errLockHeld is returned by tryLock when another process holds the lock.
+var errLockHeld = errors.New("lock is held by another process")
+
+// tryLock is not supported on this platform, which has no flock.
+func tryLock(f *os.File, shared bool) error {
+	return errors.New("locking is not supported on this platform")
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
+
+// processRunning returns true, processes cannot be checked on this platform.
+func processRunning(pid int) bool {
+	return true
+}
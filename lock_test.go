@@ -0,0 +1,134 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriter_Lock(t *testing.T) {
+	if !lockSupported {
+		t.Skip("Lock is not supported on this platform")
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	t.Run("fails fast while another writer holds the lock", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(dir, DefaultLockFile))
+
+		_, err = New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.True(t, errors.Is(err, ErrLocked), "got %v", err)
+
+		require.NoError(t, w.Close())
+		w, err = New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.NoError(t, err, "lock must be released on Close")
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("waits for the lock", func(t *testing.T) {
+		dir := newDir(t)
+		opts := Options{Directory: dir, Lock: &LockOptions{Path: "app.lock", Wait: 5 * time.Second}}
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(dir, "app.lock"))
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			w.Close()
+		}()
+		start := time.Now()
+		w2, err := New(logger, opts)
+		require.NoError(t, err)
+		require.True(t, time.Since(start) >= 100*time.Millisecond)
+		require.NoError(t, w2.Close())
+	})
+
+	t.Run("gives up waiting", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.NoError(t, err)
+		defer w.Close()
+
+		_, err = New(logger, Options{Directory: dir, Lock: &LockOptions{Wait: 100 * time.Millisecond}})
+		require.True(t, errors.Is(err, ErrLocked), "got %v", err)
+	})
+
+	t.Run("records the process ID", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.NoError(t, err)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, DefaultLockFile))
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(b))
+
+		require.NoError(t, w.Close())
+		b, err = ioutil.ReadFile(filepath.Join(dir, DefaultLockFile))
+		require.NoError(t, err)
+		require.Empty(t, b)
+	})
+
+	t.Run("does not take over locks held for a process which is not running", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, DefaultLockFile)
+
+		// A process which is not running is recorded as the holder, while the
+		// lock is held, as by a child which inherited it or a process in
+		// another PID namespace.
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Run())
+		pid := strconv.Itoa(cmd.Process.Pid) + "\n"
+		require.NoError(t, ioutil.WriteFile(path, []byte(pid), 0644))
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, tryLock(f, false))
+
+		_, err = New(logger, Options{Directory: dir, Lock: &LockOptions{Wait: 2 * lockPollInterval}})
+		require.True(t, errors.Is(err, ErrLocked))
+		require.Contains(t, err.Error(), "held by process "+strconv.Itoa(cmd.Process.Pid))
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, pid, string(b))
+	})
+
+	t.Run("overwrites the process ID of a holder which stopped", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, DefaultLockFile)
+
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Run())
+		require.NoError(t, ioutil.WriteFile(path, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644))
+
+		w, err := New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.NoError(t, err)
+		defer w.Close()
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(b))
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		opts := Options{Directory: "logs", Lock: &LockOptions{Wait: -time.Second}}
+		require.Error(t, opts.Validate())
+
+		opts = Options{Directory: "logs", Lock: &LockOptions{}, FS: newMemFS()}
+		require.Error(t, opts.Validate())
+	})
+}
@@ -0,0 +1,32 @@
+package logrotate
+
+import "log"
+
+// Logger receives log lines produced by the Writer itself.
+// *log.Logger satisfies Logger, other logging libraries can be adapted with a single method.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggerFunc adapts a function to the Logger interface.
+type LoggerFunc func(format string, v ...interface{})
+
+// Printf calls f(format, v...).
+func (f LoggerFunc) Printf(format string, v ...interface{}) {
+	f(format, v...)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
+
+// loggerOrDiscard returns logger, or a Logger discarding all log lines if logger is nil.
+func loggerOrDiscard(logger Logger) Logger {
+	if logger == nil {
+		return discardLogger{}
+	}
+	if l, ok := logger.(*log.Logger); ok && l == nil {
+		return discardLogger{}
+	}
+	return logger
+}
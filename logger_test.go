@@ -0,0 +1,48 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	failing := Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+	}
+
+	t.Run("tolerates nil loggers", func(t *testing.T) {
+		var typedNil *log.Logger
+		for _, logger := range []Logger{nil, typedNil} {
+			w, err := New(logger, failing)
+			require.NoError(t, err)
+
+			_, err = w.Write([]byte("message"))
+			require.NoError(t, err)
+			require.Error(t, w.Close())
+		}
+	})
+
+	t.Run("accepts logger func", func(t *testing.T) {
+		var lines []string
+		w, err := New(LoggerFunc(func(format string, v ...interface{}) {
+			lines = append(lines, fmt.Sprintf(format, v...))
+		}), failing)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err)
+		require.Error(t, w.Close())
+
+		require.NotEmpty(t, lines, "must log failures")
+	})
+}
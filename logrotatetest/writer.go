@@ -0,0 +1,115 @@
+//go:build go1.15
+// +build go1.15
+
+// Package logrotatetest provides utilities for testing code writing logs with logrotate.
+//
+//	func TestRotation(t *testing.T) {
+//		w := logrotatetest.New(t, logrotate.Options{MaximumFileSize: 100})
+//		run(w)
+//		require.Len(t, w.Files(), 3)
+//	}
+package logrotatetest
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Writer is a logrotate.Writer writing into a temporary directory,
+// which is closed when the test and all its subtests complete.
+type Writer struct {
+	*logrotate.Writer
+
+	// Dir is the directory files are written to.
+	Dir string
+
+	t testing.TB
+
+	mu    sync.Mutex
+	names []string
+}
+
+// New creates a Writer with opts, writing into a new directory returned by t.TempDir.
+// opts.Directory is ignored. New fails the test when the Writer cannot be created.
+//
+// The Writer is closed on test cleanup, tests asserting on errors returned
+// by Close should call it themselves.
+func New(t testing.TB, opts logrotate.Options) *Writer {
+	t.Helper()
+
+	w := &Writer{
+		Dir: t.TempDir(),
+		t:   t,
+	}
+
+	fileNameFunc := opts.FileNameFunc
+	if fileNameFunc == nil {
+		fileNameFunc = logrotate.DefaultFilenameFunc
+	}
+	opts.Directory = w.Dir
+	opts.FileNameFunc = func() string {
+		name := fileNameFunc()
+		w.mu.Lock()
+		w.names = append(w.names, name)
+		w.mu.Unlock()
+		return name
+	}
+
+	writer, err := logrotate.New(nil, opts)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.Writer = writer
+	t.Cleanup(func() { _ = writer.Close() })
+
+	return w
+}
+
+// Files flushes the Writer and returns the paths of the files it created,
+// in the order they were created. Files which no longer exist are omitted.
+func (w *Writer) Files() []string {
+	w.t.Helper()
+
+	if err := w.Flush(); err != nil && !errors.Is(err, logrotate.ErrClosed) {
+		w.t.Fatalf("Failed to flush writer: %v", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var files []string
+	seen := map[string]bool{}
+	for _, name := range w.names {
+		path := filepath.Join(w.Dir, name)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Contents flushes the Writer and returns the contents of the files it created,
+// in the order they were created.
+func (w *Writer) Contents() []string {
+	w.t.Helper()
+
+	var contents []string
+	for _, path := range w.Files() {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			w.t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		contents = append(contents, string(b))
+	}
+	return contents
+}
@@ -0,0 +1,46 @@
+//go:build go1.15
+// +build go1.15
+
+package logrotatetest
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var w *Writer
+	t.Run("writes", func(t *testing.T) {
+		i := 0
+		w = New(t, logrotate.Options{
+			Directory:       "ignored",
+			MaximumFileSize: 13,
+			FileNameFunc: func() string {
+				i++
+				return strconv.Itoa(10-i) + ".log"
+			},
+		})
+
+		for _, message := range []string{"first\n", "second\n", "third\n"} {
+			_, err := w.Write([]byte(message))
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, []string{filepath.Join(w.Dir, "9.log"), filepath.Join(w.Dir, "8.log")}, w.Files(),
+			"files must be in creation order")
+		require.Equal(t, []string{"first\nsecond\n", "third\n"}, w.Contents())
+		_, err := os.Stat("ignored")
+		require.True(t, os.IsNotExist(err), "Directory must be ignored")
+	})
+
+	_, err := w.Write([]byte("message\n"))
+	require.True(t, errors.Is(err, logrotate.ErrClosed), "writer must be closed on cleanup")
+
+	_, err = os.Stat(w.Dir)
+	require.True(t, os.IsNotExist(err), "directory must be removed on cleanup")
+}
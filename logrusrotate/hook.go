@@ -0,0 +1,89 @@
+// Package logrusrotate provides a logrus hook writing entries into logrotate Writers.
+//
+// The hook can write all entries into a single Writer, or split entries by level
+// into separate Writers, each with its own rotation settings:
+//
+//	errors, _ := logrotate.New(nil, logrotate.Options{Directory: "/var/log/app/errors"})
+//	all, _ := logrotate.New(nil, logrotate.Options{Directory: "/var/log/app/all"})
+//
+//	logrus.AddHook(logrusrotate.NewLevelHook(map[logrus.Level]*logrotate.Writer{
+//		logrus.ErrorLevel: errors,
+//		logrus.InfoLevel:  all,
+//	}, nil))
+package logrusrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sort"
+)
+
+// Hook is a logrus.Hook writing formatted entries into logrotate Writers.
+type Hook struct {
+	formatter logrus.Formatter
+	writers   map[logrus.Level]*logrotate.Writer
+	levels    []logrus.Level
+}
+
+// NewHook returns a Hook writing entries of the given levels into w.
+// When no levels are given, entries of all levels are written.
+// When formatter is nil, entries are formatted with a logrus.TextFormatter without colors.
+func NewHook(w *logrotate.Writer, formatter logrus.Formatter, levels ...logrus.Level) *Hook {
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+
+	writers := make(map[logrus.Level]*logrotate.Writer, len(levels))
+	for _, level := range levels {
+		writers[level] = w
+	}
+
+	return NewLevelHook(writers, formatter)
+}
+
+// NewLevelHook returns a Hook writing entries of each level into the Writer
+// configured for it. Entries of levels without a Writer are ignored.
+// When formatter is nil, entries are formatted with a logrus.TextFormatter without colors.
+func NewLevelHook(writers map[logrus.Level]*logrotate.Writer, formatter logrus.Formatter) *Hook {
+	if formatter == nil {
+		formatter = &logrus.TextFormatter{DisableColors: true}
+	}
+
+	levels := make([]logrus.Level, 0, len(writers))
+	for level := range writers {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	return &Hook{
+		formatter: formatter,
+		writers:   writers,
+		levels:    levels,
+	}
+}
+
+// Levels returns the levels for which a Writer is configured.
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire formats the entry and writes it into the Writer configured for its level.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	w, ok := h.writers[entry.Level]
+	if !ok {
+		return nil
+	}
+
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to format entry")
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return errors.Wrap(err, "failed to write entry")
+	}
+	return nil
+}
+
+var _ logrus.Hook = (*Hook)(nil)
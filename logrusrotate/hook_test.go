@@ -0,0 +1,60 @@
+package logrusrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLevelHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newWriter := func(name string) *logrotate.Writer {
+		w, err := logrotate.New(nil, logrotate.Options{
+			Directory:    filepath.Join(dir, name),
+			FileNameFunc: func() string { return name + ".log" },
+		})
+		require.NoError(t, err)
+		return w
+	}
+	errorsWriter, infoWriter := newWriter("errors"), newWriter("info")
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(NewLevelHook(map[logrus.Level]*logrotate.Writer{
+		logrus.ErrorLevel: errorsWriter,
+		logrus.InfoLevel:  infoWriter,
+	}, &logrus.JSONFormatter{}))
+
+	logger.Info("started")
+	logger.Error("failed")
+	logger.Warn("ignored")
+
+	require.NoError(t, errorsWriter.Close())
+	require.NoError(t, infoWriter.Close())
+
+	errorsLog, err := ioutil.ReadFile(filepath.Join(dir, "errors", "errors.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(errorsLog), `"msg":"failed"`)
+	require.NotContains(t, string(errorsLog), "started")
+
+	infoLog, err := ioutil.ReadFile(filepath.Join(dir, "info", "info.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(infoLog), `"msg":"started"`)
+	require.NotContains(t, string(infoLog), "ignored")
+}
+
+func TestHook_AllLevels(t *testing.T) {
+	w, err := logrotate.New(nil, logrotate.Options{Directory: os.TempDir()})
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.Equal(t, logrus.AllLevels, NewHook(w, nil).Levels())
+	require.Equal(t, []logrus.Level{logrus.ErrorLevel}, NewHook(w, nil, logrus.ErrorLevel).Levels())
+}
@@ -0,0 +1,331 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCleanupInterval is the CleanupInterval used when none is specified.
+const defaultCleanupInterval = time.Minute
+
+// ManagerOptions configure a Manager.
+type ManagerOptions struct {
+	// Writers are the options of each Writer of the Manager, by name, for
+	// example "app", "access" and "audit". Each Writer must have its own
+	// Directory, which must not contain other files than the Writer's, since
+	// the Manager removes them.
+	Writers map[string]Options
+
+	// MaximumTotalSize is the number of bytes the files of all the Writers may
+	// occupy together. When exceeded, the oldest closed files are removed,
+	// regardless of their Writer, together with their checksum and metadata
+	// sidecars. Current files and files which have not been uploaded yet are
+	// never removed, and are counted.
+	MaximumTotalSize int64
+
+	// CleanupInterval is the interval at which the size of the files is
+	// checked, in addition to whenever one of the Writers closes a file.
+	// When CleanupInterval == 0, a default of 1 minute will be used.
+	CleanupInterval time.Duration
+
+	// NewTicker creates the Ticker firing every CleanupInterval.
+	// When NewTicker is not specified, a time.Ticker will be used.
+	NewTicker func(d time.Duration) Ticker
+
+	// Debug logs the decisions of the Manager to its logger, prefixed with
+	// "[debug]": when the files exceed MaximumTotalSize, which files are removed
+	// and which are kept, and why.
+	Debug bool
+}
+
+func (o ManagerOptions) validate() error {
+	if len(o.Writers) == 0 {
+		return errors.New("Writers must not be empty")
+	}
+	dirs := make(map[string]string, len(o.Writers))
+	for name, opts := range o.Writers {
+		if err := opts.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid options of writer %s", name)
+		}
+		dir := filepath.Clean(opts.Directory)
+		if other, ok := dirs[dir]; ok {
+			return errors.Errorf("writers %s and %s must have distinct directories", other, name)
+		}
+		dirs[dir] = name
+	}
+	if o.MaximumTotalSize < 1 {
+		return errors.Errorf("MaximumTotalSize must be positive, got %d", o.MaximumTotalSize)
+	}
+	if o.CleanupInterval < 0 {
+		return errors.Errorf("CleanupInterval must not be negative, got %v", o.CleanupInterval)
+	}
+	return nil
+}
+
+// Manager owns several Writers, such as the application, access and audit
+// logs of a service, and keeps their files within a disk budget they share,
+// rather than each Writer being sized in isolation.
+type Manager struct {
+	logger  Logger
+	opts    ManagerOptions
+	writers map[string]*Writer
+	// dirs are the FS and Directory of each Writer, as created
+	dirs map[*Writer]managedDir
+
+	// cleanupMu serializes cleanups
+	cleanupMu sync.Mutex
+	// closed signals the Writers closed a file
+	closed    chan struct{}
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// managedDir is the directory of a Writer of a Manager.
+type managedDir struct {
+	fs   FS
+	path string
+	// reserved are the paths of the lock file and manifests of the Writer
+	reserved map[string]bool
+}
+
+func newManagedDir(opts Options) managedDir {
+	dir := managedDir{fs: opts.FS, path: opts.Directory, reserved: map[string]bool{opts.UploadManifest: true}}
+	if opts.Lock != nil {
+		dir.reserved[opts.Lock.path(opts.Directory)] = true
+	}
+	if path := opts.RotationManifest; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(opts.Directory, path)
+		}
+		dir.reserved[path] = true
+	}
+	return dir
+}
+
+// NewManager creates a Manager, and its Writers.
+func NewManager(logger Logger, opts ManagerOptions) (*Manager, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid manager options")
+	}
+	if opts.CleanupInterval == 0 {
+		opts.CleanupInterval = defaultCleanupInterval
+	}
+	if opts.NewTicker == nil {
+		opts.NewTicker = newTimeTicker
+	}
+
+	m := &Manager{
+		logger:  loggerOrDiscard(logger),
+		opts:    opts,
+		writers: make(map[string]*Writer, len(opts.Writers)),
+		dirs:    make(map[*Writer]managedDir, len(opts.Writers)),
+		closed:  make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for name, writerOpts := range opts.Writers {
+		onFileClose := writerOpts.OnFileClose
+		writerOpts.OnFileClose = func(e FileCloseEvent) {
+			if onFileClose != nil {
+				onFileClose(e)
+			}
+			select {
+			case m.closed <- struct{}{}:
+			default:
+			}
+		}
+		w, err := New(logger, writerOpts)
+		if err != nil {
+			closeWriters(m.writers)
+			return nil, errors.Wrapf(err, "failed to create writer %s", name)
+		}
+		m.writers[name] = w
+		m.dirs[w] = newManagedDir(w.opts)
+	}
+
+	go m.run()
+
+	return m, nil
+}
+
+// Writer returns the Writer named name, or nil if there is no such Writer.
+func (m *Manager) Writer(name string) *Writer {
+	return m.writers[name]
+}
+
+func (m *Manager) run() {
+	defer close(m.done)
+
+	ticker := m.opts.NewTicker(m.opts.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Cleanup(); err != nil {
+			m.logger.Printf("Failed to clean up files: %v", err)
+		}
+		select {
+		case <-ticker.C():
+		case <-m.closed:
+		case <-m.closing:
+			return
+		}
+	}
+}
+
+// managedFile is a file of a Writer, with its sidecars.
+type managedFile struct {
+	fs    FS
+	w     *Writer
+	paths []string
+	size  int64
+	mod   time.Time
+	// kept is why the file cannot be removed, such as being the current
+	// file, empty when the file can be removed
+	kept string
+}
+
+// Cleanup removes the oldest closed files of the Writers until they are within
+// MaximumTotalSize. Cleanup is called periodically, see CleanupInterval.
+func (m *Manager) Cleanup() error {
+	m.cleanupMu.Lock()
+	defer m.cleanupMu.Unlock()
+
+	var (
+		files    []*managedFile
+		kept     []*managedFile
+		total    int64
+		firstErr error
+	)
+	for name, w := range m.writers {
+		wfiles, err := m.listFiles(w)
+		if err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to list files of writer %s", name)
+		}
+		for _, f := range wfiles {
+			total += f.size
+			if f.kept == "" {
+				files = append(files, f)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+	}
+	if total <= m.opts.MaximumTotalSize {
+		return firstErr
+	}
+	m.debugf("Files use %d bytes, exceeding MaximumTotalSize of %d bytes, %d files can be removed", total, m.opts.MaximumTotalSize, len(files))
+	for _, f := range kept {
+		m.debugf("Keeping %s, %s", f.paths[0], f.kept)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].mod.Equal(files[j].mod) {
+			return files[i].mod.Before(files[j].mod)
+		}
+		return files[i].paths[0] < files[j].paths[0]
+	})
+
+	for _, f := range files {
+		if total <= m.opts.MaximumTotalSize {
+			break
+		}
+		// the Writer may have reopened the file, or closed the file it was
+		// writing to, since the files were listed
+		if kept := keptReason(f.w, f.paths[0], f.w.CurrentFilename()); kept != "" {
+			m.debugf("Keeping %s, %s", f.paths[0], kept)
+			continue
+		}
+		m.debugf("Removing %s, the oldest removable file, modified at %s, %d bytes with its sidecars", f.paths[0], f.mod.Format(time.RFC3339), f.size)
+		if err := f.fs.Remove(f.paths[0]); err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to remove %s", f.paths[0])
+			}
+			continue
+		}
+		for _, sidecar := range f.paths[1:] {
+			if err := f.fs.Remove(sidecar); err != nil && firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to remove %s", sidecar)
+			}
+		}
+		m.logger.Printf("Removed %s to keep files within %d bytes", f.paths[0], m.opts.MaximumTotalSize)
+		f.w.recordPruned(f.paths[0])
+		total -= f.size
+	}
+	return firstErr
+}
+
+// listFiles returns the files in the Directory of w, grouped with their sidecars.
+// The lock file, manifests and hidden files are ignored.
+func (m *Manager) listFiles(w *Writer) ([]*managedFile, error) {
+	dir := m.dirs[w]
+	infos, err := dir.fs.ReadDir(dir.path)
+	if err != nil {
+		return nil, err
+	}
+	// the current file is looked up after listing, so that files listed are
+	// either current or closed
+	current := w.CurrentFilename()
+
+	files := make(map[string]*managedFile)
+	var sidecars []os.FileInfo
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasSuffix(name, ChecksumSuffix) || strings.HasSuffix(name, MetadataSuffix) {
+			sidecars = append(sidecars, info)
+			continue
+		}
+		path := filepath.Join(dir.path, name)
+		if dir.reserved[path] {
+			continue
+		}
+		files[name] = &managedFile{
+			fs:    dir.fs,
+			w:     w,
+			paths: []string{path},
+			size:  info.Size(),
+			mod:   info.ModTime(),
+			kept:  keptReason(w, path, current),
+		}
+	}
+	for _, info := range sidecars {
+		name := strings.TrimSuffix(strings.TrimSuffix(info.Name(), ChecksumSuffix), MetadataSuffix)
+		if f, ok := files[name]; ok {
+			f.paths = append(f.paths, filepath.Join(dir.path, info.Name()))
+			f.size += info.Size()
+		}
+	}
+
+	list := make([]*managedFile, 0, len(files))
+	for _, f := range files {
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// keptReason returns why the file at path of w cannot be removed, given the
+// current file of w, or an empty string if it can be removed.
+func keptReason(w *Writer, path, current string) string {
+	switch {
+	case path == current:
+		return "it is the current file"
+	case w.awaitingUpload(path):
+		return "it has not been uploaded yet"
+	}
+	return ""
+}
+
+// Close stops cleaning up files, and closes the Writers concurrently. Close
+// returns the first error encountered.
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() { close(m.closing) })
+	<-m.done
+	return closeWriters(m.writers)
+}
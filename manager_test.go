@@ -0,0 +1,147 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("removes the oldest files of all writers beyond the budget", func(t *testing.T) {
+		root, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(root)
+
+		now := time.Now()
+		createFile := func(path string, size int, age time.Duration) {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, ioutil.WriteFile(path, []byte(strings.Repeat("x", size)), 0644))
+			require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		}
+		app, access := filepath.Join(root, "app"), filepath.Join(root, "access")
+		createFile(filepath.Join(app, "1.log"), 100, 3*time.Hour)
+		createFile(filepath.Join(access, "2.log"), 100, 2*time.Hour)
+		createFile(filepath.Join(access, "2.log"+ChecksumSuffix), 10, 2*time.Hour)
+		createFile(filepath.Join(app, "3.log"), 100, time.Hour)
+
+		m, err := NewManager(logger, ManagerOptions{
+			Writers: map[string]Options{
+				"app":    {Directory: app, FileNameFunc: func() string { return "current.log" }, Lock: &LockOptions{Path: "app.lock"}},
+				"access": {Directory: access},
+			},
+			MaximumTotalSize: 250,
+			CleanupInterval:  time.Hour,
+		})
+		require.NoError(t, err)
+		defer m.Close()
+
+		exists := func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}
+
+		require.NoError(t, m.Cleanup())
+		require.False(t, exists(filepath.Join(app, "1.log")))
+		require.True(t, exists(filepath.Join(access, "2.log")))
+		require.True(t, exists(filepath.Join(app, "3.log")))
+
+		_, err = m.Writer("app").Write([]byte(strings.Repeat("y", 99) + "\n"))
+		require.NoError(t, err)
+		require.NoError(t, m.Writer("app").Flush())
+		require.NoError(t, m.Cleanup())
+		require.False(t, exists(filepath.Join(access, "2.log")))
+		require.False(t, exists(filepath.Join(access, "2.log"+ChecksumSuffix)))
+		require.True(t, exists(filepath.Join(app, "3.log")))
+		require.True(t, exists(filepath.Join(app, "current.log")))
+		require.True(t, exists(filepath.Join(app, "app.lock")))
+
+		require.NoError(t, m.Close())
+	})
+
+	t.Run("keeps files reopened after they were listed", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		now := time.Now()
+		for i, name := range []string{"1.log", "2.log"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, ioutil.WriteFile(path, []byte(strings.Repeat("x", 100)), 0644))
+			age := time.Duration(2-i) * time.Hour
+			require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		}
+
+		// the Writer reopens 2.log while 1.log is removed, after the files
+		// were listed
+		var (
+			mu   sync.Mutex
+			name = "current.log"
+		)
+		ready := make(chan struct{})
+		var m *Manager
+		fs := removeHookFS{FS: OSFS{}, onRemove: func(path string) {
+			if filepath.Base(path) != "1.log" {
+				return
+			}
+			<-ready
+			mu.Lock()
+			name = "2.log"
+			mu.Unlock()
+			_, err := m.Writer("app").Write([]byte("reopened\n"))
+			require.NoError(t, err)
+			require.NoError(t, m.Writer("app").Flush())
+		}}
+		m, err = NewManager(logger, ManagerOptions{
+			Writers: map[string]Options{
+				"app": {Directory: dir, FS: fs, FileNameFunc: func() string {
+					mu.Lock()
+					defer mu.Unlock()
+					return name
+				}},
+			},
+			MaximumTotalSize: 50,
+			CleanupInterval:  time.Hour,
+		})
+		close(ready)
+		require.NoError(t, err)
+		defer m.Close()
+
+		require.NoError(t, m.Cleanup())
+		_, err = os.Stat(filepath.Join(dir, "1.log"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "2.log"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "2.log"), m.Writer("app").CurrentFilename())
+
+		require.NoError(t, m.Close())
+	})
+
+	t.Run("rejects writers sharing a directory", func(t *testing.T) {
+		_, err := NewManager(logger, ManagerOptions{
+			Writers:          map[string]Options{"app": {Directory: "logs"}, "access": {Directory: "logs/"}},
+			MaximumTotalSize: 1 << 20,
+		})
+		require.Error(t, err)
+		_, err = NewManager(logger, ManagerOptions{Writers: map[string]Options{"app": {Directory: "logs"}}})
+		require.Error(t, err)
+	})
+}
+
+// removeHookFS calls onRemove before removing a file.
+type removeHookFS struct {
+	FS
+	onRemove func(name string)
+}
+
+func (fs removeHookFS) Remove(name string) error {
+	fs.onRemove(name)
+	return fs.FS.Remove(name)
+}
@@ -0,0 +1,248 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUploadManifest is the name of the upload manifest within Directory,
+// see Options.UploadManifest.
+const DefaultUploadManifest = ".upload-manifest"
+
+// manifest tracks files which have not been uploaded yet, so that files rotated
+// while uploads were failing, or while the process was stopped, are uploaded
+// once the Writer is created again.
+type manifest struct {
+	path string
+
+	mu    sync.Mutex
+	files []manifestFile
+}
+
+// manifestFile is a file awaiting to be uploaded, or still being written to.
+type manifestFile struct {
+	Path   string    `json:"path"`
+	Size   int64     `json:"size"`
+	Opened time.Time `json:"opened"`
+	// Closed is zero while the file is being written to.
+	Closed time.Time `json:"closed"`
+	SHA256 string    `json:"sha256,omitempty"`
+}
+
+// corruptManifestSuffix is appended to the name of an upload manifest which
+// cannot be parsed, when it is moved aside, see loadManifest.
+const corruptManifestSuffix = ".corrupt"
+
+// loadManifest reads the upload manifest of opts, a missing manifest is empty.
+// A manifest which cannot be parsed is moved aside, and replaced by the files
+// found in Directory, so that files awaiting to be uploaded are not lost,
+// at the cost of uploading again files which were already uploaded.
+func loadManifest(logger Logger, opts Options) (*manifest, error) {
+	path := opts.UploadManifest
+	m := &manifest{path: path}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read upload manifest")
+	}
+	err = json.Unmarshal(b, &m.files)
+	if err == nil {
+		return m, nil
+	}
+	logger.Printf("Failed to parse upload manifest %s, moving it to %s and scanning %s for files to upload: %v",
+		path, path+corruptManifestSuffix, opts.Directory, err)
+
+	if err := os.Rename(path, path+corruptManifestSuffix); err != nil {
+		return nil, errors.Wrap(err, "failed to move corrupt upload manifest aside")
+	}
+	if m.files, err = scanManifest(opts); err != nil {
+		return nil, errors.Wrapf(err, "failed to scan %s for files to upload", opts.Directory)
+	}
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// scanManifest returns the files of Directory, as if they were still being
+// written to, so that their size and closing time are taken from the file
+// system, see pending. Sidecars, manifests and hidden files are skipped.
+func scanManifest(opts Options) ([]manifestFile, error) {
+	infos, err := opts.FS.ReadDir(opts.Directory)
+	if err != nil {
+		return nil, err
+	}
+	skipped := map[string]bool{
+		filepath.Clean(opts.UploadManifest): true,
+	}
+	if path := opts.RotationManifest; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(opts.Directory, path)
+		}
+		skipped[filepath.Clean(path)] = true
+	}
+
+	var files []manifestFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ChecksumSuffix) || strings.HasSuffix(name, MetadataSuffix) {
+			continue
+		}
+		path := filepath.Join(opts.Directory, name)
+		if skipped[path] || strings.HasSuffix(path, corruptManifestSuffix) {
+			continue
+		}
+		files = append(files, manifestFile{Path: path, Opened: info.ModTime().UTC()})
+	}
+	return files, nil
+}
+
+// pending returns the uploads of files in the manifest, oldest first.
+// Files which were still being written to when the manifest was saved, because
+// the process stopped without closing the Writer, are considered closed at their
+// last modification. Files which no longer exist are removed from the manifest.
+func (m *manifest) pending(stat func(path string) (os.FileInfo, error)) ([]upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := m.files[:0]
+	for _, f := range m.files {
+		info, err := stat(f.Path)
+		if err != nil {
+			continue
+		}
+		if f.Closed.IsZero() {
+			f.Size = info.Size()
+			f.Closed = info.ModTime().UTC()
+		}
+		files = append(files, f)
+	}
+	m.files = files
+	sort.SliceStable(m.files, func(i, j int) bool { return m.files[i].Closed.Before(m.files[j].Closed) })
+
+	ups := make([]upload, 0, len(m.files))
+	for _, f := range m.files {
+		up := upload{path: f.Path, meta: f.meta()}
+		if f.SHA256 != "" {
+			if _, err := stat(f.Path + ChecksumSuffix); err == nil {
+				up.sidecars = append(up.sidecars, f.Path+ChecksumSuffix)
+			}
+		}
+		if _, err := stat(f.Path + MetadataSuffix); err == nil {
+			up.sidecars = append(up.sidecars, f.Path+MetadataSuffix)
+		}
+		ups = append(ups, up)
+	}
+	return ups, m.save()
+}
+
+// open records a file being written to, which is uploaded on the next start
+// if the process stops before the file is closed.
+func (m *manifest) open(path string, opened time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.find(path) < 0 {
+		m.files = append(m.files, manifestFile{Path: path, Opened: opened})
+	}
+	return m.save()
+}
+
+// close records a closed file awaiting to be uploaded.
+func (m *manifest) close(path string, meta FileMeta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := manifestFile{Path: path, Size: meta.Size, Opened: meta.Opened, Closed: meta.Closed, SHA256: meta.SHA256}
+	if i := m.find(path); i >= 0 {
+		m.files[i] = f
+	} else {
+		m.files = append(m.files, f)
+	}
+	return m.save()
+}
+
+// remove removes an uploaded file from the manifest.
+func (m *manifest) remove(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.find(path)
+	if i < 0 {
+		return nil
+	}
+	m.files = append(m.files[:i], m.files[i+1:]...)
+	return m.save()
+}
+
+// contains reports whether a file is awaiting to be uploaded, or still being written to.
+func (m *manifest) contains(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.find(path) >= 0
+}
+
+func (m *manifest) find(path string) int {
+	for i, f := range m.files {
+		if f.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the manifest, replacing it atomically.
+func (m *manifest) save() error {
+	b, err := json.Marshal(m.files)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode upload manifest")
+	}
+
+	tmp := m.path + ".tmp"
+	if err := writeFileSync(tmp, b); err != nil {
+		return errors.Wrap(err, "failed to write upload manifest")
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return errors.Wrap(err, "failed to replace upload manifest")
+	}
+	return nil
+}
+
+// writeFileSync writes b to the file at path, and syncs it to disk, so that
+// it is complete before it replaces a previous version.
+func writeFileSync(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (f manifestFile) meta() FileMeta {
+	return FileMeta{
+		Name:   filepath.Base(f.Path),
+		Size:   f.Size,
+		Opened: f.Opened,
+		Closed: f.Closed,
+		SHA256: f.SHA256,
+	}
+}
@@ -0,0 +1,166 @@
+package logrotate
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriter_UploadManifest(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	// recorder is an Uploader recording the files uploaded, failing while fail is set.
+	type recorder struct {
+		mu       sync.Mutex
+		fail     bool
+		uploaded []FileMeta
+	}
+	newUploader := func(r *recorder) Uploader {
+		return uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.fail {
+				return errors.New("network is down")
+			}
+			r.uploaded = append(r.uploaded, meta)
+			return nil
+		})
+	}
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	readManifest := func(t *testing.T, dir string) []manifestFile {
+		b, err := ioutil.ReadFile(filepath.Join(dir, DefaultUploadManifest))
+		require.NoError(t, err)
+		var files []manifestFile
+		require.NoError(t, json.Unmarshal(b, &files))
+		return files
+	}
+
+	t.Run("uploads files left over by the previous Writer", func(t *testing.T) {
+		dir := newDir(t)
+		i := 0
+		opts := Options{
+			Directory:       dir,
+			MaximumFileSize: 10,
+			FileNameFunc: func() string {
+				i++
+				return fmt.Sprintf("%d.log", i)
+			},
+			DeleteAfterUpload: true,
+		}
+
+		down := &recorder{fail: true}
+		opts.Uploader = newUploader(down)
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		for j := 0; j < 2; j++ {
+			_, err := w.Write([]byte("message\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		require.Len(t, readManifest(t, dir), 2)
+
+		up := &recorder{}
+		opts.Uploader = newUploader(up)
+		w, err = New(logger, opts)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Len(t, up.uploaded, 2)
+		require.Equal(t, "1.log", up.uploaded[0].Name)
+		require.Equal(t, "2.log", up.uploaded[1].Name)
+		require.Equal(t, int64(8), up.uploaded[0].Size)
+		require.Empty(t, readManifest(t, dir))
+		require.Empty(t, logFiles(t, dir))
+	})
+
+	t.Run("uploads the file being written when the process stopped", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, "app.log")
+		require.NoError(t, ioutil.WriteFile(path, []byte("message\n"), 0644))
+		modTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+		opened := modTime.Add(-time.Hour)
+		b, err := json.Marshal([]manifestFile{
+			{Path: path, Opened: opened},
+			{Path: filepath.Join(dir, "missing.log"), Opened: opened, Closed: opened, Size: 8},
+		})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, DefaultUploadManifest), b, 0644))
+
+		up := &recorder{}
+		w, err := New(logger, Options{Directory: dir, Uploader: newUploader(up)})
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []FileMeta{{Name: "app.log", Size: 8, Opened: opened, Closed: modTime}}, up.uploaded,
+			"missing files must be skipped")
+		require.Empty(t, readManifest(t, dir))
+	})
+
+	t.Run("tracks the current file", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{Directory: dir, Uploader: newUploader(&recorder{})})
+		require.NoError(t, err)
+		defer w.Close()
+
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+
+		files := readManifest(t, dir)
+		require.Len(t, files, 1)
+		require.Equal(t, w.CurrentFilename(), files[0].Path)
+		require.True(t, files[0].Closed.IsZero())
+	})
+
+	t.Run("rescans the directory when the manifest is corrupt", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, "app.log")
+		require.NoError(t, ioutil.WriteFile(path, []byte("message\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(path+MetadataSuffix, []byte("{}"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, DefaultUploadManifest), []byte("{"), 0644))
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rotations.jsonl"), nil, 0644))
+
+		up := &recorder{}
+		w, err := New(logger, Options{Directory: dir, Uploader: newUploader(up), RotationManifest: "rotations.jsonl"})
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Len(t, up.uploaded, 2, "the file and its sidecar must be uploaded once")
+		require.Equal(t, "app.log", up.uploaded[0].Name)
+		require.Equal(t, "app.log"+MetadataSuffix, up.uploaded[1].Name)
+		require.Empty(t, readManifest(t, dir))
+		b, err := ioutil.ReadFile(filepath.Join(dir, DefaultUploadManifest+corruptManifestSuffix))
+		require.NoError(t, err)
+		require.Equal(t, "{", string(b))
+	})
+
+	t.Run("does not publish expvars when the manifest cannot be read", func(t *testing.T) {
+		dir := newDir(t)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, DefaultUploadManifest), 0755))
+
+		prefix := "logrotate.test.manifest." + filepath.Base(dir)
+		_, err := New(logger, Options{Directory: dir, Uploader: newUploader(&recorder{}), ExpvarPrefix: prefix})
+		require.Error(t, err)
+		require.Nil(t, expvar.Get(prefix+".bytes_written"))
+	})
+}
@@ -0,0 +1,53 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"time"
+)
+
+// rotationMarker is the line written at the end and the beginning of rotated
+// files, see Options.RotationMarkers.
+type rotationMarker struct {
+	Logrotate string    `json:"logrotate"`
+	To        string    `json:"to,omitempty"`
+	From      string    `json:"from,omitempty"`
+	Reason    string    `json:"reason"`
+	Time      time.Time `json:"time"`
+}
+
+// writeMarker writes m into the current file, with Options.RotationMarkers.
+// Like the header and footer, markers are not entries.
+func (w *Writer) writeMarker(m rotationMarker) {
+	if !w.opts.RotationMarkers {
+		return
+	}
+	m.Logrotate = "rotated"
+	line, err := json.Marshal(m)
+	if err != nil {
+		w.report(errors.Wrap(err, "failed to encode rotation marker"))
+		return
+	}
+
+	b := append(w.separator(), w.arrayElement(w.crlf(append(line, '\n')))...)
+	if _, err := (fileWriter{w}).Write(b); err != nil {
+		err = errors.Wrapf(err, "failed to write rotation marker into %s", w.path)
+		w.logger.Printf("%v", err)
+		w.report(err)
+	}
+}
+
+// markSuccessor writes a marker pointing to the file at next into the current
+// file, before it is closed by a rotation.
+func (w *Writer) markSuccessor(next string, reason RotationReason) {
+	w.writeMarker(rotationMarker{To: filepath.Base(next), Reason: reason.String(), Time: w.now().UTC()})
+}
+
+// markPredecessor writes a marker pointing to the file at previous into the
+// file which has just been opened by a rotation. The marker counts as part of
+// the header, a file holding only its header and marker is not rotated.
+func (w *Writer) markPredecessor(previous string, reason RotationReason) {
+	w.writeMarker(rotationMarker{From: filepath.Base(previous), Reason: reason.String(), Time: w.now().UTC()})
+	w.headerSize = w.bytesWritten
+}
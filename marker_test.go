@@ -0,0 +1,81 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_RotationMarkers(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newWriter := func(t *testing.T, fs *memFS, opts Options) *Writer {
+		files := 0
+		opts.Directory = "logs"
+		opts.FS = fs
+		opts.RotationMarkers = true
+		opts.Now = newFakeClock().Now
+		opts.FileNameFunc = func() string {
+			files++
+			return fmt.Sprintf("%d.log", files)
+		}
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		return w
+	}
+
+	t.Run("links rotated files", func(t *testing.T) {
+		fs := newMemFS()
+		w := newWriter(t, fs, Options{MaximumFileSize: 100})
+		for _, msg := range []string{"first\n", "second\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+			require.NoError(t, w.Rotate())
+		}
+		require.NoError(t, w.Close())
+
+		require.Equal(t, "first\n"+
+			`{"logrotate":"rotated","to":"2.log","reason":"manual","time":"2020-03-28T15:00:00Z"}`+"\n",
+			fs.contents(filepath.Join("logs", "1.log")))
+		require.Equal(t, `{"logrotate":"rotated","from":"1.log","reason":"manual","time":"2020-03-28T15:00:00Z"}`+"\n"+
+			"second\n"+
+			`{"logrotate":"rotated","to":"3.log","reason":"manual","time":"2020-03-28T15:00:00Z"}`+"\n",
+			fs.contents(filepath.Join("logs", "2.log")))
+		require.Equal(t, `{"logrotate":"rotated","from":"2.log","reason":"manual","time":"2020-03-28T15:00:00Z"}`+"\n",
+			fs.contents(filepath.Join("logs", "3.log")), "Close must not write a marker")
+	})
+
+	t.Run("does not rotate files holding only a marker", func(t *testing.T) {
+		fs := newMemFS()
+		w := newWriter(t, fs, Options{MaximumFileSize: 100})
+		_, err := w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+		_, err = w.Write([]byte(fmt.Sprintf("%099d\n", 0)))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		infos, err := fs.ReadDir("logs")
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+	})
+
+	t.Run("writes markers as elements of JSON arrays", func(t *testing.T) {
+		fs := newMemFS()
+		w := newWriter(t, fs, Options{JSONArray: true})
+		_, err := w.Write([]byte(`{"msg":"first"}`))
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+		require.NoError(t, w.Close())
+
+		for name, length := range map[string]int{"1.log": 2, "2.log": 1} {
+			var elements []map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(fs.contents(filepath.Join("logs", name))), &elements))
+			require.Len(t, elements, length, name)
+		}
+	})
+}
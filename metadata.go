@@ -0,0 +1,92 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"time"
+)
+
+// MetadataSuffix is appended to the name of a file to name its metadata sidecar,
+// see Options.Metadata.
+const MetadataSuffix = ".meta.json"
+
+const (
+	// closeReason is the reason of files closed by Close.
+	closeReason = "close"
+	// errorReason is the reason of files closed after a failure.
+	errorReason = "error"
+)
+
+// metadataRecord is the content of a metadata sidecar, see Options.Metadata.
+type metadataRecord struct {
+	Name       string     `json:"name"`
+	Opened     time.Time  `json:"opened"`
+	Closed     time.Time  `json:"closed"`
+	FirstEntry *time.Time `json:"first_entry,omitempty"`
+	LastEntry  *time.Time `json:"last_entry,omitempty"`
+	Bytes      int64      `json:"bytes"`
+	Entries    int64      `json:"entries"`
+	Reason     string     `json:"reason"`
+	SHA256     string     `json:"sha256,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// recordEntryTime records the time of an entry written into the current file,
+// ts when it was timestamped by Write, now otherwise.
+func (w *Writer) recordEntryTime(ts time.Time) {
+	if !w.opts.Metadata {
+		return
+	}
+	if ts.IsZero() {
+		ts = w.now()
+	}
+	ts = ts.UTC()
+	if w.firstEntryTime.IsZero() {
+		w.firstEntryTime = ts
+	}
+	w.lastEntryTime = ts
+}
+
+// writeMetadata writes the metadata sidecar of the file described by e, which
+// has just been closed, if Options.Metadata is set.
+func (w *Writer) writeMetadata(e FileCloseEvent, reason string) error {
+	if !w.opts.Metadata {
+		return nil
+	}
+	first, last := w.firstEntryTime, w.lastEntryTime
+
+	record := metadataRecord{
+		Name:    filepath.Base(e.Path),
+		Opened:  e.Opened,
+		Closed:  e.Closed,
+		Bytes:   e.Size,
+		Entries: e.Entries,
+		Reason:  reason,
+		SHA256:  e.SHA256,
+	}
+	if !first.IsZero() {
+		record.FirstEntry, record.LastEntry = &first, &last
+	}
+	if e.Err != nil {
+		record.Error = e.Err.Error()
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode metadata")
+	}
+
+	sidecar := e.Path + MetadataSuffix
+	f, err := w.openLimited(sidecar, newFileFlag)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create metadata file %s", sidecar)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write metadata file %s", sidecar)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close metadata file %s", sidecar)
+	}
+	return setAttributes(w.opts, sidecar, w.opts.FileMode)
+}
@@ -0,0 +1,129 @@
+package logrotate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriter_Metadata(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	readMetadata := func(t *testing.T, path string) metadataRecord {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		var record metadataRecord
+		require.NoError(t, json.Unmarshal(b, &record))
+		return record
+	}
+
+	t.Run("writes sidecars", func(t *testing.T) {
+		dir := newDir(t)
+		clock := newFakeClock()
+		start := clock.Now()
+		i := 0
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 20,
+			Metadata:        true,
+			Now:             clock.Now,
+			FileNameFunc: func() string {
+				i++
+				return fmt.Sprintf("%d.log", i)
+			},
+		})
+		require.NoError(t, err)
+
+		for _, msg := range []string{"first\n", "second\n", "third entry\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			clock.Advance(time.Second)
+		}
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"1.log", "1.log.meta.json", "2.log", "2.log.meta.json"}, logFiles(t, dir))
+
+		first := readMetadata(t, filepath.Join(dir, "1.log"+MetadataSuffix))
+		require.Equal(t, "1.log", first.Name)
+		require.Equal(t, int64(2), first.Entries)
+		require.Equal(t, int64(len("first\nsecond\n")), first.Bytes)
+		require.Equal(t, "size", first.Reason)
+		require.True(t, start.Equal(*first.FirstEntry))
+		require.True(t, start.Add(time.Second).Equal(*first.LastEntry))
+
+		second := readMetadata(t, filepath.Join(dir, "2.log"+MetadataSuffix))
+		require.Equal(t, int64(1), second.Entries)
+		require.Equal(t, "close", second.Reason)
+		require.True(t, start.Add(2*time.Second).Equal(*second.FirstEntry))
+		require.True(t, second.FirstEntry.Equal(*second.LastEntry))
+	})
+
+	t.Run("omits entry times of files without entries", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{
+			Directory:    dir,
+			Metadata:     true,
+			FileNameFunc: func() string { return "app.log" },
+			Header: func(w io.Writer) error {
+				_, err := io.WriteString(w, "# header\n")
+				return err
+			},
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"+MetadataSuffix))
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "first_entry")
+		require.Contains(t, string(b), `"reason":"close"`)
+	})
+
+	t.Run("uploads sidecars after files", func(t *testing.T) {
+		dir := newDir(t)
+		var (
+			mu       sync.Mutex
+			uploaded []string
+		)
+		w, err := New(logger, Options{
+			Directory: dir,
+			Checksum:  true,
+			Metadata:  true,
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded = append(uploaded, meta.Name)
+				return nil
+			}),
+			DeleteAfterUpload: true,
+			FileNameFunc:      func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"app.log", "app.log.sha256", "app.log.meta.json"}, uploaded)
+		require.Empty(t, logFiles(t, dir))
+	})
+}
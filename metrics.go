@@ -0,0 +1,74 @@
+package logrotate
+
+import "time"
+
+// Names of the metrics reported to Options.Metrics. The metrics which are also
+// part of Stats, such as MetricBytesWritten, have the names of the variables
+// published for ExpvarPrefix. Other metrics, such as MetricErrors and the
+// timers, are only reported to Metrics.
+const (
+	// MetricEntriesWritten is a counter of the entries written to files.
+	MetricEntriesWritten = "entries_written"
+	// MetricBytesWritten is a counter of the bytes written to files.
+	MetricBytesWritten = "bytes_written"
+	// MetricEntriesDropped is a counter of the entries which were not written, see Stats.EntriesDropped.
+	// Entries dropped are also counted by DropReason, including filtered and rate limited
+	// entries, in counters named MetricEntriesDropped followed by an underscore and the
+	// DropReason, such as "entries_dropped_queue_full", see Stats.DroppedByReason.
+	MetricEntriesDropped = "entries_dropped"
+	// MetricEntriesFiltered is a counter of the entries discarded by Options.Filter.
+	MetricEntriesFiltered = "entries_filtered"
+	// MetricEntriesRateLimited is a counter of the entries discarded by Options.RateLimit.
+	MetricEntriesRateLimited = "entries_rate_limited"
+	// MetricRotations is a counter of the files closed by a rotation, see Stats.Rotations.
+	MetricRotations = "rotations"
+	// MetricErrors is a counter of the errors reported by the Writer, see Errors.
+	MetricErrors = "errors"
+	// MetricFilesRemoved is a counter of the files removed by a Manager to keep
+	// within MaximumTotalSize.
+	MetricFilesRemoved = "files_removed"
+	// MetricCurrentFileSize is a gauge of the size of the file currently being written to.
+	MetricCurrentFileSize = "current_file_size"
+	// MetricQueueDepth is a gauge of the number of entries awaiting to be written,
+	// reported whenever an entry is queued up or taken from the queue.
+	MetricQueueDepth = "queue_depth"
+	// MetricQueueLatency is a timer of the time entries spend queued up, from
+	// being accepted by Write to being taken from the queue to be written.
+	MetricQueueLatency = "queue_latency"
+	// MetricFileWriteDuration is a timer of the writes into files, which are
+	// buffered, so that each write holds several entries.
+	MetricFileWriteDuration = "file_write_duration"
+	// MetricFileSyncDuration is a timer of the syncs of files to disk.
+	MetricFileSyncDuration = "file_sync_duration"
+	// MetricRotationDuration is a timer of the time taken to close a file on rotation,
+	// including its checksum and metadata sidecars.
+	MetricRotationDuration = "rotation_duration"
+)
+
+// Metrics receives the measurements of a Writer, so that they can be exported
+// to a metrics system such as StatsD or OpenTelemetry, see Options.Metrics.
+// Names are the Metric constants, timers are best exported as histograms, to
+// observe the tail latency of writes. Metrics are called synchronously, from the
+// goroutines writing entries, and must therefore be safe for concurrent use
+// and return quickly.
+type Metrics interface {
+	// IncCounter adds delta to the counter name.
+	IncCounter(name string, delta int64)
+	// SetGauge sets the gauge name to value.
+	SetGauge(name string, value float64)
+	// ObserveDuration records a duration d of the timer name.
+	ObserveDuration(name string, d time.Duration)
+}
+
+// NopMetrics is a Metrics discarding every measurement, used when
+// Options.Metrics is not specified.
+type NopMetrics struct{}
+
+// IncCounter does nothing.
+func (NopMetrics) IncCounter(name string, delta int64) {}
+
+// SetGauge does nothing.
+func (NopMetrics) SetGauge(name string, value float64) {}
+
+// ObserveDuration does nothing.
+func (NopMetrics) ObserveDuration(name string, d time.Duration) {}
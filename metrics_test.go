@@ -0,0 +1,179 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics records the measurements it receives.
+type recordingMetrics struct {
+	mu        sync.Mutex
+	counters  map[string]int64
+	gauges    map[string]float64
+	durations map[string][]time.Duration
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		counters:  make(map[string]int64),
+		gauges:    make(map[string]float64),
+		durations: make(map[string][]time.Duration),
+	}
+}
+
+func (m *recordingMetrics) IncCounter(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *recordingMetrics) SetGauge(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *recordingMetrics) ObserveDuration(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations[name] = append(m.durations[name], d)
+}
+
+func (m *recordingMetrics) counter(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+func TestWriter_Metrics(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("reports the activity of the writer", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		metrics := newRecordingMetrics()
+		w, err := NewWithOptions(logger,
+			WithDirectory(dir),
+			WithMaximumFileSize(10),
+			WithFilter(func(p []byte) bool { return !bytes.HasPrefix(p, []byte("debug")) }),
+			WithMetrics(metrics),
+		)
+		require.NoError(t, err)
+
+		for _, message := range []string{"12345", "debug", "12345", "123", "too large message"} {
+			w.Write([]byte(message))
+		}
+		require.NoError(t, w.Flush())
+
+		stats := w.Stats()
+		require.Equal(t, stats.EntriesWritten, metrics.counter(MetricEntriesWritten))
+		require.Equal(t, stats.BytesWritten, metrics.counter(MetricBytesWritten))
+		require.Equal(t, int64(1), metrics.counter(MetricEntriesDropped))
+		require.Equal(t, int64(1), metrics.counter(MetricEntriesFiltered))
+		require.Equal(t, int64(1), metrics.counter(MetricRotations))
+		require.Len(t, metrics.durations[MetricRotationDuration], 1)
+		require.Equal(t, float64(3), metrics.gauges[MetricCurrentFileSize])
+
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("measures latencies", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		metrics := newRecordingMetrics()
+		w, err := New(logger, Options{Directory: dir, Metrics: metrics})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := w.Write([]byte("entry\n"))
+			require.NoError(t, err)
+		}
+		_, err = w.WritePriority([]byte("priority entry\n"), PriorityHigh)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		require.Len(t, metrics.durations[MetricQueueLatency], 4)
+		require.NotEmpty(t, metrics.durations[MetricFileWriteDuration])
+		require.Len(t, metrics.durations[MetricFileSyncDuration], 1)
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		metrics := newRecordingMetrics()
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return filepath.Join("missing", "file.log") },
+			Metrics:      metrics,
+		})
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		w.Close()
+
+		require.True(t, metrics.counter(MetricErrors) > 0)
+		require.Equal(t, int64(1), metrics.counter(MetricEntriesDropped))
+	})
+
+	t.Run("reports files removed by a manager", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		for _, name := range []string{"1.log", "2.log"} {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0644))
+		}
+
+		metrics := newRecordingMetrics()
+		m, err := NewManager(logger, ManagerOptions{
+			Writers:          map[string]Options{"app": {Directory: dir, Metrics: metrics}},
+			MaximumTotalSize: 15,
+			CleanupInterval:  time.Hour,
+		})
+		require.NoError(t, err)
+		require.NoError(t, m.Cleanup())
+		require.NoError(t, m.Close())
+
+		require.Equal(t, int64(1), metrics.counter(MetricFilesRemoved))
+	})
+
+	t.Run("rejects nil metrics", func(t *testing.T) {
+		_, err := NewWithOptions(logger, WithDirectory("logs"), WithMetrics(nil))
+		require.Error(t, err)
+	})
+}
+
+func TestMetricNames(t *testing.T) {
+	published := map[string]bool{}
+	for _, stat := range expvarStats {
+		published[stat.name] = true
+	}
+	for _, name := range []string{
+		MetricEntriesWritten,
+		MetricBytesWritten,
+		MetricEntriesDropped,
+		MetricEntriesFiltered,
+		MetricEntriesRateLimited,
+		MetricRotations,
+		MetricCurrentFileSize,
+		MetricQueueDepth,
+	} {
+		require.True(t, published[name], "metric %s must match the name of its expvar", name)
+	}
+}
@@ -0,0 +1,286 @@
+package logrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultMirrorWriteTimeout is the WriteTimeout used when none is specified,
+// see MirrorOptions.
+const DefaultMirrorWriteTimeout = time.Second
+
+// MirrorOptions configure a Mirror.
+type MirrorOptions struct {
+	// Targets are the options of the Writer of each copy of the stream, each
+	// with its own Directory, for example on a local disk and on a network
+	// mount. Failures are handled by each Writer according to its own options,
+	// such as Retry, Fallback and DiskFullPolicy. A target whose queue is full
+	// blocks Write up to WriteTimeout, unless it is NonBlocking.
+	Targets []Options
+
+	// WriteTimeout is how long Write waits for a target to accept an entry,
+	// for example while its queue is full because its disk hangs. A target
+	// which does not accept an entry in time is stalled: entries are not written
+	// into it, without waiting, until the write it is stuck in completes. Such
+	// entries are counted as dropped by the Writer of the target, with
+	// DropStalled, see Targets. Defaults to DefaultMirrorWriteTimeout.
+	WriteTimeout time.Duration
+}
+
+func (o MirrorOptions) validate() error {
+	if o.WriteTimeout < 0 {
+		return errors.Errorf("WriteTimeout must not be negative, got %v", o.WriteTimeout)
+	}
+	if len(o.Targets) < 2 {
+		return errors.Errorf("at least 2 Targets are required, got %d", len(o.Targets))
+	}
+	dirs := make(map[string]bool, len(o.Targets))
+	for i, opts := range o.Targets {
+		if err := opts.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid options of target %d", i)
+		}
+		dir := filepath.Clean(opts.Directory)
+		if dirs[dir] {
+			return errors.Errorf("targets must have distinct directories, %s is used twice", dir)
+		}
+		dirs[dir] = true
+	}
+	return nil
+}
+
+// Mirror writes every entry into several Writers, so that losing one of their
+// directories does not lose entries, similarly to RAID-1.
+type Mirror struct {
+	logger  Logger
+	timeout time.Duration
+	targets []*Writer
+	// writes are the entries handed to the goroutine writing into each target,
+	// so that a target which hangs does not block the others
+	writes []chan mirrorWrite
+	// busy is set for each target whose goroutine is writing an entry, and
+	// stalled for each target whose goroutine is stuck in a write which
+	// exceeded the timeout, accessed under mu
+	busy, stalled []bool
+
+	// closeMu synchronizes Write handing over entries with Close closing writes
+	closeMu sync.RWMutex
+	closed  bool
+
+	mu sync.Mutex
+	// failing holds the indexes of targets whose last write failed
+	failing map[int]bool
+}
+
+// mirrorWrite is an entry handed to the goroutine of a target.
+type mirrorWrite struct {
+	p      []byte
+	result chan error
+}
+
+// NewMirror creates a Mirror, and the Writer of each of its targets.
+func NewMirror(logger Logger, opts MirrorOptions) (*Mirror, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid mirror options")
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = DefaultMirrorWriteTimeout
+	}
+
+	m := &Mirror{
+		logger:  loggerOrDiscard(logger),
+		timeout: opts.WriteTimeout,
+		targets: make([]*Writer, 0, len(opts.Targets)),
+		writes:  make([]chan mirrorWrite, len(opts.Targets)),
+		busy:    make([]bool, len(opts.Targets)),
+		stalled: make([]bool, len(opts.Targets)),
+		failing: make(map[int]bool),
+	}
+	for i := range m.writes {
+		m.writes[i] = make(chan mirrorWrite)
+	}
+	for i, targetOpts := range opts.Targets {
+		w, err := New(logger, targetOpts)
+		if err != nil {
+			m.Close()
+			return nil, errors.Wrapf(err, "failed to create writer of target %d", i)
+		}
+		m.targets = append(m.targets, w)
+		go m.writeTarget(i, w)
+	}
+	return m, nil
+}
+
+// writeTarget writes the entries handed over by Write into w, the target with
+// index i, until Close is called.
+func (m *Mirror) writeTarget(i int, w *Writer) {
+	for write := range m.writes[i] {
+		m.mu.Lock()
+		m.busy[i] = true
+		m.mu.Unlock()
+
+		_, err := w.Write(write.p)
+		write.result <- err
+
+		m.mu.Lock()
+		m.busy[i] = false
+		if m.stalled[i] {
+			m.stalled[i] = false
+			m.logger.Printf("Target %d of mirror is no longer stalled.", i)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Write writes p into every target, see Writer.Write. Write succeeds as long as
+// one of the targets accepts p, failing targets are logged when they start and
+// stop failing. When every target fails, the error of the first one is returned.
+// A target which does not accept p within WriteTimeout fails with ErrStalled.
+func (m *Mirror) Write(p []byte) (int, error) {
+	m.closeMu.RLock()
+	defer m.closeMu.RUnlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	// p is copied, a stalled target may still write it once Write returned.
+	p = append([]byte(nil), p...)
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	results := make([]chan error, len(m.targets))
+	for i, w := range m.targets {
+		if m.isStalled(i) {
+			w.recordDrop(DropStalled, ErrStalled)
+			continue
+		}
+		write := mirrorWrite{p: p, result: make(chan error, 1)}
+		if m.handOver(ctx, i, write) {
+			results[i] = write.result
+		} else {
+			m.stall(i)
+			w.recordDrop(DropStalled, ErrStalled)
+		}
+	}
+
+	var (
+		accepted bool
+		firstErr error
+	)
+	for i, result := range results {
+		err := ErrStalled
+		if result != nil {
+			var ok bool
+			if err, ok = receive(ctx, result); !ok {
+				err = ErrStalled
+				m.stall(i)
+			}
+		}
+		m.setFailing(i, err)
+		if err == nil {
+			accepted = true
+		} else if firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to write to target %d", i)
+		}
+	}
+	if !accepted {
+		return 0, firstErr
+	}
+	return len(p), nil
+}
+
+// handOver hands write over to the goroutine of the target with index i,
+// and returns false if it did not take it before ctx is done.
+func (m *Mirror) handOver(ctx context.Context, i int, write mirrorWrite) bool {
+	// An idle target takes write even though ctx is done, since another target
+	// exceeded the timeout.
+	select {
+	case m.writes[i] <- write:
+		return true
+	default:
+	}
+	select {
+	case m.writes[i] <- write:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// receive returns the result of a write, and false if it is not available
+// before ctx is done.
+func receive(ctx context.Context, result chan error) (error, bool) {
+	select {
+	case err := <-result:
+		return err, true
+	default:
+	}
+	select {
+	case err := <-result:
+		return err, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// isStalled reports whether the target with index i is stalled.
+func (m *Mirror) isStalled(i int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stalled[i]
+}
+
+// stall marks the target with index i as stalled, after it exceeded the timeout,
+// unless it completed its write since.
+func (m *Mirror) stall(i int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[i] && !m.stalled[i] {
+		m.stalled[i] = true
+		m.logger.Printf("Target %d of mirror did not accept an entry within %v, skipping it until it does.", i, m.timeout)
+	}
+}
+
+// setFailing records the result of a write into the target with index i.
+func (m *Mirror) setFailing(i int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failing := err != nil
+	if m.failing[i] == failing {
+		return
+	}
+	if failing {
+		m.failing[i] = true
+		m.logger.Printf("Target %d of mirror failed, writing to the other targets: %v", i, err)
+	} else {
+		delete(m.failing, i)
+		m.logger.Printf("Target %d of mirror recovered.", i)
+	}
+}
+
+// Targets returns the Writers of the targets, in order.
+func (m *Mirror) Targets() []*Writer {
+	return append([]*Writer(nil), m.targets...)
+}
+
+// Close closes the targets concurrently, and returns the first error encountered.
+func (m *Mirror) Close() error {
+	m.closeMu.Lock()
+	if !m.closed {
+		m.closed = true
+		for _, writes := range m.writes {
+			close(writes)
+		}
+	}
+	m.closeMu.Unlock()
+
+	writers := make(map[string]*Writer, len(m.targets))
+	for i, w := range m.targets {
+		writers[fmt.Sprintf("target%d", i)] = w
+	}
+	return closeWriters(writers)
+}
@@ -0,0 +1,131 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newTargets := func(t *testing.T) []Options {
+		var targets []Options
+		for i := 0; i < 2; i++ {
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			t.Cleanup(func() { os.RemoveAll(dir) })
+			targets = append(targets, Options{
+				Directory:    dir,
+				FileNameFunc: func() string { return "app.log" },
+			})
+		}
+		return targets
+	}
+
+	t.Run("writes entries into every target", func(t *testing.T) {
+		targets := newTargets(t)
+		m, err := NewMirror(logger, MirrorOptions{Targets: targets})
+		require.NoError(t, err)
+
+		for _, entry := range []string{"first\n", "second\n"} {
+			n, err := m.Write([]byte(entry))
+			require.NoError(t, err)
+			require.Equal(t, len(entry), n)
+		}
+		require.NoError(t, m.Close())
+
+		for _, target := range targets {
+			b, err := ioutil.ReadFile(filepath.Join(target.Directory, "app.log"))
+			require.NoError(t, err)
+			require.Equal(t, "first\nsecond\n", string(b))
+		}
+	})
+
+	t.Run("keeps writing when a target fails", func(t *testing.T) {
+		targets := newTargets(t)
+		m, err := NewMirror(logger, MirrorOptions{Targets: targets})
+		require.NoError(t, err)
+
+		require.NoError(t, m.Targets()[1].Close())
+		_, err = m.Write([]byte("entry\n"))
+		require.NoError(t, err)
+
+		require.NoError(t, m.Targets()[0].Close())
+		_, err = m.Write([]byte("lost\n"))
+		require.True(t, errors.Is(err, ErrClosed))
+
+		b, err := ioutil.ReadFile(filepath.Join(targets[0].Directory, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "entry\n", string(b))
+	})
+
+	t.Run("skips a stalled target", func(t *testing.T) {
+		targets := newTargets(t)
+		// The second target hangs, as on a disk which stopped responding, so
+		// that its queue fills up.
+		filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+		targets[1].QueueSize = 1
+		targets[1].Filter = func(b []byte) bool {
+			select {
+			case filtering <- struct{}{}:
+				<-unblock
+			default:
+			}
+			return true
+		}
+		m, err := NewMirror(logger, MirrorOptions{Targets: targets, WriteTimeout: 50 * time.Millisecond})
+		require.NoError(t, err)
+
+		_, err = m.Write([]byte("1\n"))
+		require.NoError(t, err)
+		<-filtering
+		_, err = m.Write([]byte("2\n"))
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = m.Write([]byte("3\n"))
+		require.NoError(t, err, "must write into the other target")
+		require.True(t, m.isStalled(1))
+		for i := 4; i <= 10; i++ {
+			_, err = m.Write([]byte(fmt.Sprintf("%d\n", i)))
+			require.NoError(t, err)
+		}
+		require.True(t, time.Since(start) < time.Second, "must not wait for the stalled target again")
+		require.Equal(t, int64(7), m.Targets()[1].Stats().DroppedByReason[DropStalled], "must count the skipped entries")
+		require.Zero(t, m.Targets()[0].Stats().EntriesDropped)
+
+		close(unblock)
+		for m.isStalled(1) {
+			time.Sleep(time.Millisecond)
+		}
+		_, err = m.Write([]byte("11\n"))
+		require.NoError(t, err)
+		require.NoError(t, m.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(targets[0].Directory, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n", string(b))
+		b, err = ioutil.ReadFile(filepath.Join(targets[1].Directory, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "1\n2\n3\n11\n", string(b), "must resume writing once the target recovers")
+	})
+
+	t.Run("rejects invalid write timeout", func(t *testing.T) {
+		_, err := NewMirror(logger, MirrorOptions{Targets: newTargets(t), WriteTimeout: -time.Second})
+		require.Error(t, err)
+	})
+
+	t.Run("rejects targets sharing a directory", func(t *testing.T) {
+		_, err := NewMirror(logger, MirrorOptions{Targets: []Options{{Directory: "logs"}}})
+		require.Error(t, err)
+		_, err = NewMirror(logger, MirrorOptions{Targets: []Options{{Directory: "logs"}, {Directory: "logs/"}}})
+		require.Error(t, err)
+	})
+}
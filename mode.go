@@ -0,0 +1,36 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"os"
+)
+
+// ChmodFS is a FS which can change the permissions of files, required by Options.ExactMode.
+// The semantics of Chmod follow os.Chmod.
+type ChmodFS interface {
+	FS
+	Chmod(name string, mode os.FileMode) error
+}
+
+// chmod changes the permissions of the file or directory at path to mode.
+func chmod(fs FS, path string, mode os.FileMode) error {
+	cfs, ok := fs.(ChmodFS)
+	if !ok {
+		return errors.New("FS does not support changing permissions")
+	}
+	if err := cfs.Chmod(path, mode); err != nil {
+		return errors.Wrapf(err, "failed to change permissions of %s", path)
+	}
+	return nil
+}
+
+// setAttributes applies the permissions, with ExactMode, and the Owner of opts
+// to a file or directory which has just been created or opened.
+func setAttributes(opts Options, path string, mode os.FileMode) error {
+	if opts.ExactMode {
+		if err := chmod(opts.FS, path, mode); err != nil {
+			return err
+		}
+	}
+	return chown(opts.FS, opts.Owner, path)
+}
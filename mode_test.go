@@ -0,0 +1,78 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriter_ExactMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not support Unix permissions")
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	mode := func(t *testing.T, path string) os.FileMode {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	t.Run("applies modes regardless of the umask", func(t *testing.T) {
+		// Group write permissions are masked by the usual umasks, 022 or 077.
+		dir := filepath.Join(newDir(t), "logs")
+		w, err := New(logger, Options{
+			Directory:        dir,
+			FileMode:         0664,
+			DirMode:          0775,
+			ExactMode:        true,
+			Checksum:         true,
+			RotationManifest: "rotations.jsonl",
+			FileNameFunc:     func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, os.FileMode(0775), mode(t, dir))
+		for _, name := range []string{"app.log", "app.log" + ChecksumSuffix, "rotations.jsonl"} {
+			require.Equal(t, os.FileMode(0664), mode(t, filepath.Join(dir, name)), name)
+		}
+	})
+
+	t.Run("applies modes to existing files", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, "app.log")
+		require.NoError(t, ioutil.WriteFile(path, []byte("existing\n"), 0600))
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileMode:     0640,
+			ExactMode:    true,
+			FileNameFunc: func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, os.FileMode(0640), mode(t, path))
+	})
+
+	t.Run("requires a FS supporting Chmod", func(t *testing.T) {
+		opts := Options{Directory: "logs", ExactMode: true, FS: newMemFS()}
+		require.Error(t, opts.Validate())
+	})
+}
@@ -0,0 +1,83 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"os"
+	"sync"
+)
+
+// fileLimit bounds the number of files open at the same time, see
+// Options.MaximumOpenFiles. A nil fileLimit does not bound them.
+type fileLimit chan struct{}
+
+func newFileLimit(n int) fileLimit {
+	if n == 0 {
+		return nil
+	}
+	return make(fileLimit, n)
+}
+
+// acquire waits for a file to be allowed to open, until abort is closed.
+func (l fileLimit) acquire(abort <-chan struct{}) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-abort:
+		return errors.New("aborted while waiting for a file to close, see MaximumOpenFiles")
+	}
+}
+
+func (l fileLimit) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// openFile opens the file at name in fs once the limit allows it, closing the
+// returned File allows another one to be opened.
+func (l fileLimit) openFile(fs FS, name string, flag int, perm os.FileMode, abort <-chan struct{}) (File, error) {
+	if l == nil {
+		return fs.OpenFile(name, flag, perm)
+	}
+	if err := l.acquire(abort); err != nil {
+		return nil, err
+	}
+	f, err := fs.OpenFile(name, flag, perm)
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	return &limitedFile{File: f, limit: l}, nil
+}
+
+// limitedFile releases its fileLimit once closed.
+type limitedFile struct {
+	File
+	limit fileLimit
+	once  sync.Once
+}
+
+func (f *limitedFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(f.limit.release)
+	return err
+}
+
+// openLimited opens a file of the Writer, see Options.MaximumOpenFiles.
+func (w *Writer) openLimited(name string, flag int) (File, error) {
+	return w.files.openFile(w.opts.FS, name, flag, w.opts.FileMode, w.abort)
+}
+
+// upload runs an Uploader once the limit allows it, since the Uploader opens
+// the file to read it.
+func (l fileLimit) upload(ctx context.Context, uploader Uploader, path string, meta FileMeta) error {
+	if err := l.acquire(ctx.Done()); err != nil {
+		return ctx.Err()
+	}
+	defer l.release()
+	return uploader.Upload(ctx, path, meta)
+}
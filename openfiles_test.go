@@ -0,0 +1,143 @@
+package logrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// openFilesCounter tracks the number of files open at the same time.
+type openFilesCounter struct {
+	mu      sync.Mutex
+	open    int
+	maximum int
+}
+
+func (c *openFilesCounter) add(delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open += delta
+	if c.open > c.maximum {
+		c.maximum = c.open
+	}
+}
+
+// countingFS counts the files open in FS.
+type countingFS struct {
+	FS
+	counter *openFilesCounter
+}
+
+func (fs countingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	fs.counter.add(1)
+	return &countedFile{File: f, counter: fs.counter}, nil
+}
+
+type countedFile struct {
+	File
+	counter *openFilesCounter
+	once    sync.Once
+}
+
+func (f *countedFile) Close() error {
+	f.once.Do(func() { f.counter.add(-1) })
+	return f.File.Close()
+}
+
+func TestWriter_MaximumOpenFiles(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("opens one file at a time", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		counter := &openFilesCounter{}
+		var (
+			mu       sync.Mutex
+			uploaded []string
+		)
+		files := 0
+		// countingFS writes to the operating system's filesystem, which Uploader
+		// reads from, Validate rejecting a custom FS with Uploader is bypassed.
+		w, err := newWriter(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				files++
+				return fmt.Sprintf("%03d.log", files)
+			},
+			MaximumFileSize:  10,
+			FS:               countingFS{FS: OSFS{}, counter: counter},
+			Checksum:         true,
+			Metadata:         true,
+			MaximumOpenFiles: 1,
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				counter.add(1)
+				defer counter.add(-1)
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded = append(uploaded, filepath.Base(localPath))
+				return nil
+			}),
+		}, newFileLimit(1))
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := w.Write([]byte("entry 123\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		require.Equal(t, 1, counter.maximum)
+		require.Len(t, uploaded, 9)
+		for _, name := range []string{"001.log", "002.log", "003.log"} {
+			require.Contains(t, uploaded, name)
+			require.Contains(t, uploaded, name+ChecksumSuffix)
+			require.Contains(t, uploaded, name+MetadataSuffix)
+		}
+	})
+
+	t.Run("shares the limit within a pool", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		counter := &openFilesCounter{}
+		p, err := NewPool(logger, PoolOptions{
+			Options: Options{
+				Directory:        dir,
+				FS:               countingFS{FS: OSFS{}, counter: counter},
+				MaximumOpenFiles: 2,
+			},
+			MaximumOpen: 1,
+		})
+		require.NoError(t, err)
+
+		for _, key := range []string{"a", "b", "c", "a"} {
+			_, err := p.Writer(key).Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, p.Close())
+		require.True(t, counter.maximum <= 2)
+	})
+
+	t.Run("rejects pools which could not open files", func(t *testing.T) {
+		for _, maximumOpen := range []int{0, 2} {
+			_, err := NewPool(logger, PoolOptions{
+				Options:     Options{Directory: "logs", MaximumOpenFiles: 2},
+				MaximumOpen: maximumOpen,
+			})
+			require.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,876 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// defaultQueueSize is the QueueSize used when none is specified.
+const defaultQueueSize = 1024
+
+// defaultFileMode and defaultDirMode are the FileMode and DirMode used when none is specified.
+const (
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0755
+)
+
+// Validate checks the options for invalid or conflicting values.
+// Validate is called by New, it is exported so that configuration can be
+// checked early, for example when loading a configuration file.
+func (o Options) Validate() error {
+	if o.Directory == "" {
+		return errors.New("Directory must not be empty")
+	}
+	if o.MaximumFileSize < 0 {
+		return errors.Errorf("MaximumFileSize must not be negative, got %d", o.MaximumFileSize)
+	}
+	if o.MaximumLifetime < 0 {
+		return errors.Errorf("MaximumLifetime must not be negative, got %v", o.MaximumLifetime)
+	}
+	if err := o.Retry.validate(); err != nil {
+		return errors.Wrap(err, "invalid Retry")
+	}
+	if err := o.UploadRetry.validate(); err != nil {
+		return errors.Wrap(err, "invalid UploadRetry")
+	}
+	if o.UploadConcurrency < 0 {
+		return errors.Errorf("UploadConcurrency must not be negative, got %d", o.UploadConcurrency)
+	}
+	if o.UploadBandwidth < 0 {
+		return errors.Errorf("UploadBandwidth must not be negative, got %d", o.UploadBandwidth)
+	}
+	if o.FileMode&^os.ModePerm != 0 {
+		return errors.Errorf("FileMode must only contain permission bits, got %v", o.FileMode)
+	}
+	if o.DirMode&^os.ModePerm != 0 {
+		return errors.Errorf("DirMode must only contain permission bits, got %v", o.DirMode)
+	}
+	if _, ok := o.FS.(ChmodFS); o.ExactMode && o.FS != nil && !ok {
+		return errors.New("ExactMode requires a FS implementing ChmodFS")
+	}
+	if o.EncryptionKey != nil && len(o.EncryptionKey) != EncryptionKeySize {
+		return errors.Errorf("EncryptionKey must be %d bytes, got %d", EncryptionKeySize, len(o.EncryptionKey))
+	}
+	if o.Owner != nil {
+		if err := o.Owner.validate(); err != nil {
+			return errors.Wrap(err, "invalid Owner")
+		}
+		if _, ok := o.FS.(ChownFS); o.FS != nil && !ok {
+			return errors.New("Owner requires a FS implementing ChownFS")
+		}
+	}
+	if o.AppendOnly {
+		if !fileAttributesSupported {
+			return errors.New("AppendOnly is not supported on this platform")
+		}
+		if _, ok := o.FS.(OSFS); o.FS != nil && !ok {
+			return errors.New("AppendOnly cannot be used with a custom FS")
+		}
+		if o.DeleteAfterUpload || o.FailedUploadDirectory != "" {
+			return errors.New("AppendOnly cannot be combined with DeleteAfterUpload or FailedUploadDirectory")
+		}
+	}
+	if o.Lock != nil {
+		if err := o.Lock.validate(); err != nil {
+			return errors.Wrap(err, "invalid Lock")
+		}
+		if !lockSupported {
+			return errors.New("Lock is not supported on this platform")
+		}
+		if _, ok := o.FS.(OSFS); o.FS != nil && !ok {
+			return errors.New("Lock cannot be used with a custom FS")
+		}
+	}
+	if o.CRLF && (o.Framed || o.JSONArray) {
+		return errors.New("CRLF cannot be combined with Framed or JSONArray")
+	}
+	if o.JSONArray && (o.Header != nil || o.Footer != nil || o.Framed || o.Shared) {
+		return errors.New("JSONArray cannot be combined with Header, Footer, Framed or Shared")
+	}
+	if _, ok := o.FS.(OSFS); o.Uploader != nil && o.FS != nil && !ok {
+		return errors.New("Uploader cannot be used with a custom FS")
+	}
+	if o.Shared && o.Uploader != nil && o.UploadManifest == "" {
+		return errors.New("Shared requires an UploadManifest per process with Uploader")
+	}
+	if o.MaximumOpenFiles < 0 {
+		return errors.Errorf("MaximumOpenFiles must not be negative, got %d", o.MaximumOpenFiles)
+	}
+	if o.QueueSize < 0 {
+		return errors.Errorf("QueueSize must not be negative, got %d", o.QueueSize)
+	}
+	if o.MaxEntrySize < 0 {
+		return errors.Errorf("MaxEntrySize must not be negative, got %d", o.MaxEntrySize)
+	}
+	switch o.EntrySizePolicy {
+	case EntrySizeReject, EntrySizeChunk:
+	case EntrySizeTruncate:
+		if o.MaxEntrySize != 0 && o.MaxEntrySize < minimumTruncatedEntrySize {
+			return errors.Errorf("MaxEntrySize must be at least %d with EntrySizeTruncate, got %d", minimumTruncatedEntrySize, o.MaxEntrySize)
+		}
+	default:
+		return errors.Errorf("unknown EntrySizePolicy %d", o.EntrySizePolicy)
+	}
+	switch o.DiskFullPolicy {
+	case DiskFullDrop, DiskFullBlock, DiskFullFail:
+	default:
+		return errors.Errorf("unknown DiskFullPolicy %d", o.DiskFullPolicy)
+	}
+	if o.RateLimit != nil {
+		if err := o.RateLimit.validate(); err != nil {
+			return errors.Wrap(err, "invalid RateLimit")
+		}
+	}
+	if o.Syslog != nil {
+		if err := o.Syslog.validate(); err != nil {
+			return errors.Wrap(err, "invalid Syslog")
+		}
+	}
+	if o.Webhook != nil {
+		if err := o.Webhook.validate(); err != nil {
+			return errors.Wrap(err, "invalid Webhook")
+		}
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the options with defaults filled in.
+func (o Options) withDefaults() Options {
+	if o.FileNameFunc == nil && o.Shared {
+		o.FileNameFunc = SharedFilenameFunc
+	}
+	if o.FileNameFunc == nil {
+		o.FileNameFunc = DefaultFilenameFunc
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	if o.NewTicker == nil && o.Scheduler != nil {
+		o.NewTicker = o.Scheduler.NewTicker
+	}
+	if o.NewTicker == nil {
+		o.NewTicker = newTimeTicker
+	}
+	if o.FS == nil {
+		o.FS = OSFS{}
+	}
+	if o.Metrics == nil {
+		o.Metrics = NopMetrics{}
+	}
+	if o.QueueSize == 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	if o.FileMode == 0 {
+		o.FileMode = defaultFileMode
+	}
+	if o.DirMode == 0 {
+		o.DirMode = defaultDirMode
+	}
+	if o.UploadManifest == "" {
+		o.UploadManifest = filepath.Join(o.Directory, DefaultUploadManifest)
+	}
+	return o
+}
+
+// immutableOptions lists the options which are fixed when the Writer is
+// created, SetOptions rejects changing them.
+var immutableOptions = []struct {
+	name  string
+	value func(Options) interface{}
+}{
+	{"Directory", func(o Options) interface{} { return o.Directory }},
+	{"EncryptionKey", func(o Options) interface{} { return o.EncryptionKey }},
+	{"Framed", func(o Options) interface{} { return o.Framed }},
+	{"JSONArray", func(o Options) interface{} { return o.JSONArray }},
+	{"Now", func(o Options) interface{} { return o.Now }},
+	{"Scheduler", func(o Options) interface{} { return o.Scheduler }},
+	{"QueueSize", func(o Options) interface{} { return o.QueueSize }},
+	{"FS", func(o Options) interface{} { return o.FS }},
+	{"MaximumOpenFiles", func(o Options) interface{} { return o.MaximumOpenFiles }},
+	{"Shared", func(o Options) interface{} { return o.Shared }},
+	{"Lock", func(o Options) interface{} { return o.Lock }},
+	{"ExpvarPrefix", func(o Options) interface{} { return o.ExpvarPrefix }},
+	{"Metrics", func(o Options) interface{} { return o.Metrics }},
+	{"Debug", func(o Options) interface{} { return o.Debug }},
+	{"Syslog", func(o Options) interface{} { return o.Syslog }},
+	{"Webhook", func(o Options) interface{} { return o.Webhook }},
+	{"TeeTo", func(o Options) interface{} { return o.TeeTo }},
+	{"Uploader", func(o Options) interface{} { return o.Uploader }},
+	{"UploadRetry", func(o Options) interface{} { return o.UploadRetry }},
+	{"UploadConcurrency", func(o Options) interface{} { return o.UploadConcurrency }},
+	{"UploadBandwidth", func(o Options) interface{} { return o.UploadBandwidth }},
+	{"UploadManifest", func(o Options) interface{} { return o.UploadManifest }},
+	{"FailedUploadDirectory", func(o Options) interface{} { return o.FailedUploadDirectory }},
+	{"OnUploadFailure", func(o Options) interface{} { return o.OnUploadFailure }},
+}
+
+// sameOption reports whether a and b are the same value of an option.
+// Functions, which cannot be compared, are the same when they are the same
+// function, so that options built again from the same configuration are
+// accepted by SetOptions.
+func sameOption(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Func && vb.Kind() == reflect.Func {
+		return va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// SetOptions reconfigures a running Writer, for example on configuration reload.
+// The new options are validated and take effect before the next entry is written:
+// rotation thresholds apply to the current file, so lowering MaximumFileSize
+// may rotate it immediately, while settings such as FileNameFunc apply from
+// the next rotation.
+// Rotation thresholds, entry processing such as Filter, RateLimit and Transform,
+// failure policies, sidecars, callbacks such as OnRotate, file modes and
+// DeleteAfterUpload can be changed. The options documented as fixed, such as
+// Directory, FS, EncryptionKey, Lock, Uploader, ExpvarPrefix and the tees,
+// must be the same as the Writer's, otherwise SetOptions fails naming the
+// option, without changing any of them.
+func (w *Writer) SetOptions(opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return errors.Wrap(err, "invalid options")
+	}
+
+	select {
+	case <-w.closing:
+		return ErrClosed
+	default:
+	}
+
+	opts = opts.withDefaults()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, option := range immutableOptions {
+		if !sameOption(option.value(w.opts), option.value(opts)) {
+			return errors.Errorf("%s cannot be changed with SetOptions", option.name)
+		}
+	}
+	w.nextOpts = &opts
+
+	return nil
+}
+
+// applyNextOptions switches to options set by SetOptions, if any.
+func (w *Writer) applyNextOptions() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.nextOpts == nil {
+		return
+	}
+
+	w.opts = *w.nextOpts
+	w.writeOpts = *w.nextOpts
+	w.nextOpts = nil
+}
+
+// Option configures a Writer created with NewWithOptions.
+// An Option returns an error when given an invalid value.
+type Option func(*Options) error
+
+// WithDirectory sets the directory where log files will be written to.
+// Required.
+func WithDirectory(dir string) Option {
+	return func(o *Options) error {
+		if dir == "" {
+			return errors.New("directory must not be empty")
+		}
+		o.Directory = dir
+		return nil
+	}
+}
+
+// WithMaximumFileSize enables size based rotation, files will not grow beyond size bytes.
+func WithMaximumFileSize(size int64) Option {
+	return func(o *Options) error {
+		if size <= 0 {
+			return errors.Errorf("maximum file size must be positive, got %d", size)
+		}
+		o.MaximumFileSize = size
+		return nil
+	}
+}
+
+// WithMaximumLifetime enables time based rotation, files will be rotated after lifetime elapses.
+func WithMaximumLifetime(lifetime time.Duration) Option {
+	return func(o *Options) error {
+		if lifetime <= 0 {
+			return errors.Errorf("maximum lifetime must be positive, got %v", lifetime)
+		}
+		o.MaximumLifetime = lifetime
+		return nil
+	}
+}
+
+// WithFileNameFunc sets the function used to name new files.
+func WithFileNameFunc(fn func() string) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("file name func must not be nil")
+		}
+		o.FileNameFunc = fn
+		return nil
+	}
+}
+
+// WithRetry sets the policy for retrying failed file operations.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *Options) error {
+		if policy.MaximumAttempts < 1 {
+			return errors.Errorf("maximum attempts must be at least 1, got %d", policy.MaximumAttempts)
+		}
+		if policy.InitialBackoff < 0 || policy.MaximumBackoff < 0 {
+			return errors.New("backoff must not be negative")
+		}
+		o.Retry = policy
+		return nil
+	}
+}
+
+// WithFallback sets the writer receiving entries which could not be written to a file.
+func WithFallback(fallback io.Writer) Option {
+	return func(o *Options) error {
+		if fallback == nil {
+			return errors.New("fallback writer must not be nil")
+		}
+		o.Fallback = fallback
+		return nil
+	}
+}
+
+// WithMaxEntrySize limits the size of entries to size bytes, larger entries are
+// handled according to policy, see Options.MaxEntrySize.
+func WithMaxEntrySize(size int64, policy EntrySizePolicy) Option {
+	return func(o *Options) error {
+		if size <= 0 {
+			return errors.Errorf("maximum entry size must be positive, got %d", size)
+		}
+		switch policy {
+		case EntrySizeReject, EntrySizeChunk:
+		case EntrySizeTruncate:
+			if size < minimumTruncatedEntrySize {
+				return errors.Errorf("maximum entry size must be at least %d with EntrySizeTruncate, got %d", minimumTruncatedEntrySize, size)
+			}
+		default:
+			return errors.Errorf("unknown entry size policy %d", policy)
+		}
+		o.MaxEntrySize = size
+		o.EntrySizePolicy = policy
+		return nil
+	}
+}
+
+// WithDiskFullPolicy sets the behavior when the disk is full.
+func WithDiskFullPolicy(policy DiskFullPolicy) Option {
+	return func(o *Options) error {
+		switch policy {
+		case DiskFullDrop, DiskFullBlock, DiskFullFail:
+			o.DiskFullPolicy = policy
+			return nil
+		default:
+			return errors.Errorf("unknown disk full policy %d", policy)
+		}
+	}
+}
+
+// WithQueueSize sets the number of entries which can be queued up awaiting to be
+// written, 0 uses the default, see Options.QueueSize.
+func WithQueueSize(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.Errorf("queue size must not be negative, got %d", n)
+		}
+		o.QueueSize = n
+		return nil
+	}
+}
+
+// WithNonBlocking makes Write return ErrQueueFull instead of blocking when the queue is full.
+func WithNonBlocking() Option {
+	return func(o *Options) error {
+		o.NonBlocking = true
+		return nil
+	}
+}
+
+// WithChecksum writes a SHA-256 checksum sidecar for each file, see Options.Checksum.
+func WithChecksum() Option {
+	return func(o *Options) error {
+		o.Checksum = true
+		return nil
+	}
+}
+
+// WithMetadata writes a JSON metadata sidecar for each file, see Options.Metadata.
+func WithMetadata() Option {
+	return func(o *Options) error {
+		o.Metadata = true
+		return nil
+	}
+}
+
+// WithFilter discards entries for which keep returns false, see Options.Filter.
+func WithFilter(keep func(b []byte) bool) Option {
+	return func(o *Options) error {
+		if keep == nil {
+			return errors.New("filter must not be nil")
+		}
+		o.Filter = keep
+		return nil
+	}
+}
+
+// WithRateLimit limits the rate at which entries are written, see Options.RateLimit.
+func WithRateLimit(opts RateLimitOptions) Option {
+	return func(o *Options) error {
+		if err := opts.validate(); err != nil {
+			return errors.Wrap(err, "invalid rate limit options")
+		}
+		o.RateLimit = &opts
+		return nil
+	}
+}
+
+// WithTimestampFormat prefixes entries with the time they were written, see Options.TimestampFormat.
+func WithTimestampFormat(layout string) Option {
+	return func(o *Options) error {
+		if layout == "" {
+			return errors.New("timestamp format must not be empty")
+		}
+		o.TimestampFormat = layout
+		return nil
+	}
+}
+
+// WithEnsureNewline appends a newline to entries lacking one, see Options.EnsureNewline.
+func WithEnsureNewline() Option {
+	return func(o *Options) error {
+		o.EnsureNewline = true
+		return nil
+	}
+}
+
+// WithFramed writes entries as length-prefixed records, see Options.Framed.
+func WithFramed() Option {
+	return func(o *Options) error {
+		o.Framed = true
+		return nil
+	}
+}
+
+// WithJSONArray writes files as JSON arrays, see Options.JSONArray.
+func WithJSONArray() Option {
+	return func(o *Options) error {
+		o.JSONArray = true
+		return nil
+	}
+}
+
+// WithRotationMarkers writes markers linking rotated files, see Options.RotationMarkers.
+func WithRotationMarkers() Option {
+	return func(o *Options) error {
+		o.RotationMarkers = true
+		return nil
+	}
+}
+
+// WithCRLF converts the line endings of entries to CRLF, see Options.CRLF.
+func WithCRLF() Option {
+	return func(o *Options) error {
+		o.CRLF = true
+		return nil
+	}
+}
+
+// WithTransform applies fns in order to each entry before it is written, see Options.Transform.
+func WithTransform(fns ...func(b []byte) []byte) Option {
+	return func(o *Options) error {
+		if len(fns) == 0 {
+			return errors.New("at least one transform is required")
+		}
+		for _, fn := range fns {
+			if fn == nil {
+				return errors.New("transform must not be nil")
+			}
+		}
+		o.Transform = ChainTransforms(fns...)
+		return nil
+	}
+}
+
+// WithSyslog copies every entry to a syslog daemon, see Options.Syslog.
+func WithSyslog(opts SyslogOptions) Option {
+	return func(o *Options) error {
+		if err := opts.validate(); err != nil {
+			return errors.Wrap(err, "invalid syslog options")
+		}
+		o.Syslog = &opts
+		return nil
+	}
+}
+
+// WithWebhook notifies an HTTP endpoint of each closed file, see Options.Webhook.
+func WithWebhook(opts WebhookOptions) Option {
+	return func(o *Options) error {
+		if err := opts.validate(); err != nil {
+			return errors.Wrap(err, "invalid webhook options")
+		}
+		o.Webhook = &opts
+		return nil
+	}
+}
+
+// WithTeeTo copies every entry to tee, see Options.TeeTo.
+func WithTeeTo(tee io.Writer) Option {
+	return func(o *Options) error {
+		if tee == nil {
+			return errors.New("tee writer must not be nil")
+		}
+		o.TeeTo = tee
+		return nil
+	}
+}
+
+// WithUploader uploads files once they will not be written to again, see Options.Uploader.
+func WithUploader(uploader Uploader) Option {
+	return func(o *Options) error {
+		if uploader == nil {
+			return errors.New("uploader must not be nil")
+		}
+		o.Uploader = uploader
+		return nil
+	}
+}
+
+// WithDeleteAfterUpload removes files once they have been uploaded, see Options.DeleteAfterUpload.
+func WithDeleteAfterUpload() Option {
+	return func(o *Options) error {
+		o.DeleteAfterUpload = true
+		return nil
+	}
+}
+
+// WithUploadRetry sets the policy for retrying failed uploads, see Options.UploadRetry.
+func WithUploadRetry(policy RetryPolicy) Option {
+	return func(o *Options) error {
+		if policy.MaximumAttempts < 1 {
+			return errors.Errorf("maximum attempts must be at least 1, got %d", policy.MaximumAttempts)
+		}
+		if policy.InitialBackoff < 0 || policy.MaximumBackoff < 0 {
+			return errors.New("backoff must not be negative")
+		}
+		o.UploadRetry = policy
+		return nil
+	}
+}
+
+// WithUploadConcurrency sets the number of files uploaded at the same time.
+func WithUploadConcurrency(n int) Option {
+	return func(o *Options) error {
+		if n < 1 {
+			return errors.Errorf("upload concurrency must be at least 1, got %d", n)
+		}
+		o.UploadConcurrency = n
+		return nil
+	}
+}
+
+// WithUploadBandwidth limits the rate at which files are uploaded, see Options.UploadBandwidth.
+func WithUploadBandwidth(bytesPerSecond int64) Option {
+	return func(o *Options) error {
+		if bytesPerSecond < 1 {
+			return errors.Errorf("upload bandwidth must be at least 1 byte per second, got %d", bytesPerSecond)
+		}
+		o.UploadBandwidth = bytesPerSecond
+		return nil
+	}
+}
+
+// WithMaximumOpenFiles bounds the number of files open at the same time, see Options.MaximumOpenFiles.
+func WithMaximumOpenFiles(n int) Option {
+	return func(o *Options) error {
+		if n < 1 {
+			return errors.Errorf("maximum open files must be at least 1, got %d", n)
+		}
+		o.MaximumOpenFiles = n
+		return nil
+	}
+}
+
+// WithScheduler shares the timers and background work of the Writer with other Writers, see Options.Scheduler.
+func WithScheduler(s *Scheduler) Option {
+	return func(o *Options) error {
+		if s == nil {
+			return errors.New("scheduler must not be nil")
+		}
+		o.Scheduler = s
+		return nil
+	}
+}
+
+// WithLock prevents other processes from writing into the same directory, see Options.Lock.
+func WithLock(opts LockOptions) Option {
+	return func(o *Options) error {
+		if err := opts.validate(); err != nil {
+			return errors.Wrap(err, "invalid lock options")
+		}
+		o.Lock = &opts
+		return nil
+	}
+}
+
+// WithShared enables writing into the same directory from multiple processes, see Options.Shared.
+func WithShared() Option {
+	return func(o *Options) error {
+		o.Shared = true
+		return nil
+	}
+}
+
+// WithExpvarPrefix publishes the Writer's Stats via expvar under prefix.
+func WithExpvarPrefix(prefix string) Option {
+	return func(o *Options) error {
+		if prefix == "" {
+			return errors.New("expvar prefix must not be empty")
+		}
+		o.ExpvarPrefix = prefix
+		return nil
+	}
+}
+
+// WithMetrics reports the counters, gauges and timers of the Writer to metrics, see Options.Metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *Options) error {
+		if metrics == nil {
+			return errors.New("metrics must not be nil")
+		}
+		o.Metrics = metrics
+		return nil
+	}
+}
+
+// WithDebug logs the decisions of the Writer, such as why files are rotated, see Options.Debug.
+func WithDebug() Option {
+	return func(o *Options) error {
+		o.Debug = true
+		return nil
+	}
+}
+
+// WithOnRotate calls fn after each rotation, see Options.OnRotate.
+func WithOnRotate(fn func(e RotationEvent)) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("rotation hook must not be nil")
+		}
+		o.OnRotate = fn
+		return nil
+	}
+}
+
+// WithOnFileClose calls fn once for each file the Writer finished writing, see Options.OnFileClose.
+func WithOnFileClose(fn func(e FileCloseEvent)) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("file close hook must not be nil")
+		}
+		o.OnFileClose = fn
+		return nil
+	}
+}
+
+// WithNow sets the source of the current time, see Options.Now.
+func WithNow(now func() time.Time) Option {
+	return func(o *Options) error {
+		if now == nil {
+			return errors.New("now must not be nil")
+		}
+		o.Now = now
+		return nil
+	}
+}
+
+// WithNewTicker sets how tickers rotating expired files are created, see Options.NewTicker.
+func WithNewTicker(newTicker func(d time.Duration) Ticker) Option {
+	return func(o *Options) error {
+		if newTicker == nil {
+			return errors.New("new ticker must not be nil")
+		}
+		o.NewTicker = newTicker
+		return nil
+	}
+}
+
+// WithFS sets the filesystem files are written to, see Options.FS.
+func WithFS(fs FS) Option {
+	return func(o *Options) error {
+		if fs == nil {
+			return errors.New("FS must not be nil")
+		}
+		o.FS = fs
+		return nil
+	}
+}
+
+// WithUploadManifest sets the file tracking files which have not been uploaded yet, see Options.UploadManifest.
+func WithUploadManifest(path string) Option {
+	return func(o *Options) error {
+		if path == "" {
+			return errors.New("upload manifest must not be empty")
+		}
+		o.UploadManifest = path
+		return nil
+	}
+}
+
+// WithFailedUploadDirectory moves files which failed to upload after all retries to dir, see Options.FailedUploadDirectory.
+func WithFailedUploadDirectory(dir string) Option {
+	return func(o *Options) error {
+		if dir == "" {
+			return errors.New("failed upload directory must not be empty")
+		}
+		o.FailedUploadDirectory = dir
+		return nil
+	}
+}
+
+// WithOnUploadFailure calls fn when a file failed to upload after all retries, see Options.OnUploadFailure.
+func WithOnUploadFailure(fn func(e UploadFailureEvent)) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("upload failure hook must not be nil")
+		}
+		o.OnUploadFailure = fn
+		return nil
+	}
+}
+
+// WithRotationManifest records each closed file as a JSON line in the file at path, see Options.RotationManifest.
+func WithRotationManifest(path string) Option {
+	return func(o *Options) error {
+		if path == "" {
+			return errors.New("rotation manifest must not be empty")
+		}
+		o.RotationManifest = path
+		return nil
+	}
+}
+
+// WithFileMode sets the permissions of the files created, see Options.FileMode.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *Options) error {
+		if mode&^os.ModePerm != 0 {
+			return errors.Errorf("file mode must only contain permission bits, got %v", mode)
+		}
+		o.FileMode = mode
+		return nil
+	}
+}
+
+// WithDirMode sets the permissions of the directories created, see Options.DirMode.
+func WithDirMode(mode os.FileMode) Option {
+	return func(o *Options) error {
+		if mode&^os.ModePerm != 0 {
+			return errors.Errorf("directory mode must only contain permission bits, got %v", mode)
+		}
+		o.DirMode = mode
+		return nil
+	}
+}
+
+// WithOwner changes the owner of the files and directories created, see Options.Owner.
+func WithOwner(owner Owner) Option {
+	return func(o *Options) error {
+		if err := owner.validate(); err != nil {
+			return errors.Wrap(err, "invalid owner")
+		}
+		o.Owner = &owner
+		return nil
+	}
+}
+
+// WithExactMode applies FileMode and DirMode regardless of the umask, see Options.ExactMode.
+func WithExactMode() Option {
+	return func(o *Options) error {
+		o.ExactMode = true
+		return nil
+	}
+}
+
+// WithEncryptionKey encrypts entries with AES-256-GCM using key, see Options.EncryptionKey.
+func WithEncryptionKey(key []byte) Option {
+	return func(o *Options) error {
+		if len(key) != EncryptionKeySize {
+			return errors.Errorf("encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+		}
+		o.EncryptionKey = key
+		return nil
+	}
+}
+
+// WithAppendOnly makes files append-only while they are written to, and immutable once closed, see Options.AppendOnly.
+func WithAppendOnly() Option {
+	return func(o *Options) error {
+		o.AppendOnly = true
+		return nil
+	}
+}
+
+// WithSuppressDuplicates summarizes consecutive identical entries, see Options.SuppressDuplicates.
+func WithSuppressDuplicates() Option {
+	return func(o *Options) error {
+		o.SuppressDuplicates = true
+		return nil
+	}
+}
+
+// WithHeader writes the output of fn at the beginning of each file, see Options.Header.
+func WithHeader(fn func(w io.Writer) error) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("header must not be nil")
+		}
+		o.Header = fn
+		return nil
+	}
+}
+
+// WithFooter writes the output of fn at the end of each file, see Options.Footer.
+func WithFooter(fn func(w io.Writer) error) Option {
+	return func(o *Options) error {
+		if fn == nil {
+			return errors.New("footer must not be nil")
+		}
+		o.Footer = fn
+		return nil
+	}
+}
+
+// NewWithOptions creates a new Writer configured by functional options.
+// It is equivalent to New with an Options struct, but options are validated
+// individually and only the settings which are explicitly given are enabled.
+//
+//	w, err := logrotate.NewWithOptions(logger,
+//		logrotate.WithDirectory("/var/log/app"),
+//		logrotate.WithMaximumFileSize(100<<20),
+//	)
+func NewWithOptions(logger Logger, options ...Option) (*Writer, error) {
+	var opts Options
+	for _, option := range options {
+		if err := option(&opts); err != nil {
+			return nil, errors.Wrap(err, "invalid option")
+		}
+	}
+
+	if opts.Directory == "" {
+		return nil, errors.New("directory is required, see WithDirectory")
+	}
+
+	return New(logger, opts)
+}
@@ -0,0 +1,234 @@
+package logrotate
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("applies options", func(t *testing.T) {
+		clock, key := newFakeClock(), make([]byte, EncryptionKeySize)
+		w, err := NewWithOptions(logger,
+			WithDirectory(dir),
+			WithMaximumFileSize(1024),
+			WithMaximumLifetime(time.Hour),
+			WithDiskFullPolicy(DiskFullFail),
+			WithQueueSize(16),
+			WithNonBlocking(),
+			WithChecksum(),
+			WithDeleteAfterUpload(),
+			WithOnRotate(func(e RotationEvent) {}),
+			WithOnFileClose(func(e FileCloseEvent) {}),
+			WithNow(clock.Now),
+			WithNewTicker(clock.NewTicker),
+			WithFS(OSFS{}),
+			WithUploadManifest(filepath.Join(dir, "uploads")),
+			WithFailedUploadDirectory("failed"),
+			WithOnUploadFailure(func(e UploadFailureEvent) {}),
+			WithRotationManifest("rotations.jsonl"),
+			WithFileMode(0600),
+			WithDirMode(0700),
+			WithOwner(Owner{UID: -1, GID: -1}),
+			WithExactMode(),
+			WithEncryptionKey(key),
+			WithSuppressDuplicates(),
+			WithHeader(func(w io.Writer) error { return nil }),
+			WithFooter(func(w io.Writer) error { return nil }),
+		)
+		require.NoError(t, err)
+		defer w.Close()
+
+		require.Equal(t, dir, w.opts.Directory)
+		require.Equal(t, int64(1024), w.opts.MaximumFileSize)
+		require.Equal(t, time.Hour, w.opts.MaximumLifetime)
+		require.Equal(t, DiskFullFail, w.opts.DiskFullPolicy)
+		require.True(t, w.opts.NonBlocking)
+		require.Equal(t, 16, w.opts.QueueSize)
+		require.Equal(t, 16, cap(w.queue))
+		require.True(t, w.opts.Checksum)
+		require.True(t, w.opts.DeleteAfterUpload)
+		require.NotNil(t, w.opts.OnRotate)
+		require.NotNil(t, w.opts.OnFileClose)
+		require.Equal(t, clock.Now(), w.opts.Now())
+		require.NotNil(t, w.opts.NewTicker)
+		require.Equal(t, OSFS{}, w.opts.FS)
+		require.Equal(t, filepath.Join(dir, "uploads"), w.opts.UploadManifest)
+		require.Equal(t, "failed", w.opts.FailedUploadDirectory)
+		require.NotNil(t, w.opts.OnUploadFailure)
+		require.Equal(t, "rotations.jsonl", w.opts.RotationManifest)
+		require.Equal(t, os.FileMode(0600), w.opts.FileMode)
+		require.Equal(t, os.FileMode(0700), w.opts.DirMode)
+		require.Equal(t, &Owner{UID: -1, GID: -1}, w.opts.Owner)
+		require.True(t, w.opts.ExactMode)
+		require.Equal(t, key, w.opts.EncryptionKey)
+		require.True(t, w.opts.SuppressDuplicates)
+		require.NotNil(t, w.opts.Header)
+		require.NotNil(t, w.opts.Footer)
+		require.NotNil(t, w.opts.FileNameFunc, "must default file name func")
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		for name, option := range map[string]Option{
+			"empty directory":               WithDirectory(""),
+			"zero file size":                WithMaximumFileSize(0),
+			"negative lifetime":             WithMaximumLifetime(-time.Second),
+			"nil file name func":            WithFileNameFunc(nil),
+			"no attempts":                   WithRetry(RetryPolicy{}),
+			"no upload attempts":            WithUploadRetry(RetryPolicy{}),
+			"no upload workers":             WithUploadConcurrency(0),
+			"no upload bandwidth":           WithUploadBandwidth(0),
+			"nil fallback":                  WithFallback(nil),
+			"unknown policy":                WithDiskFullPolicy(DiskFullPolicy(42)),
+			"negative queue size":           WithQueueSize(-1),
+			"nil rotation hook":             WithOnRotate(nil),
+			"nil file close hook":           WithOnFileClose(nil),
+			"nil now":                       WithNow(nil),
+			"nil new ticker":                WithNewTicker(nil),
+			"nil FS":                        WithFS(nil),
+			"empty upload manifest":         WithUploadManifest(""),
+			"empty failed upload directory": WithFailedUploadDirectory(""),
+			"nil upload failure hook":       WithOnUploadFailure(nil),
+			"empty rotation manifest":       WithRotationManifest(""),
+			"file mode type bits":           WithFileMode(os.ModeDir | 0644),
+			"dir mode type bits":            WithDirMode(os.ModeSymlink | 0755),
+			"invalid owner":                 WithOwner(Owner{UID: -2}),
+			"short encryption key":          WithEncryptionKey(make([]byte, EncryptionKeySize-1)),
+			"nil header":                    WithHeader(nil),
+			"nil footer":                    WithFooter(nil),
+		} {
+			_, err := NewWithOptions(logger, WithDirectory(dir), option)
+			require.Error(t, err, name)
+		}
+	})
+
+	t.Run("sets options requiring privileges", func(t *testing.T) {
+		// Applied to Options only, since setting file attributes requires CAP_LINUX_IMMUTABLE.
+		var opts Options
+		require.NoError(t, WithAppendOnly()(&opts))
+		require.True(t, opts.AppendOnly)
+	})
+
+	t.Run("requires directory", func(t *testing.T) {
+		_, err := NewWithOptions(logger)
+		require.Error(t, err)
+	})
+}
+
+func TestOptions_Validate(t *testing.T) {
+	require.NoError(t, Options{Directory: "logs"}.Validate())
+	require.NoError(t, Options{Directory: "logs", MaximumLifetime: time.Millisecond}.Validate(), "must accept short lifetimes")
+
+	for name, opts := range map[string]Options{
+		"empty directory":       {},
+		"negative size":         {Directory: "logs", MaximumFileSize: -1},
+		"negative lifetime":     {Directory: "logs", MaximumLifetime: -time.Second},
+		"negative attempts":     {Directory: "logs", Retry: RetryPolicy{MaximumAttempts: -1}},
+		"conflicting backoff":   {Directory: "logs", Retry: RetryPolicy{InitialBackoff: time.Second, MaximumBackoff: time.Millisecond}},
+		"unknown disk policy":   {Directory: "logs", DiskFullPolicy: DiskFullPolicy(42)},
+		"negative backoff":      {Directory: "logs", Retry: RetryPolicy{InitialBackoff: -time.Second}},
+		"negative max. backoff": {Directory: "logs", Retry: RetryPolicy{MaximumBackoff: -time.Second}},
+		"negative upload retry": {Directory: "logs", UploadRetry: RetryPolicy{MaximumAttempts: -1}},
+		"negative concurrency":  {Directory: "logs", UploadConcurrency: -1},
+		"negative bandwidth":    {Directory: "logs", UploadBandwidth: -1},
+		"file mode type bits":   {Directory: "logs", FileMode: os.ModeDir | 0644},
+		"dir mode type bits":    {Directory: "logs", DirMode: os.ModeSymlink | 0755},
+		"uploader with FS":      {Directory: "logs", FS: newMemFS(), Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error { return nil })},
+	} {
+		err := opts.Validate()
+		require.Error(t, err, name)
+
+		_, err = New(nil, opts)
+		require.Error(t, err, "New must validate options: %v", name)
+	}
+}
+
+func TestWriter_SetOptions(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory: dir,
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	require.Error(t, w.SetOptions(Options{}), "must validate options")
+
+	require.NoError(t, w.SetOptions(Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+		FileNameFunc:    func() string { return "next.log" },
+	}))
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, filepath.Join(dir, "next.log"), w.CurrentFilename(), "must rotate into the new file name")
+	require.Equal(t, int64(1), w.Stats().Rotations)
+
+	_, err = w.Write([]byte("01234567890"))
+	require.Error(t, err, "must apply new limits to Write")
+
+	t.Run("rejects changes to options fixed at creation", func(t *testing.T) {
+		for name, opts := range map[string]Options{
+			"Directory":     {Directory: filepath.Join(dir, "next")},
+			"FS":            {Directory: dir, FS: newMemFS()},
+			"EncryptionKey": {Directory: dir, EncryptionKey: make([]byte, EncryptionKeySize)},
+			"QueueSize":     {Directory: dir, QueueSize: 1},
+			"ExpvarPrefix":  {Directory: dir, ExpvarPrefix: "logrotate.test.setoptions"},
+			"TeeTo":         {Directory: dir, TeeTo: ioutil.Discard},
+			"Now":           {Directory: dir, Now: newFakeClock().Now},
+		} {
+			err := w.SetOptions(opts)
+			require.Error(t, err, name)
+			require.Contains(t, err.Error(), name, "must name the option")
+		}
+		require.Equal(t, int64(10), w.opts.MaximumFileSize, "must not change any option")
+	})
+
+	t.Run("applies options to the next entry", func(t *testing.T) {
+		fs := newMemFS()
+		opts := Options{Directory: "logs", FS: fs, FileNameFunc: func() string { return "app.log" }}
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		defer w.Close()
+
+		_, err = w.Write([]byte("first\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+
+		opts.Filter = func(b []byte) bool { return !bytes.HasPrefix(b, []byte("second")) }
+		opts.Transform = bytes.ToUpper
+		require.NoError(t, w.SetOptions(opts))
+		for _, entry := range []string{"second\n", "third\n"} {
+			_, err = w.Write([]byte(entry))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+		require.Equal(t, "first\nTHIRD\n", fs.contents(filepath.Join("logs", "app.log")))
+		require.Equal(t, int64(1), w.Stats().EntriesFiltered)
+	})
+
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,352 @@
+// Package otlprotate exports entries to an OpenTelemetry collector over OTLP/HTTP,
+// so that teams can keep local rotated files while migrating to OpenTelemetry.
+//
+// An Exporter is an io.Writer sending each entry as a log record. Entries are
+// queued and sent in batches in the background, Write never blocks and never
+// fails, so an Exporter can be combined with a logrotate.Writer without an
+// unavailable collector affecting the local files:
+//
+//	exporter, err := otlprotate.NewExporter(otlprotate.Options{
+//		Endpoint: "http://otel-collector:4318/v1/logs",
+//		Resource: map[string]string{"service.name": "app"},
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	defer exporter.Close()
+//
+//	logger := log.New(io.MultiWriter(exporter, w), "", log.LstdFlags)
+package otlprotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEndpoint is the OTLP/HTTP logs endpoint of a collector running locally.
+const DefaultEndpoint = "http://localhost:4318/v1/logs"
+
+const (
+	defaultBatchSize    = 512
+	defaultBatchTimeout = time.Second
+	defaultQueueSize    = 2048
+	defaultTimeout      = 10 * time.Second
+
+	// scopeName is the instrumentation scope of exported records.
+	scopeName = "github.com/easyCZ/logrotate/otlprotate"
+)
+
+// Options configure an Exporter.
+type Options struct {
+	// Endpoint is the URL records are sent to. Defaults to DefaultEndpoint.
+	Endpoint string
+
+	// Headers are added to each request, for example for authentication.
+	Headers map[string]string
+
+	// Resource are the attributes of the resource producing the records,
+	// such as service.name.
+	Resource map[string]string
+
+	// Client sends requests. When Client is nil, a client with a 10s timeout is used.
+	Client *http.Client
+
+	// BatchSize is the maximum number of records sent in one request. Defaults to 512.
+	BatchSize int
+
+	// BatchTimeout is the longest time a record waits for a batch to fill up
+	// before it is sent. Defaults to 1s.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of records which can be queued up awaiting to be sent,
+	// records written while the queue is full are dropped. Defaults to 2048.
+	QueueSize int
+
+	// Logger receives failures to send records. When nil, failures are not logged.
+	Logger logrotate.Logger
+}
+
+// Exporter sends entries written to it as log records to an OTLP/HTTP endpoint.
+type Exporter struct {
+	opts   Options
+	logger logrotate.Logger
+
+	queue   chan record
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+	closeMu   sync.RWMutex
+	closed    bool
+
+	dropped int64
+}
+
+// record is an entry awaiting to be sent.
+type record struct {
+	body string
+	time time.Time
+}
+
+// NewExporter creates an Exporter and starts sending records in the background.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.BatchSize < 0 || opts.BatchTimeout < 0 || opts.QueueSize < 0 {
+		return nil, errors.New("BatchSize, BatchTimeout and QueueSize must not be negative")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.BatchTimeout == 0 {
+		opts.BatchTimeout = defaultBatchTimeout
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrotate.LoggerFunc(func(string, ...interface{}) {})
+	}
+
+	e := &Exporter{
+		opts:    opts,
+		logger:  logger,
+		queue:   make(chan record, opts.QueueSize),
+		flushes: make(chan chan struct{}),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e, nil
+}
+
+// Write queues p to be sent as the body of a log record, without a trailing newline.
+// Write does not block, when the queue is full or the Exporter is closed the
+// record is dropped, see Dropped. Write always reports success.
+func (e *Exporter) Write(p []byte) (int, error) {
+	e.closeMu.RLock()
+	defer e.closeMu.RUnlock()
+
+	if e.closed {
+		atomic.AddInt64(&e.dropped, 1)
+		return len(p), nil
+	}
+
+	r := record{body: string(bytes.TrimRight(p, "\r\n")), time: time.Now()}
+	select {
+	case e.queue <- r:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of records which were not sent, because the queue
+// was full, the Exporter was closed or the endpoint rejected them.
+func (e *Exporter) Dropped() int64 {
+	return atomic.LoadInt64(&e.dropped)
+}
+
+// Flush blocks until all records written so far have been sent, or until ctx is done.
+func (e *Exporter) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case e.flushes <- flushed:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close sends all queued records and stops the Exporter.
+// Records written after Close are dropped.
+func (e *Exporter) Close() error {
+	e.closeOnce.Do(func() {
+		e.closeMu.Lock()
+		e.closed = true
+		e.closeMu.Unlock()
+
+		close(e.closing)
+	})
+
+	<-e.done
+	return nil
+}
+
+// run batches queued records and sends them until the Exporter is closed.
+func (e *Exporter) run() {
+	defer close(e.done)
+
+	timer := time.NewTimer(e.opts.BatchTimeout)
+	defer timer.Stop()
+
+	batch := make([]record, 0, e.opts.BatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+	// drain sends all records currently queued.
+	drain := func() {
+		for {
+			select {
+			case r := <-e.queue:
+				batch = append(batch, r)
+				if len(batch) == e.opts.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case r := <-e.queue:
+			batch = append(batch, r)
+			if len(batch) == e.opts.BatchSize {
+				send()
+			}
+		case <-timer.C:
+			send()
+			timer.Reset(e.opts.BatchTimeout)
+		case flushed := <-e.flushes:
+			drain()
+			close(flushed)
+		case <-e.closing:
+			drain()
+			return
+		}
+	}
+}
+
+// send exports a batch of records, failures are logged and counted as dropped.
+func (e *Exporter) send(batch []record) {
+	if err := e.post(batch); err != nil {
+		atomic.AddInt64(&e.dropped, int64(len(batch)))
+		e.logger.Printf("Failed to export %d records to %s: %v", len(batch), e.opts.Endpoint, err)
+	}
+}
+
+// post sends a batch of records as an OTLP/HTTP JSON request.
+func (e *Exporter) post(batch []record) error {
+	body, err := json.Marshal(newLogsRequest(e.opts.Resource, batch))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode records")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// The following types are the subset of the OTLP JSON encoding used by the Exporter,
+// see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string   `json:"timeUnixNano"`
+	ObservedTimeUnixNano string   `json:"observedTimeUnixNano"`
+	Body                 anyValue `json:"body"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func newLogsRequest(attributes map[string]string, batch []record) logsRequest {
+	var res resource
+	for key, value := range attributes {
+		res.Attributes = append(res.Attributes, keyValue{Key: key, Value: anyValue{StringValue: value}})
+	}
+	sort.Slice(res.Attributes, func(i, j int) bool { return res.Attributes[i].Key < res.Attributes[j].Key })
+
+	records := make([]logRecord, 0, len(batch))
+	for _, r := range batch {
+		ts := strconv.FormatInt(r.time.UnixNano(), 10)
+		records = append(records, logRecord{
+			TimeUnixNano:         ts,
+			ObservedTimeUnixNano: ts,
+			Body:                 anyValue{StringValue: r.body},
+		})
+	}
+
+	return logsRequest{ResourceLogs: []resourceLogs{{
+		Resource:  res,
+		ScopeLogs: []scopeLogs{{Scope: scope{Name: scopeName}, LogRecords: records}},
+	}}}
+}
@@ -0,0 +1,80 @@
+package otlprotate
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []logsRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		var req logsRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(Options{
+		Endpoint:     server.URL + "/v1/logs",
+		Headers:      map[string]string{"Authorization": "secret"},
+		Resource:     map[string]string{"service.name": "test"},
+		BatchSize:    2,
+		BatchTimeout: time.Hour,
+	})
+	require.NoError(t, err)
+
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		n, err := exporter.Write([]byte(message))
+		require.NoError(t, err)
+		require.Equal(t, len(message), n)
+	}
+	require.NoError(t, exporter.Flush(context.Background()))
+	require.NoError(t, exporter.Close())
+
+	var bodies []string
+	for _, req := range requests {
+		require.Len(t, req.ResourceLogs, 1)
+		require.Equal(t, []keyValue{{Key: "service.name", Value: anyValue{StringValue: "test"}}}, req.ResourceLogs[0].Resource.Attributes)
+		for _, record := range req.ResourceLogs[0].ScopeLogs[0].LogRecords {
+			require.NotEmpty(t, record.TimeUnixNano)
+			bodies = append(bodies, record.Body.StringValue)
+		}
+	}
+	require.Len(t, requests, 2, "batches of 2 records")
+	require.Equal(t, []string{"first", "second", "third"}, bodies)
+	require.Zero(t, exporter.Dropped())
+
+	_, err = exporter.Write([]byte("after close"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), exporter.Dropped())
+}
+
+func TestExporter_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(Options{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = exporter.Write([]byte("message"))
+	require.NoError(t, err)
+	require.NoError(t, exporter.Close())
+	require.Equal(t, int64(1), exporter.Dropped())
+}
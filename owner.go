@@ -0,0 +1,43 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Owner is the user and group owning the files and directories created by a Writer,
+// see Options.Owner.
+type Owner struct {
+	// UID is the numeric user ID of the owner, -1 keeps the user of the process.
+	UID int
+	// GID is the numeric group ID of the owner, -1 keeps the group of the process.
+	GID int
+}
+
+func (o Owner) validate() error {
+	if o.UID < -1 || o.GID < -1 {
+		return errors.Errorf("UID and GID must be IDs or -1, got %d and %d", o.UID, o.GID)
+	}
+	return nil
+}
+
+// ChownFS is a FS which can change the owner of files, required by Options.Owner.
+// The semantics of Chown follow os.Chown.
+type ChownFS interface {
+	FS
+	Chown(name string, uid, gid int) error
+}
+
+// chown changes the owner of the file or directory at path to owner, if set.
+func chown(fs FS, owner *Owner, path string) error {
+	if owner == nil {
+		return nil
+	}
+	cfs, ok := fs.(ChownFS)
+	if !ok {
+		return errors.New("FS does not support changing owners")
+	}
+	if err := cfs.Chown(path, owner.UID, owner.GID); err != nil {
+		return errors.Wrapf(err, "failed to change owner of %s", path)
+	}
+	return nil
+}
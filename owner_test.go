@@ -0,0 +1,84 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// chownFS is a memFS recording the owners of files.
+type chownFS struct {
+	*memFS
+
+	mu     sync.Mutex
+	owners map[string]Owner
+}
+
+func (fs *chownFS) Chown(name string, uid, gid int) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.owners[name] = Owner{UID: uid, GID: gid}
+	return nil
+}
+
+func TestWriter_Owner(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("changes the owner of created files and directories", func(t *testing.T) {
+		fs := &chownFS{memFS: newMemFS(), owners: map[string]Owner{}}
+		owner := Owner{UID: 1000, GID: -1}
+		w, err := New(logger, Options{
+			Directory:        "/logs",
+			FS:               fs,
+			Owner:            &owner,
+			Checksum:         true,
+			RotationManifest: "rotations.jsonl",
+			FileNameFunc:     func() string { return "app.log" },
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, map[string]Owner{
+			"/logs":                 owner,
+			"/logs/app.log":         owner,
+			"/logs/app.log.sha256":  owner,
+			"/logs/rotations.jsonl": owner,
+		}, fs.owners)
+	})
+
+	t.Run("changes the owner with the operating system's filesystem", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Chown is not supported on Windows")
+		}
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		// Changing the owner to the current user does not require privileges.
+		w, err := New(logger, Options{
+			Directory: filepath.Join(dir, "logs"),
+			Owner:     &Owner{UID: os.Getuid(), GID: os.Getgid()},
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("rejects invalid owners", func(t *testing.T) {
+		for name, opts := range map[string]Options{
+			"invalid uid":    {Directory: "logs", Owner: &Owner{UID: -2, GID: -1}},
+			"invalid gid":    {Directory: "logs", Owner: &Owner{UID: -1, GID: -2}},
+			"unsupported FS": {Directory: "logs", Owner: &Owner{UID: 1000, GID: 1000}, FS: newMemFS()},
+		} {
+			require.Error(t, opts.Validate(), name)
+		}
+	})
+}
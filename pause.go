@@ -0,0 +1,72 @@
+package logrotate
+
+// Pause stops writing entries to files until Resume is called.
+// Before Pause returns, all entries accepted so far are written and
+// synchronized to disk, so that the current file can be safely copied or snapshotted.
+// While paused, Write keeps accepting entries until the queue is full,
+// see Options.QueueSize. Closing the Writer resumes it.
+// Calling Pause on a paused Writer does nothing.
+// While paused, calls handled by the background writer, such as Flush, Sync,
+// Rotate, Reopen and a concurrent Pause, block until Resume or Close is called.
+// A Pause blocked in this way returns without pausing the Writer again.
+func (w *Writer) Pause() error {
+	w.mu.Lock()
+	if w.resumed != nil {
+		w.mu.Unlock()
+		return nil
+	}
+	resumes := w.resumes
+	w.mu.Unlock()
+
+	return w.control(func() error {
+		// Checked again on the background writer, which runs a single
+		// operation at a time, since another Pause, or Resume, may have been
+		// called since.
+		w.mu.Lock()
+		skip := w.resumed != nil || w.resumes != resumes
+		w.mu.Unlock()
+		if skip {
+			return nil
+		}
+
+		if err := w.flush(true); err != nil {
+			return err
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.resumes == resumes {
+			w.resumed = make(chan struct{})
+		}
+		return nil
+	})
+}
+
+// Resume continues writing entries after Pause.
+// Calling Resume on a Writer which is not paused does nothing.
+func (w *Writer) Resume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.resumes++
+	if w.resumed != nil {
+		close(w.resumed)
+		w.resumed = nil
+	}
+}
+
+// waitWhilePaused blocks the background writer until it is resumed or aborted.
+func (w *Writer) waitWhilePaused() {
+	w.mu.Lock()
+	resumed := w.resumed
+	w.mu.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	select {
+	case <-resumed:
+	case <-w.abort:
+	}
+}
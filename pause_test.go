@@ -0,0 +1,104 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_PauseResume(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return "file.log" },
+		QueueSize:    2,
+		NonBlocking:  true,
+	})
+	require.NoError(t, err)
+	path := filepath.Join(dir, "file.log")
+
+	_, err = w.Write([]byte("before"))
+	require.NoError(t, err)
+	require.NoError(t, w.Pause())
+	require.NoError(t, w.Pause(), "must tolerate pausing twice")
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "before", string(written), "must write accepted entries before pausing")
+
+	for i := 0; i < 2; i++ {
+		_, err = w.Write([]byte("-paused"))
+		require.NoError(t, err)
+	}
+	_, err = w.Write([]byte("-dropped"))
+	require.Equal(t, ErrQueueFull, err, "must buffer entries up to the queue size")
+
+	time.Sleep(10 * time.Millisecond)
+	written, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "before", string(written), "must not write while paused")
+
+	w.Resume()
+	require.NoError(t, w.Flush())
+
+	written, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "before-paused-paused", string(written))
+
+	require.NoError(t, w.Pause())
+	require.NoError(t, w.Close(), "must resume when closing")
+}
+
+func TestWriter_ConcurrentPause(t *testing.T) {
+	filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+	w, err := New(log.New(os.Stderr, "", log.LstdFlags), Options{
+		Directory: "logs",
+		FS:        newMemFS(),
+		Filter: func(b []byte) bool {
+			select {
+			case filtering <- struct{}{}:
+				<-unblock
+			default:
+			}
+			return true
+		},
+	})
+	require.NoError(t, err)
+
+	// Both calls find the Writer running, and are queued up behind the entry
+	// held by Filter.
+	_, err = w.Write([]byte("entry\n"))
+	require.NoError(t, err)
+	<-filtering
+	paused := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { paused <- w.Pause() }()
+	}
+	for len(w.queue) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+
+	require.NoError(t, <-paused)
+	w.Resume()
+	require.NoError(t, <-paused)
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- w.Flush() }()
+	select {
+	case err := <-flushed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the Pause queued up before Resume must not pause the Writer again")
+	}
+	require.NoError(t, w.Close())
+}
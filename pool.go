@@ -0,0 +1,482 @@
+package logrotate
+
+import (
+	"container/list"
+	"github.com/pkg/errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolOptions configure a Pool.
+type PoolOptions struct {
+	// Options are the options of every Writer of the Pool, Directory is the
+	// parent directory of the Writers' directories. Options which would be
+	// shared by the Writers, ExpvarPrefix, UploadManifest and an absolute Lock
+	// path, cannot be used.
+	Options Options
+
+	// MaximumOpen is the maximum number of Writers kept open, so that Pools
+	// with thousands of keys do not exhaust file descriptors. When a Writer
+	// must be created beyond MaximumOpen, the least recently used Writer which
+	// is not being written to is closed, finishing its file, and is created
+	// again by the next write with its key, starting a new file. FileNameFunc
+	// must therefore return unique names, as the default does, unless Shared
+	// is set so that files are appended to rather than truncated.
+	// When MaximumOpen == 0, Writers are kept open until the Pool is closed.
+	// With Options.MaximumOpenFiles, which then bounds the files of all the
+	// Writers, MaximumOpen must be set and lower, so that files can be opened
+	// while every open Writer has a current file.
+	MaximumOpen int
+
+	// IdleTimeout, when set, closes Writers which have not been written to for
+	// IdleTimeout, finishing their files so that they are uploaded promptly and
+	// releasing their file descriptors. Like Writers evicted by MaximumOpen,
+	// they are created again by the next write with their key.
+	// Idle Writers are looked for every half of IdleTimeout, using the Now and
+	// NewTicker of Options.
+	IdleTimeout time.Duration
+
+	// Overrides customize the Options of the Writers of some keys, for example
+	// to sync audit logs after every entry while rotating debug logs often.
+	Overrides []PoolOverride
+}
+
+// PoolOverride customizes the Options of the Writers whose key matches Pattern.
+type PoolOverride struct {
+	// Pattern is matched against keys with filepath.Match, for example "audit"
+	// or "debug-*".
+	Pattern string
+	// Options are applied in order to the Options of the Pool, after those of
+	// the previous overrides which match the key. Directory is always the
+	// subdirectory of the key, and MaximumOpenFiles cannot be overridden.
+	Options []Option
+}
+
+func (o PoolOptions) validate() error {
+	if err := validatePoolWriterOptions(o.Options); err != nil {
+		return err
+	}
+	for _, override := range o.Overrides {
+		if _, err := filepath.Match(override.Pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid override pattern %q", override.Pattern)
+		}
+		opts, err := override.apply(o.Options)
+		if err != nil {
+			return errors.Wrapf(err, "invalid override %q", override.Pattern)
+		}
+		if err := validatePoolWriterOptions(opts); err != nil {
+			return errors.Wrapf(err, "invalid override %q", override.Pattern)
+		}
+		if opts.MaximumOpenFiles != o.Options.MaximumOpenFiles {
+			return errors.Errorf("override %q cannot change MaximumOpenFiles", override.Pattern)
+		}
+	}
+	if o.MaximumOpen < 0 {
+		return errors.Errorf("MaximumOpen must not be negative, got %d", o.MaximumOpen)
+	}
+	if o.IdleTimeout < 0 {
+		return errors.Errorf("IdleTimeout must not be negative, got %v", o.IdleTimeout)
+	}
+	if n := o.Options.MaximumOpenFiles; n > 0 && (o.MaximumOpen == 0 || o.MaximumOpen >= n) {
+		return errors.Errorf("MaximumOpen must be between 1 and %d with MaximumOpenFiles, got %d", n-1, o.MaximumOpen)
+	}
+	return nil
+}
+
+// validatePoolWriterOptions checks the options of the Writers of a Pool.
+func validatePoolWriterOptions(o Options) error {
+	if err := o.Validate(); err != nil {
+		return errors.Wrap(err, "invalid options")
+	}
+	if o.ExpvarPrefix != "" {
+		return errors.New("ExpvarPrefix cannot be used with a Pool")
+	}
+	if o.UploadManifest != "" {
+		return errors.New("UploadManifest cannot be used with a Pool, each Writer has its own")
+	}
+	if o.Lock != nil && filepath.IsAbs(o.Lock.Path) {
+		return errors.New("Lock path must be relative with a Pool")
+	}
+	return nil
+}
+
+// apply returns opts with the Options of the override applied.
+func (o PoolOverride) apply(opts Options) (Options, error) {
+	for _, option := range o.Options {
+		if err := option(&opts); err != nil {
+			return Options{}, err
+		}
+	}
+	return opts, nil
+}
+
+// writerOptions returns the options of the Writer of key.
+func (p *Pool) writerOptions(key string) (Options, error) {
+	opts := p.opts.Options
+	for _, override := range p.opts.Overrides {
+		if ok, _ := filepath.Match(override.Pattern, key); !ok {
+			continue
+		}
+		var err error
+		if opts, err = override.apply(opts); err != nil {
+			return Options{}, errors.Wrapf(err, "invalid override %q", override.Pattern)
+		}
+	}
+	if err := validatePoolWriterOptions(opts); err != nil {
+		return Options{}, err
+	}
+	opts.Directory = filepath.Join(p.opts.Options.Directory, key)
+	opts.MaximumOpenFiles = p.opts.Options.MaximumOpenFiles
+	return opts, nil
+}
+
+// Pool is a set of Writers keyed by a label, such as the name of a job or of
+// a tenant, each writing into its own subdirectory of Options.Directory, named
+// after the key. Writers are created lazily, on the first write with their key,
+// and share the Options of the Pool.
+type Pool struct {
+	logger Logger
+	opts   PoolOptions
+	// files bounds the files of all the Writers, see Options.MaximumOpenFiles
+	files fileLimit
+
+	mu      sync.Mutex
+	writers map[string]*pooledWriter
+	// recent orders the keys of writers from the most to the least recently used.
+	recent *list.List
+	// pending holds the keys of Writers being created, or evicted and being
+	// closed, and is closed once they are, so that a key has one Writer at a time.
+	pending map[string]chan struct{}
+	closed  bool
+
+	// stop and done stop looking for idle Writers, see IdleTimeout
+	stop chan struct{}
+	done chan struct{}
+}
+
+// pooledWriter is an open Writer of a Pool.
+type pooledWriter struct {
+	w *Writer
+	// refs is the number of writes in progress, the Writer is not evicted while positive.
+	refs int
+	used *list.Element
+	// lastUsed is the time the Writer was last written to, see IdleTimeout
+	lastUsed time.Time
+}
+
+// NewPool creates a Pool of Writers configured with opts.
+func NewPool(logger Logger, opts PoolOptions) (*Pool, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid pool options")
+	}
+
+	p := &Pool{
+		logger:  loggerOrDiscard(logger),
+		opts:    opts,
+		files:   newFileLimit(opts.Options.MaximumOpenFiles),
+		writers: make(map[string]*pooledWriter),
+		recent:  list.New(),
+		pending: make(map[string]chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts.IdleTimeout > 0 {
+		go p.closeIdle()
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// Writer returns an io.Writer writing into the Writer of key, creating it if
+// necessary. Keys must be valid file names. When the Writer cannot be created,
+// or the Pool has been closed, writes fail with the reason, and creating the
+// Writer is attempted again by the next write.
+func (p *Pool) Writer(key string) io.Writer {
+	pw, err := p.acquire(key)
+	if err != nil {
+		return errWriter{err}
+	}
+	p.release(pw)
+	return poolWriter{pool: p, key: key}
+}
+
+// acquire returns the open Writer of key, creating it if necessary, and
+// prevents its eviction until release is called.
+func (p *Pool) acquire(key string) (*pooledWriter, error) {
+	p.mu.Lock()
+	for {
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if pw, ok := p.writers[key]; ok {
+			pw.refs++
+			pw.lastUsed = p.now()
+			p.recent.MoveToFront(pw.used)
+			p.mu.Unlock()
+			return pw, nil
+		}
+		done, ok := p.pending[key]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+		<-done
+		p.mu.Lock()
+	}
+
+	// The Writer is created without holding p.mu, which would block the
+	// other keys while files are opened and manifests loaded.
+	done := make(chan struct{})
+	p.pending[key] = done
+	p.mu.Unlock()
+
+	w, err := p.create(key)
+
+	p.mu.Lock()
+	delete(p.pending, key)
+	close(done)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	if p.closed {
+		p.mu.Unlock()
+		w.Close()
+		return nil, ErrClosed
+	}
+	pw := &pooledWriter{w: w, refs: 1, used: p.recent.PushFront(key), lastUsed: p.now()}
+	p.writers[key] = pw
+	evicted := p.evict()
+	p.mu.Unlock()
+
+	p.closeEvicted(evicted)
+	return pw, nil
+}
+
+// create creates the Writer of key.
+func (p *Pool) create(key string) (*Writer, error) {
+	if err := validatePoolKey(key); err != nil {
+		return nil, err
+	}
+	opts, err := p.writerOptions(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create writer %s", key)
+	}
+	w, err := newWriter(p.logger, opts, p.files)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create writer %s", key)
+	}
+	return w, nil
+}
+
+// release allows the eviction of pw again.
+func (p *Pool) release(pw *pooledWriter) {
+	p.mu.Lock()
+	pw.refs--
+	pw.lastUsed = p.now()
+	evicted := p.evict()
+	p.mu.Unlock()
+
+	p.closeEvicted(evicted)
+}
+
+// evict removes the least recently used Writers which are not being written
+// to, until at most MaximumOpen are open, and returns them to be closed with
+// closeEvicted. evict is called with p.mu held.
+func (p *Pool) evict() map[string]*Writer {
+	if p.opts.MaximumOpen == 0 {
+		return nil
+	}
+
+	var evicted map[string]*Writer
+	for e := p.recent.Back(); e != nil && len(p.writers) > p.opts.MaximumOpen; {
+		prev := e.Prev()
+		if pw := p.writers[e.Value.(string)]; pw.refs == 0 {
+			evicted = p.remove(evicted, e)
+		}
+		e = prev
+	}
+	return evicted
+}
+
+// evictIdle removes the Writers which have not been written to for IdleTimeout,
+// and returns them to be closed with closeEvicted. evictIdle is called with p.mu held.
+func (p *Pool) evictIdle() map[string]*Writer {
+	now := p.now()
+
+	var evicted map[string]*Writer
+	for e := p.recent.Back(); e != nil; {
+		prev := e.Prev()
+		pw := p.writers[e.Value.(string)]
+		if now.Sub(pw.lastUsed) < p.opts.IdleTimeout {
+			// writers are ordered by use, the following ones are not idle either
+			break
+		}
+		if pw.refs == 0 {
+			evicted = p.remove(evicted, e)
+		}
+		e = prev
+	}
+	return evicted
+}
+
+// remove removes the Writer of the key at e in recent, adding it to evicted.
+func (p *Pool) remove(evicted map[string]*Writer, e *list.Element) map[string]*Writer {
+	key := e.Value.(string)
+	if evicted == nil {
+		evicted = make(map[string]*Writer)
+	}
+	evicted[key] = p.writers[key].w
+	delete(p.writers, key)
+	p.recent.Remove(e)
+	p.pending[key] = make(chan struct{})
+	return evicted
+}
+
+// closeIdle closes idle Writers until the Pool is closed, see IdleTimeout.
+func (p *Pool) closeIdle() {
+	defer close(p.done)
+
+	newTicker := p.opts.Options.NewTicker
+	if newTicker == nil {
+		newTicker = newTimeTicker
+	}
+	ticker := newTicker(p.opts.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			p.mu.Lock()
+			evicted := p.evictIdle()
+			p.mu.Unlock()
+			p.closeEvicted(evicted)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Pool) now() time.Time {
+	if p.opts.Options.Now != nil {
+		return p.opts.Options.Now()
+	}
+	return time.Now()
+}
+
+// closeEvicted closes the Writers returned by evict.
+func (p *Pool) closeEvicted(evicted map[string]*Writer) {
+	for key, w := range evicted {
+		if err := w.Close(); err != nil {
+			p.logger.Printf("Failed to close evicted writer %s: %v", key, err)
+		}
+		p.mu.Lock()
+		close(p.pending[key])
+		delete(p.pending, key)
+		p.mu.Unlock()
+	}
+}
+
+// Keys returns the keys of the open Writers, in lexical order.
+func (p *Pool) Keys() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]string, 0, len(p.writers))
+	for key := range p.writers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Close closes the Writers of the Pool concurrently, and returns the first
+// error encountered. Subsequent writes fail with ErrClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		close(p.stop)
+	}
+	p.closed = true
+	writers := make(map[string]*Writer, len(p.writers))
+	for key, pw := range p.writers {
+		writers[key] = pw.w
+	}
+	p.writers = make(map[string]*pooledWriter)
+	p.recent.Init()
+	pending := make([]chan struct{}, 0, len(p.pending))
+	for _, done := range p.pending {
+		pending = append(pending, done)
+	}
+	p.mu.Unlock()
+
+	err := closeWriters(writers)
+	for _, done := range pending {
+		<-done
+	}
+	<-p.done
+	return err
+}
+
+// poolWriter writes into the Writer of key in pool, which may be closed and
+// created again between writes when the Pool has a MaximumOpen.
+type poolWriter struct {
+	pool *Pool
+	key  string
+}
+
+func (w poolWriter) Write(p []byte) (int, error) {
+	pw, err := w.pool.acquire(w.key)
+	if err != nil {
+		return 0, err
+	}
+	defer w.pool.release(pw)
+	return pw.w.Write(p)
+}
+
+// closeWriters closes writers concurrently, and returns the first error encountered.
+func closeWriters(writers map[string]*Writer) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for key, w := range writers {
+		wg.Add(1)
+		go func(key string, w *Writer) {
+			defer wg.Done()
+			if err := w.Close(); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to close writer %s", key)
+				}
+				mu.Unlock()
+			}
+		}(key, w)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// validatePoolKey checks that key names a subdirectory of the Pool's Directory.
+func validatePoolKey(key string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return errors.Errorf("invalid pool key %q, keys must be valid file names", key)
+	}
+	return nil
+}
+
+// errWriter fails every write with err.
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
@@ -0,0 +1,271 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	t.Run("writes each key into its own directory", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{Options: Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "job.log" },
+		}})
+		require.NoError(t, err)
+
+		for _, key := range []string{"build", "deploy", "build"} {
+			_, err := p.Writer(key).Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, p.Writer("build"), p.Writer("build"))
+		require.Equal(t, []string{"build", "deploy"}, p.Keys())
+		require.NoError(t, p.Close())
+
+		for key, expected := range map[string]string{"build": "build\nbuild\n", "deploy": "deploy\n"} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, key, "job.log"))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(b))
+		}
+	})
+
+	t.Run("rejects keys which are not file names", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{Options: Options{Directory: dir}})
+		require.NoError(t, err)
+		defer p.Close()
+
+		for _, key := range []string{"", "..", "a/b"} {
+			_, err := p.Writer(key).Write([]byte("entry\n"))
+			require.Error(t, err, key)
+		}
+		require.Empty(t, p.Keys())
+	})
+
+	t.Run("rejects writes once closed", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{Options: Options{Directory: dir}})
+		require.NoError(t, err)
+		w := p.Writer("job")
+		require.NoError(t, p.Close())
+
+		_, err = w.Write([]byte("entry\n"))
+		require.True(t, errors.Is(err, ErrClosed))
+		_, err = p.Writer("other").Write([]byte("entry\n"))
+		require.True(t, errors.Is(err, ErrClosed))
+	})
+
+	t.Run("closes idle writers", func(t *testing.T) {
+		dir := newDir(t)
+		clock := newFakeClock()
+		var (
+			mu     sync.Mutex
+			closed []string
+		)
+		p, err := NewPool(logger, PoolOptions{
+			Options: Options{
+				Directory:    dir,
+				FileNameFunc: func() string { return "job.log" },
+				Shared:       true,
+				Now:          clock.Now,
+				NewTicker:    clock.NewTicker,
+				OnFileClose: func(e FileCloseEvent) {
+					mu.Lock()
+					defer mu.Unlock()
+					closed = append(closed, e.Path)
+				},
+			},
+			IdleTimeout: time.Minute,
+		})
+		require.NoError(t, err)
+
+		write := func(key string) {
+			_, err := p.Writer(key).Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		write("a")
+		write("b")
+		clock.Advance(30 * time.Second)
+		write("a")
+		clock.Advance(40 * time.Second)
+		// the second tick is received once the first one has been handled
+		clock.Tick()
+		clock.Tick()
+		require.Equal(t, []string{"a"}, p.Keys())
+		mu.Lock()
+		require.Equal(t, []string{filepath.Join(dir, "b", "job.log")}, closed)
+		mu.Unlock()
+
+		write("b")
+		require.Equal(t, []string{"a", "b"}, p.Keys())
+		require.NoError(t, p.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "b", "job.log"))
+		require.NoError(t, err)
+		require.Equal(t, "b\nb\n", string(b))
+	})
+
+	t.Run("overrides the options of matching keys", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{
+			Options: Options{
+				Directory:    dir,
+				FileNameFunc: func() string { return "job.log" },
+				Now:          newFakeClock().Now,
+			},
+			Overrides: []PoolOverride{
+				{Pattern: "debug-*", Options: []Option{WithFileNameFunc(func() string { return "debug.log" })}},
+				{Pattern: "debug-verbose", Options: []Option{WithTimestampFormat("2006")}},
+			},
+		})
+		require.NoError(t, err)
+
+		for _, key := range []string{"build", "debug-http", "debug-verbose"} {
+			_, err := p.Writer(key).Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, p.Close())
+
+		for path, expected := range map[string]string{
+			"build/job.log":           "build\n",
+			"debug-http/debug.log":    "debug-http\n",
+			"debug-verbose/debug.log": "2020 debug-verbose\n",
+		} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, path))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(b))
+		}
+	})
+
+	t.Run("rejects invalid overrides", func(t *testing.T) {
+		for _, override := range []PoolOverride{
+			{Pattern: "["},
+			{Pattern: "audit", Options: []Option{WithExpvarPrefix("audit")}},
+			{Pattern: "audit", Options: []Option{WithMaximumOpenFiles(10)}},
+			{Pattern: "audit", Options: []Option{WithMaximumFileSize(-1)}},
+		} {
+			_, err := NewPool(logger, PoolOptions{Options: Options{Directory: "logs"}, Overrides: []PoolOverride{override}})
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("rejects options shared by the writers", func(t *testing.T) {
+		_, err := NewPool(logger, PoolOptions{Options: Options{Directory: "logs", ExpvarPrefix: "logs"}})
+		require.Error(t, err)
+		_, err = NewPool(logger, PoolOptions{Options: Options{Directory: "logs", Lock: &LockOptions{Path: "/run/app.lock"}}})
+		require.Error(t, err)
+		_, err = NewPool(logger, PoolOptions{Options: Options{Directory: "logs"}, MaximumOpen: -1})
+		require.Error(t, err)
+	})
+
+	t.Run("keeps the most recently used writers open", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{
+			Options: Options{
+				Directory:    dir,
+				FileNameFunc: func() string { return "tenant.log" },
+				Shared:       true,
+			},
+			MaximumOpen: 2,
+		})
+		require.NoError(t, err)
+
+		writers := make(map[string]io.Writer)
+		for _, key := range []string{"a", "b", "c"} {
+			writers[key] = p.Writer(key)
+		}
+		require.Equal(t, []string{"b", "c"}, p.Keys())
+
+		for _, key := range []string{"a", "b", "a", "c"} {
+			_, err := writers[key].Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, []string{"a", "c"}, p.Keys())
+		require.NoError(t, p.Close())
+
+		for key, expected := range map[string]string{"a": "a\na\n", "b": "b\n", "c": "c\n"} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, key, "tenant.log"))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(b))
+		}
+	})
+
+	t.Run("evicts writers without a logger", func(t *testing.T) {
+		p, err := NewPool(nil, PoolOptions{
+			Options:     Options{Directory: "logs", FS: closeFailingFS{newMemFS()}},
+			MaximumOpen: 1,
+		})
+		require.NoError(t, err)
+
+		for _, key := range []string{"a", "b", "a"} {
+			_, err := p.Writer(key).Write([]byte(key + "\n"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, []string{"a"}, p.Keys())
+		require.Error(t, p.Close())
+	})
+
+	t.Run("creates one writer per key under concurrent writes", func(t *testing.T) {
+		dir := newDir(t)
+		p, err := NewPool(logger, PoolOptions{
+			Options: Options{Directory: dir, FileNameFunc: func() string { return "tenant.log" }},
+		})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := p.Writer("a").Write([]byte("entry\n"))
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, p.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "a", "tenant.log"))
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("entry\n", 10), string(b))
+	})
+}
+
+// closeFailingFS is an FS whose files fail to close.
+type closeFailingFS struct {
+	FS
+}
+
+func (fs closeFailingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return closeFailingFile{f}, nil
+}
+
+type closeFailingFile struct {
+	File
+}
+
+func (f closeFailingFile) Close() error {
+	f.File.Close()
+	return errors.New("close failed")
+}
@@ -0,0 +1,38 @@
+package logrotate
+
+// Priority is the priority of an entry written with WritePriority.
+type Priority int
+
+const (
+	// PriorityNormal entries are queued up in order, as by Write.
+	PriorityNormal Priority = iota
+	// PriorityHigh entries are written before PriorityNormal entries, and flushed once written.
+	PriorityHigh
+)
+
+// priorityLaneSize is the number of PriorityHigh entries which can be queued up.
+const priorityLaneSize = 64
+
+// processPriority writes an entry written with PriorityHigh, and flushes it to the file.
+func (w *Writer) processPriority(e entry) {
+	w.process(e.b, e.ts)
+	putBuffer(e.b)
+	if err := w.flush(false); err != nil {
+		w.logger.Printf("Failed to flush high priority entry: %v", err)
+		w.setError(err)
+	}
+}
+
+// drainPriority writes the PriorityHigh entries still queued up, once the queue
+// has been closed. Entries are queued up in the lane before the queue is closed.
+func (w *Writer) drainPriority() {
+	for {
+		select {
+		case e := <-w.priority:
+			w.recordDequeue(e)
+			w.processPriority(e)
+		default:
+			return
+		}
+	}
+}
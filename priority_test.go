@@ -0,0 +1,66 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_WritePriority(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("writes high priority entries first", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "app.log" },
+			QueueSize:    2,
+			NonBlocking:  true,
+		})
+		require.NoError(t, err)
+		require.NoError(t, w.Pause())
+
+		for _, entry := range []string{"debug 1\n", "debug 2\n"} {
+			_, err := w.Write([]byte(entry))
+			require.NoError(t, err)
+		}
+		_, err = w.Write([]byte("debug 3\n"))
+		require.True(t, errors.Is(err, ErrQueueFull))
+
+		_, err = w.WritePriority([]byte("panic\n"), PriorityHigh)
+		require.NoError(t, err)
+		require.Equal(t, 3, w.Stats().QueueDepth)
+
+		w.Resume()
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "panic\ndebug 1\ndebug 2\n", string(b))
+	})
+
+	t.Run("writes normal priority entries in order", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		w, err := New(logger, Options{Directory: dir, FileNameFunc: func() string { return "app.log" }})
+		require.NoError(t, err)
+		for _, entry := range []string{"first\n", "second\n"} {
+			_, err := w.WritePriority([]byte(entry), PriorityNormal)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\n", string(b))
+	})
+}
@@ -0,0 +1,102 @@
+// Package promrotate exposes logrotate Writer statistics as Prometheus metrics.
+//
+// The collector is in its own module so that the logrotate module does not
+// depend on the Prometheus client. Register a Collector for each Writer,
+// distinguishing Writers with constant labels:
+//
+//	prometheus.MustRegister(promrotate.NewCollector(w, prometheus.Labels{"writer": "access"}))
+//
+// Metrics are read from Writer.Stats and Writer.Health when scraped, so
+// collecting does not slow down writes.
+package promrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace prefixes the names of all metrics.
+const Namespace = "logrotate"
+
+// Collector is a prometheus.Collector exposing the statistics of a Writer.
+type Collector struct {
+	w *logrotate.Writer
+
+	entriesWritten     *prometheus.Desc
+	bytesWritten       *prometheus.Desc
+	entriesDropped     *prometheus.Desc
+	droppedByReason    *prometheus.Desc
+	entriesFiltered    *prometheus.Desc
+	entriesRateLimited *prometheus.Desc
+	rotations          *prometheus.Desc
+	queueDepth         *prometheus.Desc
+	queueHighWatermark *prometheus.Desc
+	currentFileSize    *prometheus.Desc
+	currentFileAge     *prometheus.Desc
+	healthy            *prometheus.Desc
+}
+
+// NewCollector returns a Collector for w, labels are added to all metrics.
+func NewCollector(w *logrotate.Writer, labels prometheus.Labels) *Collector {
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", name), help, variableLabels, labels)
+	}
+
+	return &Collector{
+		w:                  w,
+		entriesWritten:     desc("entries_written_total", "Number of entries written to files."),
+		bytesWritten:       desc("bytes_written_total", "Number of bytes written to files."),
+		entriesDropped:     desc("entries_dropped_total", "Number of entries which were not written to a file."),
+		droppedByReason:    desc("entries_dropped_by_reason_total", "Number of entries which were not written to a file, including filtered and rate limited entries, by reason.", "reason"),
+		entriesFiltered:    desc("entries_filtered_total", "Number of entries discarded by the filter."),
+		entriesRateLimited: desc("entries_rate_limited_total", "Number of entries discarded by the rate limit."),
+		rotations:          desc("rotations_total", "Number of times a file was closed and a new one opened."),
+		queueDepth:         desc("queue_depth", "Number of entries awaiting to be written."),
+		queueHighWatermark: desc("queue_high_watermark", "Largest number of entries awaiting to be written since the writer was created, or its high-watermark reset."),
+		currentFileSize:    desc("current_file_size_bytes", "Size of the file currently being written to."),
+		currentFileAge:     desc("current_file_age_seconds", "Time elapsed since the current file was opened."),
+		healthy:            desc("healthy", "Whether the most recent write succeeded and the writer accepts writes."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entriesWritten
+	ch <- c.bytesWritten
+	ch <- c.entriesDropped
+	ch <- c.droppedByReason
+	ch <- c.entriesFiltered
+	ch <- c.entriesRateLimited
+	ch <- c.rotations
+	ch <- c.queueDepth
+	ch <- c.queueHighWatermark
+	ch <- c.currentFileSize
+	ch <- c.currentFileAge
+	ch <- c.healthy
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.w.Stats()
+	healthy := 0.0
+	if c.w.Health().Healthy() {
+		healthy = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.entriesWritten, prometheus.CounterValue, float64(stats.EntriesWritten))
+	ch <- prometheus.MustNewConstMetric(c.bytesWritten, prometheus.CounterValue, float64(stats.BytesWritten))
+	ch <- prometheus.MustNewConstMetric(c.entriesDropped, prometheus.CounterValue, float64(stats.EntriesDropped))
+	for reason, n := range stats.DroppedByReason {
+		ch <- prometheus.MustNewConstMetric(c.droppedByReason, prometheus.CounterValue, float64(n), reason.String())
+	}
+	ch <- prometheus.MustNewConstMetric(c.entriesFiltered, prometheus.CounterValue, float64(stats.EntriesFiltered))
+	ch <- prometheus.MustNewConstMetric(c.entriesRateLimited, prometheus.CounterValue, float64(stats.EntriesRateLimited))
+	ch <- prometheus.MustNewConstMetric(c.rotations, prometheus.CounterValue, float64(stats.Rotations))
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(stats.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(c.queueHighWatermark, prometheus.GaugeValue, float64(stats.QueueHighWatermark))
+	ch <- prometheus.MustNewConstMetric(c.currentFileSize, prometheus.GaugeValue, float64(stats.CurrentFileSize))
+	ch <- prometheus.MustNewConstMetric(c.currentFileAge, prometheus.GaugeValue, stats.CurrentFileAge.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.healthy, prometheus.GaugeValue, healthy)
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
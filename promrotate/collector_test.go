@@ -0,0 +1,81 @@
+package promrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := logrotate.New(nil, logrotate.Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("message\n"))
+		require.NoError(t, err)
+	}
+	_, err = w.Write([]byte("too large message\n"))
+	require.Error(t, err)
+	require.NoError(t, w.Flush())
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(NewCollector(w, prometheus.Labels{"writer": "test"})))
+
+	expected := `
+# HELP logrotate_bytes_written_total Number of bytes written to files.
+# TYPE logrotate_bytes_written_total counter
+logrotate_bytes_written_total{writer="test"} 24
+# HELP logrotate_entries_dropped_total Number of entries which were not written to a file.
+# TYPE logrotate_entries_dropped_total counter
+logrotate_entries_dropped_total{writer="test"} 1
+# HELP logrotate_entries_dropped_by_reason_total Number of entries which were not written to a file, including filtered and rate limited entries, by reason.
+# TYPE logrotate_entries_dropped_by_reason_total counter
+logrotate_entries_dropped_by_reason_total{reason="too_large",writer="test"} 1
+# HELP logrotate_entries_filtered_total Number of entries discarded by the filter.
+# TYPE logrotate_entries_filtered_total counter
+logrotate_entries_filtered_total{writer="test"} 0
+# HELP logrotate_entries_rate_limited_total Number of entries discarded by the rate limit.
+# TYPE logrotate_entries_rate_limited_total counter
+logrotate_entries_rate_limited_total{writer="test"} 0
+# HELP logrotate_entries_written_total Number of entries written to files.
+# TYPE logrotate_entries_written_total counter
+logrotate_entries_written_total{writer="test"} 3
+# HELP logrotate_healthy Whether the most recent write succeeded and the writer accepts writes.
+# TYPE logrotate_healthy gauge
+logrotate_healthy{writer="test"} 1
+# HELP logrotate_queue_depth Number of entries awaiting to be written.
+# TYPE logrotate_queue_depth gauge
+logrotate_queue_depth{writer="test"} 0
+# HELP logrotate_rotations_total Number of times a file was closed and a new one opened.
+# TYPE logrotate_rotations_total counter
+logrotate_rotations_total{writer="test"} 2
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"logrotate_bytes_written_total",
+		"logrotate_entries_dropped_total",
+		"logrotate_entries_dropped_by_reason_total",
+		"logrotate_entries_filtered_total",
+		"logrotate_entries_rate_limited_total",
+		"logrotate_entries_written_total",
+		"logrotate_healthy",
+		"logrotate_queue_depth",
+		"logrotate_rotations_total",
+	))
+
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	require.Equal(t, 12, count)
+}
@@ -0,0 +1,103 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// RateLimitOptions limit the rate at which entries are written, see Options.RateLimit.
+// Up to one second worth of entries and bytes may be written at once.
+type RateLimitOptions struct {
+	// EntriesPerSecond is the maximum number of entries written per second,
+	// 0 for no limit.
+	EntriesPerSecond float64
+
+	// BytesPerSecond is the maximum number of bytes written per second,
+	// 0 for no limit.
+	BytesPerSecond int64
+
+	// Sample, when set, writes 1 in Sample of the entries exceeding the limits,
+	// instead of discarding all of them, so that a runaway source remains visible.
+	Sample int
+}
+
+func (o RateLimitOptions) validate() error {
+	if o.EntriesPerSecond < 0 {
+		return errors.Errorf("EntriesPerSecond must not be negative, got %v", o.EntriesPerSecond)
+	}
+	if o.BytesPerSecond < 0 {
+		return errors.Errorf("BytesPerSecond must not be negative, got %d", o.BytesPerSecond)
+	}
+	if o.EntriesPerSecond == 0 && o.BytesPerSecond == 0 {
+		return errors.New("EntriesPerSecond or BytesPerSecond must be set")
+	}
+	if o.Sample < 0 {
+		return errors.Errorf("Sample must not be negative, got %d", o.Sample)
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket for entries and one for bytes,
+// each holding up to one second worth of tokens.
+type rateLimiter struct {
+	opts    RateLimitOptions
+	entries float64
+	bytes   float64
+	last    time.Time
+	// excess is the number of entries which exceeded the limits, for sampling
+	excess int64
+}
+
+func newRateLimiter(opts RateLimitOptions, now time.Time) *rateLimiter {
+	return &rateLimiter{
+		opts:    opts,
+		entries: opts.EntriesPerSecond,
+		bytes:   float64(opts.BytesPerSecond),
+		last:    now,
+	}
+}
+
+// allow returns whether an entry of size bytes may be written at now.
+func (l *rateLimiter) allow(now time.Time, size int) bool {
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.entries = minFloat(l.entries+elapsed*l.opts.EntriesPerSecond, l.opts.EntriesPerSecond)
+		l.bytes = minFloat(l.bytes+elapsed*float64(l.opts.BytesPerSecond), float64(l.opts.BytesPerSecond))
+		l.last = now
+	}
+
+	entriesOK := l.opts.EntriesPerSecond == 0 || l.entries >= 1
+	// Entries larger than BytesPerSecond are written once the bucket is full,
+	// the bucket then stays empty until their size has been refilled.
+	bytesOK := l.opts.BytesPerSecond == 0 || l.bytes >= minFloat(float64(size), float64(l.opts.BytesPerSecond))
+	if entriesOK && bytesOK {
+		if l.opts.EntriesPerSecond > 0 {
+			l.entries--
+		}
+		if l.opts.BytesPerSecond > 0 {
+			l.bytes -= float64(size)
+		}
+		return true
+	}
+
+	l.excess++
+	return l.opts.Sample > 0 && (l.excess-1)%int64(l.opts.Sample) == 0
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// rateLimited returns true if b exceeds Options.RateLimit and is not sampled.
+func (w *Writer) rateLimited(b []byte) bool {
+	if w.opts.RateLimit == nil {
+		w.limiter = nil
+		return false
+	}
+	if w.limiter == nil || w.limiter.opts != *w.opts.RateLimit {
+		w.limiter = newRateLimiter(*w.opts.RateLimit, w.now())
+	}
+	return !w.limiter.allow(w.now(), len(b))
+}
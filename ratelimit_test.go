@@ -0,0 +1,80 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_RateLimit(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("discards entries exceeding the limit", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		clock := newFakeClock()
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "app.log" },
+			Now:          clock.Now,
+			RateLimit:    &RateLimitOptions{EntriesPerSecond: 2},
+		})
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			_, err := w.Write([]byte(fmt.Sprintf("%d\n", i)))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+
+		clock.Advance(time.Second)
+		_, err = w.Write([]byte("5\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "0\n1\n5\n", string(b))
+		require.Equal(t, int64(3), w.Stats().EntriesRateLimited)
+	})
+
+	t.Run("limits bytes", func(t *testing.T) {
+		now := time.Now()
+		l := newRateLimiter(RateLimitOptions{BytesPerSecond: 10}, now)
+		require.True(t, l.allow(now, 6))
+		require.False(t, l.allow(now, 6))
+		require.True(t, l.allow(now, 4))
+
+		// Entries larger than the limit are written once the bucket is full,
+		// their excess is refilled before further entries are written.
+		now = now.Add(time.Second)
+		require.True(t, l.allow(now, 15))
+		now = now.Add(250 * time.Millisecond)
+		require.False(t, l.allow(now, 1))
+		now = now.Add(400 * time.Millisecond)
+		require.True(t, l.allow(now, 1))
+	})
+
+	t.Run("samples entries exceeding the limit", func(t *testing.T) {
+		now := time.Now()
+		l := newRateLimiter(RateLimitOptions{EntriesPerSecond: 1, Sample: 3}, now)
+		var allowed []bool
+		for i := 0; i < 8; i++ {
+			allowed = append(allowed, l.allow(now, 1))
+		}
+		require.Equal(t, []bool{true, true, false, false, true, false, false, true}, allowed)
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		for _, rl := range []RateLimitOptions{{}, {EntriesPerSecond: -1}, {BytesPerSecond: 1, Sample: -1}} {
+			opts := Options{Directory: "logs", RateLimit: &rl}
+			require.Error(t, opts.Validate(), "%+v", rl)
+		}
+	})
+}
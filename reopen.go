@@ -0,0 +1,24 @@
+package logrotate
+
+// Reopen closes the current file and opens the same path again, appending to it.
+//
+// Reopen supports rotation by external tools such as logrotate(8): once the tool
+// renamed the current file, Reopen releases the old file and creates a new one
+// at the original path. Typically, Reopen is called on SIGHUP.
+// If no file is open, Reopen does nothing and the next write creates a new file.
+func (w *Writer) Reopen() error {
+	return w.control(w.reopen)
+}
+
+func (w *Writer) reopen() error {
+	if w.f == nil {
+		return nil
+	}
+
+	path := w.path
+	if err := w.closeCurrentFile(); err != nil {
+		return err
+	}
+
+	return w.openFile(path, appendFileFlag)
+}
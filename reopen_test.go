@@ -0,0 +1,45 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_Reopen(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return "app.log" },
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("before"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	// rotate the file externally, like logrotate(8) would
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, w.Reopen())
+
+	_, err = w.Write([]byte("after"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	rotated, err := ioutil.ReadFile(path + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "before", string(rotated))
+
+	current, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "after", string(current), "must write into a new file at the same path")
+}
@@ -0,0 +1,70 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// RetryPolicy defines how failed file operations are retried.
+// Each subsequent attempt waits twice as long as the previous one,
+// starting at InitialBackoff and capped at MaximumBackoff.
+type RetryPolicy struct {
+	// MaximumAttempts is the maximum number of times an operation is attempted,
+	// including the first attempt.
+	// When MaximumAttempts <= 1, operations are not retried.
+	MaximumAttempts int
+
+	// InitialBackoff is the time to wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaximumBackoff caps the time to wait between retries.
+	// When MaximumBackoff == 0, the backoff is not capped.
+	MaximumBackoff time.Duration
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaximumAttempts < 0 {
+		return errors.Errorf("MaximumAttempts must not be negative, got %d", p.MaximumAttempts)
+	}
+	if p.InitialBackoff < 0 {
+		return errors.Errorf("InitialBackoff must not be negative, got %v", p.InitialBackoff)
+	}
+	if p.MaximumBackoff < 0 {
+		return errors.Errorf("MaximumBackoff must not be negative, got %v", p.MaximumBackoff)
+	}
+	if p.MaximumBackoff != 0 && p.MaximumBackoff < p.InitialBackoff {
+		return errors.Errorf("MaximumBackoff %v must not be less than InitialBackoff %v", p.MaximumBackoff, p.InitialBackoff)
+	}
+	return nil
+}
+
+// backoff returns the time to wait before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if p.MaximumBackoff != 0 && d >= p.MaximumBackoff {
+			break
+		}
+	}
+	if p.MaximumBackoff != 0 && d > p.MaximumBackoff {
+		d = p.MaximumBackoff
+	}
+	return d
+}
+
+// retry runs op until it succeeds or the retry policy is exhausted.
+// The error of the last attempt is returned.
+func (w *Writer) retry(op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= w.opts.Retry.MaximumAttempts {
+			return err
+		}
+
+		w.logger.Printf("File operation failed, retrying: %v", err)
+		if !w.sleep(w.opts.Retry.backoff(attempt)) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaximumBackoff: 50 * time.Millisecond,
+	}
+
+	require.Equal(t, 10*time.Millisecond, p.backoff(1))
+	require.Equal(t, 20*time.Millisecond, p.backoff(2))
+	require.Equal(t, 40*time.Millisecond, p.backoff(3))
+	require.Equal(t, 50*time.Millisecond, p.backoff(4), "must cap backoff")
+	require.Equal(t, 50*time.Millisecond, p.backoff(100), "must not overflow")
+}
@@ -0,0 +1,63 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RFC5424Format configures the messages written by FormatRFC5424.
+type RFC5424Format struct {
+	// Facility of the messages, see RFC 5424 section 6.2.1.
+	// When zero, which is reserved for kernel messages, 1 for user-level messages is used.
+	Facility int
+	// Severity of the messages, see RFC 5424 section 6.2.1.
+	// When zero, 6 for informational messages is used.
+	Severity int
+
+	// Hostname identifies the machine writing the messages. Defaults to os.Hostname.
+	Hostname string
+	// AppName identifies the application writing the messages. Defaults to the program name.
+	AppName string
+
+	// Now returns the timestamp of the messages. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// FormatRFC5424 returns a Transform wrapping each entry into an RFC 5424 syslog
+// message, with the priority, timestamp, hostname, app-name and process ID
+// of f, followed by a newline, so that files can be replayed into syslog based
+// pipelines, for example with rsyslog's imfile. Entries are timestamped when
+// they are written to files, see Options.Transform.
+func FormatRFC5424(f RFC5424Format) (func(b []byte) []byte, error) {
+	if f.Facility < 0 || f.Facility > 23 {
+		return nil, errors.Errorf("Facility must be between 0 and 23, got %d", f.Facility)
+	}
+	if f.Severity < 0 || f.Severity > 7 {
+		return nil, errors.Errorf("Severity must be between 0 and 7, got %d", f.Severity)
+	}
+	if f.Facility == 0 {
+		f.Facility = defaultSyslogFacility
+	}
+	if f.Severity == 0 {
+		f.Severity = defaultSyslogSeverity
+	}
+	if f.Hostname == "" {
+		f.Hostname, _ = os.Hostname()
+	}
+	if f.AppName == "" {
+		f.AppName = filepath.Base(os.Args[0])
+	}
+	if f.Now == nil {
+		f.Now = time.Now
+	}
+
+	priority := f.Facility*8 + f.Severity
+	procID := strconv.Itoa(os.Getpid())
+	return func(b []byte) []byte {
+		msg := appendRFC5424(make([]byte, 0, len(b)+128), priority, f.Now(), f.Hostname, f.AppName, procID, b)
+		return append(msg, '\n')
+	}, nil
+}
@@ -0,0 +1,62 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFormatRFC5424(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("wraps entries into syslog messages", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		format, err := FormatRFC5424(RFC5424Format{
+			Facility: 16,
+			Severity: 4,
+			Hostname: "web-1",
+			AppName:  "api",
+			Now:      newFakeClock().Now,
+		})
+		require.NoError(t, err)
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "app.log" },
+			Transform:    format,
+		})
+		require.NoError(t, err)
+		for _, msg := range []string{"first\n", "second"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		pid := strconv.Itoa(os.Getpid())
+		require.Equal(t,
+			"<132>1 2020-03-28T15:00:00.000000Z web-1 api "+pid+" - - first\n"+
+				"<132>1 2020-03-28T15:00:00.000000Z web-1 api "+pid+" - - second\n",
+			string(b))
+	})
+
+	t.Run("defaults to user-level informational messages", func(t *testing.T) {
+		format, err := FormatRFC5424(RFC5424Format{})
+		require.NoError(t, err)
+		require.Regexp(t, `^<14>1 \S+ \S+ \S+ \d+ - - message\n$`, string(format([]byte("message\n"))))
+	})
+
+	t.Run("rejects invalid priorities", func(t *testing.T) {
+		_, err := FormatRFC5424(RFC5424Format{Facility: 24})
+		require.Error(t, err)
+		_, err = FormatRFC5424(RFC5424Format{Severity: -1})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,79 @@
+package logrotate
+
+import "time"
+
+// RotationReason describes what triggered a rotation.
+type RotationReason int
+
+const (
+	// RotationManual is a rotation requested through Writer.Rotate.
+	RotationManual RotationReason = iota
+	// RotationSize is a rotation due to the file reaching MaximumFileSize.
+	RotationSize
+	// RotationTime is a rotation due to the file exceeding MaximumLifetime.
+	RotationTime
+)
+
+func (r RotationReason) String() string {
+	switch r {
+	case RotationManual:
+		return "manual"
+	case RotationSize:
+		return "size"
+	case RotationTime:
+		return "time"
+	default:
+		return "unknown"
+	}
+}
+
+// RotationEvent describes a file which has been rotated.
+type RotationEvent struct {
+	// Path is the path of the file which has been closed.
+	Path string
+	// NextPath is the path of the file opened in its place.
+	// NextPath is empty if the next file could not be opened, or if the file
+	// exceeded MaximumLifetime while no entries were written, in which case the
+	// next file is opened by the next entry.
+	NextPath string
+	// Size is the size of the closed file in bytes.
+	Size int64
+	// Opened and Closed are the times the file was opened and closed.
+	Opened time.Time
+	Closed time.Time
+	// Reason is what triggered the rotation.
+	Reason RotationReason
+}
+
+// FileCloseEvent describes a file which has been closed and will not be written to again.
+type FileCloseEvent struct {
+	// Path is the path of the closed file.
+	Path string
+	// Size is the size of the closed file in bytes.
+	Size int64
+	// Entries is the number of entries written to the file by the Writer.
+	Entries int64
+	// Opened and Closed are the times the file was opened and closed.
+	Opened time.Time
+	Closed time.Time
+	// Err is the error encountered while flushing, synchronizing or closing the file.
+	// When Err is set, the file may be missing entries.
+	Err error
+	// SHA256 is the hex encoded SHA-256 of the file, written to its checksum sidecar.
+	// SHA256 is empty unless Options.Checksum is set, or when the checksum is unknown.
+	SHA256 string
+}
+
+// Rotate closes the current file and opens a new one, regardless of
+// MaximumFileSize and MaximumLifetime.
+// All entries accepted before Rotate was called are written into the current file.
+// If no file is open, Rotate does nothing.
+func (w *Writer) Rotate() error {
+	return w.control(func() error {
+		if w.f == nil {
+			return nil
+		}
+		w.debugRotation(RotationManual, 0)
+		return w.rotate(RotationManual)
+	})
+}
@@ -0,0 +1,170 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriter_OnRotate(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var events []RotationEvent
+	files := 0
+	w, err := New(logger, Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+		FileNameFunc: func() string {
+			files++
+			return strings.Repeat("f", files) + ".log"
+		},
+		OnRotate: func(e RotationEvent) {
+			events = append(events, e)
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+	require.NoError(t, w.Close())
+
+	require.Len(t, events, 2, "must not report closing the writer as a rotation")
+
+	require.Equal(t, RotationSize, events[0].Reason)
+	require.Equal(t, filepath.Join(dir, "f.log"), events[0].Path)
+	require.Equal(t, filepath.Join(dir, "ff.log"), events[0].NextPath)
+	require.Equal(t, int64(10), events[0].Size)
+	require.False(t, events[0].Closed.Before(events[0].Opened))
+
+	require.Equal(t, RotationManual, events[1].Reason)
+	require.Equal(t, filepath.Join(dir, "ff.log"), events[1].Path)
+	require.Equal(t, int64(3), events[1].Size)
+}
+
+func TestWriter_OnFileClose(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	closed := map[string]int{}
+	w, err := New(logger, Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+		OnFileClose: func(e FileCloseEvent) {
+			require.NoError(t, e.Err)
+
+			// the file must be complete and not written to anymore
+			contents, err := ioutil.ReadFile(e.Path)
+			require.NoError(t, err)
+			require.Equal(t, e.Size, int64(len(contents)))
+
+			closed[e.Path]++
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	require.Len(t, closed, 5, "must report every file, including the last one")
+	for path, count := range closed {
+		require.Equal(t, 1, count, "must report %v exactly once", path)
+	}
+}
+
+func TestWriter_RotatesBetweenEntries(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	fs := &recordingFS{memFS: newMemFS()}
+	files := 0
+	w, err := New(logger, Options{
+		Directory:       "logs",
+		FS:              fs,
+		MaximumFileSize: 10000,
+		FileNameFunc: func() string {
+			files++
+			return fmt.Sprintf("%03d.log", files)
+		},
+	})
+	require.NoError(t, err)
+
+	// Entries of varying sizes, which do not fit evenly into the buffer.
+	var entries []string
+	for i := 0; i < 50; i++ {
+		entry := fmt.Sprintf("%03d %s\n", i, strings.Repeat("x", 1000+(i*337)%2000))
+		entries = append(entries, entry)
+		_, err := w.Write([]byte(entry))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// wholeEntries checks that s is a sequence of whole entries.
+	wholeEntries := func(s string) {
+		require.True(t, strings.HasSuffix(s, "\n"), "must end with a whole entry")
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n") {
+			var i int
+			_, err := fmt.Sscanf(line, "%03d", &i)
+			require.NoError(t, err)
+			require.Equal(t, entries[i], strings.TrimSuffix(line, "\n")+"\n", "entry %d must be whole", i)
+		}
+	}
+
+	for _, b := range fs.writes {
+		wholeEntries(b)
+	}
+	infos, err := fs.ReadDir("logs")
+	require.NoError(t, err)
+	require.True(t, len(infos) > 1, "must rotate")
+	var all string
+	for _, info := range infos {
+		contents := fs.contents(filepath.Join("logs", info.Name()))
+		require.True(t, len(contents) <= 10000)
+		wholeEntries(contents)
+		all += contents
+	}
+	require.Equal(t, strings.Join(entries, ""), all)
+}
+
+// recordingFS records each write into its files.
+type recordingFS struct {
+	*memFS
+	mu     sync.Mutex
+	writes []string
+}
+
+func (fs *recordingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.memFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return recordingFile{File: f, fs: fs}, nil
+}
+
+type recordingFile struct {
+	File
+	fs *recordingFS
+}
+
+func (f recordingFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	f.fs.writes = append(f.fs.writes, string(p))
+	f.fs.mu.Unlock()
+	return f.File.Write(p)
+}
@@ -0,0 +1,65 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"time"
+)
+
+// rotationRecord is a line of the rotation manifest, see Options.RotationManifest.
+type rotationRecord struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Opened  time.Time `json:"opened"`
+	Closed  time.Time `json:"closed"`
+	Bytes   int64     `json:"bytes"`
+	Entries int64     `json:"entries"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func newRotationRecord(e FileCloseEvent) rotationRecord {
+	record := rotationRecord{
+		Path:    e.Path,
+		Name:    filepath.Base(e.Path),
+		Opened:  e.Opened,
+		Closed:  e.Closed,
+		Bytes:   e.Size,
+		Entries: e.Entries,
+		SHA256:  e.SHA256,
+	}
+	if e.Err != nil {
+		record.Error = e.Err.Error()
+	}
+	return record
+}
+
+// appendRotationManifest records a closed file in the rotation manifest, if configured.
+func (w *Writer) appendRotationManifest(e FileCloseEvent) error {
+	path := w.opts.RotationManifest
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.opts.Directory, path)
+	}
+
+	b, err := json.Marshal(newRotationRecord(e))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode rotation manifest record")
+	}
+
+	f, err := w.openLimited(path, appendFileFlag)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open rotation manifest %s", path)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write rotation manifest %s", path)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close rotation manifest %s", path)
+	}
+	return setAttributes(w.opts, path, w.opts.FileMode)
+}
@@ -0,0 +1,66 @@
+package logrotate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter_RotationManifest(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	i := 0
+	w, err := New(logger, Options{
+		Directory:        dir,
+		MaximumFileSize:  15,
+		RotationManifest: "rotations.jsonl",
+		Checksum:         true,
+		FileNameFunc: func() string {
+			i++
+			return fmt.Sprintf("%d.log", i)
+		},
+	})
+	require.NoError(t, err)
+
+	for _, msg := range []string{"first\n", "second\n", "third\n"} {
+		_, err := w.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	f, err := os.Open(filepath.Join(dir, "rotations.jsonl"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []rotationRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record rotationRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, records, 2)
+	require.Equal(t, filepath.Join(dir, "1.log"), records[0].Path)
+	require.Equal(t, "1.log", records[0].Name)
+	require.Equal(t, int64(13), records[0].Bytes)
+	require.Equal(t, int64(2), records[0].Entries)
+	require.Len(t, records[0].SHA256, 64)
+	require.False(t, records[0].Closed.Before(records[0].Opened))
+	require.Empty(t, records[0].Error)
+
+	require.Equal(t, "2.log", records[1].Name)
+	require.Equal(t, int64(6), records[1].Bytes)
+	require.Equal(t, int64(1), records[1].Entries)
+}
@@ -0,0 +1,89 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+)
+
+// RouterOptions configure a Router.
+type RouterOptions struct {
+	// Classify returns the route of an entry, for example "error", "info" or
+	// "debug" depending on the level of the entry. Classify is called by Write,
+	// concurrently when Write is, and must not retain b.
+	Classify func(b []byte) string
+
+	// Routes are the options of the Writer of each route, each with its own
+	// Directory, or FileNameFunc, and its own rotation settings.
+	Routes map[string]Options
+
+	// Default is the route of entries for which Classify returns a route
+	// which is not in Routes. Default must be in Routes.
+	Default string
+}
+
+func (o RouterOptions) validate() error {
+	if o.Classify == nil {
+		return errors.New("Classify must be set")
+	}
+	if _, ok := o.Routes[o.Default]; !ok {
+		return errors.Errorf("Default route %q is not in Routes", o.Default)
+	}
+	for route, opts := range o.Routes {
+		if err := opts.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid options of route %s", route)
+		}
+	}
+	return nil
+}
+
+// Router writes each entry into one of several Writers, chosen by classifying
+// the entry, for example to split errors, informational and debug entries into
+// separate files rotated and retained independently.
+type Router struct {
+	classify func(b []byte) string
+	writers  map[string]*Writer
+	fallback *Writer
+}
+
+// NewRouter creates a Router, and the Writer of each of its routes.
+func NewRouter(logger Logger, opts RouterOptions) (*Router, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid router options")
+	}
+
+	writers := make(map[string]*Writer, len(opts.Routes))
+	for route, routeOpts := range opts.Routes {
+		w, err := New(logger, routeOpts)
+		if err != nil {
+			closeWriters(writers)
+			return nil, errors.Wrapf(err, "failed to create writer of route %s", route)
+		}
+		writers[route] = w
+	}
+
+	return &Router{
+		classify: opts.Classify,
+		writers:  writers,
+		fallback: writers[opts.Default],
+	}, nil
+}
+
+// Write writes p into the Writer of its route, see Writer.Write.
+func (r *Router) Write(p []byte) (int, error) {
+	w, ok := r.writers[r.classify(p)]
+	if !ok {
+		w = r.fallback
+	}
+	return w.Write(p)
+}
+
+// Writer returns the Writer of route, or nil if there is no such route,
+// for example to inspect its Stats.
+func (r *Router) Writer(route string) *Writer {
+	return r.writers[route]
+}
+
+// Close closes the Writers of all routes concurrently, and returns the first
+// error encountered.
+func (r *Router) Close() error {
+	return closeWriters(r.writers)
+}
@@ -0,0 +1,67 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	classify := func(b []byte) string {
+		if i := bytes.IndexByte(b, ' '); i > 0 {
+			return string(b[:i])
+		}
+		return ""
+	}
+
+	t.Run("splits entries by route", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		routes := make(map[string]Options)
+		for _, route := range []string{"error", "info"} {
+			route := route
+			routes[route] = Options{
+				Directory:       dir,
+				FileNameFunc:    func() string { return route + ".log" },
+				MaximumFileSize: 1024,
+			}
+		}
+		r, err := NewRouter(logger, RouterOptions{Classify: classify, Routes: routes, Default: "info"})
+		require.NoError(t, err)
+
+		for _, entry := range []string{"info started\n", "error failed\n", "debug detail\n", "unclassified\n"} {
+			_, err := r.Write([]byte(entry))
+			require.NoError(t, err)
+		}
+		require.NoError(t, r.Close())
+		require.Equal(t, int64(1), r.Writer("error").Stats().EntriesWritten)
+		require.Nil(t, r.Writer("debug"))
+
+		for route, expected := range map[string]string{
+			"error": "error failed\n",
+			"info":  "info started\ndebug detail\nunclassified\n",
+		} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, route+".log"))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(b))
+		}
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		routes := map[string]Options{"info": {Directory: "logs"}}
+		_, err := NewRouter(logger, RouterOptions{Routes: routes, Default: "info"})
+		require.Error(t, err)
+		_, err = NewRouter(logger, RouterOptions{Classify: classify, Routes: routes, Default: "error"})
+		require.Error(t, err)
+		_, err = NewRouter(logger, RouterOptions{Classify: classify, Routes: map[string]Options{"info": {}}, Default: "info"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,150 @@
+package s3rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Limits of the parts of multipart uploads, see Options.PartSize.
+const (
+	MinimumPartSize int64 = 5 << 20
+	MaximumPartSize int64 = 5 << 30
+	DefaultPartSize int64 = 64 << 20
+
+	// maximumParts is the number of parts a multipart upload can have at most.
+	maximumParts = 10000
+	// abortTimeout bounds the request aborting a failed multipart upload, which
+	// is sent even though the context of the upload is done.
+	abortTimeout = 30 * time.Second
+)
+
+// completedPart is a part of a multipart upload, as listed when completing it.
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// uploadMultipart uploads the file f of size bytes with a multipart upload.
+// The upload is aborted if a part fails, so that its parts are not kept, and
+// billed, by the bucket.
+func (u *Uploader) uploadMultipart(ctx context.Context, f *os.File, size int64, key string) error {
+	partSize := u.opts.PartSize
+	if minimum := (size + maximumParts - 1) / maximumParts; partSize < minimum {
+		partSize = minimum
+	}
+
+	uploadID, err := u.createMultipartUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var parts []completedPart
+	for offset := int64(0); offset < size; offset += partSize {
+		n := partSize
+		if size-offset < n {
+			n = size - offset
+		}
+		part := completedPart{PartNumber: len(parts) + 1}
+		part.ETag, err = u.uploadPart(ctx, key, uploadID, part.PartNumber, io.NewSectionReader(f, offset, n))
+		if err != nil {
+			u.abortMultipartUpload(key, uploadID)
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	if err := u.completeMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		u.abortMultipartUpload(key, uploadID)
+		return err
+	}
+	return nil
+}
+
+func (u *Uploader) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	req, err := u.newSignedRequest(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, io.NewSectionReader(bytes.NewReader(nil), 0, 0))
+	if err != nil {
+		return "", err
+	}
+	u.setObjectHeaders(req)
+	body, _, err := u.send(req, key)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", errors.Errorf("failed to upload to s3://%s/%s: invalid response creating multipart upload: %s", u.opts.Bucket, key, body)
+	}
+	return result.UploadID, nil
+}
+
+func (u *Uploader) uploadPart(ctx context.Context, key, uploadID string, number int, part *io.SectionReader) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	req, err := u.newSignedRequest(ctx, http.MethodPut, key, query, part)
+	if err != nil {
+		return "", err
+	}
+	_, header, err := u.send(req, key)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to upload part %d", number)
+	}
+	etag := header.Get("ETag")
+	if etag == "" {
+		return "", errors.Errorf("failed to upload to s3://%s/%s: part %d has no ETag", u.opts.Bucket, key, number)
+	}
+	return etag, nil
+}
+
+func (u *Uploader) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode parts")
+	}
+	req, err := u.newSignedRequest(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))))
+	if err != nil {
+		return err
+	}
+	body, _, err := u.send(req, key)
+	if err != nil {
+		return err
+	}
+
+	// Completing an upload may fail after the response status has been sent,
+	// the error is then in the body of a 200 OK response.
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err := xml.Unmarshal(body, &result); err == nil && result.XMLName.Local == "Error" {
+		return errors.Errorf("failed to upload to s3://%s/%s: %s: %s", u.opts.Bucket, key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// abortMultipartUpload removes the parts of a failed multipart upload, failing
+// to do so is not reported, the upload is retried as a whole and lifecycle
+// rules of the bucket can remove incomplete uploads.
+func (u *Uploader) abortMultipartUpload(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+
+	req, err := u.newSignedRequest(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, io.NewSectionReader(bytes.NewReader(nil), 0, 0))
+	if err != nil {
+		return
+	}
+	_, _, _ = u.send(req, key)
+}
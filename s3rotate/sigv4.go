@@ -0,0 +1,29 @@
+package s3rotate
+
+import (
+	"github.com/easyCZ/logrotate/internal/awsv4"
+	"strings"
+)
+
+// Credentials are AWS access keys used to sign requests.
+type Credentials = awsv4.Credentials
+
+// escapePath escapes an object key for use in a URL path, as required by
+// Signature Version 4: every byte except unreserved characters and '/' is escaped.
+func escapePath(key string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0xf])
+	}
+	return b.String()
+}
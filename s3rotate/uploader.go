@@ -0,0 +1,278 @@
+// Package s3rotate uploads rotated files to Amazon S3 or S3-compatible object storage.
+//
+// Uploader implements logrotate.Uploader, requests are signed with
+// AWS Signature Version 4 without depending on the AWS SDK:
+//
+//	uploader, err := s3rotate.NewUploader(s3rotate.Options{
+//		Bucket: "logs",
+//		Region: "eu-west-1",
+//		Prefix: `app/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`,
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	w, err := logrotate.New(nil, logrotate.Options{
+//		Directory:         "/var/log/app",
+//		Uploader:          uploader,
+//		DeleteAfterUpload: true,
+//	})
+package s3rotate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/easyCZ/logrotate"
+	"github.com/easyCZ/logrotate/internal/awsv4"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Server-side encryption settings for Options.ServerSideEncryption.
+const (
+	SSES3  = "AES256"
+	SSEKMS = "aws:kms"
+)
+
+// Options configure an Uploader.
+type Options struct {
+	// Bucket is the bucket files are uploaded to. Required.
+	Bucket string
+
+	// Region is the region of the bucket. Defaults to us-east-1.
+	Region string
+
+	// Endpoint is the URL of the S3 service, for example https://minio.example.com:9000
+	// for S3-compatible storage such as MinIO or Ceph. Defaults to the AWS endpoint of Region.
+	Endpoint string
+
+	// PathStyle addresses the bucket in the path of URLs, as in
+	// https://minio.example.com:9000/bucket/key, instead of as a subdomain of the
+	// endpoint. Most S3-compatible storage requires path-style addressing.
+	PathStyle bool
+
+	// Prefix is prepended to file names to form object keys. Prefix is a text/template
+	// executed with the file's logrotate.FileMeta and Hostname, for example
+	// `logs/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`.
+	Prefix string
+
+	// Credentials sign requests. When nil, credentials are read from the
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+	Credentials *Credentials
+
+	// ServerSideEncryption requests encryption of uploaded objects, SSES3 or SSEKMS.
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key used with SSEKMS. Defaults to the bucket's default key.
+	SSEKMSKeyID string
+
+	// ContentType of uploaded objects. Defaults to text/plain; charset=utf-8.
+	ContentType string
+
+	// Client sends requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// PartSize is the size of the parts of files uploaded with a multipart upload,
+	// since a single request cannot upload more than 5 GiB. Files larger than
+	// PartSize are uploaded in parts, larger parts are used for files which would
+	// otherwise exceed the limit of 10000 parts. PartSize must be between
+	// MinimumPartSize and MaximumPartSize. Defaults to DefaultPartSize.
+	PartSize int64
+}
+
+// Uploader uploads files to S3, it implements logrotate.Uploader.
+type Uploader struct {
+	opts     Options
+	endpoint *url.URL
+	prefix   *template.Template
+	hostname string
+	now      func() time.Time
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader.
+func NewUploader(opts Options) (*Uploader, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("Bucket must not be empty")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid Endpoint %s", opts.Endpoint)
+	}
+	switch opts.ServerSideEncryption {
+	case "", SSES3, SSEKMS:
+	default:
+		return nil, errors.Errorf("unsupported ServerSideEncryption %s", opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyID != "" && opts.ServerSideEncryption != SSEKMS {
+		return nil, errors.New("SSEKMSKeyID requires ServerSideEncryption SSEKMS")
+	}
+	if opts.Credentials == nil {
+		opts.Credentials = awsv4.CredentialsFromEnv()
+	}
+	if opts.Credentials.AccessKeyID == "" || opts.Credentials.SecretAccessKey == "" {
+		return nil, errors.New("missing credentials, set Credentials or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "text/plain; charset=utf-8"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = DefaultPartSize
+	}
+	if opts.PartSize < MinimumPartSize || opts.PartSize > MaximumPartSize {
+		return nil, errors.Errorf("PartSize must be between %d and %d bytes, got %d", MinimumPartSize, MaximumPartSize, opts.PartSize)
+	}
+
+	prefix, err := template.New("prefix").Parse(opts.Prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Prefix")
+	}
+	hostname, _ := os.Hostname()
+
+	return &Uploader{
+		opts:     opts,
+		endpoint: endpoint,
+		prefix:   prefix,
+		hostname: hostname,
+		now:      time.Now,
+	}, nil
+}
+
+// prefixData is the data Options.Prefix is executed with.
+type prefixData struct {
+	logrotate.FileMeta
+	Hostname string
+}
+
+// Key returns the object key of a file.
+func (u *Uploader) Key(meta logrotate.FileMeta) (string, error) {
+	var b bytes.Buffer
+	if err := u.prefix.Execute(&b, prefixData{FileMeta: meta, Hostname: u.hostname}); err != nil {
+		return "", errors.Wrap(err, "failed to execute Prefix")
+	}
+	return b.String() + meta.Name, nil
+}
+
+// Upload uploads the file at localPath with a single PUT request, or with
+// a multipart upload if it is larger than Options.PartSize.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	key, err := u.Key(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat file")
+	}
+	if info.Size() > u.opts.PartSize {
+		return u.uploadMultipart(ctx, f, info.Size(), key)
+	}
+
+	req, err := u.newSignedRequest(ctx, http.MethodPut, key, nil, io.NewSectionReader(f, 0, info.Size()))
+	if err != nil {
+		return err
+	}
+	u.setObjectHeaders(req)
+	_, _, err = u.send(req, key)
+	return err
+}
+
+// newSignedRequest creates a request for the object key, with query parameters
+// and body. The request is signed by send. The payload hash is part of the
+// signature, body is read twice instead of being buffered in memory.
+func (u *Uploader) newSignedRequest(ctx context.Context, method, key string, query url.Values, body *io.SectionReader) (*http.Request, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return nil, errors.Wrap(err, "failed to hash file")
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "failed to rewind file")
+	}
+
+	target := u.objectURL(key)
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, ioutil.NopCloser(logrotate.UploadReader(ctx, body)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = body.Size()
+	if req.ContentLength == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(h.Sum(nil)))
+	return req, nil
+}
+
+// setObjectHeaders sets the headers describing the object created by req.
+func (u *Uploader) setObjectHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", u.opts.ContentType)
+	if u.opts.ServerSideEncryption != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", u.opts.ServerSideEncryption)
+	}
+	if u.opts.SSEKMSKeyID != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", u.opts.SSEKMSKeyID)
+	}
+}
+
+// maximumResponseSize bounds the responses read by send, which are small XML documents.
+const maximumResponseSize = 1 << 20
+
+// send signs and sends req about the object key, and returns the body and
+// headers of a successful response.
+func (u *Uploader) send(req *http.Request, key string) ([]byte, http.Header, error) {
+	awsv4.Sign(req, *u.opts.Credentials, u.opts.Region, "s3", req.Header.Get("X-Amz-Content-Sha256"), u.now())
+
+	resp, err := u.opts.Client.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to upload to s3://%s/%s", u.opts.Bucket, key)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, nil, errors.Errorf("failed to upload to s3://%s/%s: %s: %s", u.opts.Bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maximumResponseSize))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read response of s3://%s/%s", u.opts.Bucket, key)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return body, resp.Header, nil
+}
+
+// objectURL returns the URL of an object, addressing the bucket as a subdomain
+// of the endpoint, or in the path with Options.PathStyle.
+func (u *Uploader) objectURL(key string) string {
+	base := strings.TrimSuffix(u.endpoint.Path, "/")
+	if u.opts.PathStyle {
+		return u.endpoint.Scheme + "://" + u.endpoint.Host + base + "/" + escapePath(u.opts.Bucket) + "/" + escapePath(key)
+	}
+	return u.endpoint.Scheme + "://" + u.opts.Bucket + "." + u.endpoint.Host + base + "/" + escapePath(key)
+}
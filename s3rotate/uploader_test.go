@@ -0,0 +1,211 @@
+package s3rotate
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/easyCZ/logrotate"
+	"github.com/easyCZ/logrotate/internal/awsv4"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploader(t *testing.T) {
+	var (
+		host, path, body string
+		header           http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		host, path, body, header = r.Host, r.URL.EscapedPath(), string(b), r.Header
+	}))
+	defer server.Close()
+
+	// Resolve the bucket subdomain to the test server.
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+
+	uploader, err := NewUploader(Options{
+		Bucket:               "logs",
+		Region:               "eu-west-1",
+		Endpoint:             "http://s3.test",
+		Prefix:               `app/{{.Closed.Format "2006/01/02"}}/`,
+		Credentials:          &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"},
+		ServerSideEncryption: SSEKMS,
+		SSEKMSKeyID:          "key",
+		Client:               client,
+	})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "2020-01-02T03:04:05Z-abc.log")
+	require.NoError(t, ioutil.WriteFile(localPath, []byte("message\n"), 0644))
+
+	meta := logrotate.FileMeta{
+		Name:   filepath.Base(localPath),
+		Size:   8,
+		Closed: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+
+	require.Equal(t, "logs.s3.test", host)
+	require.Equal(t, "/app/2020/01/02/2020-01-02T03%3A04%3A05Z-abc.log", path)
+	require.Equal(t, "message\n", body)
+	require.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"))
+	require.Equal(t, "key", header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	require.Equal(t, "token", header.Get("X-Amz-Security-Token"))
+	require.Equal(t, awsv4.HashHex([]byte("message\n")), header.Get("X-Amz-Content-Sha256"))
+	require.True(t, strings.HasPrefix(header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+	require.Contains(t, header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+
+	server.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+	})
+	err = uploader.Upload(context.Background(), localPath, meta)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "AccessDenied")
+}
+
+func TestUploader_PathStyle(t *testing.T) {
+	var host, path string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		host, path = r.Host, r.URL.EscapedPath()
+	}))
+	defer server.Close()
+
+	// MinIO and Ceph are usually addressed by IP or a single host name, which
+	// cannot have bucket subdomains.
+	uploader, err := NewUploader(Options{
+		Bucket:      "logs",
+		Endpoint:    server.URL + "/s3",
+		PathStyle:   true,
+		Credentials: &Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"},
+	})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, []byte("message\n"), 0644))
+
+	require.NoError(t, uploader.Upload(context.Background(), localPath, logrotate.FileMeta{Name: "app.log", Size: 8}))
+	require.Equal(t, server.Listener.Addr().String(), host)
+	require.Equal(t, "/s3/logs/app.log", path)
+}
+
+func TestUploader_Multipart(t *testing.T) {
+	// server implements the multipart upload of a single object.
+	var (
+		mu      sync.Mutex
+		parts   = map[string]string{}
+		aborted bool
+		body    string
+		fail    bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, awsv4.HashHex(b), r.Header.Get("X-Amz-Content-Sha256"))
+		require.Equal(t, "/logs/app.log", r.URL.EscapedPath())
+
+		mu.Lock()
+		defer mu.Unlock()
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && r.URL.RawQuery == "uploads=":
+			require.Equal(t, SSES3, r.Header.Get("X-Amz-Server-Side-Encryption"))
+			rw.Write([]byte("<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>"))
+		case r.Method == http.MethodPut && query.Get("uploadId") == "upload-1":
+			if fail && query.Get("partNumber") == "2" {
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			parts[query.Get("partNumber")] = string(b)
+			rw.Header().Set("ETag", `"etag-`+query.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost && query.Get("uploadId") == "upload-1":
+			var complete struct {
+				Parts []struct {
+					PartNumber string
+					ETag       string
+				} `xml:"Part"`
+			}
+			require.NoError(t, xml.Unmarshal(b, &complete))
+			body = ""
+			for i, part := range complete.Parts {
+				require.Equal(t, strconv.Itoa(i+1), part.PartNumber)
+				require.Equal(t, `"etag-`+part.PartNumber+`"`, part.ETag)
+				body += parts[part.PartNumber]
+			}
+			rw.Write([]byte("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>"))
+		case r.Method == http.MethodDelete && query.Get("uploadId") == "upload-1":
+			aborted = true
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	uploader, err := NewUploader(Options{
+		Bucket:               "logs",
+		Endpoint:             server.URL,
+		PathStyle:            true,
+		Credentials:          &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		ServerSideEncryption: SSES3,
+		PartSize:             MinimumPartSize,
+	})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "app.log")
+	content := strings.Repeat("a", int(MinimumPartSize)) + "end\n"
+	require.NoError(t, ioutil.WriteFile(localPath, []byte(content), 0644))
+	meta := logrotate.FileMeta{Name: "app.log", Size: int64(len(content))}
+
+	require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+	require.Len(t, parts, 2)
+	require.Equal(t, "end\n", parts["2"])
+	require.True(t, body == content, "must complete the upload with the parts in order")
+	require.False(t, aborted)
+
+	fail = true
+	require.Error(t, uploader.Upload(context.Background(), localPath, meta))
+	require.True(t, aborted, "must abort a failed upload")
+}
+
+func TestNewUploader(t *testing.T) {
+	creds := &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	for _, opts := range []Options{
+		{Credentials: creds},
+		{Bucket: "logs", Credentials: creds, Endpoint: "://"},
+		{Bucket: "logs", Credentials: creds, ServerSideEncryption: "rot13"},
+		{Bucket: "logs", Credentials: creds, SSEKMSKeyID: "key"},
+		{Bucket: "logs", Credentials: creds, Prefix: "{{"},
+		{Bucket: "logs", Credentials: &Credentials{}},
+		{Bucket: "logs", Credentials: creds, PartSize: MinimumPartSize - 1},
+		{Bucket: "logs", Credentials: creds, PartSize: MaximumPartSize + 1},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+}
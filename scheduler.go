@@ -0,0 +1,196 @@
+package logrotate
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// defaultSchedulerWorkers is the number of workers of a Scheduler when none is specified.
+const defaultSchedulerWorkers = 4
+
+// SchedulerOptions configure a Scheduler.
+type SchedulerOptions struct {
+	// Workers is the number of goroutines running the background work of the
+	// Writers, such as uploads. When Workers == 0, a default of 4 will be used.
+	Workers int
+}
+
+// Scheduler runs the timers and background work of several Writers, so that
+// applications creating many Writers, for example with a Pool, have a bounded
+// number of goroutines and timers rather than a few per Writer. Writers share
+// a Scheduler by setting Options.Scheduler. The rotation tickers of the Writers
+// are driven by a single timer, and their uploads by the Scheduler's workers.
+type Scheduler struct {
+	mu sync.Mutex
+	// cond is signalled when tasks or closed change
+	cond    *sync.Cond
+	tasks   []func()
+	closed  bool
+	workers sync.WaitGroup
+
+	tickersMu sync.Mutex
+	tickers   map[*scheduledTicker]bool
+	// wake signals the tickers changed
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler creates a Scheduler and starts its goroutines, which run until Close.
+func NewScheduler(opts SchedulerOptions) (*Scheduler, error) {
+	if opts.Workers < 0 {
+		return nil, errors.Errorf("Workers must not be negative, got %d", opts.Workers)
+	}
+	if opts.Workers == 0 {
+		opts.Workers = defaultSchedulerWorkers
+	}
+
+	s := &Scheduler{
+		tickers: make(map[*scheduledTicker]bool),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.workers.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go s.work()
+	}
+	go s.tick()
+
+	return s, nil
+}
+
+// submit queues task to be run by a worker, without blocking. Once the
+// Scheduler is closed, tasks run in their own goroutine.
+func (s *Scheduler) submit(task func()) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		go task()
+		return
+	}
+	s.tasks = append(s.tasks, task)
+	s.mu.Unlock()
+
+	s.cond.Signal()
+}
+
+func (s *Scheduler) work() {
+	defer s.workers.Done()
+
+	for {
+		s.mu.Lock()
+		for len(s.tasks) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.tasks) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		task := s.tasks[0]
+		s.tasks = s.tasks[1:]
+		s.mu.Unlock()
+
+		task()
+	}
+}
+
+// scheduledTicker is a Ticker driven by the timer of a Scheduler.
+type scheduledTicker struct {
+	s    *Scheduler
+	c    chan time.Time
+	d    time.Duration
+	next time.Time
+}
+
+func (t *scheduledTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *scheduledTicker) Stop() {
+	t.s.tickersMu.Lock()
+	delete(t.s.tickers, t)
+	t.s.tickersMu.Unlock()
+}
+
+// NewTicker creates a Ticker firing every d, driven by the Scheduler's timer.
+// Like a time.Ticker, ticks are dropped for slow receivers. NewTicker is used
+// by the Writers with Options.Scheduler, see Options.NewTicker.
+func (s *Scheduler) NewTicker(d time.Duration) Ticker {
+	t := &scheduledTicker{s: s, c: make(chan time.Time, 1), d: d, next: time.Now().Add(d)}
+
+	s.tickersMu.Lock()
+	s.tickers[t] = true
+	s.tickersMu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return t
+}
+
+// tick fires the tickers when they are due, with a single timer.
+func (s *Scheduler) tick() {
+	defer close(s.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		next := now.Add(time.Hour)
+
+		s.tickersMu.Lock()
+		for t := range s.tickers {
+			if !t.next.After(now) {
+				select {
+				case t.c <- now:
+				default:
+				}
+				for !t.next.After(now) {
+					t.next = t.next.Add(t.d)
+				}
+			}
+			if t.next.Before(next) {
+				next = t.next
+			}
+		}
+		s.tickersMu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next.Sub(now))
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close waits for the queued work to be done, and stops the Scheduler's
+// goroutines. Writers using the Scheduler should be closed first.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+
+	s.workers.Wait()
+	close(s.stop)
+	<-s.done
+	return nil
+}
@@ -0,0 +1,106 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("fires tickers", func(t *testing.T) {
+		s, err := NewScheduler(SchedulerOptions{})
+		require.NoError(t, err)
+		defer s.Close()
+
+		slow := s.NewTicker(time.Hour)
+		defer slow.Stop()
+		fast := s.NewTicker(10 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-fast.C():
+			case <-slow.C():
+				t.Fatal("slow ticker fired")
+			case <-time.After(time.Second):
+				t.Fatal("ticker did not fire")
+			}
+		}
+		fast.Stop()
+	})
+
+	t.Run("runs the uploads of several writers", func(t *testing.T) {
+		s, err := NewScheduler(SchedulerOptions{Workers: 1})
+		require.NoError(t, err)
+		defer s.Close()
+
+		var (
+			mu                  sync.Mutex
+			uploaded            []string
+			running, concurrent int
+		)
+		uploader := uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+			mu.Lock()
+			running++
+			if running > concurrent {
+				concurrent = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			uploaded = append(uploaded, filepath.Base(filepath.Dir(localPath))+"/"+filepath.Base(localPath))
+			return nil
+		})
+
+		var writers []*Writer
+		for _, name := range []string{"a", "b"} {
+			name := name
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			dir = filepath.Join(dir, name)
+
+			files := 0
+			w, err := New(logger, Options{
+				Directory: dir,
+				FileNameFunc: func() string {
+					files++
+					return name + string(rune('0'+files)) + ".log"
+				},
+				Uploader:          uploader,
+				UploadConcurrency: 2,
+				Scheduler:         s,
+			})
+			require.NoError(t, err)
+			writers = append(writers, w)
+		}
+
+		for _, w := range writers {
+			for i := 0; i < 2; i++ {
+				if i > 0 {
+					require.NoError(t, w.Rotate())
+				}
+				_, err := w.Write([]byte("entry\n"))
+				require.NoError(t, err)
+			}
+		}
+		for _, w := range writers {
+			require.NoError(t, w.Close())
+		}
+
+		sort.Strings(uploaded)
+		require.Equal(t, []string{"a/a1.log", "a/a2.log", "b/b1.log", "b/b2.log"}, uploaded)
+		require.Equal(t, 1, concurrent)
+	})
+}
@@ -0,0 +1,19 @@
+module github.com/easyCZ/logrotate/sftprotate
+
+go 1.26.0
+
+require (
+	github.com/easyCZ/logrotate v1.0.0
+	github.com/pkg/errors v0.9.1
+	github.com/pkg/sftp v1.13.11
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/crypto v0.57.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
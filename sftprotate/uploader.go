@@ -0,0 +1,293 @@
+// Package sftprotate uploads rotated files to a remote host over SFTP, for
+// environments where object storage is not available and rotated files must
+// land on a central log host.
+//
+// Uploader implements logrotate.Uploader. Files are written under a temporary
+// name and renamed once complete, so that the log host never sees partial files:
+//
+//	signer, err := ssh.ParsePrivateKey(key)
+//	if err != nil {
+//		// handle err
+//	}
+//	hostKeys, err := knownhosts.New("/etc/ssh/ssh_known_hosts")
+//	if err != nil {
+//		// handle err
+//	}
+//	uploader, err := sftprotate.NewUploader(sftprotate.Options{
+//		Address:         "loghost.example.com:22",
+//		User:            "logs",
+//		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+//		HostKeyCallback: hostKeys,
+//		Prefix:          `/srv/logs/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`,
+//	})
+//	if err != nil {
+//		// handle err
+//	}
+//	defer uploader.Close()
+package sftprotate
+
+import (
+	"bytes"
+	"context"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultPort    = "22"
+	defaultTimeout = 30 * time.Second
+
+	// partSuffix is appended to the name of files while they are uploaded.
+	partSuffix = ".part"
+)
+
+// Options configure an Uploader.
+type Options struct {
+	// Address is the host and port of the SSH server. The port defaults to 22.
+	Address string
+
+	// User is the user to authenticate as.
+	User string
+
+	// Auth are the methods used to authenticate, for example ssh.PublicKeys.
+	Auth []ssh.AuthMethod
+
+	// HostKeyCallback verifies the key of the server, for example using
+	// golang.org/x/crypto/ssh/knownhosts. Required.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Prefix is prepended to file names to form remote paths, relative paths are
+	// relative to the user's home directory. Prefix is a text/template executed with
+	// the file's logrotate.FileMeta and Hostname, for example
+	// `/srv/logs/{{.Hostname}}/{{.Closed.Format "2006/01/02"}}/`.
+	// Missing directories are created.
+	Prefix string
+
+	// Timeout bounds establishing the connection. Defaults to 30s.
+	Timeout time.Duration
+}
+
+// Uploader uploads files over SFTP, it implements logrotate.Uploader.
+// The connection is established on the first upload, shared by concurrent
+// uploads, and established again after a failure.
+type Uploader struct {
+	opts     Options
+	config   *ssh.ClientConfig
+	prefix   *template.Template
+	hostname string
+
+	mu   sync.Mutex
+	conn *connection
+}
+
+// connection is an SFTP session over an SSH connection.
+type connection struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *connection) close() error {
+	err := c.sftp.Close()
+	if sshErr := c.ssh.Close(); err == nil {
+		err = sshErr
+	}
+	return err
+}
+
+var _ logrotate.Uploader = (*Uploader)(nil)
+
+// NewUploader validates opts and returns an Uploader, without connecting to the server.
+func NewUploader(opts Options) (*Uploader, error) {
+	if opts.Address == "" {
+		return nil, errors.New("Address must not be empty")
+	}
+	if _, _, err := net.SplitHostPort(opts.Address); err != nil {
+		opts.Address = net.JoinHostPort(opts.Address, defaultPort)
+	}
+	if opts.User == "" {
+		return nil, errors.New("User must not be empty")
+	}
+	if opts.HostKeyCallback == nil {
+		return nil, errors.New("HostKeyCallback must not be nil")
+	}
+	if opts.Timeout < 0 {
+		return nil, errors.Errorf("Timeout must not be negative, got %v", opts.Timeout)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	prefix, err := template.New("prefix").Parse(opts.Prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Prefix")
+	}
+	hostname, _ := os.Hostname()
+
+	return &Uploader{
+		opts: opts,
+		config: &ssh.ClientConfig{
+			User:            opts.User,
+			Auth:            opts.Auth,
+			HostKeyCallback: opts.HostKeyCallback,
+			Timeout:         opts.Timeout,
+		},
+		prefix:   prefix,
+		hostname: hostname,
+	}, nil
+}
+
+// prefixData is the data Options.Prefix is executed with.
+type prefixData struct {
+	logrotate.FileMeta
+	Hostname string
+}
+
+// RemotePath returns the remote path of a file.
+func (u *Uploader) RemotePath(meta logrotate.FileMeta) (string, error) {
+	var b bytes.Buffer
+	if err := u.prefix.Execute(&b, prefixData{FileMeta: meta, Hostname: u.hostname}); err != nil {
+		return "", errors.Wrap(err, "failed to execute Prefix")
+	}
+	return b.String() + meta.Name, nil
+}
+
+// Upload uploads the file at localPath, replacing an existing remote file.
+// When ctx is done, the connection is closed to interrupt the upload.
+func (u *Uploader) Upload(ctx context.Context, localPath string, meta logrotate.FileMeta) error {
+	remotePath, err := u.RemotePath(meta)
+	if err != nil {
+		return err
+	}
+
+	conn, err := u.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			u.disconnect(conn)
+		case <-done:
+		}
+	}()
+
+	if err := u.upload(ctx, conn.sftp, localPath, remotePath); err != nil {
+		u.disconnect(conn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.Wrapf(err, "failed to upload to %s:%s", u.opts.Address, remotePath)
+	}
+	return nil
+}
+
+// upload copies the file at localPath to remotePath, through a temporary file.
+func (u *Uploader) upload(ctx context.Context, client *sftp.Client, localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	if dir := path.Dir(remotePath); dir != "." {
+		if err := client.MkdirAll(dir); err != nil {
+			return errors.Wrap(err, "failed to create remote directory")
+		}
+	}
+
+	part := remotePath + partSuffix
+	remote, err := client.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return errors.Wrap(err, "failed to create remote file")
+	}
+	if _, err := io.Copy(remote, logrotate.UploadReader(ctx, f)); err != nil {
+		remote.Close()
+		return errors.Wrap(err, "failed to write remote file")
+	}
+	if err := remote.Close(); err != nil {
+		return errors.Wrap(err, "failed to close remote file")
+	}
+
+	// The POSIX rename extension replaces an existing file, a plain SFTP rename does not.
+	if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+		err = client.PosixRename(part, remotePath)
+	} else {
+		err = client.Rename(part, remotePath)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to rename remote file")
+	}
+	return nil
+}
+
+// connect returns the current connection, or establishes a new one.
+func (u *Uploader) connect(ctx context.Context) (*connection, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.conn != nil {
+		return u.conn, nil
+	}
+
+	dialer := net.Dialer{Timeout: u.opts.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", u.opts.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", u.opts.Address)
+	}
+
+	// The handshake is bounded by the timeout as well.
+	_ = netConn.SetDeadline(time.Now().Add(u.opts.Timeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, u.opts.Address, u.config)
+	if err != nil {
+		netConn.Close()
+		return nil, errors.Wrapf(err, "failed to establish SSH connection to %s", u.opts.Address)
+	}
+	_ = netConn.SetDeadline(time.Time{})
+
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, errors.Wrapf(err, "failed to start SFTP session on %s", u.opts.Address)
+	}
+
+	u.conn = &connection{ssh: sshClient, sftp: sftpClient}
+	return u.conn, nil
+}
+
+// disconnect closes conn, so that the next upload establishes a new connection.
+func (u *Uploader) disconnect(conn *connection) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.conn == conn {
+		u.conn = nil
+		conn.close()
+	}
+}
+
+// Close closes the connection to the server, if any.
+// The Uploader can still be used, the next upload establishes a new connection.
+func (u *Uploader) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.conn == nil {
+		return nil
+	}
+	err := u.conn.close()
+	u.conn = nil
+	return err
+}
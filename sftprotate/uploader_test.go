@@ -0,0 +1,186 @@
+package sftprotate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startServer starts an SFTP server serving dir, accepting the user "logs" with the password "secret".
+func startServer(t *testing.T, dir string) (addr string, hostKey ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "logs" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config, dir)
+		}
+	}()
+
+	return listener.Addr().String(), signer.PublicKey()
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig, dir string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(dir))
+					if err == nil {
+						server.Serve()
+						server.Close()
+					}
+				}
+			}
+		}()
+	}
+}
+
+func TestUploader(t *testing.T) {
+	local, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(local)
+	remote, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(remote)
+
+	addr, hostKey := startServer(t, remote)
+
+	localPath := filepath.Join(local, "app.log")
+	require.NoError(t, ioutil.WriteFile(localPath, []byte("message\n"), 0644))
+	meta := logrotate.FileMeta{
+		Name:   "app.log",
+		Size:   8,
+		Closed: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	t.Run("uploads files", func(t *testing.T) {
+		uploader, err := NewUploader(Options{
+			Address:         addr,
+			User:            "logs",
+			Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Prefix:          `logs/{{.Closed.Format "2006/01/02"}}/`,
+		})
+		require.NoError(t, err)
+		defer uploader.Close()
+
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		b, err := ioutil.ReadFile(filepath.Join(remote, "logs", "2020", "01", "02", "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "message\n", string(b))
+
+		// Files are replaced, over the same connection.
+		require.NoError(t, ioutil.WriteFile(localPath, []byte("replaced\n"), 0644))
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		b, err = ioutil.ReadFile(filepath.Join(remote, "logs", "2020", "01", "02", "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "replaced\n", string(b))
+
+		_, err = os.Stat(filepath.Join(remote, "logs", "2020", "01", "02", "app.log"+partSuffix))
+		require.True(t, os.IsNotExist(err), "temporary file must be renamed")
+	})
+
+	t.Run("reconnects after Close", func(t *testing.T) {
+		uploader, err := NewUploader(Options{
+			Address:         addr,
+			User:            "logs",
+			Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+		})
+		require.NoError(t, err)
+		defer uploader.Close()
+
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		require.NoError(t, uploader.Close())
+		require.NoError(t, uploader.Upload(context.Background(), localPath, meta))
+		require.FileExists(t, filepath.Join(remote, "app.log"))
+	})
+
+	t.Run("rejects unknown host keys", func(t *testing.T) {
+		_, other, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		otherKey, err := ssh.NewPublicKey(other.Public())
+		require.NoError(t, err)
+
+		uploader, err := NewUploader(Options{
+			Address:         addr,
+			User:            "logs",
+			Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+			HostKeyCallback: ssh.FixedHostKey(otherKey),
+		})
+		require.NoError(t, err)
+		require.Error(t, uploader.Upload(context.Background(), localPath, meta))
+	})
+
+	t.Run("fails to authenticate", func(t *testing.T) {
+		uploader, err := NewUploader(Options{
+			Address:         addr,
+			User:            "logs",
+			Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+		})
+		require.NoError(t, err)
+		require.Error(t, uploader.Upload(context.Background(), localPath, meta))
+	})
+}
+
+func TestNewUploader(t *testing.T) {
+	callback := ssh.InsecureIgnoreHostKey()
+	for _, opts := range []Options{
+		{User: "logs", HostKeyCallback: callback},
+		{Address: "loghost", HostKeyCallback: callback},
+		{Address: "loghost", User: "logs"},
+		{Address: "loghost", User: "logs", HostKeyCallback: callback, Timeout: -time.Second},
+		{Address: "loghost", User: "logs", HostKeyCallback: callback, Prefix: "{{"},
+	} {
+		_, err := NewUploader(opts)
+		require.Error(t, err, "%+v", opts)
+	}
+
+	uploader, err := NewUploader(Options{Address: "loghost", User: "logs", HostKeyCallback: callback})
+	require.NoError(t, err)
+	require.Equal(t, "loghost:22", uploader.opts.Address)
+}
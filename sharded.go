@@ -0,0 +1,226 @@
+package logrotate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedOptions configure a Sharded writer.
+type ShardedOptions struct {
+	// Options are the options of every shard. The names returned by
+	// FileNameFunc are suffixed with "-shard" and the index of the shard,
+	// before their extension, for example "app-shard0.log", and FileNameFunc
+	// is called by the shards concurrently. Each shard keeps its own
+	// UploadManifest, suffixed in the same way. Lock and ExpvarPrefix, which
+	// would be shared by the shards, cannot be used.
+	// Set TimestampFormat so that shards can be merged with MergeShards.
+	Options Options
+
+	// Shards is the number of files written at the same time.
+	Shards int
+}
+
+func (o ShardedOptions) validate() error {
+	if err := o.Options.Validate(); err != nil {
+		return errors.Wrap(err, "invalid options")
+	}
+	if o.Options.Lock != nil {
+		return errors.New("Lock cannot be used with shards")
+	}
+	if o.Options.ExpvarPrefix != "" {
+		return errors.New("ExpvarPrefix cannot be used with shards")
+	}
+	if o.Shards < 1 {
+		return errors.Errorf("Shards must be at least 1, got %d", o.Shards)
+	}
+	return nil
+}
+
+// Sharded stripes entries across several Writers writing into the same
+// Directory, each with its own queue and consumer goroutine, for workloads
+// where a single file or queue cannot keep up. Entries are distributed in
+// turn, so that their order is only preserved within a shard, see MergeShards.
+type Sharded struct {
+	shards []*Writer
+	next   uint32
+}
+
+// NewSharded creates a Sharded writer, and the Writer of each of its shards.
+func NewSharded(logger Logger, opts ShardedOptions) (*Sharded, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid sharded options")
+	}
+
+	s := &Sharded{shards: make([]*Writer, 0, opts.Shards)}
+	for i := 0; i < opts.Shards; i++ {
+		w, err := New(logger, shardOptions(opts.Options, i))
+		if err != nil {
+			s.Close()
+			return nil, errors.Wrapf(err, "failed to create shard %d", i)
+		}
+		s.shards = append(s.shards, w)
+	}
+	return s, nil
+}
+
+// shardOptions returns the options of the shard with index i.
+func shardOptions(opts Options, i int) Options {
+	suffix := fmt.Sprintf("-shard%d", i)
+
+	fileName := opts.FileNameFunc
+	if fileName == nil && opts.Shared {
+		fileName = SharedFilenameFunc
+	}
+	if fileName == nil {
+		fileName = DefaultFilenameFunc
+	}
+	opts.FileNameFunc = func() string {
+		name := fileName()
+		ext := filepath.Ext(name)
+		return strings.TrimSuffix(name, ext) + suffix + ext
+	}
+
+	if opts.UploadManifest == "" {
+		opts.UploadManifest = filepath.Join(opts.Directory, DefaultUploadManifest)
+	}
+	opts.UploadManifest += suffix
+	return opts
+}
+
+// Write writes p into the next shard, see Writer.Write.
+func (s *Sharded) Write(p []byte) (int, error) {
+	i := atomic.AddUint32(&s.next, 1) - 1
+	return s.shards[int(i%uint32(len(s.shards)))].Write(p)
+}
+
+// Shards returns the Writers of the shards, in order.
+func (s *Sharded) Shards() []*Writer {
+	return append([]*Writer(nil), s.shards...)
+}
+
+// Flush flushes every shard, see Writer.Flush, and returns the first error encountered.
+func (s *Sharded) Flush() error {
+	var firstErr error
+	for i, w := range s.shards {
+		if err := w.Flush(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to flush shard %d", i)
+		}
+	}
+	return firstErr
+}
+
+// Rotate rotates every shard, see Writer.Rotate, and returns the first error encountered.
+func (s *Sharded) Rotate() error {
+	var firstErr error
+	for i, w := range s.shards {
+		if err := w.Rotate(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to rotate shard %d", i)
+		}
+	}
+	return firstErr
+}
+
+// Close closes the shards concurrently, and returns the first error encountered.
+func (s *Sharded) Close() error {
+	writers := make(map[string]*Writer, len(s.shards))
+	for i, w := range s.shards {
+		writers[fmt.Sprintf("shard%d", i)] = w
+	}
+	return closeWriters(writers)
+}
+
+// MergeShards writes the lines of shards into w, interleaved by the timestamps
+// prefixed to them by Options.TimestampFormat, given as layout. The order
+// of lines within each shard is preserved, lines without a timestamp, such as
+// continuations of multi-line entries, follow the line preceding them.
+// The timestamp of a line is made of as many space separated fields as the
+// layout, such as time.RFC3339Nano.
+func MergeShards(w io.Writer, layout string, shards ...io.Reader) error {
+	fields := strings.Count(layout, " ") + 1
+	heads := make([]*shardReader, 0, len(shards))
+	for _, r := range shards {
+		sr := &shardReader{r: bufio.NewReader(r), layout: layout, fields: fields}
+		if err := sr.advance(); err != nil {
+			return err
+		}
+		if sr.line != nil {
+			heads = append(heads, sr)
+		}
+	}
+
+	for len(heads) > 0 {
+		next := 0
+		for i, sr := range heads {
+			if sr.ts.Before(heads[next].ts) {
+				next = i
+			}
+		}
+		sr := heads[next]
+		if _, err := w.Write(sr.line); err != nil {
+			return errors.Wrap(err, "failed to write merged line")
+		}
+		if err := sr.advance(); err != nil {
+			return err
+		}
+		if sr.line == nil {
+			heads = append(heads[:next], heads[next+1:]...)
+		}
+	}
+	return nil
+}
+
+// shardReader reads the lines of a shard for MergeShards.
+type shardReader struct {
+	r      *bufio.Reader
+	layout string
+	fields int
+
+	// line is the next line of the shard, nil at the end of the shard
+	line []byte
+	// ts is the timestamp of line, or of the last line which had one
+	ts time.Time
+}
+
+// advance reads the next line of the shard.
+func (sr *shardReader) advance() error {
+	line, err := sr.r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "failed to read shard")
+	}
+	if len(line) == 0 {
+		sr.line = nil
+		return nil
+	}
+	if line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	sr.line = line
+	if ts, ok := sr.timestamp(line); ok {
+		sr.ts = ts
+	}
+	return nil
+}
+
+// timestamp parses the timestamp prefixed to line.
+func (sr *shardReader) timestamp(line []byte) (time.Time, bool) {
+	end := 0
+	for i := 0; i < sr.fields; i++ {
+		n := bytes.IndexByte(line[end:], ' ')
+		if n < 0 {
+			return time.Time{}, false
+		}
+		end += n + 1
+	}
+	ts, err := time.Parse(sr.layout, string(line[:end-1]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
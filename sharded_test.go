@@ -0,0 +1,83 @@
+package logrotate
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharded(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("stripes entries across shards which merge back in order", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		clock := newFakeClock()
+		s, err := NewSharded(logger, ShardedOptions{
+			Options: Options{
+				Directory:       dir,
+				FileNameFunc:    func() string { return "app.log" },
+				Now:             clock.Now,
+				TimestampFormat: time.RFC3339Nano,
+			},
+			Shards: 3,
+		})
+		require.NoError(t, err)
+		require.Len(t, s.Shards(), 3)
+
+		var entries []string
+		for i := 0; i < 9; i++ {
+			entry := fmt.Sprintf("entry %d\n", i)
+			entries = append(entries, entry)
+			_, err := s.Write([]byte(entry))
+			require.NoError(t, err)
+			clock.Advance(time.Millisecond)
+		}
+		require.NoError(t, s.Close())
+
+		var shards []io.Reader
+		for i := 0; i < 3; i++ {
+			b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("app-shard%d.log", i)))
+			require.NoError(t, err)
+			require.Equal(t, 3, strings.Count(string(b), "\n"))
+			require.Contains(t, string(b), entries[i])
+			shards = append(shards, bytes.NewReader(b))
+		}
+
+		var merged bytes.Buffer
+		require.NoError(t, MergeShards(&merged, time.RFC3339Nano, shards...))
+		lines := strings.SplitAfter(merged.String(), "\n")
+		require.Len(t, lines, 10)
+		for i, entry := range entries {
+			require.True(t, strings.HasSuffix(lines[i], " "+entry), lines[i])
+		}
+	})
+
+	t.Run("keeps lines without timestamps after the preceding line", func(t *testing.T) {
+		var merged bytes.Buffer
+		require.NoError(t, MergeShards(&merged, time.RFC3339,
+			strings.NewReader("2020-03-28T15:00:00Z panic\n\tgoroutine 1\n2020-03-28T15:00:03Z d"),
+			strings.NewReader("2020-03-28T15:00:01Z b\n2020-03-28T15:00:02Z c\n"),
+		))
+		require.Equal(t,
+			"2020-03-28T15:00:00Z panic\n\tgoroutine 1\n2020-03-28T15:00:01Z b\n2020-03-28T15:00:02Z c\n2020-03-28T15:00:03Z d\n",
+			merged.String())
+	})
+
+	t.Run("rejects options shared by the shards", func(t *testing.T) {
+		_, err := NewSharded(logger, ShardedOptions{Options: Options{Directory: "logs"}})
+		require.Error(t, err)
+		_, err = NewSharded(logger, ShardedOptions{Options: Options{Directory: "logs", Lock: &LockOptions{}}, Shards: 2})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,23 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// SharedFilenameFunc is the default FileNameFunc of Writers in Shared mode.
+// Names include the process ID, so that processes sharing a Directory
+// never write into each other's files.
+func SharedFilenameFunc() string {
+	return fmt.Sprintf("%s-%d-%s.log", time.Now().UTC().Format(time.RFC3339), os.Getpid(), RandomHash(3))
+}
+
+// newFileFlags returns the flags used to open new files, in Shared mode
+// existing files are appended to rather than truncated.
+func (w *Writer) newFileFlags() int {
+	if w.opts.Shared {
+		return appendFileFlag
+	}
+	return newFileFlag
+}
@@ -0,0 +1,102 @@
+package logrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_Shared(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newDir := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+
+	t.Run("appends whole entries to a shared file", func(t *testing.T) {
+		dir := newDir(t)
+		path := filepath.Join(dir, "app.log")
+		require.NoError(t, ioutil.WriteFile(path, []byte("existing\n"), 0644))
+
+		opts := Options{Directory: dir, Shared: true, FileNameFunc: func() string { return "app.log" }}
+		first, err := New(logger, opts)
+		require.NoError(t, err)
+		second, err := New(logger, opts)
+		require.NoError(t, err)
+
+		// The second entry does not fit into the buffer behind the first one,
+		// the first one must be written on its own, without part of the second.
+		large := strings.Repeat("x", 3000) + "\n"
+		for _, msg := range []string{large, large} {
+			_, err := first.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		var b []byte
+		require.Eventually(t, func() bool {
+			b, err = ioutil.ReadFile(path)
+			require.NoError(t, err)
+			return len(b) > len("existing\n")
+		}, time.Second, time.Millisecond)
+		require.Equal(t, "existing\n"+large, string(b))
+
+		_, err = second.Write([]byte("other\n"))
+		require.NoError(t, err)
+		require.NoError(t, second.Close())
+		require.NoError(t, first.Close())
+
+		b, err = ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "existing\n"+large+"other\n"+large, string(b))
+	})
+
+	t.Run("names files after the process", func(t *testing.T) {
+		dir := newDir(t)
+		w, err := New(logger, Options{Directory: dir, Shared: true})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Contains(t, files[0].Name(), fmt.Sprintf("-%d-", os.Getpid()))
+	})
+
+	t.Run("shares the lock", func(t *testing.T) {
+		if !lockSupported {
+			t.Skip("Lock is not supported on this platform")
+		}
+		dir := newDir(t)
+		opts := Options{Directory: dir, Shared: true, Lock: &LockOptions{}}
+		first, err := New(logger, opts)
+		require.NoError(t, err)
+		defer first.Close()
+		second, err := New(logger, opts)
+		require.NoError(t, err)
+		defer second.Close()
+
+		_, err = New(logger, Options{Directory: dir, Lock: &LockOptions{}})
+		require.True(t, errors.Is(err, ErrLocked), "got %v", err)
+	})
+
+	t.Run("requires an upload manifest per process", func(t *testing.T) {
+		uploader := uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error { return nil })
+		opts := Options{Directory: "logs", Shared: true, Uploader: uploader}
+		require.Error(t, opts.Validate())
+
+		opts.UploadManifest = "logs/.upload-manifest-1"
+		require.NoError(t, opts.Validate())
+	})
+}
@@ -0,0 +1,134 @@
+package ship
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"net"
+	"time"
+)
+
+// fluentTimeout bounds connecting and writing to a Fluent Forward endpoint.
+const fluentTimeout = 10 * time.Second
+
+// FluentSink sends records to a Fluentd or Fluent Bit forward input,
+// using the Forward mode of the Fluent Forward protocol over TCP.
+// Each record is sent with a single "message" field.
+type FluentSink struct {
+	// Address is the address of the forward input, for example "fluentd:24224".
+	Address string
+	// Tag is the tag of the records.
+	Tag string
+
+	conn net.Conn
+}
+
+// NewFluentSink returns a FluentSink sending records tagged with tag to address.
+func NewFluentSink(address, tag string) *FluentSink {
+	return &FluentSink{Address: address, Tag: tag}
+}
+
+// Send sends records as a single Forward mode message.
+// The connection is established on first use, and again after a failure.
+func (f *FluentSink) Send(ctx context.Context, records []Record) error {
+	if f.conn == nil {
+		var d net.Dialer
+		dialCtx, cancel := context.WithTimeout(ctx, fluentTimeout)
+		defer cancel()
+
+		conn, err := d.DialContext(dialCtx, "tcp", f.Address)
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect to %s", f.Address)
+		}
+		f.conn = conn
+	}
+
+	_ = f.conn.SetWriteDeadline(time.Now().Add(fluentTimeout))
+	if _, err := f.conn.Write(encodeForward(f.Tag, records)); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return errors.Wrapf(err, "failed to send to %s", f.Address)
+	}
+	return nil
+}
+
+// Close closes the connection, if any.
+func (f *FluentSink) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
+
+// encodeForward encodes records as a Forward mode message, [tag, [[time, {"message": line}], ...]],
+// in MessagePack.
+func encodeForward(tag string, records []Record) []byte {
+	b := appendArrayHeader(nil, 2)
+	b = appendString(b, tag)
+	b = appendArrayHeader(b, len(records))
+	for _, r := range records {
+		b = appendArrayHeader(b, 2)
+		b = appendUint(b, uint64(r.Time.Unix()))
+		b = append(b, 0x81) // map with a single entry
+		b = appendString(b, "message")
+		b = appendString(b, r.Line)
+	}
+	return b
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= 0xffff:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= 0xffffffff:
+		return appendUint32(append(b, 0xce), uint32(v))
+	default:
+		return appendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,85 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LokiSink sends records to Grafana Loki using its push API.
+type LokiSink struct {
+	// URL is the push endpoint, for example http://loki:3100/loki/api/v1/push.
+	URL string
+	// Labels identify the stream records are added to.
+	Labels map[string]string
+	// Headers are added to each request, for example X-Scope-OrgID or Authorization.
+	Headers map[string]string
+	// Client sends requests.
+	Client *http.Client
+}
+
+// NewLokiSink returns a LokiSink pushing records to url, into the stream identified by labels.
+func NewLokiSink(url string, labels map[string]string) *LokiSink {
+	return &LokiSink{
+		URL:    url,
+		Labels: labels,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Send pushes records to Loki as a single stream.
+func (l *LokiSink) Send(ctx context.Context, records []Record) error {
+	stream := lokiStream{Stream: l.Labels, Values: make([][2]string, 0, len(records))}
+	if stream.Stream == nil {
+		stream.Stream = map[string]string{}
+	}
+	for _, r := range records {
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), r.Line})
+	}
+
+	body, err := json.Marshal(lokiPush{Streams: []lokiStream{stream}})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode records")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range l.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to push to Loki")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+// Package ship forwards entries written by a logrotate Writer to a remote
+// destination, such as Grafana Loki or a Fluent Forward endpoint, in addition
+// to local rotation.
+//
+// A Shipper reads entries back from the files in the Writer's directory and
+// remembers how far it got in a state file. While the destination is down,
+// entries accumulate in the local files, which act as a durable buffer, and
+// are sent once it is available again, including after a restart:
+//
+//	shipper, err := ship.New("/var/log/app", ship.NewLokiSink("http://loki:3100/loki/api/v1/push",
+//		map[string]string{"app": "app"}), ship.Options{})
+//	if err != nil {
+//		// handle err
+//	}
+//	go shipper.Run(ctx)
+//
+// Entries are shipped line by line, each line is sent as a Record. Only complete
+// lines of the most recent file are shipped, a line is complete once it ends
+// with a newline. Entries are sent at least once, entries sent right before
+// a crash may be sent again after a restart.
+package ship
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultStateFile is the name of the state file within the directory,
+// hidden files are not shipped.
+const DefaultStateFile = ".ship-state"
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 500
+)
+
+// Record is a line read from a log file.
+type Record struct {
+	// Time is the time the line was read.
+	Time time.Time
+	// Line is the line without its trailing newline.
+	Line string
+	// File is the name of the file the line was read from.
+	File string
+}
+
+// Sink sends records to a remote destination.
+type Sink interface {
+	// Send sends a batch of records, in the order they were written.
+	// When Send fails, the records are sent again later.
+	Send(ctx context.Context, records []Record) error
+}
+
+// Options configure a Shipper.
+type Options struct {
+	// Interval is the time between checks for new entries, and between attempts
+	// to send entries while the destination is down. Defaults to 1s.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of records sent at once. Defaults to 500.
+	BatchSize int
+
+	// StateFile is the path of the file storing the position of the last entry sent.
+	// Defaults to DefaultStateFile in the directory.
+	StateFile string
+
+	// Logger receives failures to send entries. When nil, failures are not logged.
+	Logger logrotate.Logger
+}
+
+// Shipper sends the lines of the files in a directory to a Sink.
+type Shipper struct {
+	dir    string
+	sink   Sink
+	opts   Options
+	logger logrotate.Logger
+
+	state state
+}
+
+// state is the position after the last line sent, persisted in the state file.
+type state struct {
+	File    string    `json:"file"`
+	Offset  int64     `json:"offset"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// New creates a Shipper for the files in dir, resuming from the state file if it exists.
+func New(dir string, sink Sink, opts Options) (*Shipper, error) {
+	if opts.Interval < 0 || opts.BatchSize < 0 {
+		return nil, errors.New("Interval and BatchSize must not be negative")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.StateFile == "" {
+		opts.StateFile = filepath.Join(dir, DefaultStateFile)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrotate.LoggerFunc(func(string, ...interface{}) {})
+	}
+
+	s := &Shipper{
+		dir:    dir,
+		sink:   sink,
+		opts:   opts,
+		logger: logger,
+	}
+
+	b, err := ioutil.ReadFile(opts.StateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read state file")
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &s.state); err != nil {
+			return nil, errors.Wrap(err, "failed to parse state file")
+		}
+	}
+
+	return s, nil
+}
+
+// Run ships entries every Interval until ctx is done, then returns ctx.Err().
+// Failures to ship entries are logged and retried.
+func (s *Shipper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Ship(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Printf("Failed to ship entries, retrying in %v: %v", s.opts.Interval, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ship sends all complete lines written since the last call, and returns once
+// they have been sent or sending failed.
+func (s *Shipper) Ship(ctx context.Context) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch []Record
+		// next is the state once batch has been sent
+		next = s.state
+	)
+	send := func() error {
+		if len(batch) > 0 {
+			if err := s.sink.Send(ctx, batch); err != nil {
+				return errors.Wrap(err, "failed to send records")
+			}
+			batch = batch[:0]
+		}
+		return s.save(next)
+	}
+
+	for i, file := range files {
+		offset := int64(0)
+		if file.Name() == next.File {
+			offset = next.Offset
+		} else if next.File != "" && !after(file, next) {
+			continue
+		}
+
+		last := i == len(files)-1
+		err := s.read(file.Name(), offset, last, func(line string, end int64) error {
+			batch = append(batch, Record{Time: time.Now(), Line: line, File: file.Name()})
+			next = state{File: file.Name(), Offset: end, ModTime: file.ModTime()}
+			if len(batch) < s.opts.BatchSize {
+				return nil
+			}
+			return send()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return send()
+}
+
+// read calls fn with each line of the file starting at offset and the offset after it.
+// A final line without a trailing newline is only read when the file is not the last one,
+// the last file may still be written to.
+func (s *Shipper) read(name string, offset int64, last bool, fn func(line string, end int64) error) error {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", name)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "failed to seek %s", name)
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && (line == "" || last) {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return errors.Wrapf(err, "failed to read %s", name)
+		}
+
+		offset += int64(len(line))
+		if err := fn(strings.TrimSuffix(line, "\n"), offset); err != nil {
+			return err
+		}
+	}
+}
+
+// files returns the log files in the directory, oldest first.
+func (s *Shipper) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list log files")
+	}
+
+	files := infos[:0]
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") &&
+			filepath.Join(s.dir, info.Name()) != filepath.Clean(s.opts.StateFile) {
+			files = append(files, info)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].ModTime().Equal(files[j].ModTime()) {
+			return files[i].ModTime().Before(files[j].ModTime())
+		}
+		return files[i].Name() < files[j].Name()
+	})
+	return files, nil
+}
+
+// after reports whether file was written after the file of state,
+// used when the file of state no longer exists.
+func after(file os.FileInfo, st state) bool {
+	if !file.ModTime().Equal(st.ModTime) {
+		return file.ModTime().After(st.ModTime)
+	}
+	return file.Name() > st.File
+}
+
+// save persists st in the state file, replacing it atomically.
+func (s *Shipper) save(st state) error {
+	if st == s.state {
+		return nil
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode state")
+	}
+
+	tmp := s.opts.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.Wrap(err, "failed to write state file")
+	}
+	if err := os.Rename(tmp, s.opts.StateFile); err != nil {
+		return errors.Wrap(err, "failed to replace state file")
+	}
+
+	s.state = st
+	return nil
+}
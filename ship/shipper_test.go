@@ -0,0 +1,95 @@
+package ship
+
+import (
+	"context"
+	"fmt"
+	"github.com/easyCZ/logrotate"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingSink records the lines sent, or fails while err is set.
+type recordingSink struct {
+	lines   []string
+	batches int
+	err     error
+}
+
+func (s *recordingSink) Send(ctx context.Context, records []Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, r := range records {
+		s.lines = append(s.lines, r.Line)
+	}
+	s.batches++
+	return nil
+}
+
+func TestShipper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	i := 0
+	w, err := logrotate.New(nil, logrotate.Options{
+		Directory:       dir,
+		MaximumFileSize: 24,
+		FileNameFunc: func() string {
+			i++
+			return fmt.Sprintf("%02d.log", i)
+		},
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	write := func(lines ...string) {
+		for _, line := range lines {
+			_, err := w.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+	}
+
+	sink := &recordingSink{}
+	shipper, err := New(dir, sink, Options{BatchSize: 2})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	write("first\n", "second\n", "third\n", "fourth\n", "incomplete")
+	require.NoError(t, shipper.Ship(ctx))
+	require.Equal(t, []string{"first", "second", "third", "fourth"}, sink.lines,
+		"incomplete line of the current file must not be shipped")
+	require.Equal(t, 2, sink.batches)
+
+	sink.err = errors.New("unavailable")
+	write(" line\n", "fifth\n")
+	require.Error(t, shipper.Ship(ctx))
+
+	sink.err = nil
+	require.NoError(t, shipper.Ship(ctx))
+	require.Equal(t, []string{"first", "second", "third", "fourth", "incomplete line", "fifth"}, sink.lines)
+
+	write("sixth\n")
+	restarted, err := New(dir, sink, Options{})
+	require.NoError(t, err)
+	require.NoError(t, restarted.Ship(ctx))
+	require.Equal(t, []string{"first", "second", "third", "fourth", "incomplete line", "fifth", "sixth"}, sink.lines,
+		"restarted shipper must resume from the state file")
+
+	// Files shipped completely can be removed, for example by retention.
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	require.NoError(t, err)
+	require.True(t, len(files) > 2)
+	for _, file := range files[:len(files)-1] {
+		require.NoError(t, os.Remove(file))
+	}
+	write("seventh\n", "eighth\n")
+	require.NoError(t, restarted.Ship(ctx))
+	require.Equal(t, []string{"seventh", "eighth"}, sink.lines[len(sink.lines)-2:])
+	require.Len(t, sink.lines, 9)
+}
@@ -0,0 +1,71 @@
+package ship
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLokiSink(t *testing.T) {
+	var push lokiPush
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&push))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewLokiSink(server.URL, map[string]string{"app": "test"})
+	sink.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+
+	ts := time.Unix(1, 5)
+	require.NoError(t, sink.Send(context.Background(), []Record{{Time: ts, Line: "first"}, {Time: ts, Line: "second"}}))
+	require.Equal(t, lokiPush{Streams: []lokiStream{{
+		Stream: map[string]string{"app": "test"},
+		Values: [][2]string{{"1000000005", "first"}, {"1000000005", "second"}},
+	}}}, push)
+
+	server.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTooManyRequests)
+	})
+	require.Error(t, sink.Send(context.Background(), []Record{{Time: ts, Line: "third"}}))
+}
+
+func TestFluentSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		received <- b
+	}()
+
+	sink := NewFluentSink(listener.Addr().String(), "app")
+	require.NoError(t, sink.Send(context.Background(), []Record{{Time: time.Unix(1500000000, 0), Line: "hello"}}))
+	require.NoError(t, sink.Close())
+
+	expected := []byte{
+		0x92,                // [tag, entries]
+		0xa3, 'a', 'p', 'p', // "app"
+		0x91,                         // [entry]
+		0x92,                         // [time, record]
+		0xce, 0x59, 0x68, 0x2f, 0x00, // 1500000000
+		0x81,                                    // {"message": "hello"}
+		0xa7, 'm', 'e', 's', 's', 'a', 'g', 'e', // "message"
+		0xa5, 'h', 'e', 'l', 'l', 'o', // "hello"
+	}
+	require.Equal(t, expected, <-received)
+}
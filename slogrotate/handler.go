@@ -0,0 +1,53 @@
+// Package slogrotate integrates log/slog with logrotate.
+//
+// Handlers created by NewHandler format records with slog's built-in JSON or
+// text handlers and write each record into a logrotate.Writer as a single entry,
+// so records are never split across files.
+//
+//	w, err := logrotate.New(nil, logrotate.Options{Directory: "/var/log/app"})
+//	if err != nil {
+//		// handle err
+//	}
+//	logger := slog.New(slogrotate.NewHandler(w, nil))
+package slogrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/easyCZ/logrotate"
+	"log/slog"
+)
+
+// Options configure a Handler created by NewHandler.
+type Options struct {
+	// HandlerOptions are passed to the underlying slog handler.
+	slog.HandlerOptions
+
+	// Text selects slog's text format, records are formatted as JSON otherwise.
+	Text bool
+}
+
+// NewHandler returns a slog.Handler writing records into w.
+// When opts is nil, default options are used.
+//
+// slog reuses its formatting buffers once a record has been written,
+// logrotate.Writer copies each entry before returning from Write so
+// no additional copying or buffering is required.
+func NewHandler(w *logrotate.Writer, opts *Options) slog.Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if opts.Text {
+		return slog.NewTextHandler(w, &opts.HandlerOptions)
+	}
+	return slog.NewJSONHandler(w, &opts.HandlerOptions)
+}
+
+// Logger adapts l to logrotate.Logger, so that a Writer's own log lines,
+// such as failures to write files, are logged through slog at level.
+func Logger(l *slog.Logger, level slog.Level) logrotate.Logger {
+	return logrotate.LoggerFunc(func(format string, v ...interface{}) {
+		l.Log(context.Background(), level, fmt.Sprintf(format, v...))
+	})
+}
@@ -0,0 +1,76 @@
+package slogrotate
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewHandler(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := logrotate.New(nil, logrotate.Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return "app.log" },
+	})
+	require.NoError(t, err)
+
+	logger := slog.New(NewHandler(w, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("message", "writer", i, "payload", strings.Repeat("x", i*10))
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, w.Close())
+
+	f, err := os.Open(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record struct {
+			Msg     string
+			Writer  int
+			Payload string
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "must write intact records")
+		require.Equal(t, strings.Repeat("x", record.Writer*10), record.Payload, "must not corrupt reused buffers")
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, 1000, lines)
+}
+
+func TestNewHandler_Text(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := logrotate.New(nil, logrotate.Options{
+		Directory:    dir,
+		FileNameFunc: func() string { return "app.log" },
+	})
+	require.NoError(t, err)
+
+	logger := slog.New(NewHandler(w, &Options{Text: true}))
+	logger.Info("hello", "key", "value")
+	require.NoError(t, w.Close())
+
+	written, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(written), "msg=hello key=value")
+}
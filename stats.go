@@ -0,0 +1,154 @@
+package logrotate
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are counters describing the activity of a Writer since it was created.
+type Stats struct {
+	// BytesWritten is the number of bytes written to files.
+	BytesWritten int64
+	// EntriesWritten is the number of entries written to files.
+	EntriesWritten int64
+	// EntriesDropped is the number of entries which were not written to a file,
+	// either because they were rejected by Write or because writing them failed.
+	EntriesDropped int64
+	// EntriesFiltered is the number of entries discarded by Options.Filter.
+	EntriesFiltered int64
+	// EntriesRateLimited is the number of entries discarded by Options.RateLimit.
+	EntriesRateLimited int64
+	// DroppedByReason is the number of entries which were not written by
+	// reason, including the entries counted in EntriesFiltered and
+	// EntriesRateLimited, so that no entry is lost without being accounted for.
+	// Reasons without dropped entries are omitted.
+	DroppedByReason map[DropReason]int64
+	// Rotations is the number of files closed by a rotation, by size, manually
+	// or by time. A file exceeding MaximumLifetime while no entries are written
+	// is closed, and counted, before the next one is opened by the next entry.
+	// Closing the Writer is not a rotation.
+	Rotations int64
+
+	// CurrentFileSize is the size of the file currently being written to.
+	CurrentFileSize int64
+	// CurrentFileAge is the time elapsed since the current file was opened.
+	CurrentFileAge time.Duration
+
+	// QueueDepth is the number of entries awaiting to be written.
+	QueueDepth int
+	// QueueHighWatermark is the largest QueueDepth since the Writer was created,
+	// or since ResetQueueHighWatermark was last called, so that a queue nearing
+	// QueueSize is noticed although it drained by the time Stats is called.
+	QueueHighWatermark int
+}
+
+// Stats returns the current counters of the Writer.
+// Stats is safe to call concurrently.
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.DroppedByReason = make(map[DropReason]int64, len(w.stats.DroppedByReason))
+	for reason, n := range w.stats.DroppedByReason {
+		stats.DroppedByReason[reason] = n
+	}
+	if w.path != "" {
+		stats.CurrentFileSize = w.fileSize
+		stats.CurrentFileAge = w.now().Sub(w.fileOpened)
+	}
+	stats.QueueDepth = len(w.queue) + len(w.priority)
+	stats.QueueHighWatermark = int(atomic.LoadInt64(&w.queueHighWatermark))
+	if stats.QueueHighWatermark < stats.QueueDepth {
+		stats.QueueHighWatermark = stats.QueueDepth
+	}
+
+	return stats
+}
+
+// ResetQueueHighWatermark resets Stats.QueueHighWatermark to the current
+// queue depth, starting a new period, for example for a reader of the Stats
+// reporting the largest queue depth of each interval. The reset affects every
+// reader of the Stats.
+func (w *Writer) ResetQueueHighWatermark() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	atomic.StoreInt64(&w.queueHighWatermark, int64(len(w.queue)+len(w.priority)))
+}
+
+// recordDrop counts an entry which was not written for reason, err describes
+// the reason.
+func (w *Writer) recordDrop(reason DropReason, err error) {
+	w.mu.Lock()
+	w.stats.EntriesDropped++
+	w.stats.countDrop(reason)
+	w.mu.Unlock()
+
+	w.metrics.IncCounter(MetricEntriesDropped, 1)
+	w.metrics.IncCounter(droppedMetric(reason), 1)
+	w.emit(Event{Type: EventDropped, Err: err, DropReason: reason})
+}
+
+// recordFiltered counts an entry discarded by Options.Filter.
+func (w *Writer) recordFiltered() {
+	w.mu.Lock()
+	w.stats.EntriesFiltered++
+	w.stats.countDrop(DropFiltered)
+	w.mu.Unlock()
+
+	w.metrics.IncCounter(MetricEntriesFiltered, 1)
+	w.metrics.IncCounter(droppedMetric(DropFiltered), 1)
+}
+
+// recordRateLimited counts an entry discarded by Options.RateLimit.
+func (w *Writer) recordRateLimited() {
+	w.mu.Lock()
+	w.stats.EntriesRateLimited++
+	w.stats.countDrop(DropRateLimited)
+	w.mu.Unlock()
+
+	w.metrics.IncCounter(MetricEntriesRateLimited, 1)
+	w.metrics.IncCounter(droppedMetric(DropRateLimited), 1)
+}
+
+// recordOpen updates the current file statistics after a file has been opened.
+func (w *Writer) recordOpen(size int64) {
+	w.mu.Lock()
+	w.fileSize = size
+	w.fileOpened = w.now()
+	w.mu.Unlock()
+
+	w.metrics.SetGauge(MetricCurrentFileSize, float64(size))
+}
+
+// recordRotation counts a file closed due to rotation, which took d.
+func (w *Writer) recordRotation(event RotationEvent, d time.Duration) {
+	w.mu.Lock()
+	w.stats.Rotations++
+	w.lastRotationPath = event.Path
+	w.lastRotation = event.Closed
+	w.mu.Unlock()
+
+	w.metrics.IncCounter(MetricRotations, 1)
+	w.metrics.ObserveDuration(MetricRotationDuration, d)
+}
+
+// recordQueueDepth raises the queue high-watermark to the current depth of
+// the queue, and reports the depth to the metrics.
+func (w *Writer) recordQueueDepth() {
+	depth := int64(len(w.queue) + len(w.priority))
+	for {
+		high := atomic.LoadInt64(&w.queueHighWatermark)
+		if depth <= high || atomic.CompareAndSwapInt64(&w.queueHighWatermark, high, depth) {
+			break
+		}
+	}
+	w.metrics.SetGauge(MetricQueueDepth, float64(depth))
+	w.debugQueuePressure()
+}
+
+// recordPruned counts a file at path removed by a Manager.
+func (w *Writer) recordPruned(path string) {
+	w.metrics.IncCounter(MetricFilesRemoved, 1)
+	w.emit(Event{Type: EventPruned, Path: path})
+}
@@ -0,0 +1,88 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestWriter_Stats(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(logger, Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+	})
+	require.NoError(t, err)
+
+	for _, message := range []string{"12345", "12345", "123", "too large message"} {
+		w.Write([]byte(message))
+	}
+	require.NoError(t, w.Flush())
+
+	stats := w.Stats()
+	require.Equal(t, int64(13), stats.BytesWritten)
+	require.Equal(t, int64(3), stats.EntriesWritten)
+	require.Equal(t, int64(1), stats.EntriesDropped)
+	require.Equal(t, int64(1), stats.Rotations)
+	require.Equal(t, int64(3), stats.CurrentFileSize)
+	require.True(t, stats.CurrentFileAge > 0)
+	require.Equal(t, 0, stats.QueueDepth)
+
+	require.NoError(t, w.Close())
+}
+
+func TestWriter_QueueHighWatermark(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	filtering, unblock := make(chan struct{}, 1), make(chan struct{})
+	metrics := newRecordingMetrics()
+	w, err := New(logger, Options{
+		Directory: "logs",
+		FS:        newMemFS(),
+		Filter: func(b []byte) bool {
+			filtering <- struct{}{}
+			<-unblock
+			return true
+		},
+		Metrics: metrics,
+	})
+	require.NoError(t, err)
+
+	// The first entry is held by Filter, the others are queued up.
+	_, err = w.Write([]byte("entry\n"))
+	require.NoError(t, err)
+	<-filtering
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+	}
+	stats := w.Stats()
+	require.Equal(t, 3, stats.QueueDepth)
+	require.Equal(t, 3, stats.QueueHighWatermark)
+
+	close(unblock)
+	for i := 0; i < 3; i++ {
+		<-filtering
+	}
+	require.NoError(t, w.Flush())
+
+	stats = w.Stats()
+	require.Equal(t, 0, stats.QueueDepth)
+	require.Equal(t, 3, stats.QueueHighWatermark, "must keep the high-watermark once drained")
+	require.Equal(t, 3, w.Stats().QueueHighWatermark, "must not reset the high-watermark when read")
+	w.ResetQueueHighWatermark()
+	require.Equal(t, 0, w.Stats().QueueHighWatermark, "must reset the high-watermark to the queue depth")
+
+	metrics.mu.Lock()
+	require.Equal(t, float64(0), metrics.gauges[MetricQueueDepth])
+	metrics.mu.Unlock()
+
+	require.NoError(t, w.Close())
+}
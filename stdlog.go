@@ -0,0 +1,27 @@
+package logrotate
+
+import (
+	"io"
+	"log"
+)
+
+// NewStdLogger creates a Writer with opts and returns a *log.Logger writing into it,
+// with the given prefix and flags as in log.New:
+//
+//	logger, closer, err := logrotate.NewStdLogger(logrotate.Options{Directory: "/var/log/app"}, "", log.LstdFlags)
+//	if err != nil {
+//		// handle err
+//	}
+//	defer closer.Close()
+//
+// Entries are written in the background, closer must be closed before the
+// program exits so that all entries are written to the current file.
+// The Writer's own log lines, such as failures to write files, are discarded.
+func NewStdLogger(opts Options, prefix string, flags int) (*log.Logger, io.Closer, error) {
+	w, err := New(nil, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return log.New(w, prefix, flags), w, nil
+}
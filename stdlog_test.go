@@ -0,0 +1,33 @@
+package logrotate
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewStdLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logger, closer, err := NewStdLogger(Options{Directory: dir}, "app: ", 0)
+	require.NoError(t, err)
+
+	logger.Printf("message %d", 1)
+	logger.Print("message 2")
+	require.NoError(t, closer.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, "app: message 1\napp: message 2\n", string(b))
+
+	_, _, err = NewStdLogger(Options{}, "", log.LstdFlags)
+	require.Error(t, err)
+}
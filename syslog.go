@@ -0,0 +1,190 @@
+package logrotate
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SyslogOptions configure copying entries to a syslog daemon, see Options.Syslog.
+type SyslogOptions struct {
+	// Network is the network of the syslog daemon, "udp", "tcp", "unix" or "unixgram".
+	// When Network is empty, the local syslog daemon is used.
+	Network string
+	// Address is the address of the syslog daemon, for example "logs.example.com:514".
+	Address string
+
+	// Facility of the messages, see RFC 5424 section 6.2.1.
+	// When zero, which is reserved for kernel messages, 1 for user-level messages is used.
+	Facility int
+	// Severity of the messages, see RFC 5424 section 6.2.1.
+	// When zero, 6 for informational messages is used.
+	Severity int
+
+	// Hostname identifies the machine sending the messages. Defaults to os.Hostname.
+	Hostname string
+	// AppName identifies the application sending the messages. Defaults to the program name.
+	AppName string
+}
+
+const (
+	defaultSyslogFacility = 1
+	defaultSyslogSeverity = 6
+
+	// syslogTimeout bounds connecting and writing to the syslog daemon.
+	syslogTimeout = 5 * time.Second
+)
+
+// localSyslogPaths are the sockets a local syslog daemon commonly listens on.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+func (o SyslogOptions) validate() error {
+	switch o.Network {
+	case "":
+		if o.Address != "" {
+			return errors.New("Network must be set when Address is set")
+		}
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+		if o.Address == "" {
+			return errors.Errorf("Address must be set for network %s", o.Network)
+		}
+	default:
+		return errors.Errorf("unsupported Network %s", o.Network)
+	}
+	if o.Facility < 0 || o.Facility > 23 {
+		return errors.Errorf("Facility must be between 0 and 23, got %d", o.Facility)
+	}
+	if o.Severity < 0 || o.Severity > 7 {
+		return errors.Errorf("Severity must be between 0 and 7, got %d", o.Severity)
+	}
+	return nil
+}
+
+// syslogWriter writes each entry as an RFC 5424 message to a syslog daemon.
+// The connection is established on the first write, and again after a failure.
+type syslogWriter struct {
+	opts     SyslogOptions
+	priority int
+	procID   string
+	now      func() time.Time
+
+	conn net.Conn
+}
+
+func newSyslogWriter(opts SyslogOptions, now func() time.Time) *syslogWriter {
+	if opts.Facility == 0 {
+		opts.Facility = defaultSyslogFacility
+	}
+	if opts.Severity == 0 {
+		opts.Severity = defaultSyslogSeverity
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+
+	return &syslogWriter{
+		opts:     opts,
+		priority: opts.Facility*8 + opts.Severity,
+		procID:   strconv.Itoa(os.Getpid()),
+		now:      now,
+	}
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return 0, err
+		}
+		s.conn = conn
+	}
+
+	msg := appendRFC5424(nil, s.priority, s.now(), s.opts.Hostname, s.opts.AppName, s.procID, p)
+	if s.opts.Network == "tcp" || s.opts.Network == "tcp4" || s.opts.Network == "tcp6" {
+		// Octet counting framing, RFC 6587 section 3.4.1.
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return 0, errors.Wrap(err, "failed to write to syslog")
+	}
+	return len(p), nil
+}
+
+func (s *syslogWriter) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// dial connects to the configured syslog daemon, or to the local one.
+func (s *syslogWriter) dial() (net.Conn, error) {
+	if s.opts.Network != "" {
+		conn, err := net.DialTimeout(s.opts.Network, s.opts.Address, syslogTimeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to syslog at %s %s", s.opts.Network, s.opts.Address)
+		}
+		return conn, nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range localSyslogPaths {
+			if conn, err := net.DialTimeout(network, path, syslogTimeout); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, errors.New("failed to connect to local syslog, no syslog socket found")
+}
+
+// appendRFC5424 appends msg formatted as an RFC 5424 syslog message to b,
+// without structured data and message ID. A trailing newline of msg is removed.
+func appendRFC5424(b []byte, priority int, ts time.Time, hostname, appName, procID string, msg []byte) []byte {
+	for len(msg) > 0 && (msg[len(msg)-1] == '\n' || msg[len(msg)-1] == '\r') {
+		msg = msg[:len(msg)-1]
+	}
+
+	b = append(b, fmt.Sprintf("<%d>1 ", priority)...)
+	b = ts.AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00")
+	b = append(b, ' ')
+	b = appendSyslogField(b, hostname, 255)
+	b = append(b, ' ')
+	b = appendSyslogField(b, appName, 48)
+	b = append(b, ' ')
+	b = appendSyslogField(b, procID, 128)
+	b = append(b, " - - "...)
+	return append(b, msg...)
+}
+
+// appendSyslogField appends a header field, which must be printable ASCII
+// without spaces and at most max characters long, or "-" when empty.
+func appendSyslogField(b []byte, field string, max int) []byte {
+	if field == "" {
+		return append(b, '-')
+	}
+
+	n := 0
+	for i := 0; i < len(field) && n < max; i++ {
+		if c := field[i]; c > ' ' && c < 0x7f {
+			b = append(b, c)
+			n++
+		}
+	}
+	if n == 0 {
+		return append(b, '-')
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+package logrotate
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_Syslog(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	newWriter := func(t *testing.T, opts SyslogOptions) *Writer {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		w, err := New(logger, Options{
+			Directory: dir,
+			Syslog:    &opts,
+		})
+		require.NoError(t, err)
+		return w
+	}
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+
+		w := newWriter(t, SyslogOptions{
+			Network:  "udp",
+			Address:  conn.LocalAddr().String(),
+			Facility: 16,
+			Severity: 3,
+			Hostname: "host",
+			AppName:  "app",
+		})
+		_, err = w.Write([]byte("first message\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+
+		msg := string(buf[:n])
+		require.True(t, strings.HasPrefix(msg, "<131>1 "), msg)
+		require.True(t, strings.HasSuffix(msg, " host app "+strconv.Itoa(os.Getpid())+" - - first message"), msg)
+	})
+
+	t.Run("tcp uses octet counting", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			b, _ := ioutil.ReadAll(bufio.NewReader(conn))
+			received <- string(b)
+		}()
+
+		w := newWriter(t, SyslogOptions{Network: "tcp", Address: listener.Addr().String(), AppName: "app"})
+		for _, message := range []string{"first", "second"} {
+			_, err = w.Write([]byte(message))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		msgs := <-received
+		for _, message := range []string{"first", "second"} {
+			i := strings.Index(msgs, " ")
+			size := atoi(t, msgs[:i])
+			msg := msgs[i+1 : i+1+size]
+			require.True(t, strings.HasPrefix(msg, "<14>1 "), msg)
+			require.True(t, strings.HasSuffix(msg, " - - "+message), msg)
+			msgs = msgs[i+1+size:]
+		}
+		require.Empty(t, msgs)
+	})
+
+	t.Run("unavailable syslog does not affect files", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		w := newWriter(t, SyslogOptions{Network: "tcp", Address: address})
+		for i := 0; i < 10; i++ {
+			_, err = w.Write([]byte("message\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		require.Equal(t, int64(10), w.Stats().EntriesWritten)
+		require.Equal(t, int64(10), w.tees[0].dropped)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, opts := range []SyslogOptions{
+			{Network: "udp"},
+			{Address: "localhost:514"},
+			{Network: "http", Address: "localhost:514"},
+			{Facility: 24},
+			{Severity: -1},
+		} {
+			require.Error(t, Options{Directory: "logs", Syslog: &opts}.Validate(), "%+v", opts)
+		}
+	})
+}
+
+func TestAppendRFC5424(t *testing.T) {
+	ts := time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC)
+
+	msg := appendRFC5424(nil, 165, ts, "my host", "", "42", []byte("message\n"))
+	require.Equal(t, "<165>1 2003-10-11T22:14:15.003000Z myhost - 42 - - message", string(msg))
+}
+
+func atoi(t *testing.T, s string) int {
+	i, err := strconv.Atoi(s)
+	require.NoError(t, err)
+	return i
+}
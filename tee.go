@@ -0,0 +1,137 @@
+package logrotate
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// tee copies entries to a secondary destination, such as syslog, in the background.
+// Each tee has its own queue and goroutine, so a slow or failing destination
+// does not slow down writing files.
+type tee struct {
+	// name identifies the destination in log lines
+	name string
+	w    io.Writer
+	// lossless makes send block while the queue is full, instead of dropping the entry
+	lossless bool
+	// closer, when set, is called once all entries have been written
+	closer func() error
+
+	logger Logger
+	queue  chan teeEntry
+	done   chan struct{}
+
+	// dropped is the number of entries which were not written to w
+	dropped int64
+}
+
+// teeEntry is an item in a tee's queue, either an entry or a flush request.
+type teeEntry struct {
+	b []byte
+	// flushed, when set, is closed once all previously queued entries have been written
+	flushed chan struct{}
+}
+
+// start starts writing queued entries in the background.
+func (t *tee) start(logger Logger, queueSize int) *tee {
+	t.logger = logger
+	t.queue = make(chan teeEntry, queueSize)
+	t.done = make(chan struct{})
+
+	go t.run()
+
+	return t
+}
+
+// send queues a copy of b. Unless the tee is lossless, b is dropped when the queue is full.
+func (t *tee) send(b []byte) {
+	e := teeEntry{b: append([]byte(nil), b...)}
+	if t.lossless {
+		t.queue <- e
+		return
+	}
+
+	select {
+	case t.queue <- e:
+	default:
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+// flush blocks until all entries queued so far have been written.
+func (t *tee) flush() {
+	flushed := make(chan struct{})
+	t.queue <- teeEntry{flushed: flushed}
+	<-flushed
+}
+
+// run writes queued entries until the queue is closed.
+// Failures are logged once, until an entry is written successfully again.
+func (t *tee) run() {
+	defer close(t.done)
+
+	failing := false
+	for e := range t.queue {
+		if e.flushed != nil {
+			close(e.flushed)
+			continue
+		}
+
+		if _, err := t.w.Write(e.b); err != nil {
+			atomic.AddInt64(&t.dropped, 1)
+			if !failing {
+				t.logger.Printf("Failed to write to %s, dropping entries until it recovers: %v", t.name, err)
+				failing = true
+			}
+			continue
+		}
+
+		if failing {
+			t.logger.Printf("Writing to %s recovered, %d entries dropped so far", t.name, atomic.LoadInt64(&t.dropped))
+			failing = false
+		}
+	}
+
+	if t.closer != nil {
+		if err := t.closer(); err != nil {
+			t.logger.Printf("Failed to close %s: %v", t.name, err)
+		}
+	}
+}
+
+// close stops the tee once all queued entries have been written,
+// waiting for them to be written when wait is true.
+func (t *tee) close(wait bool) {
+	close(t.queue)
+	if wait {
+		<-t.done
+	}
+}
+
+// sendTees copies b to all tees.
+func (w *Writer) sendTees(b []byte) {
+	for _, t := range w.tees {
+		t.send(b)
+	}
+}
+
+// flushTees blocks until lossless tees have written all entries queued so far.
+// Lossy tees, such as syslog, are not waited for so that an unavailable
+// destination does not block Flush.
+func (w *Writer) flushTees() {
+	for _, t := range w.tees {
+		if t.lossless {
+			t.flush()
+		}
+	}
+}
+
+// closeTees stops all tees and the webhook, waiting for queued entries unless the Writer was aborted.
+func (w *Writer) closeTees() {
+	for _, t := range w.tees {
+		t.close(!w.aborted())
+	}
+	if w.webhook != nil {
+		w.webhook.close(!w.aborted())
+	}
+}
@@ -0,0 +1,60 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// closeTracker is a bytes.Buffer recording whether it was closed.
+type closeTracker struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriter_TeeTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	console := &closeTracker{}
+	w, err := New(log.New(os.Stderr, "", log.LstdFlags), Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+		QueueSize:       1,
+		TeeTo:           console,
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.Equal(t, "first\n", console.String(), "Flush must wait for TeeTo")
+
+	for i := 0; i < 100; i++ {
+		_, err = w.Write([]byte("message\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.False(t, console.closed, "TeeTo must not be closed")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	var contents []byte
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		contents = append(contents, b...)
+	}
+	require.Len(t, contents, console.Len(), "entries must not be dropped from TeeTo")
+	require.Equal(t, 1+100, bytes.Count(console.Bytes(), []byte("\n")))
+}
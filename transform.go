@@ -0,0 +1,84 @@
+package logrotate
+
+import (
+	"regexp"
+	"time"
+)
+
+// ChainTransforms returns a Transform applying each of fns in order,
+// see Options.Transform.
+func ChainTransforms(fns ...func(b []byte) []byte) func(b []byte) []byte {
+	return func(b []byte) []byte {
+		for _, fn := range fns {
+			b = fn(b)
+		}
+		return b
+	}
+}
+
+// RedactRegexp returns a Transform replacing matches of re with replacement,
+// which may refer to submatches as in regexp.Regexp.Expand, for example to
+// scrub secrets or personal data, see Options.Transform.
+func RedactRegexp(re *regexp.Regexp, replacement string) func(b []byte) []byte {
+	repl := []byte(replacement)
+	return func(b []byte) []byte {
+		return re.ReplaceAll(b, repl)
+	}
+}
+
+// timestamp prefixes b with ts, formatted with Options.TimestampFormat, if set.
+// Entries which were not timestamped by Write are timestamped now.
+func (w *Writer) timestamp(b []byte, ts time.Time) []byte {
+	layout := w.opts.TimestampFormat
+	if layout == "" {
+		return b
+	}
+	if ts.IsZero() {
+		ts = w.now()
+	}
+	prefixed := make([]byte, 0, len(layout)+len(b)+16)
+	prefixed = ts.UTC().AppendFormat(prefixed, layout)
+	prefixed = append(prefixed, ' ')
+	return append(prefixed, b...)
+}
+
+// terminate appends a newline to b if it lacks one, with Options.EnsureNewline.
+func (w *Writer) terminate(b []byte) []byte {
+	if !w.opts.EnsureNewline || len(b) == 0 || b[len(b)-1] == '\n' {
+		return b
+	}
+	return append(b, '\n')
+}
+
+// crlf converts the line endings of b to CRLF, with Options.CRLF.
+// Line endings which already are CRLF are left as they are.
+func (w *Writer) crlf(b []byte) []byte {
+	if !w.opts.CRLF {
+		return b
+	}
+	n := 0
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			n++
+		}
+	}
+	if n == 0 {
+		return b
+	}
+	converted := make([]byte, 0, len(b)+n)
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			converted = append(converted, '\r')
+		}
+		converted = append(converted, c)
+	}
+	return converted
+}
+
+// transform applies Options.Transform to an entry, if set.
+func (w *Writer) transform(b []byte) []byte {
+	if w.opts.Transform == nil {
+		return b
+	}
+	return w.opts.Transform(b)
+}
@@ -0,0 +1,131 @@
+package logrotate
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWriter_Transform(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	t.Run("redacts entries before they are written", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var tee bytes.Buffer
+		w, err := NewWithOptions(logger,
+			WithDirectory(dir),
+			WithFileNameFunc(func() string { return "app.log" }),
+			WithTeeTo(&tee),
+			WithTransform(
+				RedactRegexp(regexp.MustCompile(`password=\S+`), "password=REDACTED"),
+				RedactRegexp(regexp.MustCompile(`(\w+)@example\.com`), "${1}@..."),
+				bytes.ToUpper,
+			),
+		)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("login jane@example.com password=hunter2\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		expected := "LOGIN JANE@... PASSWORD=REDACTED\n"
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(b))
+		require.Equal(t, expected, tee.String())
+		require.Equal(t, int64(len(expected)), w.Stats().BytesWritten)
+	})
+
+	t.Run("terminates entries with a newline", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var seen []string
+		w, err := NewWithOptions(logger,
+			WithDirectory(dir),
+			WithFileNameFunc(func() string { return "app.log" }),
+			WithEnsureNewline(),
+			WithTransform(func(b []byte) []byte {
+				seen = append(seen, string(b))
+				return b
+			}),
+		)
+		require.NoError(t, err)
+		for _, msg := range []string{"first", "second\n", "", "third"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\nthird\n", string(b))
+		require.Equal(t, []string{"first\n", "second\n", "", "third\n"}, seen, "newlines must be appended before Transform")
+	})
+
+	t.Run("prefixes entries with timestamps", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		clock := newFakeClock()
+		w, err := New(logger, Options{
+			Directory:       dir,
+			FileNameFunc:    func() string { return "app.log" },
+			Now:             clock.Now,
+			TimestampFormat: time.RFC3339Nano,
+			EnsureNewline:   true,
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("first"))
+		require.NoError(t, err)
+		clock.Advance(1500 * time.Millisecond)
+		_, err = w.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "2020-03-28T15:00:00Z first\n2020-03-28T15:00:01.5Z second\n", string(b))
+	})
+
+	t.Run("converts line endings to CRLF", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var tee bytes.Buffer
+		w, err := New(logger, Options{
+			Directory:     dir,
+			FileNameFunc:  func() string { return "app.log" },
+			EnsureNewline: true,
+			CRLF:          true,
+			TeeTo:         &tee,
+		})
+		require.NoError(t, err)
+		for _, msg := range []string{"first", "second\r\n", "multi\nline\n", "\n"} {
+			_, err := w.Write([]byte(msg))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		require.Equal(t, "first\r\nsecond\r\nmulti\r\nline\r\n\r\n", string(b))
+		require.Equal(t, "first\nsecond\r\nmulti\nline\n\n", tee.String(), "tees must receive entries unchanged")
+	})
+
+	t.Run("rejects nil transforms", func(t *testing.T) {
+		_, err := NewWithOptions(logger, WithDirectory("logs"), WithTransform(nil))
+		require.Error(t, err)
+	})
+}
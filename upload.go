@@ -0,0 +1,422 @@
+package logrotate
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMeta describes a file which will not be written to again, passed to Uploader.
+type FileMeta struct {
+	// Name is the name of the file within Directory.
+	Name string
+	// Size is the size of the file in bytes.
+	Size int64
+	// Opened and Closed are the times the file was opened and closed.
+	Opened time.Time
+	Closed time.Time
+	// SHA256 is the hex encoded SHA-256 of the file when Options.Checksum is set,
+	// and empty otherwise.
+	SHA256 string
+}
+
+// Uploader uploads closed files to a remote destination, such as object storage.
+type Uploader interface {
+	// Upload uploads the file at localPath. The file is not modified while
+	// Upload runs. ctx is cancelled when the Writer is closed and the context
+	// passed to CloseContext is done.
+	Upload(ctx context.Context, localPath string, meta FileMeta) error
+}
+
+// UploadFailureEvent describes a file which failed to upload after all retries.
+type UploadFailureEvent struct {
+	// Path is the path the file was uploaded from.
+	Path string
+	// MovedTo is the path of the file within FailedUploadDirectory,
+	// empty when the file was not moved.
+	MovedTo string
+	// Meta describes the file.
+	Meta FileMeta
+	// Err is the error of the last attempt.
+	Err error
+}
+
+// upload is a file awaiting to be uploaded.
+type upload struct {
+	path string
+	meta FileMeta
+	// sidecars are the paths of the checksum and metadata sidecars, if any,
+	// uploaded after the file
+	sidecars []string
+	// remove, when set, removes the file and its sidecars once they have been uploaded
+	remove func(path string) error
+}
+
+// paths returns the path of the file followed by the paths of its sidecars.
+func (up upload) paths() []string {
+	return append([]string{up.path}, up.sidecars...)
+}
+
+// uploads uploads closed files in the background. Files are started in the order
+// they were closed, by up to Options.UploadConcurrency workers.
+type uploads struct {
+	uploader Uploader
+	// files bounds the files opened by uploader, see Options.MaximumOpenFiles
+	files    fileLimit
+	retry    RetryPolicy
+	manifest *manifest
+	// failedDir, when set, receives files which failed to upload
+	failedDir string
+	onFailure func(e UploadFailureEvent)
+	fs        FS
+	dirMode   os.FileMode
+	logger    Logger
+	report    func(error)
+	emit      func(Event)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// cond is signalled when pending or closed change
+	cond    *sync.Cond
+	pending []upload
+	closed  bool
+
+	// scheduler, when set, runs the workers instead of dedicated goroutines,
+	// running is then the number of workers started, up to concurrency
+	scheduler   *Scheduler
+	concurrency int
+	running     int
+
+	workers sync.WaitGroup
+}
+
+// newUploads starts uploading files in the background, uploads in progress
+// are cancelled and remaining files are not uploaded once abort is closed.
+func newUploads(opts Options, m *manifest, files fileLimit, logger Logger, report func(error), emit func(Event), abort <-chan struct{}) *uploads {
+	ctx, cancel := context.WithCancel(context.Background())
+	if opts.UploadBandwidth > 0 {
+		ctx = context.WithValue(ctx, bandwidthLimiterKey{}, &bandwidthLimiter{rate: opts.UploadBandwidth})
+	}
+	u := &uploads{
+		uploader:  opts.Uploader,
+		files:     files,
+		retry:     opts.UploadRetry,
+		manifest:  m,
+		onFailure: opts.OnUploadFailure,
+		fs:        opts.FS,
+		dirMode:   opts.DirMode,
+		logger:    logger,
+		report:    report,
+		emit:      emit,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	u.cond = sync.NewCond(&u.mu)
+	if dir := opts.FailedUploadDirectory; dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(opts.Directory, dir)
+		}
+		u.failedDir = dir
+	}
+
+	concurrency := opts.UploadConcurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	if opts.Scheduler != nil {
+		u.scheduler = opts.Scheduler
+		u.concurrency = concurrency
+	} else {
+		u.workers.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go u.run()
+		}
+	}
+	go func() {
+		select {
+		case <-abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return u
+}
+
+// schedule records a file in the manifest and queues it to be uploaded, without blocking.
+func (u *uploads) schedule(up upload) {
+	if err := u.manifest.close(up.path, up.meta); err != nil {
+		u.logger.Printf("Failed to record %s in upload manifest: %v", up.path, err)
+		u.report(err)
+	}
+	u.enqueue(up)
+}
+
+// enqueue queues a file to be uploaded, without blocking.
+func (u *uploads) enqueue(up upload) {
+	u.mu.Lock()
+	u.pending = append(u.pending, up)
+	start := u.scheduler != nil && u.running < u.concurrency
+	if start {
+		u.running++
+		u.workers.Add(1)
+	}
+	u.mu.Unlock()
+
+	if start {
+		u.scheduler.submit(u.drain)
+	}
+	u.cond.Signal()
+}
+
+// next returns the next file to upload, waiting for one unless the uploads are closed.
+func (u *uploads) next() (upload, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for len(u.pending) == 0 {
+		if u.closed {
+			return upload{}, false
+		}
+		u.cond.Wait()
+	}
+
+	up := u.pending[0]
+	u.pending = u.pending[1:]
+	return up, true
+}
+
+func (u *uploads) run() {
+	defer u.workers.Done()
+
+	for {
+		up, ok := u.next()
+		if !ok {
+			return
+		}
+		u.process(up)
+	}
+}
+
+// drain uploads pending files until there are none left, on a worker of the Scheduler.
+func (u *uploads) drain() {
+	defer u.workers.Done()
+
+	for {
+		u.mu.Lock()
+		if len(u.pending) == 0 {
+			u.running--
+			u.mu.Unlock()
+			return
+		}
+		up := u.pending[0]
+		u.pending = u.pending[1:]
+		u.mu.Unlock()
+
+		u.process(up)
+	}
+}
+
+// process uploads a file and its sidecars, removing them afterwards if requested.
+func (u *uploads) process(up upload) {
+	if u.ctx.Err() != nil {
+		return
+	}
+
+	if err := u.uploadWithSidecars(up); err != nil {
+		u.fail(up, err)
+		return
+	}
+	u.emit(Event{Type: EventUploaded, Path: up.path})
+
+	if up.remove != nil {
+		for _, path := range up.paths() {
+			if err := up.remove(path); err != nil {
+				u.logger.Printf("Failed to remove uploaded file %s: %v", path, err)
+				u.report(errors.Wrapf(err, "failed to remove uploaded file %s", path))
+			}
+		}
+	}
+	if err := u.manifest.remove(up.path); err != nil {
+		u.logger.Printf("Failed to remove %s from upload manifest: %v", up.path, err)
+		u.report(err)
+	}
+}
+
+// fail handles a file which failed to upload, moving it to the failed upload directory.
+// Cancelled uploads are kept in place and resumed by the next Writer, see Options.UploadManifest.
+func (u *uploads) fail(up upload, err error) {
+	u.report(errors.Wrapf(err, "failed to upload %s", up.path))
+
+	if u.ctx.Err() != nil {
+		u.logger.Printf("Upload of %s cancelled, keeping it locally: %v", up.path, err)
+		return
+	}
+	if u.failedDir == "" {
+		u.logger.Printf("Failed to upload %s, keeping it locally: %v", up.path, err)
+		u.notifyFailure(UploadFailureEvent{Path: up.path, Meta: up.meta, Err: err})
+		return
+	}
+
+	movedTo := filepath.Join(u.failedDir, filepath.Base(up.path))
+	if moveErr := u.moveFailed(up, movedTo); moveErr != nil {
+		u.logger.Printf("Failed to upload %s, keeping it locally: %v, failed to move it to %s: %v", up.path, err, u.failedDir, moveErr)
+		u.report(moveErr)
+		u.notifyFailure(UploadFailureEvent{Path: up.path, Meta: up.meta, Err: err})
+		return
+	}
+
+	u.logger.Printf("Failed to upload %s, moved it to %s: %v", up.path, movedTo, err)
+	if err := u.manifest.remove(up.path); err != nil {
+		u.logger.Printf("Failed to remove %s from upload manifest: %v", up.path, err)
+		u.report(err)
+	}
+	u.notifyFailure(UploadFailureEvent{Path: up.path, MovedTo: movedTo, Meta: up.meta, Err: err})
+}
+
+// moveFailed moves a file which failed to upload, and its sidecars, into the failed upload directory.
+func (u *uploads) moveFailed(up upload, movedTo string) error {
+	if err := u.fs.MkdirAll(u.failedDir, u.dirMode); err != nil {
+		return errors.Wrapf(err, "failed to create failed upload directory %s", u.failedDir)
+	}
+	if err := u.fs.Rename(up.path, movedTo); err != nil {
+		return errors.Wrapf(err, "failed to move %s to %s", up.path, movedTo)
+	}
+	for _, sidecar := range up.sidecars {
+		if err := u.fs.Rename(sidecar, movedTo+strings.TrimPrefix(sidecar, up.path)); err != nil {
+			u.logger.Printf("Failed to move sidecar %s: %v", sidecar, err)
+		}
+	}
+	return nil
+}
+
+func (u *uploads) notifyFailure(e UploadFailureEvent) {
+	if u.onFailure != nil {
+		u.onFailure(e)
+	}
+}
+
+// uploadWithSidecars uploads a file followed by its sidecars, if any.
+func (u *uploads) uploadWithSidecars(up upload) error {
+	if err := u.upload(up.path, up.meta); err != nil {
+		return err
+	}
+
+	for _, sidecar := range up.sidecars {
+		meta := FileMeta{Name: filepath.Base(sidecar), Opened: up.meta.Opened, Closed: up.meta.Closed}
+		info, err := u.fs.Stat(sidecar)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat sidecar %s", sidecar)
+		}
+		meta.Size = info.Size()
+		if err := u.upload(sidecar, meta); err != nil {
+			return errors.Wrapf(err, "failed to upload sidecar %s", sidecar)
+		}
+	}
+	return nil
+}
+
+// upload uploads a file, retrying failed attempts according to the retry policy
+// until the uploads are cancelled. The error of the last attempt is returned.
+func (u *uploads) upload(path string, meta FileMeta) error {
+	for attempt := 1; ; attempt++ {
+		err := u.files.upload(u.ctx, u.uploader, path, meta)
+		if err == nil || attempt >= u.retry.MaximumAttempts || u.ctx.Err() != nil {
+			return err
+		}
+
+		u.logger.Printf("Failed to upload %s, retrying: %v", path, err)
+		t := time.NewTimer(u.retry.backoff(attempt))
+		select {
+		case <-t.C:
+		case <-u.ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
+
+// close waits for all scheduled uploads to finish, or to be cancelled.
+func (u *uploads) close() {
+	u.mu.Lock()
+	u.closed = true
+	u.mu.Unlock()
+	u.cond.Broadcast()
+
+	u.workers.Wait()
+	u.cancel()
+}
+
+// resumeUploads queues the files of the upload manifest which were not uploaded
+// before the Writer was last closed or the process stopped.
+func (w *Writer) resumeUploads() error {
+	pending, err := w.uploads.manifest.pending(os.Stat)
+	for _, up := range pending {
+		w.logger.Printf("Resuming upload of %s", up.path)
+		if w.opts.DeleteAfterUpload {
+			up.remove = w.opts.FS.Remove
+		}
+		w.uploads.enqueue(up)
+	}
+	return err
+}
+
+// trackUpload records the current file in the upload manifest, if an Uploader is configured.
+func (w *Writer) trackUpload() {
+	if w.uploads == nil {
+		return
+	}
+	if err := w.uploads.manifest.open(w.path, w.ts); err != nil {
+		w.logger.Printf("Failed to record %s in upload manifest: %v", w.path, err)
+		w.report(err)
+	}
+}
+
+// scheduleUpload queues the file described by e for upload, if an Uploader is configured.
+func (w *Writer) scheduleUpload(e FileCloseEvent) {
+	if w.uploads == nil || e.Err != nil || e.Path == "" {
+		return
+	}
+
+	up := upload{
+		path: e.Path,
+		meta: FileMeta{
+			Name:   filepath.Base(e.Path),
+			Size:   e.Size,
+			Opened: e.Opened,
+			Closed: e.Closed,
+			SHA256: e.SHA256,
+		},
+	}
+	if e.SHA256 != "" {
+		up.sidecars = append(up.sidecars, e.Path+ChecksumSuffix)
+	}
+	if w.opts.Metadata {
+		if _, err := w.opts.FS.Stat(e.Path + MetadataSuffix); err == nil {
+			up.sidecars = append(up.sidecars, e.Path+MetadataSuffix)
+		}
+	}
+	if w.opts.DeleteAfterUpload {
+		up.remove = w.opts.FS.Remove
+	}
+
+	w.uploads.schedule(up)
+}
+
+// awaitingUpload reports whether the file at path has not been uploaded yet.
+func (w *Writer) awaitingUpload(path string) bool {
+	return w.uploads != nil && w.uploads.manifest.contains(path)
+}
+
+// closeUploads waits for scheduled uploads, which are cancelled if the Writer is aborted.
+func (w *Writer) closeUploads() {
+	if w.uploads != nil {
+		w.uploads.close()
+	}
+}
@@ -0,0 +1,258 @@
+package logrotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uploaderFunc adapts a function to the Uploader interface.
+type uploaderFunc func(ctx context.Context, localPath string, meta FileMeta) error
+
+func (f uploaderFunc) Upload(ctx context.Context, localPath string, meta FileMeta) error {
+	return f(ctx, localPath, meta)
+}
+
+// logFiles returns the names of the files in dir, except for the upload manifest.
+func logFiles(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, info := range infos {
+		if info.Name() != DefaultUploadManifest {
+			names = append(names, info.Name())
+		}
+	}
+	return names
+}
+
+func TestWriter_Uploader(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	// newWriter creates a Writer with opts, rotating after each entry.
+	newWriter := func(t *testing.T, opts Options) (*Writer, string) {
+		dir, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		i := 0
+		opts.Directory = dir
+		opts.MaximumFileSize = 10
+		opts.FileNameFunc = func() string {
+			i++
+			return fmt.Sprintf("%d.log", i)
+		}
+		w, err := New(logger, opts)
+		require.NoError(t, err)
+		return w, dir
+	}
+
+	write := func(t *testing.T, w *Writer, n int) {
+		for i := 0; i < n; i++ {
+			_, err := w.Write([]byte("message\n"))
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("uploads closed files in order", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			uploaded []FileMeta
+			contents []string
+		)
+		w, dir := newWriter(t, Options{Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+			b, err := ioutil.ReadFile(localPath)
+			require.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			uploaded = append(uploaded, meta)
+			contents = append(contents, string(b))
+			return nil
+		})})
+
+		write(t, w, 3)
+		require.NoError(t, w.Close())
+
+		require.Len(t, uploaded, 3, "the last file must be uploaded on Close")
+		for i, meta := range uploaded {
+			require.Equal(t, fmt.Sprintf("%d.log", i+1), meta.Name)
+			require.Equal(t, int64(8), meta.Size)
+			require.False(t, meta.Closed.Before(meta.Opened))
+			require.Equal(t, "message\n", contents[i])
+		}
+
+		require.Equal(t, []string{"1.log", "2.log", "3.log"}, logFiles(t, dir), "files must be kept")
+	})
+
+	t.Run("deletes uploaded files", func(t *testing.T) {
+		w, dir := newWriter(t, Options{
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				if meta.Name == "2.log" {
+					return errors.New("upload failed")
+				}
+				return nil
+			}),
+			DeleteAfterUpload: true,
+		})
+
+		write(t, w, 3)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"2.log"}, logFiles(t, dir), "only the file which failed to upload must be kept")
+
+		var errs []error
+		for err := range w.Errors() {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Error(), filepath.Join(dir, "2.log"))
+	})
+
+	t.Run("moves failed uploads to the failed upload directory", func(t *testing.T) {
+		var events []UploadFailureEvent
+		w, dir := newWriter(t, Options{
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				if meta.Name == "2.log" {
+					return errors.New("upload failed")
+				}
+				return nil
+			}),
+			DeleteAfterUpload:     true,
+			FailedUploadDirectory: "failed",
+			OnUploadFailure: func(e UploadFailureEvent) {
+				events = append(events, e)
+			},
+		})
+
+		write(t, w, 3)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, []string{"failed"}, logFiles(t, dir))
+		require.Equal(t, []string{"2.log"}, logFiles(t, filepath.Join(dir, "failed")))
+
+		require.Len(t, events, 1)
+		require.Equal(t, filepath.Join(dir, "2.log"), events[0].Path)
+		require.Equal(t, filepath.Join(dir, "failed", "2.log"), events[0].MovedTo)
+		require.Equal(t, "2.log", events[0].Meta.Name)
+		require.EqualError(t, events[0].Err, "upload failed")
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, DefaultUploadManifest))
+		require.NoError(t, err)
+		require.Equal(t, "[]", string(b), "moved files must not be uploaded by the next Writer")
+	})
+
+	t.Run("CloseContext cancels uploads", func(t *testing.T) {
+		started := make(chan struct{}, 1)
+		w, _ := newWriter(t, Options{Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		})})
+
+		write(t, w, 1)
+		require.NoError(t, w.Rotate())
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.Error(t, w.CloseContext(ctx))
+
+		select {
+		case <-w.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("uploads must be cancelled")
+		}
+	})
+
+	t.Run("retries failed uploads", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			attempts = map[string]int{}
+		)
+		w, dir := newWriter(t, Options{
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts[meta.Name]++
+				if meta.Name == "1.log" && attempts[meta.Name] < 3 {
+					return errors.New("upload failed")
+				}
+				if meta.Name == "2.log" {
+					return errors.New("upload failed")
+				}
+				return nil
+			}),
+			UploadRetry:       RetryPolicy{MaximumAttempts: 3, InitialBackoff: time.Millisecond},
+			DeleteAfterUpload: true,
+		})
+
+		write(t, w, 2)
+		require.NoError(t, w.Close())
+
+		require.Equal(t, map[string]int{"1.log": 3, "2.log": 3}, attempts)
+		require.Equal(t, []string{"2.log"}, logFiles(t, dir))
+	})
+
+	t.Run("uploads concurrently", func(t *testing.T) {
+		// Each upload waits for the other one to start, which only completes when
+		// both files are uploaded at the same time.
+		var started sync.WaitGroup
+		started.Add(2)
+		w, _ := newWriter(t, Options{
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				started.Done()
+				started.Wait()
+				return nil
+			}),
+			UploadConcurrency: 2,
+		})
+
+		write(t, w, 1)
+		require.NoError(t, w.Rotate())
+		write(t, w, 1)
+
+		closed := make(chan error, 1)
+		go func() { closed <- w.Close() }()
+		select {
+		case err := <-closed:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("files must be uploaded concurrently")
+		}
+	})
+
+	t.Run("limits upload bandwidth", func(t *testing.T) {
+		w, dir := newWriter(t, Options{
+			UploadBandwidth: 20,
+			Uploader: uploaderFunc(func(ctx context.Context, localPath string, meta FileMeta) error {
+				f, err := os.Open(localPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = io.Copy(ioutil.Discard, UploadReader(ctx, f))
+				return err
+			}),
+			DeleteAfterUpload: true,
+		})
+
+		start := time.Now()
+		write(t, w, 2)
+		require.NoError(t, w.Close())
+
+		// The files share the limit, 16 bytes take 800ms at 20 bytes per second.
+		require.True(t, time.Since(start) >= 700*time.Millisecond, "uploads must be limited, took %v", time.Since(start))
+		require.Empty(t, logFiles(t, dir))
+	})
+}
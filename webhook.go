@@ -0,0 +1,88 @@
+package logrotate
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WebhookOptions configure notifying an HTTP endpoint of closed files, see Options.Webhook.
+type WebhookOptions struct {
+	// URL receives a POST request for each closed file. Required.
+	URL string
+
+	// Headers are added to each request, for example for authentication.
+	Headers map[string]string
+
+	// Client sends requests. When Client is nil, a client with a 10s timeout is used.
+	Client *http.Client
+}
+
+// webhookTimeout bounds requests sent with the default client.
+const webhookTimeout = 10 * time.Second
+
+func (o WebhookOptions) validate() error {
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		return errors.Wrap(err, "invalid URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("URL must be an http or https URL, got %q", o.URL)
+	}
+	return nil
+}
+
+// webhookWriter posts each payload written to it to the webhook URL.
+type webhookWriter struct {
+	opts WebhookOptions
+}
+
+func newWebhookWriter(opts WebhookOptions) *webhookWriter {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: webhookTimeout}
+	}
+	return &webhookWriter{opts: opts}
+}
+
+func (h *webhookWriter) Write(p []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.opts.URL, bytes.NewReader(p))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.opts.Client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to send webhook request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, errors.Errorf("webhook responded with %s", resp.Status)
+	}
+	return len(p), nil
+}
+
+// notifyWebhook queues a notification of a closed file, if a webhook is configured.
+// The payload has the same fields as the lines of the rotation manifest.
+func (w *Writer) notifyWebhook(e FileCloseEvent) {
+	if w.webhook == nil {
+		return
+	}
+
+	b, err := json.Marshal(newRotationRecord(e))
+	if err != nil {
+		w.logger.Printf("Failed to encode webhook payload: %v", err)
+		return
+	}
+	w.webhook.send(b)
+}
@@ -0,0 +1,77 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriter_Webhook(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		mu       sync.Mutex
+		payloads []rotationRecord
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var payload rotationRecord
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	i := 0
+	w, err := New(logger, Options{
+		Directory:       dir,
+		MaximumFileSize: 10,
+		Webhook: &WebhookOptions{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		},
+		FileNameFunc: func() string {
+			i++
+			return fmt.Sprintf("%d.log", i)
+		},
+	})
+	require.NoError(t, err)
+
+	for j := 0; j < 2; j++ {
+		_, err := w.Write([]byte("message\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	require.Len(t, payloads, 2, "Close must wait for notifications")
+	for j, payload := range payloads {
+		name := fmt.Sprintf("%d.log", j+1)
+		require.Equal(t, filepath.Join(dir, name), payload.Path)
+		require.Equal(t, name, payload.Name)
+		require.Equal(t, int64(8), payload.Bytes)
+		require.Equal(t, int64(1), payload.Entries)
+		require.False(t, payload.Closed.Before(payload.Opened))
+	}
+}
+
+func TestWebhookOptions_Validate(t *testing.T) {
+	for _, opts := range []WebhookOptions{{}, {URL: "ftp://example.com"}, {URL: "://"}} {
+		require.Error(t, Options{Directory: "logs", Webhook: &opts}.Validate(), opts.URL)
+	}
+}
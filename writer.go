@@ -2,9 +2,11 @@ package logrotate
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
-	"log"
+	"hash"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -19,16 +21,32 @@ func DefaultFilenameFunc() string {
 type Options struct {
 	// Directory defines the directory where log files will be written to.
 	// If the directory does not exist, it will be created.
+	// Directory cannot be changed with SetOptions.
 	Directory string
 
 	// MaximumFileSize defines the maximum size of each log file in bytes.
+	// Sizes are accounted as int64 on all platforms, including 32-bit ones.
 	// When MaximumFileSize == 0, no upper bound will be enforced.
 	// No file will be greater than MaximumFileSize. A Write() which would
-	// exceed MaximumFileSize will instead cause a new file to be created.
+	// exceed MaximumFileSize will instead cause a new file to be created,
+	// rotation only happens between entries, an entry is always written whole
+	// into a single file.
 	// If a Write() is attempting to write more bytes than specified by
-	// MaximumFileSize, the write will be skipped.
+	// MaximumFileSize, the write will be rejected with ErrEntryTooLarge.
 	MaximumFileSize int64
 
+	// MaxEntrySize, when set, is the maximum size of entries in bytes, so that
+	// an accidental large write cannot exhaust memory, since Write copies entries
+	// into the queue, nor exceed MaximumFileSize. Entries exceeding MaxEntrySize
+	// are handled according to EntrySizePolicy before they are queued up.
+	// MaxEntrySize applies to entries as passed to Write, before TimestampFormat
+	// and Transform.
+	MaxEntrySize int64
+
+	// EntrySizePolicy defines how entries exceeding MaxEntrySize are handled.
+	// Defaults to EntrySizeReject.
+	EntrySizePolicy EntrySizePolicy
+
 	// MaximumLifetime defines the maximum amount of time a file will
 	// be written to before a rotation occurs.
 	// When MaximumLifetime == 0, no log rotation will occur.
@@ -40,21 +58,403 @@ type Options struct {
 	// may fall on the same timestamp.
 	// Eg.
 	// 	2020-03-28_15-00-945-<random-hash>.log
-	// When FileNameFunc is not specified, DefaultFilenameFunc will be used.
+	// When FileNameFunc is not specified, DefaultFilenameFunc will be used,
+	// or SharedFilenameFunc in Shared mode.
 	FileNameFunc func() string
+
+	// Retry defines how failed file operations, such as creating a new
+	// file or writing to the current one, are retried.
+	// When Retry is not specified, failed operations are not retried
+	// and the affected entry is dropped.
+	Retry RetryPolicy
+
+	// Fallback receives entries which could not be written to a file,
+	// for example os.Stderr, so that logs are not lost during disk outages.
+	// When Fallback is nil, entries which could not be written are dropped.
+	Fallback io.Writer
+
+	// DiskFullPolicy defines the behavior when a write fails because
+	// the disk is full. Defaults to DiskFullDrop.
+	DiskFullPolicy DiskFullPolicy
+
+	// NonBlocking makes Write return ErrQueueFull instead of blocking
+	// when the writer's queue is full.
+	NonBlocking bool
+
+	// RotationManifest, when set, is the path of a file recording each closed file
+	// as a line of JSON, so that collectors can discover files without parsing their
+	// names. Each line has the fields path, name, opened, closed, bytes, entries,
+	// and, when set, sha256 and error. Relative paths are relative to Directory.
+	// Files listed may have been removed since, for example by DeleteAfterUpload.
+	RotationManifest string
+
+	// Checksum computes the SHA-256 of each file while it is written, and writes it
+	// into a sidecar named after the file with ChecksumSuffix once the file is closed,
+	// in the format of sha256sum. The checksum is passed to OnFileClose and Uploader,
+	// which uploads the sidecar after the file.
+	Checksum bool
+
+	// Metadata writes a sidecar named after each file with MetadataSuffix once the
+	// file is closed, holding a JSON object with the fields name, opened, closed,
+	// first_entry and last_entry, the times the first and last entries were
+	// written, bytes, entries, reason, and, when set, sha256 and error, so that
+	// indexing tools do not need to read the whole file. The reason is size, time
+	// or manual for rotated files, see RotationReason, close for files closed by
+	// Close, and error for files closed after a failure. Uploader uploads the
+	// sidecar after the file.
+	Metadata bool
+
+	// EncryptionKey, when set, encrypts entries with AES-256-GCM before they are
+	// written, so that files never contain them in plaintext. It must be
+	// EncryptionKeySize bytes long, for example read from a secret store.
+	// Files are read back with DecryptReader. Entries are encrypted in chunks as
+	// they are flushed from the buffer, MaximumFileSize applies to the entries
+	// before encryption, which adds 20 bytes per chunk. Encrypted files cannot be
+	// read line by line, by the ship package or line based uploaders.
+	// EncryptionKey cannot be changed with SetOptions.
+	EncryptionKey []byte
+
+	// Filter, when set, is called with each entry, entries for which it returns
+	// false are discarded instead of being written to files, tees and Fallback,
+	// for example noisy health check access logs. Filter is called before
+	// Transform, on the background writer, one entry at a time, and must not
+	// retain b. Discarded entries are counted by Stats.EntriesFiltered.
+	Filter func(b []byte) bool
+
+	// SuppressDuplicates replaces runs of identical consecutive entries with the
+	// first entry, followed by a summary "last message repeated N times", as
+	// syslog does, so that tight error loops do not fill the disk. The summary is
+	// written when a different entry is written, when the Writer is closed, or
+	// 30 seconds after the first repeat it counts, according to Now, so that an
+	// entry repeated continuously is summarized every 30 seconds. Duplicates are
+	// detected after Filter, before RateLimit and Transform.
+	SuppressDuplicates bool
+
+	// RateLimit, when set, limits the rate at which entries are written, protecting
+	// the disk from runaway logging, for example debug logging enabled during an
+	// incident. Entries exceeding the limit are discarded, or sampled, before they
+	// are written to files, tees and Fallback, and are counted by
+	// Stats.EntriesRateLimited. RateLimit applies after Filter, before Transform.
+	RateLimit *RateLimitOptions
+
+	// TimestampFormat, when set, prefixes each entry with the time it was passed
+	// to Write, in UTC formatted with TimestampFormat as a time layout, followed by
+	// a space, for producers writing lines without timestamps, such as the output
+	// of a subprocess. For example time.RFC3339Nano. The timestamp is prepended
+	// after duplicate suppression, before EnsureNewline and Transform.
+	TimestampFormat string
+
+	// EnsureNewline appends a newline to entries which do not end with one,
+	// so that producers writing partial lines cannot glue two entries together.
+	// The newline is appended before Transform, empty entries are left empty.
+	EnsureNewline bool
+
+	// Transform, when set, is applied to each entry before it is written to files,
+	// tees and Fallback, for example to scrub secrets or personal data in one place,
+	// see RedactRegexp and ChainTransforms. Transform returns the entry to write,
+	// it may modify b in place, but must not retain it. MaximumFileSize and Stats
+	// apply to transformed entries. Transform runs on the background writer,
+	// one entry at a time, a panic drops the entry.
+	Transform func(b []byte) []byte
+
+	// CRLF converts the line endings of entries to CRLF in files, for files
+	// consumed by Windows tools, so that producers do not have to care. Entries
+	// are converted after Transform, tees and Fallback receive them unchanged.
+	// Rotation markers are converted too, Header and Footer are written as is.
+	// CRLF cannot be combined with Framed, which keeps entries unchanged, and
+	// JSONArray.
+	CRLF bool
+
+	// Framed writes each entry as a record prefixed with its length, a big endian
+	// uint32, instead of relying on newlines, so that entries may hold binary
+	// payloads, and files can be read record by record with RecordReader, which
+	// skips records without scanning them. Each record ends with the CRC-32C of
+	// its length and entry, so that RecordReader detects and skips corrupted
+	// records, such as a write torn by a power loss, instead of failing on the
+	// whole file. Each write of Header and Footer is a record too.
+	// MaximumFileSize includes the 8 bytes of the length and checksum, entries
+	// cannot exceed 4 GiB, and NewRecordReader reads entries up to
+	// DefaultMaximumRecordSize, see NewRecordReaderSize. Framed files cannot be
+	// read line by line, by the ship package or line based uploaders. Framed
+	// cannot be changed with SetOptions.
+	Framed bool
+
+	// JSONArray writes each file as a JSON array, for tools requiring well-formed
+	// JSON documents rather than JSON lines. Files start with an opening bracket,
+	// each entry is an element, without its trailing newline, separated from the
+	// previous one by a comma, and the closing bracket is written when the file is
+	// closed by a rotation or Close, but not by Reopen, which appends to the file
+	// again. Entries which are not valid JSON, such as the output of a panic, are
+	// written as JSON strings so that files remain well-formed. Like Footer, the
+	// closing bracket is written regardless of MaximumFileSize. JSONArray cannot
+	// be combined with Header, Footer, Framed and Shared, and cannot be changed
+	// with SetOptions.
+	JSONArray bool
+
+	// RotationMarkers writes a marker line at the end of each rotated file and at
+	// the beginning of the file replacing it, so that humans and collectors
+	// following a file discover its successor. Markers are JSON objects with the
+	// fields logrotate, always "rotated", to or from, the name of the next or
+	// previous file, reason, see RotationReason, and time. For example
+	// {"logrotate":"rotated","to":"app-2.log","reason":"size","time":"2020-03-28T15:00:00Z"}.
+	// Markers are not entries, like Header and Footer, the marker at the end of a
+	// file is written regardless of MaximumFileSize. Files closed by
+	// MaximumLifetime while no entries are written have no successor yet, and get
+	// no markers.
+	RotationMarkers bool
+
+	// Header, when set, writes the beginning of each file when it is created, for
+	// example build and version metadata. Header is not written into files which
+	// already have contents, such as files appended to by Reopen. The header counts
+	// towards MaximumFileSize, but a file holding only its header is not rotated.
+	// Failures are reported, see Errors, the file is written to nonetheless.
+	Header func(w io.Writer) error
+
+	// Footer, when set, writes the end of each file before it is closed and will
+	// not be written to again, for example a terminator expected by parsers.
+	// The footer is written regardless of MaximumFileSize.
+	// Failures are reported, see Errors.
+	Footer func(w io.Writer) error
+
+	// OnRotate is invoked each time a file is rotated, after the previous file
+	// has been closed and the next one opened.
+	// OnRotate runs on the background writer and blocks further writes until it returns,
+	// long running work such as shipping the closed file should be done asynchronously.
+	OnRotate func(e RotationEvent)
+
+	// OnFileClose is invoked exactly once for each file, after it has been
+	// synchronized to disk and closed, when it is rotated or the Writer is closed.
+	// The file will not be written to again, making OnFileClose suitable for
+	// post-processing such as compression, checksumming or uploads.
+	// OnFileClose runs on the background writer, see OnRotate.
+	OnFileClose func(e FileCloseEvent)
+
+	// Now returns the current time, used for time based rotation and timestamps.
+	// When Now is not specified, time.Now will be used.
+	// Now cannot be changed with SetOptions.
+	Now func() time.Time
+
+	// NewTicker creates a Ticker firing every d, used to rotate files which
+	// exceeded MaximumLifetime while no entries are being written, and to
+	// summarize repeated entries, see SuppressDuplicates.
+	// When NewTicker is not specified, a time.Ticker will be used.
+	// Together with Now, NewTicker allows time based rotation to be tested deterministically.
+	NewTicker func(d time.Duration) Ticker
+
+	// Scheduler, when set, runs the timers and uploads of the Writer, shared
+	// with other Writers, instead of goroutines and timers of its own. When
+	// NewTicker is not specified, the Scheduler's NewTicker will be used.
+	// Uploads are run by the Scheduler's workers, still up to UploadConcurrency
+	// at a time and in the order files were closed.
+	// Scheduler cannot be changed with SetOptions.
+	Scheduler *Scheduler
+
+	// QueueSize is the number of entries which can be queued up awaiting to be written,
+	// before Write blocks or, with NonBlocking, returns ErrQueueFull.
+	// When QueueSize == 0, a default of 1024 will be used.
+	// QueueSize cannot be changed with SetOptions.
+	QueueSize int
+
+	// FS is the filesystem files are written to.
+	// When FS is not specified, the operating system's filesystem will be used.
+	// FS cannot be changed with SetOptions.
+	FS FS
+
+	// MaximumOpenFiles bounds the number of files open at the same time: the
+	// current file, sidecars and manifests while they are written, and files
+	// read by Uploader, one per upload in progress. Opening a file beyond the
+	// limit waits for another one to be closed, so that the Writer coexists with
+	// a low RLIMIT_NOFILE, at the cost of uploads waiting for rotations.
+	// The lock file of Lock is not counted. Within a Pool, the limit is shared
+	// by its Writers, see PoolOptions.MaximumOpen.
+	// When MaximumOpenFiles == 0, files are not bounded.
+	// MaximumOpenFiles cannot be changed with SetOptions.
+	MaximumOpenFiles int
+
+	// FileMode is the permissions of created files, before the umask is applied.
+	// When FileMode == 0, a default of 0644 will be used.
+	FileMode os.FileMode
+
+	// DirMode is the permissions of created directories, before the umask is applied.
+	// When DirMode == 0, a default of 0755 will be used.
+	DirMode os.FileMode
+
+	// ExactMode changes the permissions of files and directories to FileMode and
+	// DirMode after creating or opening them, so that they are exact regardless
+	// of the process umask, and of the permissions of existing files.
+	// FS must implement ChmodFS, OSFS does.
+	ExactMode bool
+
+	// Owner, when set, is the owner of the files, checksum sidecars, rotation manifest
+	// and Directory created by the Writer, for example the unprivileged user a service runs as
+	// after starting as root. Changing the owner usually requires privileges.
+	// FS must implement ChownFS, OSFS does except on Windows.
+	Owner *Owner
+
+	// Shared enables writing into the same Directory from multiple processes,
+	// for example preforked workers of a server. Files are opened for appending
+	// and never truncated, and an entry is never split across two writes into a
+	// file, so that entries appended by different processes do not interleave.
+	// Rotation never renames files, each process rotates its own files
+	// independently. The default FileNameFunc is SharedFilenameFunc, which
+	// includes the process ID, custom FileNameFunc should include a per-process
+	// component too. Processes may deliberately share a file name, such as
+	// "app-2020-02-02T10.log" rotated hourly, as long as Checksum, Metadata,
+	// EncryptionKey and Uploader are not used, since they assume a single
+	// process writes a file.
+	// When Lock is set, the lock is shared by the processes in Shared mode, so that
+	// they exclude Writers which are not in Shared mode, and the other way around.
+	// With Uploader, each process must have its own UploadManifest.
+	// Shared cannot be changed with SetOptions.
+	Shared bool
+
+	// Lock, when set, takes an advisory lock (flock) on a lock file in Directory,
+	// so that a second process configured with the same Directory fails to start
+	// with ErrLocked, or waits for the lock, instead of interleaving writes into
+	// the same files. The lock is held until the Writer is closed, and is released
+	// by the operating system when the process stops. The lock file doubles as a
+	// PID file, recording the process ID of the holder, which describes the holder
+	// in ErrLocked errors. A lock which is held is never taken over, even though
+	// that process is not running, since it may run in another PID namespace, or
+	// the lock may have been inherited by a child. Lock is only supported on Unix systems,
+	// with OSFS. Lock cannot be changed with SetOptions.
+	Lock *LockOptions
+
+	// AppendOnly sets the append-only attribute (chattr +a) of files while they are
+	// written to, and replaces it with the immutable attribute (chattr +i) once they
+	// are closed, so that entries cannot be modified or removed, even by the user
+	// the process runs as, giving tamper-evidence for audit logs. Setting the
+	// attributes requires CAP_LINUX_IMMUTABLE and a filesystem supporting them, such
+	// as ext4 or XFS, a file is not written to when its append-only attribute cannot
+	// be set. Immutable files cannot be renamed or deleted, so AppendOnly cannot be
+	// combined with DeleteAfterUpload and FailedUploadDirectory.
+	// AppendOnly is only supported on Linux, with OSFS.
+	AppendOnly bool
+
+	// ExpvarPrefix, when set, publishes the Writer's Stats via expvar as variables
+	// named ExpvarPrefix followed by ".bytes_written", ".entries_written" and so on.
+	// The prefix must not be used by another open Writer in the same process,
+	// it is released when the Writer is closed.
+	// ExpvarPrefix cannot be changed with SetOptions.
+	ExpvarPrefix string
+
+	// Metrics, when set, receives the counters, gauges and timers of the Writer,
+	// such as entries written, drops, rotations and errors, see the Metric constants.
+	// When Metrics is not specified, NopMetrics will be used.
+	// Metrics cannot be changed with SetOptions.
+	Metrics Metrics
+
+	// Debug logs the decisions of the Writer to its logger, prefixed with
+	// "[debug]": why each file is rotated, by size, time or manually, and when
+	// the queue becomes half full, full, and drains again. See also
+	// ManagerOptions.Debug. Debug cannot be changed with SetOptions.
+	Debug bool
+
+	// Syslog, when set, copies every entry to a syslog daemon as an RFC 5424 message,
+	// in addition to writing it to a file. Entries are sent in the background with
+	// a queue of QueueSize entries, while syslog is slow or unavailable entries are
+	// dropped from syslog, writing files is never blocked.
+	// Syslog cannot be changed with SetOptions.
+	Syslog *SyslogOptions
+
+	// Webhook, when set, notifies an HTTP endpoint of each closed file with a POST
+	// request, so that pipelines can react to rotations without polling Directory.
+	// The JSON payload has the fields of the lines of RotationManifest.
+	// Notifications are sent in order in the background, they are dropped while
+	// more than QueueSize are waiting, and when the endpoint fails.
+	// Webhook cannot be changed with SetOptions.
+	Webhook *WebhookOptions
+
+	// TeeTo, when set, receives a copy of every entry in addition to the files,
+	// typically os.Stdout so that logs are visible on the console during development
+	// or in containers. Entries are written to TeeTo in order in the background,
+	// and Flush, Sync and Close wait for them. Entries are never dropped from TeeTo,
+	// so a TeeTo which blocks eventually blocks Write. TeeTo is not closed by Close.
+	// TeeTo cannot be changed with SetOptions.
+	TeeTo io.Writer
+
+	// Uploader, when set, uploads each file in the background once it will not be
+	// written to again, after rotation or when the Writer is closed. Uploads are
+	// started in the order files were closed, see UploadConcurrency. Close waits for
+	// uploads to finish, CloseContext cancels them when its context is done.
+	// Files which failed to upload, after retries, are kept and reported, see Errors.
+	// Uploader reads files from the operating system's filesystem, it cannot be
+	// used with a custom FS. Uploader cannot be changed with SetOptions.
+	Uploader Uploader
+
+	// DeleteAfterUpload removes files once they have been uploaded by Uploader.
+	DeleteAfterUpload bool
+
+	// UploadRetry defines how failed uploads are retried. Uploads are retried
+	// until the Writer is aborted, the context passed to Upload is then cancelled.
+	// When UploadRetry is not specified, failed uploads are not retried.
+	// UploadRetry cannot be changed with SetOptions.
+	UploadRetry RetryPolicy
+
+	// UploadConcurrency is the number of files uploaded at the same time.
+	// Defaults to 1, uploading files one at a time in the order they were closed.
+	// UploadConcurrency cannot be changed with SetOptions.
+	UploadConcurrency int
+
+	// UploadBandwidth limits the rate, in bytes per second, at which files are read
+	// for uploading, shared by all uploads, so that uploads do not saturate the network.
+	// Uploaders must read files through UploadReader, which the bundled ones do.
+	// Defaults to 0, no limit. UploadBandwidth cannot be changed with SetOptions.
+	UploadBandwidth int64
+
+	// UploadManifest is the path of the file tracking files which have not been
+	// uploaded yet. Files which failed to upload, were not uploaded before the
+	// Writer was closed, or were being written to when the process stopped, are
+	// uploaded when a Writer is created with the same UploadManifest. A file is
+	// uploaded again only if the process stops right after uploading it.
+	// A manifest which cannot be parsed is renamed with a ".corrupt" suffix, and
+	// every file in Directory is uploaded again.
+	// Defaults to DefaultUploadManifest within Directory.
+	// UploadManifest cannot be changed with SetOptions.
+	UploadManifest string
+
+	// FailedUploadDirectory, when set, receives files which failed to upload after
+	// all retries, so that operators can inspect and upload them manually. Relative
+	// paths are relative to Directory, for example "failed". When not set, files
+	// which failed to upload are kept in place and uploaded by the next Writer.
+	// FailedUploadDirectory cannot be changed with SetOptions.
+	FailedUploadDirectory string
+
+	// OnUploadFailure is invoked when a file failed to upload after all retries,
+	// after it has been moved to FailedUploadDirectory.
+	// OnUploadFailure runs on the upload worker and delays further uploads until it returns.
+	// OnUploadFailure cannot be changed with SetOptions.
+	OnUploadFailure func(e UploadFailureEvent)
+}
+
+// entry is an item in the Writer's queue.
+type entry struct {
+	// b is the data to be written
+	b []byte
+	// ts is the time b was written, set with Options.TimestampFormat
+	ts time.Time
+	// queued is the time the entry was queued up, set when latencies are measured
+	queued time.Time
+
+	// op, when set, marks the entry as a control operation, such as a flush,
+	// which runs in the background writer once all previously queued entries
+	// have been written. The result of op is sent on result.
+	op     func() error
+	result chan error
 }
 
 // Writer is a concurrency-safe writer with file rotation.
 type Writer struct {
-	logger *log.Logger
+	logger Logger
 
-	// opts are the configuration options for this Writer
+	// opts are the configuration options for this Writer,
+	// only accessed by the background writer
 	opts Options
 
 	// f is the currently open file used for appends.
 	// Writes to f are only synchronized once Close() is called,
 	// or when files are being rotated.
-	f *os.File
+	f File
 	// bw is a buffered writer for writing to f
 	bw *bufio.Writer
 	// bytesWritten is the number of bytes written to f so far,
@@ -63,151 +463,948 @@ type Writer struct {
 	// ts is the creation timestamp of f,
 	// used for time based log rotation
 	ts time.Time
+	// hash computes the checksum of f when Options.Checksum is set
+	hash hash.Hash
+	// entries is the number of entries written to f
+	entries int64
+	// headerSize is the size of the header written to f, see Options.Header
+	headerSize int64
+	// firstEntryTime and lastEntryTime are the times of the first and last
+	// entries written to f, with Options.Metadata
+	firstEntryTime time.Time
+	lastEntryTime  time.Time
+	// ticker triggers time based rotation of idle files,
+	// set while MaximumLifetime is enabled
+	ticker Ticker
+	// clock is Options.Now, fixed when the Writer is created
+	clock func() time.Time
+	// framed is Options.Framed, fixed when the Writer is created
+	framed bool
+	// jsonArray is Options.JSONArray, fixed when the Writer is created
+	jsonArray bool
+	// metrics is Options.Metrics, fixed when the Writer is created
+	metrics Metrics
+	// timed is whether latencies are measured, which is skipped with NopMetrics
+	timed bool
+	// debug is Options.Debug, fixed when the Writer is created
+	debug bool
+	// expvar reports the Stats for Options.ExpvarPrefix, nil without a prefix
+	expvar *expvarWriter
+	// queuePressure is the last queuePressure logged, accessed atomically
+	queuePressure int32
 
 	// queue of entries awaiting to be written
-	queue chan []byte
-	// synchronize write which have started but not been queued up
-	pending sync.WaitGroup
+	queue chan entry
+	// priority is the queue of entries written with PriorityHigh, written
+	// before queue. It is never closed, entries are queued up in it under
+	// queueMu like in queue.
+	priority chan entry
+	// queueHighWatermark is the largest depth of queue and priority since
+	// ResetQueueHighWatermark was last called, accessed atomically
+	queueHighWatermark int64
+	// queueMu synchronizes writes which have started but not been queued up
+	// with closing the queue. Write holds a read lock while enqueuing.
+	queueMu sync.RWMutex
+	// queueClosed is set once queue has been closed, guarded by queueMu
+	queueClosed bool
 	// singal the writer should close
-	closing chan struct{}
-	// signal the writer has finished writing all queued up entries.
+	closing   chan struct{}
+	closeOnce sync.Once
+	// signal the writer should discard queued up entries instead of writing them
+	abort     chan struct{}
+	abortOnce sync.Once
+	// signal the writer has finished writing all queued up entries
+	// and closed the current file.
 	done chan struct{}
+	// closeErr is the error encountered when closing the last file
+	closeErr error
+	// errs receives errors encountered by the background writer
+	errs chan error
+	// events receives the events of the Writer, see Events
+	events chan Event
+	// eventsMu synchronizes emitting events with closing events
+	eventsMu     sync.RWMutex
+	eventsClosed bool
+
+	// tees receive a copy of every entry, see Options.Syslog and Options.TeeTo
+	tees []*tee
+	// webhook receives notifications of closed files, see Options.Webhook
+	webhook *tee
+	// uploads uploads closed files, set when Options.Uploader is set
+	uploads *uploads
+	// lock is held until the Writer is closed, set when Options.Lock is set
+	lock *dirLock
+	// files bounds the files open at the same time, see Options.MaximumOpenFiles
+	files fileLimit
+
+	// diskFull is set while entries are being dropped due to a full disk
+	diskFull bool
+	// limiter enforces Options.RateLimit, set while it is enabled
+	limiter *rateLimiter
+	// lastEntry is a copy of the last entry written, with SuppressDuplicates
+	lastEntry []byte
+	// repeats is the number of times lastEntry has been repeated since it was
+	// written, or since the summary of its repeats was written
+	repeats int64
+	// repeatsSince is when lastEntry was first repeated, when repeats is not 0
+	repeatsSince time.Time
+
+	// diskFullDropped is the number of entries dropped while the disk was full
+	diskFullDropped int64
+
+	// mu guards writeOpts, nextOpts, resumed, err, failed and the health information below
+	mu sync.Mutex
+	// writeOpts are the options consulted by Write, a copy of opts
+	writeOpts Options
+	// nextOpts are options set by SetOptions, applied at the next rotation
+	nextOpts *Options
+	// resumed is set while the writer is paused, closed by Resume
+	resumed chan struct{}
+	// resumes counts the calls to Resume, so that a Pause queued up before
+	// a Resume does not pause the writer again
+	resumes int64
+	// err is the most recent error encountered by the background writer
+	err error
+	// failed is set once the writer stops accepting writes, see DiskFullFail
+	failed error
+	// path is the path of f
+	path string
+	// previousPath is the path of the file closed before f
+	previousPath string
+	// lastWrite is the time of the last successful write
+	lastWrite time.Time
+	// consecutiveFailures is the number of failed writes since the last successful one
+	consecutiveFailures int
+	// stats are the counters reported by Stats
+	stats Stats
+	// fileSize and fileOpened mirror the size and creation time of f
+	fileSize   int64
+	fileOpened time.Time
+	// lastRotationPath and lastRotation are the file closed by the most
+	// recent rotation and when it was closed
+	lastRotationPath string
+	lastRotation     time.Time
 }
 
 // Write writes p into the current file, rotating if necessary.
+// Write does not retain p, it may be reused once Write returns.
 // Write is non-blocking, if the writer's queue is not full.
-// Write is blocking otherwise.
+// Write is blocking otherwise, unless Options.NonBlocking is set
+// in which case ErrQueueFull is returned.
+//
+// Write returns ErrClosed once the writer is closing, ErrEntryTooLarge
+// if p exceeds MaximumFileSize, or MaxEntrySize with EntrySizeReject,
+// and ErrFailed if the writer has failed.
 func (w *Writer) Write(p []byte) (n int, err error) {
+	return w.WritePriority(p, PriorityNormal)
+}
+
+// WritePriority writes p like Write, with the given priority. Entries with
+// PriorityHigh, such as panic or fatal messages, are queued in a lane of
+// their own which is written before the queue of Write, and are flushed to
+// the file once written, so that they reach it even when the queue is backed
+// up. They may therefore be written before entries written earlier with a
+// lower priority. WritePriority blocks while the lane is full, even with
+// Options.NonBlocking.
+func (w *Writer) WritePriority(p []byte, priority Priority) (n int, err error) {
 	select {
 	case <-w.closing:
-		return 0, errors.Wrap(err, "writer is closing")
+		return 0, ErrClosed
 	default:
-		w.pending.Add(1)
-		defer w.pending.Done()
 	}
 
-	w.queue <- p
+	w.queueMu.RLock()
+	defer w.queueMu.RUnlock()
+	if w.queueClosed {
+		return 0, ErrClosed
+	}
+
+	a, err := w.admit(int64(len(p)))
+	if err != nil {
+		reason := DropFailed
+		if errors.Is(err, ErrEntryTooLarge) {
+			reason = DropTooLarge
+		}
+		w.recordDrop(reason, err)
+		return 0, err
+	}
+	a.lane = w.queue
+	if priority == PriorityHigh {
+		a.lane = w.priority
+		a.nonBlocking = false
+	}
 
+	var ts time.Time
+	if a.timestamp {
+		ts = w.now()
+	}
+
+	if a.maxEntrySize != 0 && int64(len(p)) > a.maxEntrySize {
+		if a.entrySizePolicy == EntrySizeChunk {
+			return w.writeChunks(p, ts, a)
+		}
+		if err := w.enqueue(entry{b: truncatedEntry(p, a.maxEntrySize), ts: ts}, a); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	// Callers may reuse p once Write returns, as required by io.Writer,
+	// the entry is therefore queued up as a copy.
+	b := getBuffer(len(p))
+	copy(b, p)
+	if err := w.enqueue(entry{b: b, ts: ts}, a); err != nil {
+		return 0, err
+	}
 	return len(p), nil
 }
 
+// enqueue queues up e in the lane of a, returning ErrQueueFull in NonBlocking
+// mode when it is full.
+func (w *Writer) enqueue(e entry, a admission) error {
+	if w.timed {
+		e.queued = w.now()
+	}
+	if !a.nonBlocking {
+		a.lane <- e
+		w.recordQueueDepth()
+		return nil
+	}
+
+	select {
+	case a.lane <- e:
+		w.recordQueueDepth()
+		return nil
+	default:
+		putBuffer(e.b)
+		w.recordDrop(DropQueueFull, ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// admission describes how Write queues up an entry, see admit.
+type admission struct {
+	// nonBlocking is whether Write returns ErrQueueFull rather than blocking
+	nonBlocking bool
+	// timestamp is whether the entry is to be timestamped
+	timestamp bool
+	// maxEntrySize and entrySizePolicy define how large entries are handled
+	maxEntrySize    int64
+	entrySizePolicy EntrySizePolicy
+	// lane is the queue the entry is queued up in, see WritePriority
+	lane chan entry
+}
+
+// admit checks whether an entry of size bytes can be accepted by Write.
+func (w *Writer) admit(size int64) (admission, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return admission{}, w.failed
+	}
+
+	a := admission{
+		nonBlocking:     w.writeOpts.NonBlocking,
+		timestamp:       w.writeOpts.TimestampFormat != "",
+		maxEntrySize:    w.writeOpts.MaxEntrySize,
+		entrySizePolicy: w.writeOpts.EntrySizePolicy,
+	}
+	if max := a.maxEntrySize; max != 0 && size > max {
+		if a.entrySizePolicy == EntrySizeReject {
+			return admission{}, errors.Wrapf(ErrEntryTooLarge, "entry of %d bytes exceeds MaxEntrySize of %d bytes", size, max)
+		}
+		// The entry is truncated or split into entries of max bytes.
+		size = max
+	}
+
+	if max := w.writeOpts.MaximumFileSize; max != 0 && size > max {
+		return admission{}, errors.Wrapf(ErrEntryTooLarge, "entry of %d bytes exceeds MaximumFileSize of %d bytes", size, max)
+	}
+
+	return a, nil
+}
+
 // Close closes the writer.
-// Any accepted writes will be flushed. Any new writes will be rejected.
+// Any accepted writes will be flushed. Any new writes will be rejected with ErrClosed.
 // Once Close() exits, files are synchronized to disk.
+// Close is safe to call multiple times, subsequent calls wait for the
+// first one to complete and return the same result.
 func (w *Writer) Close() error {
-	close(w.closing)
-	w.pending.Wait()
+	return w.CloseContext(context.Background())
+}
 
-	close(w.queue)
-	<-w.done
+// CloseContext closes the writer, like Close.
+// If ctx expires before all accepted writes are flushed, CloseContext
+// abandons the remaining entries and returns an error reporting how many
+// entries were discarded. The current file is closed in the background
+// once the entry being written, if any, completes.
+func (w *Writer) CloseContext(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		close(w.closing)
+		w.Resume()
 
-	if w.f != nil {
-		if err := w.closeCurrentFile(); err != nil {
-			return err
+		// Acquiring the lock waits for writes which are blocked on a full queue.
+		go func() {
+			w.queueMu.Lock()
+			defer w.queueMu.Unlock()
+			w.queueClosed = true
+			close(w.queue)
+		}()
+	})
+
+	select {
+	case <-w.done:
+		if w.closeErr != nil {
+			return w.closeErr
 		}
+		return w.LastError()
+	case <-ctx.Done():
+		w.abortOnce.Do(func() { close(w.abort) })
+		discarded := len(w.queue) + len(w.priority)
+		return errors.Wrapf(ctx.Err(), "close abandoned, %d entries discarded", discarded)
 	}
+}
 
-	return nil
+// LastError returns the most recent error encountered while writing
+// entries in the background, or nil if no error has occurred.
+// Write is asynchronous, LastError allows callers to detect that
+// accepted entries could not be persisted.
+func (w *Writer) LastError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Writer) setError(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+
+	w.report(err)
+}
+
+// fail marks the writer as failed, subsequent writes are rejected.
+func (w *Writer) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed == nil {
+		w.failed = withSentinel(ErrFailed, err)
+	}
+	w.err = err
+}
+
+func (w *Writer) failure() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.failed
 }
 
 func (w *Writer) listen() {
-	for b := range w.queue {
-		if w.f == nil {
-			if err := w.rotate(); err != nil {
-				w.logger.Println("Failed to create log file", err)
-			}
-		}
+	for {
+		w.waitWhilePaused()
+		w.updateTicker()
 
-		size := int64(len(b))
+		var ticks <-chan time.Time
+		if w.ticker != nil {
+			ticks = w.ticker.C()
+		}
 
-		if w.opts.MaximumFileSize != 0 && size > w.opts.MaximumFileSize {
-			w.logger.Println("Attempting to write more bytes than allowed by MaximumFileSize. Skipping.")
+		select {
+		case e := <-w.priority:
+			w.recordDequeue(e)
+			w.processPriority(e)
 			continue
+		default:
 		}
-		if w.opts.MaximumFileSize != 0 && w.bytesWritten+size > w.opts.MaximumFileSize {
-			if err := w.rotate(); err != nil {
-				w.logger.Println("Failed to rotate log file", err)
+
+		select {
+		case e := <-w.priority:
+			w.recordDequeue(e)
+			w.processPriority(e)
+
+		case e, ok := <-w.queue:
+			if !ok {
+				w.drainPriority()
+				w.shutdown()
+				return
 			}
-		}
+			w.recordDequeue(e)
+			if e.op != nil {
+				e.result <- w.runOp(e.op)
+				continue
+			}
+			w.process(e.b, e.ts)
+			putBuffer(e.b)
 
-		if w.opts.MaximumLifetime != 0 && time.Now().After(w.ts.Add(w.opts.MaximumLifetime)) {
-			if err := w.rotate(); err != nil {
-				w.logger.Println("Failed to rotate log file", err)
+		case <-ticks:
+			if err := w.runOp(w.tick); err != nil {
+				w.logger.Printf("Failed to rotate expired log file: %v", err)
+				w.setError(err)
 			}
 		}
+	}
+}
 
-		if _, err := w.bw.Write(b); err != nil {
-			w.logger.Println("Failed to write to file.", err)
+// shutdown closes the current file once all queued entries have been written.
+func (w *Writer) shutdown() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		w.ticker = nil
+	}
+
+	if !w.aborted() {
+		w.flushRepeats()
+	}
+
+	if w.f != nil {
+		w.closeErr = w.finishCurrentFile(closeReason)
+		if w.closeErr != nil {
+			w.report(w.closeErr)
 		}
-		w.bytesWritten += size
 	}
 
+	w.closeTees()
+	w.closeUploads()
+	w.releaseLock()
+	w.unpublishExpvars()
+
+	close(w.errs)
+	w.closeEvents()
 	close(w.done)
 }
 
+// process writes a single entry b, applying the configured failure policies.
+// A panic while processing b is recovered, the entry is dropped and the current
+// file is closed so that the next entry is written into a fresh file.
+func (w *Writer) process(b []byte, ts time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.logger.Printf("Recovered from panic while writing entry, reopening file: %v", r)
+			w.recordWrite(int64(len(b)), errors.Errorf("panic while writing entry: %v", r))
+			w.recoverFile()
+		}
+	}()
+
+	if w.aborted() {
+		w.recordDrop(DropClosed, ErrClosed)
+		return
+	}
+
+	// options set by SetOptions apply from the next entry on, including to
+	// Filter, duplicate suppression, RateLimit and Transform
+	w.applyNextOptions()
+	if w.opts.Filter != nil && !w.opts.Filter(b) {
+		w.recordFiltered()
+		return
+	}
+	if w.suppressDuplicate(b) {
+		return
+	}
+
+	w.processEntry(b, ts)
+}
+
+// processEntry writes an entry which passed Filter and duplicate suppression.
+func (w *Writer) processEntry(b []byte, ts time.Time) {
+	if w.rateLimited(b) {
+		w.recordRateLimited()
+		return
+	}
+
+	b = w.transform(w.terminate(w.timestamp(b, ts)))
+	w.sendTees(b)
+
+	if w.framed && int64(len(b)) > maximumRecordSize {
+		err := errors.Wrapf(ErrEntryTooLarge, "entry of %d bytes exceeds the maximum record size", len(b))
+		w.setError(err)
+		w.recordDrop(DropTooLarge, err)
+		return
+	}
+	record := w.frame(w.arrayElement(w.crlf(b)))
+	size := int64(len(record))
+
+	if w.opts.MaximumFileSize != 0 && size > w.opts.MaximumFileSize {
+		w.logger.Printf("Attempting to write more bytes than allowed by MaximumFileSize. Skipping.")
+		err := errors.Wrapf(ErrEntryTooLarge, "entry of %d bytes exceeds MaximumFileSize", size)
+		w.setError(err)
+		w.recordDrop(DropTooLarge, err)
+		return
+	}
+
+	if err := w.failure(); err != nil {
+		w.recordDrop(DropFailed, err)
+		w.writeFallback(b)
+		return
+	}
+
+	err := w.retry(func() error { return w.write(record) })
+	if isDiskFull(err) && w.opts.DiskFullPolicy == DiskFullBlock {
+		err = w.waitForDiskSpace(record, err)
+	}
+	if isDiskFull(err) {
+		err = withSentinel(ErrDiskFull, err)
+	}
+	w.recordWrite(size, err)
+	if isDiskFull(err) {
+		w.dropDiskFull(b, err)
+		return
+	}
+	if err != nil {
+		w.logger.Printf("Failed to write to file: %v", err)
+		w.writeFallback(b)
+		return
+	}
+	w.recordEntryTime(ts)
+	w.diskRecovered()
+}
+
+// recoverFile closes the current file, if any, after a panic left
+// the writer in an unknown state.
+func (w *Writer) recoverFile() {
+	defer func() {
+		if r := recover(); r != nil {
+			w.logger.Printf("Recovered from panic while closing file: %v", r)
+			w.f, w.bw = nil, nil
+			w.bytesWritten = 0
+			w.setPath("")
+		}
+	}()
+
+	if w.f != nil {
+		if err := w.finishCurrentFile(errorReason); err != nil {
+			w.logger.Printf("Failed to close file after panic: %v", err)
+		}
+	}
+}
+
+// control queues op to run in the background writer and waits for its result.
+func (w *Writer) control(op func() error) error {
+	w.queueMu.RLock()
+	if w.queueClosed {
+		w.queueMu.RUnlock()
+		return ErrClosed
+	}
+
+	result := make(chan error, 1)
+	w.queue <- entry{op: op, result: result}
+	w.queueMu.RUnlock()
+
+	return <-result
+}
+
+// runOp runs a control operation, unless the writer has been aborted.
+func (w *Writer) runOp(op func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.logger.Printf("Recovered from panic while running operation, reopening file: %v", r)
+			err = errors.Errorf("panic while running operation: %v", r)
+			w.recoverFile()
+		}
+	}()
+
+	if w.aborted() {
+		return errors.Wrap(ErrClosed, "writer was closed before running operation")
+	}
+
+	return op()
+}
+
+func (w *Writer) aborted() bool {
+	select {
+	case <-w.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep pauses the background writer for d, returning false if
+// the writer was aborted in the meantime.
+func (w *Writer) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-w.abort:
+		return false
+	}
+}
+
+// write writes b into the current file, creating or rotating the file first if necessary.
+func (w *Writer) write(b []byte) error {
+	w.applyNextOptions()
+
+	if w.f == nil {
+		if err := w.rotate(RotationManual); err != nil {
+			return errors.Wrap(err, "failed to create log file")
+		}
+	}
+
+	size := int64(len(w.separator()) + len(b))
+
+	// A file holding only its header is not rotated, the next one would not fit the entry either.
+	if w.opts.MaximumFileSize != 0 && w.bytesWritten > w.headerSize && w.bytesWritten+size > w.opts.MaximumFileSize {
+		w.debugRotation(RotationSize, size)
+		if err := w.rotate(RotationSize); err != nil {
+			return errors.Wrap(err, "failed to rotate log file")
+		}
+	}
+
+	if w.expired() {
+		w.debugRotation(RotationTime, 0)
+		if err := w.rotate(RotationTime); err != nil {
+			return errors.Wrap(err, "failed to rotate log file")
+		}
+	}
+
+	if sep := w.separator(); sep != nil {
+		// b is written again when retried, it is not modified.
+		b = append(sep, b...)
+	}
+	size = int64(len(b))
+
+	if err := w.flushBeforeEntry(b); err != nil {
+		w.bw.Reset(w.f)
+		w.invalidateChecksum()
+		return errors.Wrap(err, "failed to write to file")
+	}
+	if _, err := w.bw.Write(b); err != nil {
+		// A bufio.Writer stops accepting writes after an error,
+		// reset it so that a retry can write into the same file.
+		w.bw.Reset(w.f)
+		w.invalidateChecksum()
+		return errors.Wrap(err, "failed to write to file")
+	}
+	if _, encrypted := w.f.(*encryptedFile); !encrypted {
+		// The checksum of encrypted files is computed from the encrypted chunks.
+		w.updateChecksum(b)
+	}
+	w.bytesWritten += size
+	w.entries++
+
+	return nil
+}
+
+// flushBeforeEntry flushes the buffer when b does not fit into it, so that b is
+// written to the file by a single write, rather than partially when the buffer
+// is flushed. A failure can then not leave part of b in a file, while b is
+// retried, possibly in the next file. In Shared mode, other processes cannot
+// append their own entries within b.
+func (w *Writer) flushBeforeEntry(b []byte) error {
+	if w.bw.Buffered() == 0 || len(b) <= w.bw.Available() {
+		return nil
+	}
+	return w.bw.Flush()
+}
+
+// writeFallback writes b into the Fallback writer, if one is configured.
+func (w *Writer) writeFallback(b []byte) {
+	if w.opts.Fallback == nil {
+		return
+	}
+
+	if _, err := w.opts.Fallback.Write(b); err != nil {
+		w.logger.Printf("Failed to write to fallback writer: %v", err)
+		w.report(errors.Wrap(err, "failed to write to fallback writer"))
+	}
+}
+
+// finishCurrentFile closes the current file, which will not be written to again,
+// invokes the OnFileClose hook and schedules the file for upload.
+// reason is what caused the file to be closed, see Options.Metadata.
+func (w *Writer) finishCurrentFile(reason string) error {
+	w.writeFooter()
+	event := FileCloseEvent{
+		Path:    w.path,
+		Size:    w.bytesWritten,
+		Entries: w.entries,
+		Opened:  w.ts,
+	}
+
+	event.Err = w.closeCurrentFile()
+	event.Closed = w.now().UTC()
+	w.setPreviousPath(event.Path)
+	if err := w.setImmutable(event.Path); err != nil {
+		w.logger.Printf("Failed to make file immutable: %v", err)
+		w.report(err)
+	}
+
+	if event.Err != nil {
+		w.hash = nil
+	} else if sum, err := w.finishChecksum(event.Path); err != nil {
+		w.logger.Printf("Failed to write checksum: %v", err)
+		w.report(err)
+	} else {
+		event.SHA256 = sum
+	}
+	if err := w.writeMetadata(event, reason); err != nil {
+		w.logger.Printf("Failed to write metadata: %v", err)
+		w.report(err)
+	}
+
+	if err := w.appendRotationManifest(event); err != nil {
+		w.logger.Printf("Failed to append to rotation manifest: %v", err)
+		w.report(err)
+	}
+	w.notifyWebhook(event)
+	if w.opts.OnFileClose != nil {
+		w.opts.OnFileClose(event)
+	}
+	w.scheduleUpload(event)
+
+	return event.Err
+}
+
 func (w *Writer) closeCurrentFile() error {
-	if err := w.bw.Flush(); err != nil {
+	f, bw := w.f, w.bw
+	w.f, w.bw = nil, nil
+	w.bytesWritten = 0
+	w.entries = 0
+	w.setPath("")
+
+	if err := bw.Flush(); err != nil {
+		f.Close()
 		return errors.Wrap(err, "failed to flush buffered writer")
 	}
 
-	if err := w.f.Sync(); err != nil {
+	if err := f.Sync(); err != nil {
+		f.Close()
 		return errors.Wrap(err, "failed to sync current log file")
 	}
 
-	if err := w.f.Close(); err != nil {
+	if err := f.Close(); err != nil {
 		return errors.Wrap(err, "failed to close current log file")
 	}
 
-	w.bytesWritten = 0
 	return nil
 }
 
-func (w *Writer) rotate() error {
-	if w.f != nil {
-		if err := w.closeCurrentFile(); err != nil {
+func (w *Writer) rotate(reason RotationReason) error {
+	w.applyNextOptions()
+
+	path := filepath.Join(w.opts.Directory, w.opts.FileNameFunc())
+
+	if w.f == nil {
+		return w.openFile(path, w.newFileFlags())
+	}
+
+	previous := w.path
+	w.markSuccessor(path, reason)
+	event, err := w.closeForRotation(reason)
+	if err != nil {
+		return err
+	}
+
+	event.NextPath = path
+	err = w.openFile(path, w.newFileFlags())
+	if err != nil {
+		event.NextPath = ""
+	} else {
+		w.markPredecessor(previous, reason)
+	}
+
+	w.notifyRotate(event)
+
+	return err
+}
+
+// closeForRotation closes the current file as part of a rotation.
+func (w *Writer) closeForRotation(reason RotationReason) (RotationEvent, error) {
+	closing := w.now()
+	event := RotationEvent{
+		Path:   w.path,
+		Size:   w.bytesWritten,
+		Opened: w.ts,
+		Reason: reason,
+	}
+	if err := w.finishCurrentFile(reason.String()); err != nil {
+		return event, err
+	}
+	event.Closed = w.now().UTC()
+	w.recordRotation(event, event.Closed.Sub(closing))
+
+	return event, nil
+}
+
+// expired returns true if the current file exceeded MaximumLifetime.
+func (w *Writer) expired() bool {
+	return w.opts.MaximumLifetime != 0 && w.now().After(w.ts.Add(w.opts.MaximumLifetime))
+}
+
+// expire closes the current file if it exceeded MaximumLifetime, even when no
+// further entries are written, so that it can be post-processed promptly.
+// The next file is created once the next entry is written.
+func (w *Writer) expire() error {
+	w.applyNextOptions()
+
+	if w.f == nil || !w.expired() {
+		return nil
+	}
+
+	w.debugRotation(RotationTime, 0)
+	event, err := w.closeForRotation(RotationTime)
+	if err != nil {
+		return err
+	}
+
+	w.notifyRotate(event)
+
+	return nil
+}
+
+// notifyRotate invokes the OnRotate hook and emits an EventRotated.
+func (w *Writer) notifyRotate(event RotationEvent) {
+	if w.opts.OnRotate != nil {
+		w.opts.OnRotate(event)
+	}
+	w.emit(Event{Type: EventRotated, Path: event.Path, Rotation: &event})
+}
+
+// openFile opens the file at path with the given flags and makes it the current file.
+func (w *Writer) openFile(path string, flag int) error {
+	fs := w.opts.FS
+	f, err := w.openLimited(path, flag)
+	if os.IsNotExist(err) && !directoryExists(fs, w.opts.Directory) {
+		w.logger.Printf("Directory %v no longer exists, recreating it.", w.opts.Directory)
+		if err := createDirectory(w.opts); err != nil {
 			return err
 		}
+		f, err = w.openLimited(path, flag)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file at %v", path)
+	}
+	if err := setAttributes(w.opts, path, w.opts.FileMode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.setAppendOnly(path); err != nil {
+		f.Close()
+		return err
 	}
 
-	path := filepath.Join(w.opts.Directory, w.opts.FileNameFunc())
-	f, err := newFile(path)
+	info, err := f.Stat()
 	if err != nil {
-		return errors.Wrapf(err, "failed to create new file at %v", path)
+		f.Close()
+		return errors.Wrapf(err, "failed to stat file at %v", path)
+	}
+	if w.timed {
+		f = &timedFile{File: f, metrics: w.metrics, now: w.now}
+	}
+	if w.opts.EncryptionKey != nil {
+		f = newEncryptedFile(f, w.opts.EncryptionKey, w.updateChecksum)
 	}
 
 	w.bw = bufio.NewWriter(f)
 	w.f = f
-	w.bytesWritten = 0
-	w.ts = time.Now().UTC()
+	w.bytesWritten = info.Size()
+	w.entries = 0
+	w.firstEntryTime, w.lastEntryTime = time.Time{}, time.Time{}
+	w.ts = w.now().UTC()
+	w.setPath(path)
+	w.recordOpen(w.bytesWritten)
+	w.emit(Event{Type: EventFileOpened, Path: path})
+	w.startChecksum()
+	w.trackUpload()
+	w.writeHeader()
 
 	return nil
 }
 
 // New creates a new concurrency safe Writer which performs log rotation.
-func New(logger *log.Logger, opts Options) (*Writer, error) {
-	if _, err := os.Stat(opts.Directory); os.IsNotExist(err) {
-		if err := os.MkdirAll(opts.Directory, os.ModePerm); err != nil {
-			return nil, errors.Wrapf(err, "directory %v does not exist and could not be created", opts.Directory)
+// logger receives the Writer's own log lines, such as failures to write files.
+// When logger is nil, these log lines are discarded.
+func New(logger Logger, opts Options) (*Writer, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	return newWriter(logger, opts, newFileLimit(opts.MaximumOpenFiles))
+}
+
+// newWriter creates a Writer from valid options, files bounds the files it opens,
+// and may be shared with other Writers.
+func newWriter(logger Logger, opts Options, files fileLimit) (*Writer, error) {
+	opts = opts.withDefaults()
+
+	if !directoryExists(opts.FS, opts.Directory) {
+		if err := createDirectory(opts); err != nil {
+			return nil, err
 		}
 	}
 
-	if opts.FileNameFunc == nil {
-		opts.FileNameFunc = DefaultFilenameFunc
+	var lock *dirLock
+	if opts.Lock != nil {
+		var err error
+		if lock, err = acquireLock(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// The upload manifest is loaded before any side effect, such as publishing
+	// expvars or starting tees, which would have to be undone if it failed.
+	var m *manifest
+	if opts.Uploader != nil {
+		var err error
+		if m, err = loadManifest(loggerOrDiscard(logger), opts); err != nil {
+			if lock != nil {
+				lock.release()
+			}
+			return nil, err
+		}
 	}
 
+	_, nopMetrics := opts.Metrics.(NopMetrics)
+
 	w := &Writer{
-		logger:  logger,
-		opts:    opts,
-		queue:   make(chan []byte, 1024),
-		closing: make(chan struct{}),
-		done:    make(chan struct{}),
+		lock:      lock,
+		logger:    loggerOrDiscard(logger),
+		opts:      opts,
+		writeOpts: opts,
+		clock:     opts.Now,
+		framed:    opts.Framed,
+		jsonArray: opts.JSONArray,
+		metrics:   opts.Metrics,
+		timed:     !nopMetrics,
+		debug:     opts.Debug,
+		files:     files,
+		queue:     make(chan entry, opts.QueueSize),
+		priority:  make(chan entry, priorityLaneSize),
+		errs:      make(chan error, errorsBufferSize),
+		events:    make(chan Event, eventsBufferSize),
+		closing:   make(chan struct{}),
+		abort:     make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if opts.ExpvarPrefix != "" {
+		if err := w.publishExpvars(opts.ExpvarPrefix); err != nil {
+			w.releaseLock()
+			return nil, err
+		}
+	}
+
+	if opts.Syslog != nil {
+		syslog := newSyslogWriter(*opts.Syslog, opts.Now)
+		w.tees = append(w.tees, (&tee{name: "syslog", w: syslog, closer: syslog.Close}).start(w.logger, opts.QueueSize))
+	}
+	if opts.TeeTo != nil {
+		w.tees = append(w.tees, (&tee{name: "TeeTo", w: opts.TeeTo, lossless: true}).start(w.logger, opts.QueueSize))
+	}
+	if opts.Webhook != nil {
+		w.webhook = (&tee{name: "webhook", w: newWebhookWriter(*opts.Webhook)}).start(w.logger, opts.QueueSize)
+	}
+	if m != nil {
+		w.uploads = newUploads(opts, m, files, w.logger, w.report, w.emit, w.abort)
+		if err := w.resumeUploads(); err != nil {
+			w.logger.Printf("Failed to update upload manifest: %v", err)
+		}
 	}
 
 	go w.listen()
 
 	return w, nil
 }
-
-func newFile(path string) (*os.File, error) {
-	return os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0666)
-}
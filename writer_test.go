@@ -1,6 +1,9 @@
 package logrotate
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
@@ -43,6 +46,62 @@ func TestWriter(t *testing.T) {
 		require.True(t, f.IsDir(), "must create directory")
 	})
 
+	t.Run("recreates target directory if it is removed", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		dir = filepath.Join(dir, "foo")
+		w, err := New(logger, Options{
+			Directory: dir,
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(dir))
+
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close(), "must close writer")
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err, "must recreate directory")
+		require.Len(t, files, 1, "must write file into recreated directory")
+	})
+
+	t.Run("creates files and directories with modes", func(t *testing.T) {
+		for _, test := range []struct {
+			name              string
+			fileMode, dirMode os.FileMode
+			wantFile, wantDir os.FileMode
+		}{
+			{name: "defaults", wantFile: 0644, wantDir: 0755},
+			{name: "private", fileMode: 0600, dirMode: 0700, wantFile: 0600, wantDir: 0700},
+			{name: "group readable", fileMode: 0640, dirMode: 0750, wantFile: 0640, wantDir: 0750},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				dir, cleanup := setup(t)
+				defer cleanup()
+
+				dir = filepath.Join(dir, "foo")
+				w, err := New(logger, Options{
+					Directory:    dir,
+					FileMode:     test.fileMode,
+					DirMode:      test.dirMode,
+					FileNameFunc: func() string { return "app.log" },
+				})
+				require.NoError(t, err)
+				_, err = w.Write([]byte("message"))
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+
+				info, err := os.Stat(dir)
+				require.NoError(t, err)
+				require.Equal(t, test.wantDir, info.Mode().Perm())
+				info, err = os.Stat(filepath.Join(dir, "app.log"))
+				require.NoError(t, err)
+				require.Equal(t, test.wantFile, info.Mode().Perm())
+			})
+		}
+	})
+
 	t.Run("create, write, close", func(t *testing.T) {
 		dir, cleanup := setup(t)
 		defer cleanup()
@@ -66,6 +125,32 @@ func TestWriter(t *testing.T) {
 		require.Equal(t, message, written)
 	})
 
+	t.Run("does not retain written bytes", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		w, err := New(logger, Options{
+			Directory:    dir,
+			FileNameFunc: func() string { return "file.log" },
+		})
+		require.NoError(t, err)
+
+		// reuse the same buffer, like log.Logger and slog do
+		buf := []byte("aaaa")
+		for _, c := range []byte("abcd") {
+			for i := range buf {
+				buf[i] = c
+			}
+			_, err = w.Write(buf)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		written, err := ioutil.ReadFile(filepath.Join(dir, "file.log"))
+		require.NoError(t, err)
+		require.Equal(t, "aaaabbbbccccdddd", string(written))
+	})
+
 	t.Run("rotates on file size", func(t *testing.T) {
 		dir, cleanup := setup(t)
 		defer cleanup()
@@ -93,22 +178,57 @@ func TestWriter(t *testing.T) {
 		require.Len(t, files, 2, "must produce 2 files")
 	})
 
+	t.Run("reports the current and previous files", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 128,
+		})
+		require.NoError(t, err)
+		require.Empty(t, w.CurrentFilename(), "must not report a file before the first write")
+
+		_, err = w.Write([]byte(strings.Repeat("a", 128)))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("b"))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+
+		current, previous := w.CurrentFilename(), w.PreviousFilename()
+		require.NotEmpty(t, current)
+		require.NotEmpty(t, previous)
+		require.NotEqual(t, current, previous)
+
+		require.NoError(t, w.Reopen())
+		require.Equal(t, current, w.CurrentFilename())
+		require.Equal(t, previous, w.PreviousFilename(), "must not record a reopened file as previous")
+
+		require.NoError(t, w.Close())
+		require.Empty(t, w.CurrentFilename(), "must not report a file once closed")
+		require.Equal(t, current, w.PreviousFilename())
+	})
+
 	t.Run("rotates on lifetime", func(t *testing.T) {
 		dir, cleanup := setup(t)
 		defer cleanup()
 
+		clock := newFakeClock()
 		lifetime := time.Second
 		w, err := New(logger, Options{
 			Directory:       dir,
 			MaximumLifetime: lifetime,
+			Now:             clock.Now,
+			NewTicker:       clock.NewTicker,
 		})
 		require.NoError(t, err)
 
 		// keep writing until lifetime + half of lifetime (middle of ticks) elapses
-		end := time.Now().Add(lifetime + lifetime/2)
-		for time.Now().Before(end) {
+		for i := 0; i < 4; i++ {
 			_, err = w.Write([]byte("message"))
 			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			clock.Advance(lifetime / 2)
 		}
 
 		require.NoError(t, w.Close())
@@ -117,6 +237,182 @@ func TestWriter(t *testing.T) {
 		require.Len(t, files, 2, "should produce 2 files")
 	})
 
+	t.Run("reports background write failures", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		w, err := New(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				return filepath.Join("missing", "file.log")
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, w.LastError(), "must not report an error before writing")
+
+		_, err = w.Write([]byte("message"))
+		require.NoError(t, err, "write must be accepted")
+
+		require.Error(t, w.Close(), "close must report the failed write")
+		require.Error(t, w.LastError())
+	})
+
+	t.Run("retries failed file operations", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		attempts := 0
+		w, err := New(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				attempts++
+				if attempts < 3 {
+					return filepath.Join("missing", "file.log")
+				}
+				return "file.log"
+			},
+			Retry: RetryPolicy{
+				MaximumAttempts: 3,
+				InitialBackoff:  time.Millisecond,
+			},
+		})
+		require.NoError(t, err)
+
+		message := []byte("message")
+		_, err = w.Write(message)
+		require.NoError(t, err)
+		require.NoError(t, w.Close(), "must succeed after retrying")
+
+		written, err := ioutil.ReadFile(filepath.Join(dir, "file.log"))
+		require.NoError(t, err)
+		require.Equal(t, message, written)
+	})
+
+	t.Run("writes to fallback when file cannot be written", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		var fallback bytes.Buffer
+		w, err := New(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				return filepath.Join("missing", "file.log")
+			},
+			Fallback: &fallback,
+		})
+		require.NoError(t, err)
+
+		message := []byte("message")
+		_, err = w.Write(message)
+		require.NoError(t, err)
+		require.Error(t, w.Close())
+
+		require.Equal(t, message, fallback.Bytes(), "must write entry to fallback")
+	})
+
+	t.Run("close context abandons pending writes", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		unblock := make(chan struct{})
+		w, err := New(logger, Options{
+			Directory: dir,
+			FileNameFunc: func() string {
+				return filepath.Join("missing", "file.log")
+			},
+			Fallback: blockingWriter(unblock),
+		})
+		require.NoError(t, err)
+		defer close(unblock)
+
+		for i := 0; i < 3; i++ {
+			_, err = w.Write([]byte("message"))
+			require.NoError(t, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err = w.CloseContext(ctx)
+		require.Error(t, err, "must abandon drain")
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.Contains(t, err.Error(), "entries discarded")
+	})
+
+	t.Run("recovers from panics and reopens file", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		files := 0
+		w, err := New(logger, Options{
+			Directory:       dir,
+			MaximumFileSize: 8,
+			FileNameFunc: func() string {
+				files++
+				if files == 2 {
+					panic("filename")
+				}
+				return fmt.Sprintf("%d.log", files)
+			},
+		})
+		require.NoError(t, err)
+
+		for _, message := range []string{"first", "second", "third"} {
+			_, err = w.Write([]byte(message))
+			require.NoError(t, err)
+		}
+		require.Error(t, w.Close(), "must report the panic")
+
+		first, err := ioutil.ReadFile(filepath.Join(dir, "1.log"))
+		require.NoError(t, err)
+		require.Equal(t, "first", string(first))
+
+		third, err := ioutil.ReadFile(filepath.Join(dir, "3.log"))
+		require.NoError(t, err)
+		require.Equal(t, "third", string(third), "must continue writing after a panic")
+	})
+
+	t.Run("close is idempotent", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		w, err := New(logger, Options{
+			Directory: dir,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, w.Close())
+		require.NoError(t, w.Close(), "must not fail when closed twice")
+	})
+
+	t.Run("concurrent writes and close", func(t *testing.T) {
+		dir, cleanup := setup(t)
+		defer cleanup()
+
+		w, err := New(logger, Options{
+			Directory: dir,
+		})
+		require.NoError(t, err)
+
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			go func() {
+				for {
+					if _, err := w.Write([]byte("message")); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, w.Close())
+		for i := 0; i < 10; i++ {
+			require.True(t, errors.Is(<-errs, ErrClosed), "must reject writes with ErrClosed")
+		}
+	})
+
 	t.Run("concurrent writes", func(t *testing.T) {
 		dir, cleanup := setup(t)
 		defer cleanup()
@@ -151,6 +447,14 @@ func TestWriter(t *testing.T) {
 	})
 }
 
+// blockingWriter blocks every write until unblock is closed.
+type blockingWriter chan struct{}
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	<-b
+	return len(p), nil
+}
+
 func benchmarkWriter(b *testing.B, messages int, messageSize int, writers int) {
 	logger := log.New(os.Stderr, "", log.LstdFlags)
 
@@ -0,0 +1,19 @@
+module github.com/easyCZ/logrotate/zerologrotate
+
+go 1.21
+
+require (
+	github.com/easyCZ/logrotate v1.0.0
+	github.com/rs/zerolog v1.33.0
+	github.com/stretchr/testify v1.4.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
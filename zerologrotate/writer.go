@@ -0,0 +1,49 @@
+// Package zerologrotate provides a zerolog.LevelWriter backed by logrotate Writers.
+//
+// zerolog writes each event as a single JSON line with one call to Write,
+// logrotate writes every call to Write as a single entry which is never
+// interleaved with concurrent writes nor split across files, so events
+// remain intact under concurrency and across rotations.
+//
+//	w, _ := logrotate.New(nil, logrotate.Options{Directory: "/var/log/app"})
+//	logger := zerolog.New(zerologrotate.NewLevelWriter(w, nil))
+package zerologrotate
+
+import (
+	"github.com/easyCZ/logrotate"
+	"github.com/rs/zerolog"
+)
+
+// LevelWriter is a zerolog.LevelWriter writing events into logrotate Writers,
+// optionally routing events of particular levels into dedicated Writers.
+type LevelWriter struct {
+	// Default receives events of levels without a dedicated Writer,
+	// as well as events written without a level.
+	Default *logrotate.Writer
+	// Levels maps levels to dedicated Writers.
+	Levels map[zerolog.Level]*logrotate.Writer
+}
+
+// NewLevelWriter returns a LevelWriter writing events into w,
+// except for events of levels with a dedicated Writer in levels.
+func NewLevelWriter(w *logrotate.Writer, levels map[zerolog.Level]*logrotate.Writer) *LevelWriter {
+	return &LevelWriter{
+		Default: w,
+		Levels:  levels,
+	}
+}
+
+// Write writes an event without a level into the Default Writer.
+func (lw *LevelWriter) Write(p []byte) (int, error) {
+	return lw.Default.Write(p)
+}
+
+// WriteLevel writes an event into the Writer for level.
+func (lw *LevelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if w, ok := lw.Levels[level]; ok {
+		return w.Write(p)
+	}
+	return lw.Default.Write(p)
+}
+
+var _ zerolog.LevelWriter = (*LevelWriter)(nil)
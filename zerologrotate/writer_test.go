@@ -0,0 +1,82 @@
+package zerologrotate
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/easyCZ/logrotate"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLevelWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newWriter := func(name string, maxSize int64) *logrotate.Writer {
+		w, err := logrotate.New(nil, logrotate.Options{
+			Directory:       filepath.Join(dir, name),
+			MaximumFileSize: maxSize,
+		})
+		require.NoError(t, err)
+		return w
+	}
+	all, errs := newWriter("all", 4096), newWriter("errors", 0)
+
+	logger := zerolog.New(NewLevelWriter(all, map[zerolog.Level]*logrotate.Writer{
+		zerolog.ErrorLevel: errs,
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info().Int("writer", i).Str("payload", strings.Repeat("x", i*10)).Msg("message")
+			}
+		}(i)
+	}
+	wg.Wait()
+	logger.Error().Msg("failed")
+
+	require.NoError(t, all.Close())
+	require.NoError(t, errs.Close())
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "all"))
+	require.NoError(t, err)
+	require.True(t, len(files) > 1, "must rotate")
+
+	lines := 0
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, "all", file.Name()))
+		require.NoError(t, err)
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var event struct {
+				Writer  int
+				Payload string
+			}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "events must not be split or interleaved")
+			require.Equal(t, strings.Repeat("x", event.Writer*10), event.Payload)
+			lines++
+		}
+		require.NoError(t, scanner.Err())
+		require.NoError(t, f.Close())
+	}
+	require.Equal(t, 1000, lines)
+
+	files, err = ioutil.ReadDir(filepath.Join(dir, "errors"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	errorsLog, err := ioutil.ReadFile(filepath.Join(dir, "errors", files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(errorsLog), `"message":"failed"`)
+}